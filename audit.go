@@ -0,0 +1,74 @@
+package accord
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// the types of the audit events
+const (
+	AuditHostAuth           = "host_auth"
+	AuditHostCert           = "host_cert"
+	AuditUserAuth           = "user_auth"
+	AuditUserCert           = "user_cert"
+	AuditUserCertDenied     = "user_cert_denied"
+	AuditAuthz              = "authz"
+	AuditReload             = "reload"
+	AuditReloadFailed       = "reload_failed"
+	AuditElevationRequested = "elevation_requested"
+	AuditElevationApproved  = "elevation_approved"
+	AuditElevationDenied    = "elevation_denied"
+	AuditElevationExpired   = "elevation_expired"
+	// from the admin socket
+	AuditRevoke               = "revoke"
+	AuditUserDisabled         = "user_disabled"
+	AuditUserEnabled          = "user_enabled"
+	AuditDeploymentRegistered = "deployment_registered"
+	AuditPSKRotated           = "psk_rotated"
+	AuditPSKRetired           = "psk_retired"
+	AuditKeyIDMigrated        = "keyid_migrated"
+	AuditLegacyKeyIDRetired   = "legacy_keyid_retired"
+)
+
+// AuditEvent is something security relevant the operators need a record of,
+// the certs it signs are also in the ledger
+type AuditEvent struct {
+	// unique for each event so the sinks that can send it twice can be deduped
+	Id   string    `json:"id,omitempty"`
+	Time time.Time `json:"time"`
+	Type string    `json:"type"`
+	// who made the change, the server itself for the ones it does on its own
+	Actor string `json:"actor"`
+	// the gRPC peer and the request the event came from, empty for the ones
+	// the server does on its own
+	Peer      string `json:"peer,omitempty"`
+	RequestId string `json:"request_id,omitempty"`
+	// of the cert that was signed
+	Serial uint64 `json:"serial,omitempty"`
+	// why it failed or was denied, empty when it went through
+	Error   string            `json:"error,omitempty"`
+	Details map[string]string `json:"details,omitempty"`
+}
+
+// Failed is whether the event is for something that was denied or failed
+func (e *AuditEvent) Failed() bool {
+	return e.Error != "" || e.Type == AuditUserCertDenied || e.Type == AuditReloadFailed || e.Type == AuditElevationDenied
+}
+
+// Auditor records the audit events somewhere they can't be lost
+type Auditor interface {
+	Audit(event *AuditEvent) error
+}
+
+// LogAuditor writes the events to the log as JSON
+type LogAuditor struct{}
+
+func (l LogAuditor) Audit(event *AuditEvent) error {
+	b, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	log.Printf("audit: %s", b)
+	return nil
+}
@@ -0,0 +1,112 @@
+// Package audit has the sinks the audit events can be sent to, on top of the
+// server log
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/mistsys/accord"
+	"github.com/pkg/errors"
+)
+
+// File writes the events as JSON lines, when the file gets bigger than
+// maxSize it's moved to path.1, path.1 to path.2 and so on up to maxBackups
+type File struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+func NewFile(path string, maxSize int64, maxBackups int) (*File, error) {
+	f := &File{
+		path:       path,
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *File) open() error {
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return errors.Wrapf(err, "Cannot open the audit file %s", f.path)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return errors.Wrapf(err, "Cannot stat the audit file %s", f.path)
+	}
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+func (f *File) backup(n int) string {
+	return fmt.Sprintf("%s.%d", f.path, n)
+}
+
+// rotate needs the lock held. The current file is only closed once the new
+// one is open, when the rotation fails the events keep going to it
+func (f *File) rotate() error {
+	current := f.file
+	if f.maxBackups > 0 {
+		os.Remove(f.backup(f.maxBackups))
+		for n := f.maxBackups - 1; n > 0; n-- {
+			os.Rename(f.backup(n), f.backup(n+1))
+		}
+		if err := os.Rename(f.path, f.backup(1)); err != nil {
+			return errors.Wrapf(err, "Failed to rotate the audit file %s", f.path)
+		}
+	} else if err := os.Remove(f.path); err != nil {
+		return errors.Wrapf(err, "Failed to rotate the audit file %s", f.path)
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	// every event was synced, there's nothing to lose closing it
+	current.Close()
+	return nil
+}
+
+// Audit still writes the event when the file can't be rotated, and returns
+// why it couldn't
+func (f *File) Audit(event *accord.AuditEvent) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var rotateErr error
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(line)) > f.maxSize {
+		rotateErr = f.rotate()
+	}
+	n, err := f.file.Write(line)
+	f.size += int64(n)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to write to the audit file %s", f.path)
+	}
+	// the events are few and matter more than the throughput
+	if err := f.file.Sync(); err != nil {
+		return err
+	}
+	return rotateErr
+}
+
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.file.Close()
+}
@@ -0,0 +1,124 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mistsys/accord"
+)
+
+func readEvents(t *testing.T, path string) []*accord.AuditEvent {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Cannot open %s. %s", path, err)
+	}
+	defer f.Close()
+	events := []*accord.AuditEvent{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		event := &accord.AuditEvent{}
+		if err := json.Unmarshal(scanner.Bytes(), event); err != nil {
+			t.Fatalf("Invalid line in %s. %s", path, err)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestFile_rotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.jsonl")
+
+	event := &accord.AuditEvent{Time: time.Now(), Type: accord.AuditUserCert, Actor: "user1@ex.ample.com", Serial: 1}
+	line, _ := json.Marshal(event)
+	// two events to a file
+	f, err := NewFile(path, int64(2*(len(line)+1)), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for serial := uint64(1); serial <= 7; serial++ {
+		event.Serial = serial
+		if err := f.Audit(event); err != nil {
+			t.Fatalf("Audit() error = %v", err)
+		}
+	}
+	f.Close()
+
+	tests := []struct {
+		path    string
+		serials []uint64
+	}{
+		{path: path, serials: []uint64{7}},
+		{path: path + ".1", serials: []uint64{5, 6}},
+		{path: path + ".2", serials: []uint64{3, 4}},
+	}
+	for _, tt := range tests {
+		events := readEvents(t, tt.path)
+		if len(events) != len(tt.serials) {
+			t.Errorf("%s has %d events, want %d", tt.path, len(events), len(tt.serials))
+			continue
+		}
+		for i, event := range events {
+			if event.Serial != tt.serials[i] {
+				t.Errorf("%s event %d has serial %d, want %d", tt.path, i, event.Serial, tt.serials[i])
+			}
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("Only 2 backups should be kept")
+	}
+}
+
+func TestFile_failedRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.jsonl")
+
+	event := &accord.AuditEvent{Time: time.Now(), Type: accord.AuditUserCert, Actor: "user1@ex.ample.com", Serial: 1}
+	line, _ := json.Marshal(event)
+	f, err := NewFile(path, int64(len(line)+1), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	// the backup can't be replaced by the file
+	if err := os.MkdirAll(filepath.Join(path+".1", "taken"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	for serial := uint64(1); serial <= 3; serial++ {
+		event.Serial = serial
+		err := f.Audit(event)
+		if (err != nil) != (serial > 1) {
+			t.Errorf("Audit() error = %v for serial %d", err, serial)
+		}
+	}
+	if events := readEvents(t, path); len(events) != 3 {
+		t.Errorf("%s has %d events, want all 3 while it can't be rotated", path, len(events))
+	}
+
+	if err := os.RemoveAll(path + ".1"); err != nil {
+		t.Fatal(err)
+	}
+	event.Serial = 4
+	if err := f.Audit(event); err != nil {
+		t.Fatalf("Audit() error = %v once the file can be rotated", err)
+	}
+	if events := readEvents(t, path); len(events) != 1 || events[0].Serial != 4 {
+		t.Errorf("%s has %v, want only serial 4", path, events)
+	}
+	if events := readEvents(t, path+".1"); len(events) != 3 {
+		t.Errorf("%s.1 has %d events, want 3", path, len(events))
+	}
+}
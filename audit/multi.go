@@ -0,0 +1,28 @@
+package audit
+
+import (
+	"strings"
+
+	"github.com/mistsys/accord"
+	"github.com/pkg/errors"
+)
+
+// Multi sends the events to all of the auditors with the same id, one that
+// fails doesn't keep the others from getting it
+type Multi []accord.Auditor
+
+func (m Multi) Audit(event *accord.AuditEvent) error {
+	if event.Id == "" {
+		event.Id = string(accord.RandAsciiBytes(16))
+	}
+	failed := []string{}
+	for _, auditor := range m {
+		if err := auditor.Audit(event); err != nil {
+			failed = append(failed, err.Error())
+		}
+	}
+	if len(failed) > 0 {
+		return errors.New(strings.Join(failed, "; "))
+	}
+	return nil
+}
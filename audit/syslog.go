@@ -0,0 +1,227 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/mistsys/accord"
+	"github.com/pkg/errors"
+)
+
+const (
+	// LOG_AUTHPRIV, the audit events are about who gets access
+	facilityAuthPriv = 10
+	severityWarning  = 4
+	severityNotice   = 5
+
+	appName = "accord"
+	// 32473 is the enterprise number for examples in RFC 5612, there's no
+	// registered one for accord
+	sdId = "accord@32473"
+
+	// DefaultSyslogQueue is how many events can wait to be sent to syslog,
+	// the ones past it are dropped rather than holding up the RPCs
+	DefaultSyslogQueue = 1000
+)
+
+// Syslog sends the events as RFC 5424 messages, the fields are structured
+// data and the message is the whole event as JSON. Over TCP the messages are
+// framed with their length as in RFC 6587. The events are sent in the
+// background, when syslog is down they're queued up to DefaultSyslogQueue
+// and dropped after that
+type Syslog struct {
+	network  string
+	address  string
+	hostname string
+
+	queue   chan []byte
+	dropped uint64
+	done    chan struct{}
+	stopped chan struct{}
+	// only used by run
+	conn net.Conn
+}
+
+// NewSyslog takes the address as udp://host:port, tcp://host:port or
+// unix:///dev/log
+func NewSyslog(address string) (*Syslog, error) {
+	return newSyslog(address, DefaultSyslogQueue)
+}
+
+func newSyslog(address string, queue int) (*Syslog, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Invalid syslog address %s", address)
+	}
+	s := &Syslog{network: u.Scheme, address: u.Host}
+	switch u.Scheme {
+	case "udp", "tcp":
+	case "unix":
+		// /dev/log is a datagram socket on most systems
+		s.network = "unixgram"
+		s.address = u.Path
+	default:
+		return nil, fmt.Errorf("Unknown syslog network %s, it's udp, tcp or unix", u.Scheme)
+	}
+	s.hostname, _ = os.Hostname()
+	if s.hostname == "" {
+		s.hostname = "-"
+	}
+	s.queue = make(chan []byte, queue)
+	s.done = make(chan struct{})
+	s.stopped = make(chan struct{})
+	go s.run()
+	return s, nil
+}
+
+// sdEscape escapes a structured data param value, see RFC 5424 6.3.3
+func sdEscape(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(value)
+}
+
+// sdName keeps to what's allowed in a param name, printable without = ] " or space
+func sdName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' || r == '=' || r == ']' || r == '"' {
+			return '_'
+		}
+		return r
+	}, name)
+	if len(name) > 32 {
+		name = name[:32]
+	}
+	return name
+}
+
+func format(event *accord.AuditEvent, hostname string, pid int) ([]byte, error) {
+	message, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	severity := severityNotice
+	if event.Failed() {
+		severity = severityWarning
+	}
+	params := map[string]string{
+		"type":       event.Type,
+		"actor":      event.Actor,
+		"peer":       event.Peer,
+		"request_id": event.RequestId,
+		"error":      event.Error,
+	}
+	if event.Id != "" {
+		params["id"] = event.Id
+	}
+	if event.Serial != 0 {
+		params["serial"] = fmt.Sprintf("%d", event.Serial)
+	}
+	names := []string{}
+	for name, value := range params {
+		if value != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	b := &bytes.Buffer{}
+	fmt.Fprintf(b, "<%d>1 %s %s %s %d %s [%s", facilityAuthPriv*8+severity,
+		event.Time.UTC().Format(time.RFC3339Nano), hostname, appName, pid, sdName(event.Type), sdId)
+	for _, name := range names {
+		fmt.Fprintf(b, ` %s="%s"`, sdName(name), sdEscape(params[name]))
+	}
+	b.WriteString("] ")
+	b.Write(message)
+	return b.Bytes(), nil
+}
+
+func (s *Syslog) send(message []byte) error {
+	if s.conn == nil {
+		conn, err := net.DialTimeout(s.network, s.address, 10*time.Second)
+		if err != nil {
+			return errors.Wrapf(err, "Cannot connect to syslog at %s", s.address)
+		}
+		s.conn = conn
+	}
+	if s.network == "tcp" {
+		message = append([]byte(fmt.Sprintf("%d ", len(message))), message...)
+	}
+	s.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	_, err := s.conn.Write(message)
+	if err != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+	return err
+}
+
+// sendRetry tries once more with a new connection, the other end may have
+// closed it since the last event
+func (s *Syslog) sendRetry(message []byte) error {
+	if err := s.send(message); err != nil {
+		if err := s.send(message); err != nil {
+			return errors.Wrapf(err, "Failed to send the audit event to syslog")
+		}
+	}
+	return nil
+}
+
+func (s *Syslog) run() {
+	defer close(s.stopped)
+	for {
+		select {
+		case message := <-s.queue:
+			if err := s.sendRetry(message); err != nil {
+				log.Println(err)
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// Audit only queues the event, it's an error when the queue is full and
+// the event is dropped
+func (s *Syslog) Audit(event *accord.AuditEvent) error {
+	message, err := format(event, s.hostname, os.Getpid())
+	if err != nil {
+		return err
+	}
+	select {
+	case s.queue <- message:
+		return nil
+	default:
+		dropped := atomic.AddUint64(&s.dropped, 1)
+		return fmt.Errorf("The syslog queue is full, dropped %s, %d dropped so far", event.Type, dropped)
+	}
+}
+
+// Dropped is how many events didn't fit in the queue
+func (s *Syslog) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// Close sends what's still queued, until syslog fails to take one
+func (s *Syslog) Close() error {
+	close(s.done)
+	<-s.stopped
+	for len(s.queue) > 0 {
+		if err := s.send(<-s.queue); err != nil {
+			return errors.Wrapf(err, "Failed to send the queued audit events to syslog")
+		}
+	}
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
@@ -0,0 +1,108 @@
+package audit
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mistsys/accord"
+)
+
+func TestFormat(t *testing.T) {
+	when := time.Date(2017, 10, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		event *accord.AuditEvent
+		want  string
+	}{
+		{
+			name: "notice",
+			event: &accord.AuditEvent{
+				Time:      when,
+				Type:      accord.AuditUserCert,
+				Actor:     "user1@ex.ample.com",
+				Peer:      "10.0.0.1:5000",
+				RequestId: "abc",
+				Serial:    42,
+			},
+			want: `<85>1 2017-10-01T12:00:00Z db1 accord 7 user_cert [accord@32473 actor="user1@ex.ample.com" peer="10.0.0.1:5000" request_id="abc" serial="42" type="user_cert"] {`,
+		},
+		{
+			name: "failures are warnings and escaped",
+			event: &accord.AuditEvent{
+				Time:  when,
+				Type:  accord.AuditUserCertDenied,
+				Actor: "user1@ex.ample.com",
+				Error: `Principal "root]" is unknown \o/`,
+			},
+			want: `<84>1 2017-10-01T12:00:00Z db1 accord 7 user_cert_denied [accord@32473 actor="user1@ex.ample.com" error="Principal \"root\]\" is unknown \\o/" type="user_cert_denied"] {`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := format(tt.event, "db1", 7)
+			if err != nil {
+				t.Fatalf("format() error = %v", err)
+			}
+			if !strings.HasPrefix(string(got), tt.want) {
+				t.Errorf("format() = %s, want it to start with %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSyslog_tcp(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+	received := make(chan string, 1)
+	go func() {
+		conn, err := lis.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		buf := make([]byte, 4096)
+		n, _ := conn.Read(buf)
+		received <- string(buf[:n])
+	}()
+
+	s, err := NewSyslog("tcp://" + lis.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := s.Audit(&accord.AuditEvent{Time: time.Now(), Type: accord.AuditReload, Actor: "SIGHUP"}); err != nil {
+		t.Fatalf("Audit() error = %v", err)
+	}
+	select {
+	case msg := <-received:
+		// octet counted, the length and then the message
+		parts := strings.SplitN(msg, " ", 2)
+		if len(parts) != 2 || !strings.HasPrefix(parts[1], "<85>1 ") {
+			t.Errorf("syslog got %q", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("syslog didn't get the event")
+	}
+}
+
+func TestSyslog_queueFull(t *testing.T) {
+	// nothing takes the events off the queue
+	s := &Syslog{hostname: "db1", queue: make(chan []byte, 2)}
+	event := &accord.AuditEvent{Time: time.Now(), Type: accord.AuditUserAuth, Actor: "user1@ex.ample.com"}
+	for i := 0; i < 2; i++ {
+		if err := s.Audit(event); err != nil {
+			t.Fatalf("Audit() error = %v with room in the queue", err)
+		}
+	}
+	if err := s.Audit(event); err == nil {
+		t.Errorf("Audit() error = nil with the queue full")
+	}
+	if got := s.Dropped(); got != 1 {
+		t.Errorf("Dropped() = %d, want 1", got)
+	}
+}
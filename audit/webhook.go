@@ -0,0 +1,285 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mistsys/accord"
+	"github.com/pkg/errors"
+)
+
+const (
+	spoolName = "spool.jsonl"
+	// the spool is moved to a sending file before it's sent, so new events
+	// can go on being added while it's retried
+	sendingPattern = "sending-*.jsonl"
+	// the batches the webhook won't ever take, kept for someone to look at
+	deadLetterName = "dead-letter.jsonl"
+
+	DefaultWebhookBatch = 100
+)
+
+// Webhook posts the events to a URL as JSON arrays. The events are written
+// to a spool in dir first, and only removed from there once the webhook
+// took them, so they're kept while it's down and across restarts. Failed
+// posts are retried with exponential backoff. An event can be sent twice if
+// the server stops at the wrong time, the ids tell them apart. The batches it
+// rejects with a 4xx other than 408 or 429 won't be taken on a retry either,
+// they're moved to dead-letter.jsonl so they don't hold up the rest
+type Webhook struct {
+	url        string
+	dir        string
+	client     *http.Client
+	batch      int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+
+	mu    sync.Mutex
+	spool *os.File
+	// how many lines of each sending file the webhook already took
+	progress map[string]int
+
+	wake    chan struct{}
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+func NewWebhook(url string, dir string) (*Webhook, error) {
+	return newWebhook(url, dir, time.Second)
+}
+
+func newWebhook(url string, dir string, minBackoff time.Duration) (*Webhook, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, errors.Wrapf(err, "Cannot create the webhook spool %s", dir)
+	}
+	w := &Webhook{
+		url:        url,
+		dir:        dir,
+		client:     &http.Client{Timeout: 30 * time.Second},
+		batch:      DefaultWebhookBatch,
+		minBackoff: minBackoff,
+		maxBackoff: 5 * time.Minute,
+		progress:   map[string]int{},
+		wake:       make(chan struct{}, 1),
+		done:       make(chan struct{}),
+		stopped:    make(chan struct{}),
+	}
+	if err := w.openSpool(); err != nil {
+		return nil, err
+	}
+	go w.run()
+	return w, nil
+}
+
+func (w *Webhook) openSpool() error {
+	spool, err := os.OpenFile(filepath.Join(w.dir, spoolName), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return errors.Wrapf(err, "Cannot open the webhook spool in %s", w.dir)
+	}
+	w.spool = spool
+	return nil
+}
+
+// Audit only writes the event to the spool, it's sent in the background
+func (w *Webhook) Audit(event *accord.AuditEvent) error {
+	if event.Id == "" {
+		event.Id = string(accord.RandAsciiBytes(16))
+	}
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	w.mu.Lock()
+	_, err = w.spool.Write(line)
+	if err == nil {
+		err = w.spool.Sync()
+	}
+	w.mu.Unlock()
+	if err != nil {
+		return errors.Wrapf(err, "Failed to write to the webhook spool in %s", w.dir)
+	}
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// takeSpool moves the spool to a sending file if there's anything in it
+func (w *Webhook) takeSpool() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	info, err := w.spool.Stat()
+	if err != nil || info.Size() == 0 {
+		return err
+	}
+	if err := w.spool.Close(); err != nil {
+		return err
+	}
+	sending := filepath.Join(w.dir, fmt.Sprintf("sending-%020d.jsonl", time.Now().UnixNano()))
+	if err := os.Rename(filepath.Join(w.dir, spoolName), sending); err != nil {
+		// keep appending to it, it's tried again with the next event
+		w.openSpool()
+		return err
+	}
+	return w.openSpool()
+}
+
+// rejectedError is a response that won't change when the batch is retried
+type rejectedError struct {
+	status string
+}
+
+func (e rejectedError) Error() string {
+	return fmt.Sprintf("The webhook rejected the events with %s", e.status)
+}
+
+// post sends one batch, anything but a 2xx is a failure
+func (w *Webhook) post(lines [][]byte) error {
+	body := &bytes.Buffer{}
+	body.WriteByte('[')
+	body.Write(bytes.Join(lines, []byte(",")))
+	body.WriteByte(']')
+	resp, err := w.client.Post(w.url, "application/json", body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode >= 400 && resp.StatusCode < 500 &&
+		resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests:
+		return rejectedError{status: resp.Status}
+	}
+	return fmt.Errorf("The webhook responded with %s", resp.Status)
+}
+
+func (w *Webhook) deadLetter(lines [][]byte) error {
+	f, err := os.OpenFile(filepath.Join(w.dir, deadLetterName), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	content := append(bytes.Join(lines, []byte("\n")), '\n')
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (w *Webhook) sendFile(path string) error {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	lines := [][]byte{}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 64*1024), len(content)+1)
+	for scanner.Scan() {
+		if line := bytes.TrimSpace(scanner.Bytes()); len(line) > 0 {
+			lines = append(lines, append([]byte{}, line...))
+		}
+	}
+	for sent := w.progress[path]; sent < len(lines); sent = w.progress[path] {
+		end := sent + w.batch
+		if end > len(lines) {
+			end = len(lines)
+		}
+		err := w.post(lines[sent:end])
+		if rejected, ok := err.(rejectedError); ok {
+			log.Printf("Moving %d audit events to the webhook dead letters. %s", end-sent, rejected)
+			err = w.deadLetter(lines[sent:end])
+		}
+		if err != nil {
+			return err
+		}
+		w.progress[path] = end
+	}
+	delete(w.progress, path)
+	return os.Remove(path)
+}
+
+// send goes through the sending files oldest first, it stops at the first
+// one the webhook doesn't take so the events stay in order
+func (w *Webhook) send() error {
+	if err := w.takeSpool(); err != nil {
+		return errors.Wrapf(err, "Failed to move the webhook spool")
+	}
+	files, err := filepath.Glob(filepath.Join(w.dir, sendingPattern))
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+	for _, f := range files {
+		if err := w.sendFile(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *Webhook) run() {
+	defer close(w.stopped)
+	backoff := w.minBackoff
+	for {
+		err := w.send()
+		// nothing should be left in the spool, this is just in case
+		wait := time.Minute
+		if err != nil {
+			log.Printf("Failed to send the audit events to the webhook, retrying in %s. %s", backoff, err)
+			wait = backoff
+			backoff *= 2
+			if backoff > w.maxBackoff {
+				backoff = w.maxBackoff
+			}
+		} else {
+			backoff = w.minBackoff
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-w.done:
+			timer.Stop()
+			return
+		case <-w.wake:
+			if err == nil {
+				timer.Stop()
+				continue
+			}
+			// a new event doesn't cut the backoff short
+			select {
+			case <-w.done:
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+		case <-timer.C:
+		}
+	}
+}
+
+// Close stops sending, what's left in the spool is sent after a restart
+func (w *Webhook) Close() error {
+	close(w.done)
+	<-w.stopped
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.spool.Close()
+}
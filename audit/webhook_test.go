@@ -0,0 +1,141 @@
+package audit
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mistsys/accord"
+)
+
+func TestWebhook(t *testing.T) {
+	dir, err := ioutil.TempDir("", "webhook")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		mu       sync.Mutex
+		failures = 2
+		received = []*accord.AuditEvent{}
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if failures > 0 {
+			failures--
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		events := []*accord.AuditEvent{}
+		if err := json.NewDecoder(r.Body).Decode(&events); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		received = append(received, events...)
+	}))
+	defer server.Close()
+
+	// the events written while it was stopped are sent after the restart
+	w, err := newWebhook("http://127.0.0.1:1/unreachable", dir, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Audit(&accord.AuditEvent{Time: time.Now(), Type: accord.AuditUserAuth, Actor: "user1@ex.ample.com"})
+	w.Close()
+
+	w, err = newWebhook(server.URL, dir, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	w.Audit(&accord.AuditEvent{Time: time.Now(), Type: accord.AuditUserCert, Actor: "user1@ex.ample.com", Serial: 2})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		mu.Lock()
+		n := len(received)
+		mu.Unlock()
+		if n >= 2 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 2 {
+		t.Fatalf("webhook got %d events, want 2", len(received))
+	}
+	if received[0].Type != accord.AuditUserAuth || received[1].Serial != 2 {
+		t.Errorf("webhook got %v then %v, want them in order", received[0], received[1])
+	}
+	if received[0].Id == "" || received[0].Id == received[1].Id {
+		t.Errorf("the events need distinct ids, got %q and %q", received[0].Id, received[1].Id)
+	}
+}
+
+func TestWebhook_deadLetter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "webhook")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		mu       sync.Mutex
+		received = []*accord.AuditEvent{}
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		events := []*accord.AuditEvent{}
+		if err := json.NewDecoder(r.Body).Decode(&events); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// it'll never take this one
+		if events[0].Serial == 1 {
+			http.Error(w, "bad event", http.StatusUnprocessableEntity)
+			return
+		}
+		received = append(received, events...)
+	}))
+	defer server.Close()
+
+	w, err := newWebhook(server.URL, dir, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	w.batch = 1
+	for serial := uint64(1); serial <= 2; serial++ {
+		w.Audit(&accord.AuditEvent{Time: time.Now(), Type: accord.AuditUserCert, Actor: "user1@ex.ample.com", Serial: serial})
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		mu.Lock()
+		n := len(received)
+		mu.Unlock()
+		if n >= 1 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	// the rejected batch doesn't hold up the next one, without a retry
+	if len(received) != 1 || received[0].Serial != 2 {
+		t.Fatalf("webhook got %v, want only serial 2", received)
+	}
+	if dead := readEvents(t, filepath.Join(dir, deadLetterName)); len(dead) != 1 || dead[0].Serial != 1 {
+		t.Errorf("dead letters = %v, want serial 1", dead)
+	}
+}
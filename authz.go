@@ -1,9 +1,10 @@
 package accord
 
 import (
-	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"sort"
+	"strings"
 
 	"github.com/pkg/errors"
 )
@@ -14,9 +15,77 @@ import (
 // should have access to. If there is no overlap with the existing principals
 // the result is empty and with an error for more details for the service to
 // log for administrators or to send to the users
+// Permissions returns what a cert for the user with the given principals
+// can be used for, the client can only ask for less than this
 // Any other authorization backends can be added by implementing this interface
 type Authz interface {
 	Authorized(user string, principals []string) ([]string, error)
+	Permissions(user string, principals []string) (CertPermissions, error)
+}
+
+var (
+	ErrNoPrincipals        = errors.New("No principals requested, a cert without principals is valid for every user")
+	ErrNoPrincipalsGranted = errors.New("None of the requested principals were granted")
+)
+
+// Denial is a principal the user asked for and didn't get, and why
+type Denial struct {
+	Principal string
+	Reason    string
+}
+
+// DenialReasoner is implemented by the Authz that can tell the user why a
+// principal wasn't granted
+type DenialReasoner interface {
+	DenialReason(user string, principal string) string
+}
+
+// AdminChecker is implemented by the Authz that have admins
+type AdminChecker interface {
+	IsAdmin(user string) bool
+}
+
+// IsAdmin is false for the Authz without admins
+func IsAdmin(authz Authz, user string) bool {
+	checker, ok := authz.(AdminChecker)
+	return ok && checker.IsAdmin(user)
+}
+
+// Grant asks the authz for the principals and splits them into the granted
+// and denied ones. Nothing granted is an error, OpenSSH takes a cert without
+// principals as valid for any user so those are never signed
+func Grant(authz Authz, user string, principals []string) ([]string, []Denial, error) {
+	if len(principals) == 0 {
+		return nil, nil, ErrNoPrincipals
+	}
+	granted, err := authz.Authorized(user, principals)
+	if err != nil {
+		return nil, nil, err
+	}
+	denied := []Denial{}
+	for _, p := range principals {
+		if contains(p, granted) {
+			continue
+		}
+		reason := "not granted"
+		if reasoner, ok := authz.(DenialReasoner); ok {
+			reason = reasoner.DenialReason(user, p)
+		}
+		denied = append(denied, Denial{Principal: p, Reason: reason})
+	}
+	if len(granted) == 0 {
+		return nil, denied, errors.Wrapf(ErrNoPrincipalsGranted, "%s", FormatDenials(denied))
+	}
+	return granted, denied, nil
+}
+
+// FormatDenials is for showing the denials to the user
+func FormatDenials(denied []Denial) string {
+	msgs := make([]string, 0, len(denied))
+	for _, d := range denied {
+		msgs = append(msgs, fmt.Sprintf("%s: %s", d.Principal, d.Reason))
+	}
+	return strings.Join(msgs, ", ")
 }
 
 // GrantAll is the authz module that grants everyone everything
@@ -26,6 +95,19 @@ func (g GrantAll) Authorized(user string, principals []string) ([]string, error)
 	return principals, nil
 }
 
+func (g GrantAll) Permissions(user string, principals []string) (CertPermissions, error) {
+	return DefaultPermissions(), nil
+}
+
+// DenyRule takes the principals away from the users even if they're admins
+// or granted them otherwise. Both can be patterns
+type DenyRule struct {
+	Users      []string `json:"users" yaml:"users"`
+	Principals []string `json:"principals" yaml:"principals"`
+}
+
+// SimpleAuth is the policy in a single YAML or JSON file. The principals
+// anywhere in it can be patterns like deploy-*
 type SimpleAuth struct {
 	Principals []string `json:"principals" yaml:"principals"`
 	// these users can get the root-everywhere if they request for it
@@ -34,6 +116,13 @@ type SimpleAuth struct {
 
 	// Users -> Principals map
 	AccessMap map[string][]string `json:"access_map" yaml:"access_map"`
+	// checked last, deny wins over everything else
+	Deny []DenyRule `json:"deny" yaml:"deny"`
+
+	// restrictions for the certs of a user, or of anyone granted a principal
+	// a cert gets the restrictions of the user and of every principal in it
+	UserPermissions      map[string]CertPermissions `json:"user_permissions" yaml:"user_permissions"`
+	PrincipalPermissions map[string]CertPermissions `json:"principal_permissions" yaml:"principal_permissions"`
 }
 
 func NewSimpleAuthFromFile(filePath string) (*SimpleAuth, error) {
@@ -46,9 +135,32 @@ func NewSimpleAuthFromFile(filePath string) (*SimpleAuth, error) {
 
 func NewSimpleAuthFromBuffer(content []byte) (*SimpleAuth, error) {
 	s := &SimpleAuth{}
-	err := json.Unmarshal(content, s)
-	if err != nil {
-		return nil, errors.Wrapf(err, "Failed to parse json for simple auth")
+	if err := decodePolicy(content, s, "simple auth"); err != nil {
+		return nil, err
+	}
+	if err := validPatterns(s.Principals); err != nil {
+		return nil, errors.Wrapf(err, "Invalid principals")
+	}
+	for user, principals := range s.AccessMap {
+		if err := validPatterns(principals); err != nil {
+			return nil, errors.Wrapf(err, "Invalid principals for user %s", user)
+		}
+	}
+	if err := validDenyRules(s.Deny); err != nil {
+		return nil, err
+	}
+	for user, permissions := range s.UserPermissions {
+		if err := permissions.Validate(); err != nil {
+			return nil, errors.Wrapf(err, "Invalid permissions for user %s", user)
+		}
+	}
+	for principal, permissions := range s.PrincipalPermissions {
+		if err := validPattern(principal); err != nil {
+			return nil, err
+		}
+		if err := permissions.Validate(); err != nil {
+			return nil, errors.Wrapf(err, "Invalid permissions for principal %s", principal)
+		}
 	}
 	return s, nil
 }
@@ -67,13 +179,20 @@ func (s SimpleAuth) validPrincipals(principals []string) (bool, error) {
 	// at most 100 elements, or at least if this is becoming a bottleneck
 	// you want something like LDAP and not hacking this simple implementation
 	for _, p := range principals {
-		if !contains(p, s.Principals) {
+		if isPattern(p) {
+			return false, fmt.Errorf("Principal %s can't be a pattern", p)
+		}
+		if !s.rules().known(p) {
 			return false, fmt.Errorf("Principal %s is unknown", p)
 		}
 	}
 	return true, nil
 }
 
+func (s SimpleAuth) rules() principalRules {
+	return principalRules{principals: s.Principals, deny: s.Deny}
+}
+
 func contains(needle string, haystack []string) bool {
 	for _, thread := range haystack {
 		if needle == thread {
@@ -83,28 +202,122 @@ func contains(needle string, haystack []string) bool {
 	return false
 }
 
+// Authorized leaves out the unknown principals like the ones that aren't
+// granted, Grant tells the user which ones they were
 func (s SimpleAuth) Authorized(user string, principals []string) ([]string, error) {
-	if _, err := s.validPrincipals(principals); err != nil {
-		return nil, errors.Wrapf(err, "Invalid principals")
+	// if admin, grant the principals if they exist
+	admin := s.IsAdmin(user)
+	grantedAccess, ok := s.AccessMap[user]
+	if !ok && !admin {
+		return nil, fmt.Errorf("User not granted any access yet, talk to your administrator")
 	}
 
-	if s.IsAdmin(user) {
-		// if admin, grant the principals if they exist
-		return principals, nil
+	return s.rules().authorize(user, principals, admin, grantedAccess), nil
+}
+
+func (s SimpleAuth) DenialReason(user string, principal string) string {
+	if reason := s.rules().denialReason(user, principal); reason != "" {
+		return reason
+	}
+	if _, ok := s.AccessMap[user]; !ok && !s.IsAdmin(user) {
+		return "user not granted any access yet"
 	}
+	return fmt.Sprintf("not granted to %s, talk to your administrator", user)
+}
 
-	grantedAccess, ok := s.AccessMap[user]
-	if !ok {
-		return nil, fmt.Errorf("User not granted any access yet, talk to your administrator")
+// principalRules is how every policy decides the principals: only the known
+// ones, to the admins or the users granted them, and deny wins over both.
+// Any of them can be patterns
+type principalRules struct {
+	principals []string
+	deny       []DenyRule
+}
+
+// known is one of the principals of the policy, a pattern in the policy can
+// only be requested by the principals it matches
+func (r principalRules) known(principal string) bool {
+	return !isPattern(principal) && matchesAny(principal, r.principals)
+}
+
+func (r principalRules) denied(user string, principal string) bool {
+	for _, rule := range r.deny {
+		if matchesAny(user, rule.Users) && matchesAny(principal, rule.Principals) {
+			return true
+		}
 	}
+	return false
+}
 
-	// grant user their own principal
+func (r principalRules) authorize(user string, principals []string, admin bool, granted []string) []string {
 	grantedPrincipals := []string{}
-
 	for _, p := range principals {
-		if contains(p, grantedAccess) {
+		if !r.known(p) || r.denied(user, p) {
+			continue
+		}
+		if admin || matchesAny(p, granted) {
 			grantedPrincipals = append(grantedPrincipals, p)
 		}
 	}
-	return grantedPrincipals, nil
+	return grantedPrincipals
+}
+
+// denialReason is empty when it's up to the policy, the principal is known
+// and not denied
+func (r principalRules) denialReason(user string, principal string) string {
+	if !r.known(principal) {
+		return "unknown principal"
+	}
+	if r.denied(user, principal) {
+		return fmt.Sprintf("denied to %s by a deny rule", user)
+	}
+	return ""
+}
+
+func validDenyRules(rules []DenyRule) error {
+	for i, rule := range rules {
+		if len(rule.Users) == 0 || len(rule.Principals) == 0 {
+			return fmt.Errorf("Deny rule %d needs both users and principals", i+1)
+		}
+		if err := validPatterns(append(append([]string{}, rule.Users...), rule.Principals...)); err != nil {
+			return errors.Wrapf(err, "Invalid deny rule %d", i+1)
+		}
+	}
+	return nil
+}
+
+func (s SimpleAuth) Permissions(user string, principals []string) (CertPermissions, error) {
+	return combinePermissions(s.UserPermissions, s.PrincipalPermissions, user, principals)
+}
+
+// combinePermissions restricts the default permissions with the ones of the
+// user and of every principal. The principals can be patterns in the policy,
+// a principal gets the restrictions of every pattern it matches
+func combinePermissions(userPermissions map[string]CertPermissions, principalPermissions map[string]CertPermissions,
+	user string, principals []string) (CertPermissions, error) {
+	permissions := DefaultPermissions()
+	var err error
+	if restrictions, ok := userPermissions[user]; ok {
+		permissions, err = permissions.Intersect(restrictions)
+		if err != nil {
+			return permissions, errors.Wrapf(err, "Invalid permissions for user %s", user)
+		}
+	}
+	// sorted so the same policy always gives the same cert
+	patterns := make([]string, 0, len(principalPermissions))
+	for pattern := range principalPermissions {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+	for _, p := range principals {
+		for _, pattern := range patterns {
+			if !matches(p, pattern) {
+				continue
+			}
+			permissions, err = permissions.Intersect(principalPermissions[pattern])
+			if err != nil {
+				return permissions, errors.Wrapf(err, "Cannot combine the permissions for principal %s", p)
+			}
+		}
+	}
+	return permissions, nil
 }
@@ -2,7 +2,11 @@ package accord
 
 import (
 	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/pkg/errors"
 )
 
 func TestGrantAll_Authorized(t *testing.T) {
@@ -118,6 +122,75 @@ func TestNewSimpleAuthFromBuffer(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "simple auth initializes from yaml with patterns and deny rules",
+			args: args{
+				buf: []byte(`
+principals: [root-everywhere, deploy-*]
+admin_users: [user1@ex.ample.com]
+access_map:
+  user2@ex.ample.com: [deploy-*]
+deny:
+  - users: ["*@contractors.ex.ample.com"]
+    principals: [root-*]
+principal_permissions:
+  deploy-*:
+    force_command: /usr/local/bin/deploy
+    source_addresses: [10.0.0.0/8]
+    max_validity: 1h
+`,
+				),
+			},
+			want: &SimpleAuth{
+				Principals: []string{"root-everywhere", "deploy-*"},
+				AdminUsers: []string{"user1@ex.ample.com"},
+				AccessMap: map[string][]string{
+					"user2@ex.ample.com": []string{"deploy-*"},
+				},
+				Deny: []DenyRule{
+					{Users: []string{"*@contractors.ex.ample.com"}, Principals: []string{"root-*"}},
+				},
+				PrincipalPermissions: map[string]CertPermissions{
+					"deploy-*": {
+						ForceCommand:    "/usr/local/bin/deploy",
+						SourceAddresses: []string{"10.0.0.0/8"},
+						MaxValidity:     time.Hour,
+					},
+				},
+			},
+		},
+		{
+			name: "simple auth fails on unknown keys",
+			args: args{
+				buf: []byte(`{
+"principals": ["root-everywhere", "zones-db"],
+"admin_user": ["user1@ex.ample.com"]
+}`,
+				),
+			},
+			wantErr: true,
+		},
+		{
+			name: "simple auth fails on invalid patterns",
+			args: args{
+				buf: []byte(`principals: ["deploy-["]`),
+			},
+			wantErr: true,
+		},
+		{
+			name: "simple auth fails on deny rules without principals",
+			args: args{
+				buf: []byte(`deny: [{users: ["*"]}]`),
+			},
+			wantErr: true,
+		},
+		{
+			name: "simple auth fails on durations without units",
+			args: args{
+				buf: []byte(`principal_permissions: {deploy-prod: {max_validity: 3600}}`),
+			},
+			wantErr: true,
+		},
 		{
 			name: "simple auth fails gracefully on invalid json",
 			args: args{
@@ -145,6 +218,38 @@ func TestNewSimpleAuthFromBuffer(t *testing.T) {
 	}
 }
 
+func TestNewSimpleAuthFromBuffer_lineNumbers(t *testing.T) {
+	tests := []struct {
+		name string
+		buf  string
+		want string
+	}{
+		{
+			name: "unknown key in yaml",
+			buf:  "principals: [root-everywhere]\naccess_map: {}\nadmins: [user1@ex.ample.com]\n",
+			want: "line 3",
+		},
+		{
+			name: "unknown key in json",
+			buf:  "{\n\"principals\": [\"root-everywhere\"],\n\"user_permissions\": {\"user1@ex.ample.com\": {\"force_comand\": \"uptime\"}}\n}",
+			want: "line 3",
+		},
+		{
+			name: "json syntax error",
+			buf:  "{\n\"principals\": [\"root-everywhere\"],\n}",
+			want: "line 3",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewSimpleAuthFromBuffer([]byte(tt.buf))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("NewSimpleAuthFromBuffer() error = %v, want it to mention %s", err, tt.want)
+			}
+		})
+	}
+}
+
 func TestSimpleAuth_IsAdmin(t *testing.T) {
 	type fields struct {
 		Principals []string
@@ -340,3 +445,229 @@ func TestSimpleAuth_Authorized(t *testing.T) {
 		})
 	}
 }
+
+func TestSimpleAuth_Deny(t *testing.T) {
+	s := SimpleAuth{
+		Principals: []string{"root-everywhere", "deploy-*"},
+		AdminUsers: []string{"user1@ex.ample.com", "admin@contractors.ex.ample.com"},
+		AccessMap: map[string][]string{
+			"user2@ex.ample.com": []string{"deploy-*"},
+			"user3@ex.ample.com": []string{"deploy-*"},
+		},
+		Deny: []DenyRule{
+			{Users: []string{"*@contractors.ex.ample.com"}, Principals: []string{"root-*"}},
+			{Users: []string{"user3@ex.ample.com"}, Principals: []string{"deploy-prod"}},
+		},
+	}
+	tests := []struct {
+		name       string
+		user       string
+		principals []string
+		want       []string
+		wantReason string
+		wantErr    bool
+	}{
+		{
+			name:       "patterns grant matching principals",
+			user:       "user2@ex.ample.com",
+			principals: []string{"deploy-prod", "root-everywhere"},
+			want:       []string{"deploy-prod"},
+		},
+		{
+			name:       "deny wins over the access map",
+			user:       "user3@ex.ample.com",
+			principals: []string{"deploy-prod", "deploy-staging"},
+			want:       []string{"deploy-staging"},
+			wantReason: "denied to user3@ex.ample.com by a deny rule",
+		},
+		{
+			name:       "deny wins over admin",
+			user:       "admin@contractors.ex.ample.com",
+			principals: []string{"root-everywhere", "deploy-prod"},
+			want:       []string{"deploy-prod"},
+			wantReason: "denied to admin@contractors.ex.ample.com by a deny rule",
+		},
+		{
+			name:       "requested principals can't be patterns",
+			user:       "user1@ex.ample.com",
+			principals: []string{"deploy-*", "deploy-prod"},
+			want:       []string{"deploy-prod"},
+			wantReason: "unknown principal",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.Authorized(tt.user, tt.principals)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SimpleAuth.Authorized() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SimpleAuth.Authorized() = %v, want %v", got, tt.want)
+			}
+			if tt.wantReason == "" {
+				return
+			}
+			for _, p := range tt.principals {
+				if !contains(p, got) {
+					if reason := s.DenialReason(tt.user, p); reason != tt.wantReason {
+						t.Errorf("SimpleAuth.DenialReason(%s) = %s, want %s", p, reason, tt.wantReason)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestSimpleAuth_Permissions(t *testing.T) {
+	s := SimpleAuth{
+		UserPermissions: map[string]CertPermissions{
+			"backup@ex.ample.com": {
+				ForceCommand:    "/usr/local/bin/backup",
+				SourceAddresses: []string{"10.0.0.0/8"},
+			},
+		},
+		PrincipalPermissions: map[string]CertPermissions{
+			"zones-db":  {SourceAddresses: []string{"10.1.0.0/16"}, Extensions: []string{"permit-pty"}},
+			"zones-app": {ForceCommand: "/bin/deploy"},
+			"deploy-*":  {ForceCommand: "/usr/local/bin/deploy", MaxValidity: time.Hour},
+			"*-prod":    {MaxValidity: 10 * time.Minute},
+		},
+	}
+	tests := []struct {
+		name       string
+		user       string
+		principals []string
+		want       CertPermissions
+		wantErr    bool
+	}{
+		{
+			name:       "no restrictions",
+			user:       "user1@ex.ample.com",
+			principals: []string{"root-everywhere"},
+			want:       DefaultPermissions(),
+		},
+		{
+			name:       "user restrictions",
+			user:       "backup@ex.ample.com",
+			principals: []string{"root-everywhere"},
+			want:       CertPermissions{ForceCommand: "/usr/local/bin/backup", SourceAddresses: []string{"10.0.0.0/8"}},
+		},
+		{
+			name:       "user and principal restrictions are combined",
+			user:       "backup@ex.ample.com",
+			principals: []string{"zones-db"},
+			want: CertPermissions{
+				ForceCommand:    "/usr/local/bin/backup",
+				SourceAddresses: []string{"10.1.0.0/16"},
+				Extensions:      []string{"permit-pty"},
+			},
+		},
+		{
+			name:       "principal patterns",
+			user:       "user1@ex.ample.com",
+			principals: []string{"deploy-staging"},
+			want:       CertPermissions{ForceCommand: "/usr/local/bin/deploy", MaxValidity: time.Hour},
+		},
+		{
+			name:       "every matching pattern applies",
+			user:       "user1@ex.ample.com",
+			principals: []string{"deploy-prod"},
+			want:       CertPermissions{ForceCommand: "/usr/local/bin/deploy", MaxValidity: 10 * time.Minute},
+		},
+		{
+			name:       "conflicting force commands",
+			user:       "backup@ex.ample.com",
+			principals: []string{"zones-app"},
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.Permissions(tt.user, tt.principals)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SimpleAuth.Permissions() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SimpleAuth.Permissions() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGrant(t *testing.T) {
+	s := SimpleAuth{
+		Principals: []string{"root-everywhere", "zones-db", "zones-willywonka"},
+		AccessMap: map[string][]string{
+			"user2@ex.ample.com": []string{"zones-db"},
+		},
+	}
+	tests := []struct {
+		name        string
+		authz       Authz
+		user        string
+		principals  []string
+		wantGranted []string
+		wantDenied  []Denial
+		wantErr     error
+	}{
+		{
+			name:        "partial grant lists the denied principals",
+			authz:       s,
+			user:        "user2@ex.ample.com",
+			principals:  []string{"zones-db", "zones-willywonka"},
+			wantGranted: []string{"zones-db"},
+			wantDenied: []Denial{
+				{Principal: "zones-willywonka", Reason: "not granted to user2@ex.ample.com, talk to your administrator"},
+			},
+		},
+		{
+			name:        "unknown principals are denied, the rest granted",
+			authz:       s,
+			user:        "user2@ex.ample.com",
+			principals:  []string{"zones-chocolatefactory", "zones-db", "zones-*"},
+			wantGranted: []string{"zones-db"},
+			wantDenied: []Denial{
+				{Principal: "zones-chocolatefactory", Reason: "unknown principal"},
+				{Principal: "zones-*", Reason: "unknown principal"},
+			},
+		},
+		{
+			name:       "nothing granted is an error",
+			authz:      s,
+			user:       "user2@ex.ample.com",
+			principals: []string{"root-everywhere"},
+			wantDenied: []Denial{
+				{Principal: "root-everywhere", Reason: "not granted to user2@ex.ample.com, talk to your administrator"},
+			},
+			wantErr: ErrNoPrincipalsGranted,
+		},
+		{
+			name:       "no principals requested",
+			authz:      GrantAll{},
+			user:       "user2@ex.ample.com",
+			principals: []string{},
+			wantErr:    ErrNoPrincipals,
+		},
+		{
+			name:        "everything granted",
+			authz:       GrantAll{},
+			user:        "user2@ex.ample.com",
+			principals:  []string{"zones-db"},
+			wantGranted: []string{"zones-db"},
+			wantDenied:  []Denial{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			granted, denied, err := Grant(tt.authz, tt.user, tt.principals)
+			if errors.Cause(err) != tt.wantErr {
+				t.Errorf("Grant() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(granted, tt.wantGranted) || !reflect.DeepEqual(denied, tt.wantDenied) {
+				t.Errorf("Grant() = %v, %v, want %v, %v", granted, denied, tt.wantGranted, tt.wantDenied)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package accord
+
+import (
+	"bytes"
+	"net"
+	"sync"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// CASigner gives the CertManager access to the private key of a CA
+// wherever it's kept. Signer is called every time a cert is signed so the
+// implementations can decide how long they hold on to the key
+type CASigner interface {
+	Signer() (ssh.Signer, error)
+}
+
+// SignerBackend returns the CASigner for a CA found in the certs directory, id
+// is the one in the file name. Only the public key of the pair is guaranteed to
+// be there, the private key file is only needed by the FileBackend
+type SignerBackend func(id int, pair *CACertPair) (CASigner, error)
+
+// FileSigner is the encrypted private key on the local filesystem, it's read
+// and decrypted again every time it's used
+type FileSigner struct {
+	Path       string
+	Passphrase string
+}
+
+func (f *FileSigner) Signer() (ssh.Signer, error) {
+	return getSigner(f.Path, f.Passphrase)
+}
+
+// FileBackend reads the private key files next to the public keys, the passphrase
+// for each of them is looked up by the id of the pair
+func FileBackend(passphrase func(id int) (string, error)) SignerBackend {
+	return func(id int, pair *CACertPair) (CASigner, error) {
+		if pair.PrivateKeyPath == "" {
+			return nil, errors.Errorf("No private key file for the %s CA %d", pair.Type, id)
+		}
+		password, err := passphrase(id)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to read the passphrase for the %s CA %d", pair.Type, id)
+		}
+		return &FileSigner{Path: pair.PrivateKeyPath, Passphrase: password}, nil
+	}
+}
+
+// AgentSigner signs with a key held by an ssh-agent, the private key never
+// leaves the agent. The connection to the agent socket is kept open and
+// redialed if the agent goes away
+type AgentSigner struct {
+	publicKey ssh.PublicKey
+	socket    string
+
+	mu    sync.Mutex
+	conn  net.Conn
+	agent agent.Agent
+}
+
+// NewAgentSigner uses the key matching publicKey from the agent listening on socket
+func NewAgentSigner(socket string, publicKey ssh.PublicKey) *AgentSigner {
+	return &AgentSigner{
+		publicKey: publicKey,
+		socket:    socket,
+	}
+}
+
+// NewAgentSignerFromAgent uses an agent that's already connected, or one in
+// the same process like agent.NewKeyring()
+func NewAgentSignerFromAgent(a agent.Agent, publicKey ssh.PublicKey) *AgentSigner {
+	return &AgentSigner{
+		publicKey: publicKey,
+		agent:     a,
+	}
+}
+
+// AgentBackend looks for the key of every CA in the agent at socket
+func AgentBackend(socket string) SignerBackend {
+	return func(id int, pair *CACertPair) (CASigner, error) {
+		if socket == "" {
+			return nil, errors.New("No ssh-agent socket given")
+		}
+		return NewAgentSigner(socket, pair.PublicKey), nil
+	}
+}
+
+func (s *AgentSigner) Signer() (ssh.Signer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	signer, err := s.find()
+	if err != nil && s.socket != "" {
+		// the agent may have been restarted, try once more with a new connection
+		s.disconnect()
+		signer, err = s.find()
+	}
+	return signer, err
+}
+
+func (s *AgentSigner) find() (ssh.Signer, error) {
+	if s.agent == nil {
+		conn, err := net.Dial("unix", s.socket)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to connect to the ssh-agent at %s", s.socket)
+		}
+		s.conn = conn
+		s.agent = agent.NewClient(conn)
+	}
+	signers, err := s.agent.Signers()
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to list the keys in the ssh-agent")
+	}
+	want := s.publicKey.Marshal()
+	for _, signer := range signers {
+		if bytes.Equal(signer.PublicKey().Marshal(), want) {
+			return signer, nil
+		}
+	}
+	return nil, errors.Errorf("The ssh-agent doesn't have the key %s", ssh.FingerprintSHA256(s.publicKey))
+}
+
+func (s *AgentSigner) disconnect() {
+	if s.conn != nil {
+		s.conn.Close()
+	}
+	s.conn = nil
+	s.agent = nil
+}
+
+// Close disconnects from the agent socket
+func (s *AgentSigner) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.disconnect()
+	return nil
+}
+
+// checkSigner makes sure the backend actually has the private key for the
+// public key we publish, otherwise we'd only find out when the first cert is signed
+func checkSigner(signer CASigner, publicKey ssh.PublicKey) error {
+	s, err := signer.Signer()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(s.PublicKey().Marshal(), publicKey.Marshal()) {
+		return errors.Errorf("The private key doesn't match the public key %s", ssh.FingerprintSHA256(publicKey))
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package accord
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// moveToAgent adds the private key written by writeTestCA to the keyring
+// and removes the file, so the key is only available from the agent
+func moveToAgent(t *testing.T, keyring agent.Agent, path string) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %s", path, err)
+	}
+	key, err := ssh.ParseRawPrivateKey(contents)
+	if err != nil {
+		t.Fatalf("Failed to parse %s: %s", path, err)
+	}
+	if err := keyring.Add(agent.AddedKey{PrivateKey: key}); err != nil {
+		t.Fatalf("Failed to add %s to the agent: %s", path, err)
+	}
+	os.Remove(path)
+}
+
+func TestNewCertManagerWithBackend_Agent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "accord-agent")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	now := time.Now()
+	metadata := CertMetadata{ValidFrom: now.Add(-time.Hour), ValidUntil: now.Add(24 * time.Hour)}
+	metadata.Id = 1
+	hostCA := writeTestCA(t, dir, Host, metadata)
+	metadata.Id = 2
+	writeTestCA(t, dir, User, metadata)
+
+	keyring := agent.NewKeyring()
+	moveToAgent(t, keyring, filepath.Join(dir, "ca_host_1"))
+
+	// serve the keyring on a socket so the backend goes through the same path as a real agent
+	socket := filepath.Join(dir, "agent.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("Failed to listen on %s: %s", socket, err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go agent.ServeAgent(keyring, conn)
+		}
+	}()
+
+	if _, err := NewCertManagerWithBackend(dir, AgentBackend(socket)); err == nil {
+		t.Fatalf("NewCertManagerWithBackend() should fail when the agent is missing a CA key")
+	}
+
+	moveToAgent(t, keyring, filepath.Join(dir, "ca_user_2"))
+	m, err := NewCertManagerWithBackend(dir, AgentBackend(socket))
+	if err != nil {
+		t.Fatalf("NewCertManagerWithBackend() error = %v", err)
+	}
+
+	pubKey, err := ioutil.ReadFile("test_assets/host_key.pub")
+	if err != nil {
+		t.Fatalf("Failed to read the public key: %s", err)
+	}
+	signed, err := m.SignHostCert(&CertSignRequest{
+		PubKey:     pubKey,
+		ValidFrom:  time.Now().Add(10 * time.Second),
+		ValidUntil: time.Now().Add(time.Hour),
+		Id:         "host",
+		Serial:     1,
+		Principals: []string{"host.example.com"},
+	})
+	if err != nil {
+		t.Fatalf("CertManager.SignHostCert() error = %v", err)
+	}
+	key, _, _, _, err := ssh.ParseAuthorizedKey(signed)
+	if err != nil {
+		t.Fatalf("Failed to parse the signed cert: %s", err)
+	}
+	cert := key.(*ssh.Certificate)
+	if !bytes.Equal(cert.SignatureKey.Marshal(), hostCA.Marshal()) {
+		t.Errorf("Cert signed by %s, want %s", ssh.FingerprintSHA256(cert.SignatureKey), ssh.FingerprintSHA256(hostCA))
+	}
+	checker := &ssh.CertChecker{
+		IsHostAuthority: func(auth ssh.PublicKey, address string) bool {
+			return bytes.Equal(auth.Marshal(), hostCA.Marshal())
+		},
+		// the cert is only valid from a few seconds in the future
+		Clock: func() time.Time { return time.Now().Add(time.Minute) },
+	}
+	if err := checker.CheckCert("host.example.com", cert); err != nil {
+		t.Errorf("CertChecker.CheckCert() error = %v", err)
+	}
+}
+
+func TestAgentSigner_Signer(t *testing.T) {
+	keyring := agent.NewKeyring()
+	dir, err := ioutil.TempDir("", "accord-agent")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	inAgent := writeTestCA(t, dir, Host, CertMetadata{Id: 1})
+	moveToAgent(t, keyring, filepath.Join(dir, "ca_host_1"))
+	notInAgent := writeTestCA(t, dir, Host, CertMetadata{Id: 2})
+
+	tests := []struct {
+		name    string
+		key     ssh.PublicKey
+		wantErr bool
+	}{
+		{
+			name: "key in the agent",
+			key:  inAgent,
+		},
+		{
+			name:    "key not in the agent",
+			key:     notInAgent,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer, err := NewAgentSignerFromAgent(keyring, tt.key).Signer()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("AgentSigner.Signer() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil && !bytes.Equal(signer.PublicKey().Marshal(), tt.key.Marshal()) {
+				t.Errorf("AgentSigner.Signer() returned the wrong key")
+			}
+		})
+	}
+}
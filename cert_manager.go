@@ -9,25 +9,34 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"log"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"time"
 
 	"github.com/mistsys/accord/aws_params"
+	"github.com/mistsys/accord/db"
 	"github.com/pkg/errors"
 	"golang.org/x/crypto/ssh"
 )
 
 var (
-	ErrInvalidSerial      = errors.New("Serial should be something meaningful, not 0")
 	ErrInvalidStartTime   = errors.New("Cannot sign for certs with time in the past")
 	ErrEndBeforeStartTime = errors.New("End Time cannot be before start time")
 	ErrEmptyID            = errors.New("Empty ID supplied")
 	ErrValidityTooLong    = errors.New("The Validity for certs is too long")
+	ErrNoActiveCA         = errors.New("No CA is valid for signing right now")
+	ErrOutlivesCA         = errors.New("The cert would be valid for longer than the CA signing it")
+	ErrNoUserPrincipals   = errors.New("User certs without principals are valid for every user, not signing it")
 	keyPairRegex          = regexp.MustCompile(`ca_(?P<type>user|host)_(?P<id>\d+).?(?P<key_type>pub)?`)
 )
 
+// keep some sane bounds on how long a cert can be for
+// 90 days is probably too long
+const MaxCertValidity = 90 * 24 * time.Hour
+
 // TODO: this needs a real refactoring
 // I designed it to be very simple and it grew relatively organic
 // Once the interfaces are little cleaner, update the CertManager to not store everything
@@ -38,25 +47,24 @@ var (
 // so any kind of overflow attack needs a filesystem access too
 // while this may leak the CA Passwords, as long as the certs are
 // read on demand, and forgotten immediately, I think this should be
-// relatively safe. This can be an interface to read from different
-// stores for secrets, but that needs a lot more testing right now
-// TODO: refactor to use CACertPair structure
+// relatively safe. The keys can also be kept out of the filesystem
+// entirely with one of the other CASigner backends
+//
+// There can be more than one CA of each type loaded so that they can
+// be rotated. All the CAs that haven't expired are published, so the
+// hosts and users start trusting the next CA before it's used. Certs
+// are signed with the newest CA whose ValidFrom has been reached
 type CertManager struct {
-	rootCAPath       string
-	rootCAId         int
-	rootCAPassword   string
-	rootCAPubKey     ssh.PublicKey
-	rootCAValidFrom  time.Time
-	rootCAValidUntil time.Time
-	userCAId         int
-	userCAPath       string
-	userCAPassword   string
-	userCAPubKey     ssh.PublicKey
-	userCAValidFrom  time.Time
-	userCAValidUntil time.Time
-}
-
-// CertMetadata is included in the comment for public key
+	hostCAs []*caKey
+	userCAs []*caKey
+	// when set, the serials come from here and every signed cert is recorded
+	ledger db.Ledger
+	// only replaced in tests
+	now func() time.Time
+}
+
+// CertMetadata is included in the comment for public key. The CAs without
+// a ValidUntil never expire
 type CertMetadata struct {
 	Id         int       `json:"id"`
 	ValidFrom  time.Time `json:"valid_from"`
@@ -78,6 +86,29 @@ type CACertPair struct {
 	PublicKeyPath  string
 }
 
+// caKey is a CA the CertManager can sign with
+type caKey struct {
+	CACertPair
+	signer CASigner
+}
+
+func (c *caKey) active(now time.Time) bool {
+	return !now.Before(c.Metadata.ValidFrom) && !c.expired(now)
+}
+
+func (c *caKey) expired(now time.Time) bool {
+	return !c.Metadata.ValidUntil.IsZero() && !now.Before(c.Metadata.ValidUntil)
+}
+
+func (c *caKey) public() CAPublic {
+	return CAPublic{
+		Id:         c.Metadata.Id,
+		PublicKey:  ssh.MarshalAuthorizedKey(c.PublicKey),
+		ValidFrom:  c.Metadata.ValidFrom,
+		ValidUntil: c.Metadata.ValidUntil,
+	}
+}
+
 func (c *CACertPair) updateMetadata() error {
 	var metadata = CertMetadata{}
 	contents, err := ioutil.ReadFile(c.PublicKeyPath)
@@ -179,66 +210,110 @@ func NewCertManagerWithParameters(certsDir string, region string, roleArn string
 	if err != nil {
 		return nil, errors.Wrapf(err, "Failed to find cert pairs in %s", certsDir)
 	}
+	return newCertManagerFromPairs(certPairs, FileBackend(func(id int) (string, error) {
+		return getPassphrase(client, paramsPrefix, id)
+	}))
+}
+
+// NewCertManagerWithBackend reads the public keys and their metadata from certsDir
+// like NewCertManagerWithParameters, but the private keys come from the backend
+// so they don't have to be in certsDir at all
+func NewCertManagerWithBackend(certsDir string, backend SignerBackend) (*CertManager, error) {
+	certPairs, err := certPairsInDir(certsDir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to find cert pairs in %s", certsDir)
+	}
+	return newCertManagerFromPairs(certPairs, backend)
+}
+
+// newCertManagerFromPairs loads every pair, the CAs that have already expired
+// are skipped since they can't be used for anything anymore
+func newCertManagerFromPairs(certPairs map[int]*CACertPair, backend SignerBackend) (*CertManager, error) {
 	certManager := &CertManager{}
+	now := certManager.currentTime()
 	for id, certPair := range certPairs {
+		if certPair.PublicKey == nil {
+			return nil, fmt.Errorf("The public key is needed for id %d", id)
+		}
+		ca := &caKey{CACertPair: *certPair}
+		if ca.expired(now) {
+			log.Printf("Skipping %s CA %d, it expired at %s", certPair.Type, id, certPair.Metadata.ValidUntil)
+			continue
+		}
+		signer, err := backend(id, certPair)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to get the signer for the %s key with id %d", certPair.Type, id)
+		}
+		if err := checkSigner(signer, certPair.PublicKey); err != nil {
+			return nil, errors.Wrapf(err, "Cannot use the %s key with id %d", certPair.Type, id)
+		}
+		ca.signer = signer
 		switch certPair.Type {
 		case User:
-			certManager.userCAPath = certPair.PrivateKeyPath
-			passphrase, err := getPassphrase(client, paramsPrefix, id)
-			if err != nil {
-				return nil, errors.Wrapf(err, "Failed to read the user key for id %d", id)
-			}
-			certManager.userCAId = certPair.Metadata.Id
-			certManager.userCAPassword = passphrase
-			certManager.userCAPubKey = certPair.PublicKey
-			certManager.userCAValidFrom = certPair.Metadata.ValidFrom
-			certManager.userCAValidUntil = certPair.Metadata.ValidUntil
+			certManager.userCAs = append(certManager.userCAs, ca)
 		case Host:
-			certManager.rootCAPath = certPair.PrivateKeyPath
-			passphrase, err := getPassphrase(client, paramsPrefix, id)
-			if err != nil {
-				return nil, errors.Wrapf(err, "Failed to read the host key for id %d", id)
-			}
-			certManager.rootCAId = certPair.Metadata.Id
-			certManager.rootCAPassword = passphrase
-			certManager.rootCAPubKey = certPair.PublicKey
-			certManager.rootCAValidFrom = certPair.Metadata.ValidFrom
-			certManager.rootCAValidUntil = certPair.Metadata.ValidUntil
+			certManager.hostCAs = append(certManager.hostCAs, ca)
 		}
 	}
-
+	if len(certManager.hostCAs) == 0 {
+		return nil, errors.New("No valid host CA found")
+	}
+	if len(certManager.userCAs) == 0 {
+		return nil, errors.New("No valid user CA found")
+	}
+	sortCAs(certManager.hostCAs)
+	sortCAs(certManager.userCAs)
 	return certManager, nil
 }
 
+func sortCAs(cas []*caKey) {
+	sort.Slice(cas, func(i, j int) bool {
+		return cas[i].Metadata.ValidFrom.Before(cas[j].Metadata.ValidFrom)
+	})
+}
+
 // NewCertmanagerwithPasswords just reads the files and decrypts them with corresponding passwords
 func NewCertManagerWithPasswords(rootCAPath string, rootCAPassword string,
 	userCAPath string, userCAPassword string) (*CertManager, error) {
-	// Read the keys at initialization time and keep them
-	// so that every time a public key is requested, we're not reading from the
-	// file and decrypting the passphrases
-	rootCASigner, err := getSigner(rootCAPath, rootCAPassword)
+	return NewCertManagerWithSigners(
+		&FileSigner{Path: rootCAPath, Passphrase: rootCAPassword},
+		&FileSigner{Path: userCAPath, Passphrase: userCAPassword},
+	)
+}
+
+// NewCertManagerWithSigners uses a single host and user CA from any backend
+func NewCertManagerWithSigners(rootCA CASigner, userCA CASigner) (*CertManager, error) {
+	// Read the keys at initialization time to make sure they're usable
+	// the signers are asked for again every time a cert is signed
+	rootCASigner, err := rootCA.Signer()
 	if err != nil {
-		return nil, errors.Wrapf(err, "Cannot read rootCA from %s", rootCAPath)
+		return nil, errors.Wrapf(err, "Cannot read rootCA")
 	}
-	userCASigner, err := getSigner(userCAPath, userCAPassword)
+	userCASigner, err := userCA.Signer()
 	if err != nil {
-		return nil, errors.Wrapf(err, "Cannot read userCA from %s", userCAPath)
+		return nil, errors.Wrapf(err, "Cannot read userCA")
 	}
 
-	now := time.Now()
-	ninetyDaysLater := now.Add(24 * 90 * time.Hour)
+	// there's no metadata for these, they're valid until they're replaced
+	metadata := CertMetadata{}
 
 	return &CertManager{
-		rootCAPath:       rootCAPath,
-		rootCAPassword:   rootCAPassword,
-		rootCAPubKey:     rootCASigner.PublicKey(),
-		rootCAValidFrom:  now,
-		rootCAValidUntil: ninetyDaysLater,
-		userCAPath:       userCAPath,
-		userCAPassword:   userCAPassword,
-		userCAPubKey:     userCASigner.PublicKey(),
-		userCAValidFrom:  now,
-		userCAValidUntil: ninetyDaysLater,
+		hostCAs: []*caKey{{
+			CACertPair: CACertPair{
+				Type:      Host,
+				Metadata:  metadata,
+				PublicKey: rootCASigner.PublicKey(),
+			},
+			signer: rootCA,
+		}},
+		userCAs: []*caKey{{
+			CACertPair: CACertPair{
+				Type:      User,
+				Metadata:  metadata,
+				PublicKey: userCASigner.PublicKey(),
+			},
+			signer: userCA,
+		}},
 	}, nil
 }
 
@@ -288,12 +363,15 @@ type CertSignRequest struct {
 	Principals []string
 	// include the criticalOptions and Extensions too
 	ssh.Permissions
+	// these are only recorded in the ledger, they don't go in the cert
+	Requester  string
+	Deployment string
+	Email      string
 }
 
+// valid checks everything but the serial, the CertManager only assigns that
+// once the rest of the request is valid
 func (r *CertSignRequest) valid() (bool, error) {
-	if r.Serial == 0 {
-		return false, ErrInvalidSerial
-	}
 	if r.ValidFrom.Before(time.Now()) {
 		return false, ErrInvalidStartTime
 	}
@@ -305,9 +383,7 @@ func (r *CertSignRequest) valid() (bool, error) {
 		return false, ErrEmptyID
 	}
 
-	// keep some sane bounds on how long a cert can be for
-	// 90 days is probably too long
-	if r.ValidUntil.Sub(r.ValidFrom) > 90*24*time.Hour {
+	if r.ValidUntil.Sub(r.ValidFrom) > MaxCertValidity {
 		return false, ErrValidityTooLong
 	}
 
@@ -340,12 +416,152 @@ func getSigner(certKeyPath, passphrase string) (ssh.Signer, error) {
 	return signer, err
 }
 
-func (m *CertManager) rootCASigner() (ssh.Signer, error) {
-	return getSigner(m.rootCAPath, m.rootCAPassword)
+// SetLedger makes the CertManager assign serials from the ledger instead
+// of trusting the ones in the request, and record every cert it signs
+func (m *CertManager) SetLedger(ledger db.Ledger) {
+	m.ledger = ledger
+}
+
+// assignSerial is only called for valid requests, so the rejected ones don't
+// use up serials. Without a ledger the serial in the request is kept, and
+// the requests without one get a random serial
+func (m *CertManager) assignSerial(request *CertSignRequest) error {
+	if m.ledger == nil {
+		if request.Serial == 0 {
+			serial, err := randomSerial()
+			if err != nil {
+				return err
+			}
+			request.Serial = serial
+		}
+		return nil
+	}
+	serial, err := m.ledger.NextSerial()
+	if err != nil {
+		return errors.Wrapf(err, "Failed to get a serial from the ledger")
+	}
+	request.Serial = serial
+	return nil
+}
+
+func randomSerial() (uint64, error) {
+	b := make([]byte, 8)
+	for {
+		if _, err := rand.Read(b); err != nil {
+			return 0, errors.Wrapf(err, "Failed to generate a serial")
+		}
+		if serial := binary.BigEndian.Uint64(b); serial != 0 {
+			return serial, nil
+		}
+	}
 }
 
-func (m *CertManager) userCASigner() (ssh.Signer, error) {
-	return getSigner(m.userCAPath, m.userCAPassword)
+// if this fails the cert shouldn't be handed out, otherwise we can end up with
+// certs in the wild we have no way of tracking down
+func (m *CertManager) recordCert(certType string, cert *ssh.Certificate, request *CertSignRequest) error {
+	if m.ledger == nil {
+		return nil
+	}
+	record := &db.CertRecord{
+		Serial:      cert.Serial,
+		CertType:    certType,
+		KeyId:       cert.KeyId,
+		Fingerprint: ssh.FingerprintSHA256(cert.Key),
+		Principals:  cert.ValidPrincipals,
+		ValidFrom:   request.ValidFrom,
+		ValidUntil:  request.ValidUntil,
+		Requester:   request.Requester,
+		Deployment:  request.Deployment,
+		Email:       request.Email,
+		IssuedAt:    time.Now(),
+	}
+	err := m.ledger.Record(record)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to record cert with serial %d", cert.Serial)
+	}
+	return nil
+}
+
+func (m *CertManager) currentTime() time.Time {
+	if m.now == nil {
+		return time.Now()
+	}
+	return m.now()
+}
+
+// signingCA picks the newest CA that's active right now, so that when the
+// ValidFrom of the next CA is reached the certs start getting signed by it
+func (m *CertManager) signingCA(cas []*caKey) (*caKey, error) {
+	now := m.currentTime()
+	var newest *caKey
+	for _, ca := range cas {
+		if !ca.active(now) {
+			continue
+		}
+		if newest == nil || ca.Metadata.ValidFrom.After(newest.Metadata.ValidFrom) {
+			newest = ca
+		}
+	}
+	if newest == nil {
+		return nil, ErrNoActiveCA
+	}
+	return newest, nil
+}
+
+// signerFor returns the signer for the CA that should sign the request
+// a cert can't be valid for longer than the CA that signed it
+func (m *CertManager) signerFor(cas []*caKey, request *CertSignRequest) (*caKey, ssh.Signer, error) {
+	ca, err := m.signingCA(cas)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ca.Metadata.ValidUntil.IsZero() && request.ValidUntil.After(ca.Metadata.ValidUntil) {
+		return nil, nil, errors.Wrapf(ErrOutlivesCA, "CA %d is only valid until %s", ca.Metadata.Id, ca.Metadata.ValidUntil)
+	}
+	signer, err := ca.signer.Signer()
+	return ca, signer, err
+}
+
+// Check that there's a CA of each type to sign with right now, a CertManager
+// without them can't take over from the one in use
+func (m *CertManager) Check() error {
+	if _, err := m.signingCA(m.hostCAs); err != nil {
+		return errors.Wrapf(err, "No host CA")
+	}
+	if _, err := m.signingCA(m.userCAs); err != nil {
+		return errors.Wrapf(err, "No user CA")
+	}
+	return nil
+}
+
+// MaxHostCertValidity is the longest a host cert signed right now can be valid
+// for, it's less than MaxCertValidity when the signing CA expires before that
+func (m *CertManager) MaxHostCertValidity() (time.Duration, error) {
+	ca, err := m.signingCA(m.hostCAs)
+	if err != nil {
+		return 0, err
+	}
+	if ca.Metadata.ValidUntil.IsZero() {
+		return MaxCertValidity, nil
+	}
+	validity := ca.Metadata.ValidUntil.Sub(m.currentTime())
+	if validity > MaxCertValidity {
+		validity = MaxCertValidity
+	}
+	return validity, nil
+}
+
+// published are the CAs that haven't expired yet, including the ones
+// that aren't used for signing yet
+func (m *CertManager) published(cas []*caKey) []*caKey {
+	now := m.currentTime()
+	valid := []*caKey{}
+	for _, ca := range cas {
+		if !ca.expired(now) {
+			valid = append(valid, ca)
+		}
+	}
+	return valid
 }
 
 func (m *CertManager) marshalCert(cert *ssh.Certificate, comment string) []byte {
@@ -362,10 +578,14 @@ func (m *CertManager) marshalCert(cert *ssh.Certificate, comment string) []byte
 }
 
 // these return array because we have to overlap multiple root
-// and user keys when we need to rotate the keys in future
+// and user keys when we need to rotate the keys
 // it makes sense to make the API exposed to users be a little more flexible
 func (m *CertManager) RootCAPublicKeys() []ssh.PublicKey {
-	return []ssh.PublicKey{m.rootCAPubKey}
+	keys := []ssh.PublicKey{}
+	for _, ca := range m.published(m.hostCAs) {
+		keys = append(keys, ca.PublicKey)
+	}
+	return keys
 }
 
 // CAPublic is the public data that we want to return the user
@@ -377,25 +597,119 @@ type CAPublic struct {
 }
 
 func (m *CertManager) HostCAs() []CAPublic {
-	return []CAPublic{{
-		Id:         m.rootCAId,
-		PublicKey:  ssh.MarshalAuthorizedKey(m.rootCAPubKey),
-		ValidFrom:  m.rootCAValidFrom,
-		ValidUntil: m.rootCAValidUntil,
-	}}
+	public := []CAPublic{}
+	for _, ca := range m.published(m.hostCAs) {
+		public = append(public, ca.public())
+	}
+	return public
 }
 
 func (m *CertManager) UserCAs() []CAPublic {
-	return []CAPublic{{
-		Id:         m.userCAId,
-		PublicKey:  ssh.MarshalAuthorizedKey(m.userCAPubKey),
-		ValidFrom:  m.userCAValidFrom,
-		ValidUntil: m.userCAValidUntil,
-	}}
+	public := []CAPublic{}
+	for _, ca := range m.published(m.userCAs) {
+		public = append(public, ca.public())
+	}
+	return public
+}
+
+// CAStatus is what's loaded of a CA, for the operators
+type CAStatus struct {
+	CAPublic
+	Type        CAType
+	Fingerprint string
+	// the CA the certs of its type are signed with right now
+	Signing bool
+	Expired bool
+}
+
+// CAs are all the loaded CAs, the ones that expired since they were loaded
+// and the ones that aren't used yet included
+func (m *CertManager) CAs() []CAStatus {
+	now := m.currentTime()
+	cas := []CAStatus{}
+	for _, loaded := range [][]*caKey{m.hostCAs, m.userCAs} {
+		signing, _ := m.signingCA(loaded)
+		for _, ca := range loaded {
+			cas = append(cas, CAStatus{
+				CAPublic:    ca.public(),
+				Type:        ca.Type,
+				Fingerprint: ssh.FingerprintSHA256(ca.PublicKey),
+				Signing:     ca == signing,
+				Expired:     ca.expired(now),
+			})
+		}
+	}
+	return cas
+}
+
+// the principal of the throwaway certs TestSign signs
+const testSignPrincipal = "accord-test-sign"
+
+// CACheck is how a CA did in TestSign
+type CACheck struct {
+	CAStatus
+	Err error
+}
+
+// TestSign signs a throwaway cert with every CA that hasn't expired, so that
+// a signer that's broken (missing file, wrong passphrase, the HSM gone) is
+// found before a request needs it. The certs aren't in the ledger
+func (m *CertManager) TestSign() []CACheck {
+	checks := []CACheck{}
+	for _, ca := range m.CAs() {
+		if ca.Expired {
+			continue
+		}
+		checks = append(checks, CACheck{CAStatus: ca, Err: m.testSign(ca)})
+	}
+	return checks
+}
+
+func (m *CertManager) testSign(status CAStatus) error {
+	var ca *caKey
+	for _, cas := range [][]*caKey{m.hostCAs, m.userCAs} {
+		for _, loaded := range cas {
+			if loaded.Type == status.Type && loaded.Metadata.Id == status.Id {
+				ca = loaded
+			}
+		}
+	}
+	if ca == nil {
+		return errors.Errorf("The %s CA %d isn't loaded", status.Type, status.Id)
+	}
+	now := time.Now()
+	// the CA's own key is as good as any to put in the cert
+	cert := &ssh.Certificate{
+		CertType:        ssh.UserCert,
+		Key:             ca.PublicKey,
+		KeyId:           testSignPrincipal,
+		ValidAfter:      uint64(now.Add(-time.Minute).Unix()),
+		ValidBefore:     uint64(now.Add(time.Minute).Unix()),
+		ValidPrincipals: []string{testSignPrincipal},
+	}
+	signer, err := ca.signer.Signer()
+	if err != nil {
+		return errors.Wrapf(err, "Cannot get the signer")
+	}
+	if err := cert.SignCert(rand.Reader, signer); err != nil {
+		return errors.Wrapf(err, "Failed to sign the cert")
+	}
+	if !bytes.Equal(cert.SignatureKey.Marshal(), ca.PublicKey.Marshal()) {
+		return errors.New("The signer's key isn't the CA's public key")
+	}
+	checker := &ssh.CertChecker{}
+	if err := checker.CheckCert(testSignPrincipal, cert); err != nil {
+		return errors.Wrapf(err, "The signature doesn't verify")
+	}
+	return nil
 }
 
 func (m *CertManager) UserCAPublicKeys() []ssh.PublicKey {
-	return []ssh.PublicKey{m.userCAPubKey}
+	keys := []ssh.PublicKey{}
+	for _, ca := range m.published(m.userCAs) {
+		keys = append(keys, ca.PublicKey)
+	}
+	return keys
 }
 
 // JoinPublickeys encodes the public key and joins them in a single bytearray
@@ -410,48 +724,62 @@ func JoinPublicKeys(keys []ssh.PublicKey) []byte {
 }
 
 func (m *CertManager) SignUserCert(request *CertSignRequest) ([]byte, error) {
-	// Don't bother if the request is invalid
+	// Don't bother, or use up a serial, if the request is invalid
 	_, err := request.valid()
 	if err != nil {
 		return nil, errors.Wrapf(err, "CertSignRequest isn't valid")
 	}
 
+	if len(request.Principals) == 0 {
+		return nil, ErrNoUserPrincipals
+	}
+
 	pubkey, comment, _, _, err := ssh.ParseAuthorizedKey(request.PubKey)
 	if err != nil {
 		return nil, errors.Wrapf(err, "Failed to parse public key")
 	}
 
+	// without any permissions set the cert gets the same extensions as from ssh-keygen
+	permissions := request.Permissions
+	if permissions.CriticalOptions == nil && permissions.Extensions == nil {
+		permissions = DefaultPermissions().SSHPermissions()
+	}
+
 	cert := &ssh.Certificate{
 		CertType:        ssh.UserCert,
 		Key:             pubkey,
 		KeyId:           request.Id,
-		Serial:          request.Serial,
 		ValidAfter:      uint64(request.ValidFrom.Unix()),
 		ValidBefore:     uint64(request.ValidUntil.Unix()),
 		ValidPrincipals: request.Principals,
-		Permissions: ssh.Permissions{
-			Extensions: map[string]string{ // for compatibility with ssh-keygen's output
-				"permit-X11-forwarding":   "",
-				"permit-agent-forwarding": "",
-				"permit-pty":              "",
-				"permit-user-rc":          "",
-			}},
+		Permissions:     permissions,
 	}
 
-	signer, err := m.userCASigner()
+	ca, signer, err := m.signerFor(m.userCAs, request)
 	if err != nil {
 		return nil, errors.Wrapf(err, "Cannot get the User CA signer")
 	}
+	err = m.assignSerial(request)
+	if err != nil {
+		return nil, err
+	}
+	cert.Serial = request.Serial
+	start := time.Now()
 	err = cert.SignCert(rand.Reader, signer)
+	observeSigning(ca, start, err)
 	if err != nil {
 		return nil, errors.Wrapf(err, "Failed to sign the cert")
 	}
+	err = m.recordCert(db.UserCertType, cert, request)
+	if err != nil {
+		return nil, err
+	}
 	b := m.marshalCert(cert, comment)
 	return b, nil
 }
 
 func (m *CertManager) SignHostCert(request *CertSignRequest) ([]byte, error) {
-	// Don't bother if the request is invalid
+	// Don't bother, or use up a serial, if the request is invalid
 	_, err := request.valid()
 	if err != nil {
 		return nil, errors.Wrapf(err, "CertSignRequest isn't valid")
@@ -466,20 +794,30 @@ func (m *CertManager) SignHostCert(request *CertSignRequest) ([]byte, error) {
 		CertType:        ssh.HostCert,
 		Key:             pubkey,
 		KeyId:           request.Id,
-		Serial:          request.Serial,
 		ValidAfter:      uint64(request.ValidFrom.Unix()),
 		ValidBefore:     uint64(request.ValidUntil.Unix()),
 		ValidPrincipals: request.Principals,
 	}
 
-	signer, err := m.rootCASigner()
+	ca, signer, err := m.signerFor(m.hostCAs, request)
 	if err != nil {
 		return nil, errors.Wrapf(err, "Cannot get the Host CA signer")
 	}
+	err = m.assignSerial(request)
+	if err != nil {
+		return nil, err
+	}
+	cert.Serial = request.Serial
+	start := time.Now()
 	err = cert.SignCert(rand.Reader, signer)
+	observeSigning(ca, start, err)
 	if err != nil {
 		return nil, errors.Wrapf(err, "Failed to sign the cert")
 	}
+	err = m.recordCert(db.HostCertType, cert, request)
+	if err != nil {
+		return nil, err
+	}
 	b := m.marshalCert(cert, comment)
 	return b, nil
 }
@@ -1,10 +1,23 @@
 package accord
 
 import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/mistsys/accord/db"
 	"golang.org/x/crypto/ssh"
 )
 
@@ -25,17 +38,6 @@ func TestCertSignRequest_valid(t *testing.T) {
 		wantErr bool
 		err     error
 	}{
-		{
-			name: "check invalid serial",
-			fields: fields{
-				ValidFrom:  time.Now().Add(10 * time.Second),
-				ValidUntil: time.Now().Add(10 * time.Hour),
-				Id:         "test",
-				Serial:     0,
-			},
-			wantErr: true,
-			err:     ErrInvalidSerial,
-		},
 		{
 			name: "valid time before now",
 			fields: fields{
@@ -92,6 +94,16 @@ func TestCertSignRequest_valid(t *testing.T) {
 			},
 			want: true,
 		},
+		{
+			name: "the serial is assigned later",
+			fields: fields{
+				ValidFrom:  time.Now().Add(10 * time.Second),
+				ValidUntil: time.Now().Add(10 * time.Hour),
+				Id:         "test",
+				Serial:     0,
+			},
+			want: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -123,10 +135,8 @@ func TestCertSignRequest_valid(t *testing.T) {
 
 func TestCertManager_SignUserCert(t *testing.T) {
 	type fields struct {
-		rootCAPath     string
-		rootCAPassword string
-		userCAPath     string
-		userCAPassword string
+		hostCAs []*caKey
+		userCAs []*caKey
 	}
 	type args struct {
 		request *CertSignRequest
@@ -143,10 +153,8 @@ func TestCertManager_SignUserCert(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			m := &CertManager{
-				rootCAPath:     tt.fields.rootCAPath,
-				rootCAPassword: tt.fields.rootCAPassword,
-				userCAPath:     tt.fields.userCAPath,
-				userCAPassword: tt.fields.userCAPassword,
+				hostCAs: tt.fields.hostCAs,
+				userCAs: tt.fields.userCAs,
 			}
 			got, err := m.SignUserCert(tt.args.request)
 			if (err != nil) != tt.wantErr {
@@ -159,3 +167,363 @@ func TestCertManager_SignUserCert(t *testing.T) {
 		})
 	}
 }
+
+func newTestLedger(t *testing.T) (*db.BoltStore, func()) {
+	dir, err := ioutil.TempDir("", "accord-ledger")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %s", err)
+	}
+	store, err := db.NewBoltStore(filepath.Join(dir, "accord.db"))
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("Failed to open the ledger: %s", err)
+	}
+	return store, func() {
+		store.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestCertManager_SignWithLedger(t *testing.T) {
+	m, err := NewCertManagerWithPasswords("test_assets/root_ca_20170927", "staple horse apple newton",
+		"test_assets/user_ca_20170927", "staple horse apple thatcher")
+	if err != nil {
+		t.Fatalf("Failed to create cert manager: %s", err)
+	}
+	ledger, cleanup := newTestLedger(t)
+	defer cleanup()
+	m.SetLedger(ledger)
+
+	pubKey, err := ioutil.ReadFile("test_assets/test_pubkeys/test_hostkey.pub")
+	if err != nil {
+		t.Fatalf("Failed to read the public key: %s", err)
+	}
+
+	var lastSerial uint64
+	for i := 0; i < 3; i++ {
+		request := &CertSignRequest{
+			PubKey:     pubKey,
+			ValidFrom:  time.Now().Add(10 * time.Second),
+			ValidUntil: time.Now().Add(time.Hour),
+			Id:         "testuser",
+			// the ledger should override whatever the caller sent
+			Serial:     1,
+			Principals: []string{"zones-db"},
+			Requester:  "127.0.0.1:1234",
+			Email:      "user1@ex.ample.com",
+		}
+		signed, err := m.SignUserCert(request)
+		if err != nil {
+			t.Fatalf("CertManager.SignUserCert() error = %v", err)
+		}
+		key, _, _, _, err := ssh.ParseAuthorizedKey(signed)
+		if err != nil {
+			t.Fatalf("Failed to parse the signed cert: %s", err)
+		}
+		cert := key.(*ssh.Certificate)
+		if cert.Serial <= lastSerial {
+			t.Errorf("CertManager.SignUserCert() serial = %d, want > %d", cert.Serial, lastSerial)
+		}
+		lastSerial = cert.Serial
+
+		record, err := ledger.Get(cert.Serial)
+		if err != nil {
+			t.Fatalf("Cert with serial %d wasn't recorded: %s", cert.Serial, err)
+		}
+		if record.Email != "user1@ex.ample.com" || record.KeyId != "testuser" || record.CertType != db.UserCertType {
+			t.Errorf("Unexpected record %v", record)
+		}
+		if record.Fingerprint != ssh.FingerprintSHA256(cert.Key) {
+			t.Errorf("Recorded fingerprint = %s, want %s", record.Fingerprint, ssh.FingerprintSHA256(cert.Key))
+		}
+	}
+
+	// the rejected requests don't use up serials
+	_, err = m.SignUserCert(&CertSignRequest{
+		PubKey:     pubKey,
+		ValidFrom:  time.Now().Add(-time.Hour),
+		ValidUntil: time.Now().Add(time.Hour),
+		Id:         "testuser",
+		Principals: []string{"zones-db"},
+	})
+	if err == nil {
+		t.Fatalf("CertManager.SignUserCert() should fail for a cert valid from the past")
+	}
+	if next, _ := ledger.NextSerial(); next != lastSerial+1 {
+		t.Errorf("BoltStore.NextSerial() = %d after a rejected request, want %d", next, lastSerial+1)
+	}
+}
+
+func TestCertManager_SignWithoutLedger(t *testing.T) {
+	m, err := NewCertManagerWithPasswords("test_assets/root_ca_20170927", "staple horse apple newton",
+		"test_assets/user_ca_20170927", "staple horse apple thatcher")
+	if err != nil {
+		t.Fatalf("Failed to create cert manager: %s", err)
+	}
+	pubKey, err := ioutil.ReadFile("test_assets/test_pubkeys/test_hostkey.pub")
+	if err != nil {
+		t.Fatalf("Failed to read the public key: %s", err)
+	}
+	for _, serial := range []uint64{0, 42} {
+		signed, err := m.SignHostCert(&CertSignRequest{
+			PubKey:     pubKey,
+			ValidFrom:  time.Now().Add(10 * time.Second),
+			ValidUntil: time.Now().Add(time.Hour),
+			Id:         "host",
+			Serial:     serial,
+			Principals: []string{"host.example.com"},
+		})
+		if err != nil {
+			t.Fatalf("CertManager.SignHostCert() with serial %d error = %v", serial, err)
+		}
+		key, _, _, _, err := ssh.ParseAuthorizedKey(signed)
+		if err != nil {
+			t.Fatalf("Failed to parse the signed cert: %s", err)
+		}
+		got := key.(*ssh.Certificate).Serial
+		if got == 0 || (serial != 0 && got != serial) {
+			t.Errorf("CertManager.SignHostCert() serial = %d, requested %d", got, serial)
+		}
+	}
+}
+
+// writeTestCA writes an unencrypted CA key pair named the way certPairsInDir expects
+func writeTestCA(t *testing.T, dir string, caType CAType, metadata CertMetadata) ssh.PublicKey {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %s", err)
+	}
+	der, err := x509.MarshalECPrivateKey(priv)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %s", err)
+	}
+	pub, err := ssh.NewPublicKey(&priv.PublicKey)
+	if err != nil {
+		t.Fatalf("Failed to get the public key: %s", err)
+	}
+	comment, err := json.Marshal(metadata)
+	if err != nil {
+		t.Fatalf("Failed to marshal metadata: %s", err)
+	}
+	name := filepath.Join(dir, fmt.Sprintf("ca_%s_%d", caType, metadata.Id))
+	err = ioutil.WriteFile(name, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600)
+	if err != nil {
+		t.Fatalf("Failed to write %s: %s", name, err)
+	}
+	line := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(pub))) + " " + string(comment) + "\n"
+	err = ioutil.WriteFile(name+".pub", []byte(line), 0644)
+	if err != nil {
+		t.Fatalf("Failed to write %s.pub: %s", name, err)
+	}
+	return pub
+}
+
+func TestCertManager_Rotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "accord-cas")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	day := 24 * time.Hour
+	now := time.Now()
+	current := writeTestCA(t, dir, Host, CertMetadata{Id: 1, ValidFrom: now.Add(-30 * day), ValidUntil: now.Add(30 * day)})
+	next := writeTestCA(t, dir, Host, CertMetadata{Id: 2, ValidFrom: now.Add(10 * day), ValidUntil: now.Add(100 * day)})
+	writeTestCA(t, dir, Host, CertMetadata{Id: 3, ValidFrom: now.Add(-100 * day), ValidUntil: now.Add(-1 * day)})
+	writeTestCA(t, dir, User, CertMetadata{Id: 4, ValidFrom: now.Add(-1 * day), ValidUntil: now.Add(60 * day)})
+
+	pairs, err := certPairsInDir(dir)
+	if err != nil {
+		t.Fatalf("certPairsInDir() error = %v", err)
+	}
+	m, err := newCertManagerFromPairs(pairs, FileBackend(func(id int) (string, error) { return "", nil }))
+	if err != nil {
+		t.Fatalf("newCertManagerFromPairs() error = %v", err)
+	}
+
+	pubKey, err := ioutil.ReadFile("test_assets/host_key.pub")
+	if err != nil {
+		t.Fatalf("Failed to read the public key: %s", err)
+	}
+	signHost := func(validity time.Duration) (*ssh.Certificate, error) {
+		signed, err := m.SignHostCert(&CertSignRequest{
+			PubKey:     pubKey,
+			ValidFrom:  time.Now().Add(10 * time.Second),
+			ValidUntil: time.Now().Add(validity),
+			Id:         "host",
+			Serial:     1,
+			Principals: []string{"host.example.com"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		key, _, _, _, err := ssh.ParseAuthorizedKey(signed)
+		if err != nil {
+			t.Fatalf("Failed to parse the signed cert: %s", err)
+		}
+		return key.(*ssh.Certificate), nil
+	}
+
+	tests := []struct {
+		name          string
+		now           time.Time
+		validity      time.Duration
+		wantPublished []int
+		wantSigner    ssh.PublicKey
+		wantMax       time.Duration
+		wantSigningId int
+		wantErr       bool
+	}{
+		{
+			name:          "the next CA is published before it's used",
+			now:           now,
+			validity:      time.Hour,
+			wantPublished: []int{1, 2},
+			wantSigner:    current,
+			wantMax:       30 * day,
+			wantSigningId: 1,
+		},
+		{
+			name:          "certs can't outlive the CA signing them",
+			now:           now,
+			validity:      40 * day,
+			wantPublished: []int{1, 2},
+			wantMax:       30 * day,
+			wantSigningId: 1,
+			wantErr:       true,
+		},
+		{
+			name:          "the next CA signs once it's valid",
+			now:           now.Add(11 * day),
+			validity:      time.Hour,
+			wantPublished: []int{1, 2},
+			wantSigner:    next,
+			wantMax:       89 * day,
+			wantSigningId: 2,
+		},
+		{
+			name:          "expired CAs aren't published",
+			now:           now.Add(31 * day),
+			validity:      time.Hour,
+			wantPublished: []int{2},
+			wantSigner:    next,
+			wantMax:       69 * day,
+			wantSigningId: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m.now = func() time.Time { return tt.now }
+			published := []int{}
+			for _, ca := range m.HostCAs() {
+				published = append(published, ca.Id)
+			}
+			if !reflect.DeepEqual(published, tt.wantPublished) {
+				t.Errorf("CertManager.HostCAs() = %v, want %v", published, tt.wantPublished)
+			}
+			for _, ca := range m.CAs() {
+				if ca.Type == Host && ca.Signing != (ca.Id == tt.wantSigningId) {
+					t.Errorf("CertManager.CAs() host CA %d signing = %v", ca.Id, ca.Signing)
+				}
+			}
+			// the CA that had expired when they were loaded is skipped
+			if n := len(m.CAs()); n != 3 {
+				t.Errorf("CertManager.CAs() = %d CAs, want 3", n)
+			}
+			if max, err := m.MaxHostCertValidity(); err != nil || max != tt.wantMax {
+				t.Errorf("CertManager.MaxHostCertValidity() = %s, %v, want %s", max, err, tt.wantMax)
+			}
+			cert, err := signHost(tt.validity)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CertManager.SignHostCert() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+			if !bytes.Equal(cert.SignatureKey.Marshal(), tt.wantSigner.Marshal()) {
+				t.Errorf("CertManager.SignHostCert() signed with the wrong CA")
+			}
+		})
+	}
+}
+
+// the CAs without metadata keep signing however long the server runs
+func TestCertManager_WithoutMetadata(t *testing.T) {
+	m, err := NewCertManagerWithPasswords("test_assets/root_ca_20170927", "staple horse apple newton",
+		"test_assets/user_ca_20170927", "staple horse apple thatcher")
+	if err != nil {
+		t.Fatalf("NewCertManagerWithPasswords() error = %v", err)
+	}
+	pubKey, err := ioutil.ReadFile("test_assets/host_key.pub")
+	if err != nil {
+		t.Fatalf("Failed to read the public key: %s", err)
+	}
+	m.now = func() time.Time { return time.Now().Add(100 * 24 * time.Hour) }
+
+	if err := m.Check(); err != nil {
+		t.Errorf("CertManager.Check() error = %v", err)
+	}
+	if n := len(m.HostCAs()); n != 1 {
+		t.Errorf("CertManager.HostCAs() = %d CAs, want 1", n)
+	}
+	if max, err := m.MaxHostCertValidity(); err != nil || max != MaxCertValidity {
+		t.Errorf("CertManager.MaxHostCertValidity() = %s, %v, want %s", max, err, MaxCertValidity)
+	}
+	_, err = m.SignHostCert(&CertSignRequest{
+		PubKey:     pubKey,
+		ValidFrom:  time.Now().Add(10 * time.Second),
+		ValidUntil: time.Now().Add(MaxCertValidity),
+		Id:         "host",
+		Principals: []string{"host.example.com"},
+	})
+	if err != nil {
+		t.Errorf("CertManager.SignHostCert() error = %v", err)
+	}
+}
+
+func TestCertManager_TestSign(t *testing.T) {
+	dir, err := ioutil.TempDir("", "accord-cas")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	day := 24 * time.Hour
+	now := time.Now()
+	writeTestCA(t, dir, Host, CertMetadata{Id: 1, ValidFrom: now.Add(-30 * day), ValidUntil: now.Add(30 * day)})
+	writeTestCA(t, dir, Host, CertMetadata{Id: 2, ValidFrom: now.Add(10 * day), ValidUntil: now.Add(100 * day)})
+	writeTestCA(t, dir, User, CertMetadata{Id: 3, ValidFrom: now.Add(-1 * day), ValidUntil: now.Add(60 * day)})
+
+	pairs, err := certPairsInDir(dir)
+	if err != nil {
+		t.Fatalf("certPairsInDir() error = %v", err)
+	}
+	m, err := newCertManagerFromPairs(pairs, FileBackend(func(id int) (string, error) { return "", nil }))
+	if err != nil {
+		t.Fatalf("newCertManagerFromPairs() error = %v", err)
+	}
+	failed := func() []int {
+		ids := []int{}
+		checks := m.TestSign()
+		if len(checks) != 3 {
+			t.Errorf("CertManager.TestSign() = %d checks, want 3", len(checks))
+		}
+		for _, check := range checks {
+			if check.Err != nil {
+				ids = append(ids, check.Id)
+			}
+		}
+		return ids
+	}
+	if ids := failed(); len(ids) != 0 {
+		t.Errorf("CertManager.TestSign() failed for %v", ids)
+	}
+	// the private key going away is only noticed when signing
+	if err := os.Remove(filepath.Join(dir, "ca_host_2")); err != nil {
+		t.Fatalf("Failed to remove the private key: %s", err)
+	}
+	if ids := failed(); !reflect.DeepEqual(ids, []int{2}) {
+		t.Errorf("CertManager.TestSign() failed for %v, want [2]", ids)
+	}
+}
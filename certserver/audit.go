@@ -0,0 +1,39 @@
+package certserver
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/mistsys/accord"
+	"golang.org/x/crypto/ssh"
+)
+
+// SetAuditor sets where the audit events go, they're only logged otherwise
+func (s *AccordServer) SetAuditor(auditor accord.Auditor) {
+	s.auditor = auditor
+}
+
+// audit fills in where the event came from. The request isn't failed when
+// the event can't be recorded, the sinks that can fail buffer to disk first
+func (s *AccordServer) audit(ctx context.Context, event *accord.AuditEvent) {
+	event.Time = time.Now()
+	event.Peer = peerAddr(ctx)
+	event.RequestId = requestId(ctx)
+	if err := s.auditor.Audit(event); err != nil {
+		log.Printf("Failed to audit %s for %s. %s", event.Type, event.Actor, err)
+	}
+}
+
+// certSerial is the serial the ledger gave the signed cert
+func certSerial(signed []byte) uint64 {
+	key, _, _, _, err := ssh.ParseAuthorizedKey(signed)
+	if err != nil {
+		return 0
+	}
+	cert, ok := key.(*ssh.Certificate)
+	if !ok {
+		return 0
+	}
+	return cert.Serial
+}
@@ -1,45 +1,234 @@
 package certserver
 
 import (
+	"bytes"
 	"context"
 	"crypto/rand"
+	"encoding/json"
+	"expvar"
 	"fmt"
 	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/aws/aws-sdk-go/aws/ec2metadata"
+	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes"
 	google_protobuf "github.com/golang/protobuf/ptypes/timestamp"
 	"github.com/mistsys/accord"
+	"github.com/mistsys/accord/cloud_metadata"
+	"github.com/mistsys/accord/db"
 	"github.com/mistsys/accord/protocol"
 	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+	"google.golang.org/grpc/peer"
 )
 
 // I ran out of names to give
 type AccordServer struct {
-	pskStore       accord.PSKStore
-	certManager    *accord.CertManager
-	aesgcm         *accord.AESGCM
+	// *serverConfig, swapped as a whole by Reload
+	config         atomic.Value
+	reloadMu       sync.Mutex
 	googleClientId string
 	domain         string
-	authz          accord.Authz
+	revocations    db.RevocationStore
+	sessions       *accord.SessionSigner
+	hostSessions   db.HostSessionStore
+	// how long and how many times the ids from HostAuth can be used
+	hostSessionTTL  time.Duration
+	hostSessionUses int
+	replays         *accord.ReplayCache
+	// the legacy requests without a timestamp, checked by the nonce alone
+	legacyReplays *accord.ReplayCache
+	// nil when the host identities aren't checked
+	hostPolicy       *accord.HostPolicy
+	identityVerifier *cloud_metadata.AWSIdentityVerifier
+	// accept the HostAuth requests from the hosts that still use the PSK
+	// directly with AESGCM
+	legacyHostAuth bool
+	auditor        accord.Auditor
+	// nil when the server doesn't keep a ledger
+	certs db.CertLister
+	// nil when users can't be disabled
+	users db.UserStore
 }
 
+// the HostAuth requests that were turned away, by the reason
+// they're on /debug/vars of the status port
+var hostAuthRejections = expvar.NewMap("hostauth_rejections")
+
+// which PSK versions the hosts authenticate with, a version can be retired
+// once it's not used anymore
+var hostAuthPSKVersions = expvar.NewMap("hostauth_psk_versions")
+
+// the deployments whose hosts still authenticate with the MD5 key ids
+var hostAuthLegacyKeyIDs = expvar.NewMap("hostauth_legacy_keyids")
+
+// the envelopes the HostAuth requests come in, legacy can be turned off once
+// none of the hosts use it
+var hostAuthEnvelopes = expvar.NewMap("hostauth_envelopes")
+
+// serverConfig is the part of the server that can be reloaded while it's
+// running. The requests take it once at the start, so they never see some of
+// it from before a reload and some from after
+type serverConfig struct {
+	pskStore    accord.PSKStore
+	aesgcm      *accord.AESGCM
+	certManager *accord.CertManager
+	authz       accord.Authz
+}
+
+func (s *AccordServer) current() *serverConfig {
+	return s.config.Load().(*serverConfig)
+}
+
+// Reload switches the server to the new PSKs, CAs and authz. The ones that
+// are nil stay as they are, the callers are expected to have checked the new
+// ones before handing them over
+func (s *AccordServer) Reload(pskStore accord.PSKStore, certManager *accord.CertManager, authz accord.Authz) {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+	next := *s.current()
+	if pskStore != nil {
+		next.pskStore = pskStore
+		next.aesgcm = accord.InitAESGCM(pskStore)
+	}
+	if certManager != nil {
+		next.certManager = certManager
+	}
+	if authz != nil {
+		next.authz = authz
+	}
+	s.config.Store(&next)
+}
+
+// CertManager is the one signing the certs right now
+func (s *AccordServer) CertManager() *accord.CertManager {
+	return s.current().certManager
+}
+
+// deploymentKeyId is the current key id of the deployment, the hosts that
+// haven't been moved from the MD5 key ids still send those
+func (cfg *serverConfig) deploymentKeyId(keyId uint32) uint32 {
+	if store, ok := cfg.pskStore.(accord.LegacyKeyIDStore); ok {
+		if _, current, ok := store.LegacyKeyID(keyId); ok {
+			return current
+		}
+	}
+	return keyId
+}
+
+func pskVersionKey(ref accord.PSKRef) string {
+	if ref.Unversioned {
+		return fmt.Sprintf("%d/%d/unversioned", ref.KeyId, ref.Version)
+	}
+	return fmt.Sprintf("%d/%d", ref.KeyId, ref.Version)
+}
+
+const (
+	// long enough to get all of the host keys signed
+	DefaultHostSessionTTL = 5 * time.Minute
+	// hosts usually have a key for each of rsa, ecdsa and ed25519, this leaves some room
+	DefaultHostSessionUses = 8
+	// how long the nonces of the legacy requests without a timestamp are
+	// remembered, they could be replayed after that
+	DefaultLegacyReplayWindow = 24 * time.Hour
+)
+
+// what the hosts from before the request timestamps send in the legacy envelope
+var legacyHostLogin = []byte("Host Login")
+
 func NewAccordServer(pskStore accord.PSKStore, certManager *accord.CertManager,
 	googleClientId string,
-	domain string, authz accord.Authz) *AccordServer {
-	return &AccordServer{
-		pskStore:       pskStore,
-		certManager:    certManager,
-		googleClientId: googleClientId,
-		aesgcm:         accord.InitAESGCM(pskStore),
-		domain:         domain,
-		authz:          authz,
+	domain string, authz accord.Authz, revocations db.RevocationStore,
+	sessions *accord.SessionSigner, hostSessions db.HostSessionStore) *AccordServer {
+	s := &AccordServer{
+		googleClientId:  googleClientId,
+		domain:          domain,
+		revocations:     revocations,
+		sessions:        sessions,
+		hostSessions:    hostSessions,
+		hostSessionTTL:  DefaultHostSessionTTL,
+		hostSessionUses: DefaultHostSessionUses,
+		replays:         accord.NewReplayCache(accord.DefaultMaxClockSkew, accord.DefaultReplayCacheSize),
+		legacyReplays:   accord.NewReplayCache(DefaultLegacyReplayWindow, accord.DefaultReplayCacheSize),
+		auditor:         accord.LogAuditor{},
+	}
+	s.config.Store(&serverConfig{
+		pskStore:    pskStore,
+		aesgcm:      accord.InitAESGCM(pskStore),
+		certManager: certManager,
+		authz:       authz,
+	})
+	return s
+}
+
+// SetReplayLimits changes how far the HostAuth request time can be from
+// the server time, and how many requests are remembered for each PSK
+func (s *AccordServer) SetReplayLimits(maxSkew time.Duration, maxPerKey int) {
+	s.replays = accord.NewReplayCache(maxSkew, maxPerKey)
+	s.legacyReplays = accord.NewReplayCache(DefaultLegacyReplayWindow, maxPerKey)
+}
+
+// SetHostSessionLimits changes how long the ids from HostAuth are valid
+// and how many host certs can be requested with each of them
+func (s *AccordServer) SetHostSessionLimits(ttl time.Duration, uses int) {
+	s.hostSessionTTL = ttl
+	s.hostSessionUses = uses
+}
+
+// SetLegacyHostAuth makes HostAuth accept the requests sealed with the PSK
+// directly, from the hosts that haven't moved to the versioned envelope.
+// It's deprecated, only for migrating the hosts, the legacy envelope has no
+// key separation and the nonces aren't bound to the requests. The hosts from
+// before the request timestamps are only checked for replays by the nonce,
+// for DefaultLegacyReplayWindow
+func (s *AccordServer) SetLegacyHostAuth(legacy bool) {
+	if legacy {
+		log.Printf("Deprecated: HostAuth accepts the legacy envelope sealed with the PSK directly, turn it off once hostauth_envelopes stops counting legacy")
+	}
+	s.legacyHostAuth = legacy
+}
+
+// SetHostPolicy makes HostCert verify the AWS identity document the host
+// sends and check it against the policy for the deployment of its PSK
+func (s *AccordServer) SetHostPolicy(policy *accord.HostPolicy, verifier *cloud_metadata.AWSIdentityVerifier) {
+	s.hostPolicy = policy
+	s.identityVerifier = verifier
+}
+
+// hostIdentity returns the verified identity document from the host
+// metadata, nothing else in the metadata is trusted. The document is nil when
+// there's none. A document that fails verification is an error, the host is
+// either misconfigured or lying
+func (s *AccordServer) hostIdentity(metadata []byte) (*ec2metadata.EC2InstanceIdentityDocument, error) {
+	instanceInfo := &cloud_metadata.AWSInstanceInfo{}
+	if err := json.Unmarshal(metadata, instanceInfo); err != nil || instanceInfo.IdentitySignature == "" {
+		return nil, nil
+	}
+	if s.identityVerifier == nil {
+		return nil, errors.New("No AWS certificates to verify the identity document with")
+	}
+	return s.identityVerifier.Verify(instanceInfo.IdentitySignature)
+}
+
+// peerAddr is used to record who asked for the cert
+func peerAddr(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return "unknown"
 	}
+	return p.Addr.String()
 }
 
-func replyMetadata(reqTime *google_protobuf.Timestamp) *protocol.ReplyMetadata {
+func replyMetadata(ctx context.Context, reqTime *google_protobuf.Timestamp) *protocol.ReplyMetadata {
 	return &protocol.ReplyMetadata{
 		RequestTime:  reqTime,
 		ResponseTime: ptypes.TimestampNow(),
+		RequestId:    requestId(ctx),
 	}
 }
 
@@ -51,34 +240,265 @@ func makeUUID() []byte {
 	return []byte(fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]))
 }
 
-func (s *AccordServer) HostAuth(ctx context.Context, authRequest *protocol.HostAuthRequest) (*protocol.HostAuthResponse, error) {
-	log.Println("Received host auth request")
+// hostAuthMessage is the HostAuth request out of whichever envelope it came in
+type hostAuthMessage struct {
+	decrypted []byte
+	nonce     []byte
+	sender    accord.PSKRef
+	timestamp time.Time
+	// the hosts still on AESGCM with the PSK, from before the HostAuth message
+	legacy bool
+	// the legacy hosts from before the timestamps, timestamp is zero
+	untimestamped bool
+	// seals the response so that the host can open it
+	seal func(message []byte) ([]byte, error)
+}
 
-	decrypted, nonce, sender, err := s.aesgcm.Decrypt(authRequest.AuthInfo)
+// openHostAuth opens the versioned envelope, or the legacy one when it's
+// allowed. The response to the versioned envelope is sealed with the same
+// AEAD and PSK, with a new nonce and bound to the nonce of the request
+func (s *AccordServer) openHostAuth(cfg *serverConfig, authInfo []byte) (*hostAuthMessage, error) {
+	decrypted, header, err := accord.NewEnvelope(cfg.pskStore, 0).Open(authInfo, accord.HostToServer, nil)
+	if err == nil {
+		hostAuthEnvelopes.Add("versioned", 1)
+		envelope := accord.NewEnvelope(cfg.pskStore, header.AEAD)
+		return &hostAuthMessage{
+			decrypted: decrypted,
+			nonce:     header.Nonce,
+			sender:    header.PSK,
+			timestamp: header.Timestamp,
+			seal: func(message []byte) ([]byte, error) {
+				sealed, _, err := envelope.Seal(message, header.PSK, accord.ServerToHost, header.Nonce)
+				return sealed, err
+			},
+		}, nil
+	}
+	if !s.legacyHostAuth {
+		return nil, err
+	}
+	// the key id of a legacy envelope can start with the version byte, so
+	// anything that didn't open is tried as one
+	decrypted, nonce, sender, err := cfg.aesgcm.Open(authInfo)
 	if err != nil {
-		// maybe wait until the deadline in Context and respond?
-		// to handle for timing based attacks
-		return nil, errors.Wrapf(err, "Failed to decrypt message.")
+		return nil, err
 	}
-	log.Printf("Decrypted message from host %s", string(decrypted))
+	hostAuthEnvelopes.Add("legacy", 1)
+	msg := &hostAuthMessage{
+		decrypted: decrypted,
+		nonce:     nonce,
+		sender:    sender,
+		legacy:    true,
+		// the old hosts check that the response has their nonce
+		seal: func(message []byte) ([]byte, error) {
+			return cfg.aesgcm.Seal(message, nonce, sender)
+		},
+	}
+	// the hosts from before the timestamps only ever send this
+	if bytes.Equal(decrypted, legacyHostLogin) {
+		msg.untimestamped = true
+		return msg, nil
+	}
+	msg.decrypted, msg.timestamp, err = accord.SplitTimestamp(decrypted)
+	if err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
 
-	uuid := makeUUID()
+// respondHostAuth seals the HostAuth for the host. The hosts on the legacy
+// envelope only know the bare id, they get the errors from gRPC instead
+func (s *AccordServer) respondHostAuth(ctx context.Context, authRequest *protocol.HostAuthRequest, msg *hostAuthMessage, auth *protocol.HostAuth) (*protocol.HostAuthResponse, error) {
+	plaintext := auth.Id
+	if msg.legacy {
+		if len(auth.Errors) > 0 {
+			return nil, errors.Errorf("%s: %s", auth.Errors[0].Type, auth.Errors[0].Msg)
+		}
+	} else {
+		var err error
+		plaintext, err = proto.Marshal(auth)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to serialize the response")
+		}
+	}
 	// we have already established the server connection, no need for a new ID
 	// additionally we can have a well known server key known by every client
 	// but I don't see a lot of gain there
 	// this will be going over an already-encrypted connection too
-	encrypted, err := s.aesgcm.EncryptWithNonce(uuid, nonce, sender)
+	// in the same envelope and with the same PSK version the host used, it
+	// might not know any other
+	encrypted, err := msg.seal(plaintext)
 	if err != nil {
-		return nil, errors.Wrapf(err, "Failed to encrypt with the nonce from client")
+		return nil, errors.Wrapf(err, "Failed to encrypt the response")
 	}
 	return &protocol.HostAuthResponse{
-		Metadata:     replyMetadata(authRequest.GetRequestTime()),
+		Metadata:     replyMetadata(ctx, authRequest.GetRequestTime()),
 		AuthResponse: encrypted,
 	}, nil
 }
 
-func (s *AccordServer) HostCert(ctx context.Context, certRequest *protocol.HostCertRequest) (*protocol.HostCertResponse, error) {
-	// TODO: validate the host ID properly
+func (s *AccordServer) HostAuth(ctx context.Context, authRequest *protocol.HostAuthRequest) (*protocol.HostAuthResponse, error) {
+	log.Println("Received host auth request")
+	cfg := s.current()
+
+	msg, err := s.openHostAuth(cfg, authRequest.AuthInfo)
+	if err != nil {
+		// maybe wait until the deadline in Context and respond?
+		// to handle for timing based attacks
+		rejectHostAuth("decrypt")
+		s.audit(ctx, &accord.AuditEvent{
+			Type:    accord.AuditHostAuth,
+			Actor:   "unknown host",
+			Error:   err.Error(),
+			Details: map[string]string{"reason": "decrypt"},
+		})
+		return nil, errors.Wrapf(err, "Failed to decrypt message.")
+	}
+	hostAuthEvent := func(err error, details map[string]string) *accord.AuditEvent {
+		details["key_id"] = strconv.FormatUint(uint64(msg.sender.KeyId), 10)
+		details["psk_version"] = strconv.FormatUint(uint64(msg.sender.Version), 10)
+		details["legacy_envelope"] = strconv.FormatBool(msg.legacy)
+		event := &accord.AuditEvent{
+			Type:    accord.AuditHostAuth,
+			Actor:   fmt.Sprintf("deployment %d", cfg.deploymentKeyId(msg.sender.KeyId)),
+			Details: details,
+		}
+		if err != nil {
+			event.Error = err.Error()
+		}
+		return event
+	}
+	// past this point the host has the PSK, it's told what went wrong in
+	// the encrypted response
+	reject := func(reason string, err error) (*protocol.HostAuthResponse, error) {
+		rejectHostAuth(reason)
+		log.Printf("Rejected host auth request from %s for key %d. %s", peerAddr(ctx), msg.sender.KeyId, err)
+		s.audit(ctx, hostAuthEvent(err, map[string]string{"reason": reason}))
+		return s.respondHostAuth(ctx, authRequest, msg, &protocol.HostAuth{
+			Errors: []*protocol.Error{{Type: reason, Msg: err.Error()}},
+		})
+	}
+	decrypted, nonce, sender, timestamp := msg.decrypted, msg.nonce, msg.sender, msg.timestamp
+	hostAuthPSKVersions.Add(pskVersionKey(sender), 1)
+	if msg.untimestamped {
+		// there's no request time to go by, the nonce is taken as sent now
+		err = s.legacyReplays.Check(sender.KeyId, nonce, time.Now(), time.Now())
+	} else {
+		err = s.replays.Check(sender.KeyId, nonce, timestamp, time.Now())
+	}
+	if err != nil {
+		switch err {
+		case accord.ErrClockSkew:
+			return reject("skew", errors.Wrapf(err, "Sent at %s, the server time is %s", timestamp, time.Now()))
+		case accord.ErrReplay:
+			return reject("replay", err)
+		default:
+			return reject("cache_full", err)
+		}
+	}
+	log.Printf("Decrypted message from host %s", string(decrypted))
+	if store, ok := cfg.pskStore.(accord.LegacyKeyIDStore); ok {
+		if deployment, _, ok := store.LegacyKeyID(sender.KeyId); ok {
+			hostAuthLegacyKeyIDs.Add(deployment, 1)
+			log.Printf("Host %s of deployment %s authenticated with the MD5 key id %d", peerAddr(ctx), deployment, sender.KeyId)
+		}
+	}
+
+	auth := &protocol.HostAuth{}
+	// the host finds out now rather than on every cert request
+	if s.hostPolicy != nil {
+		keyId := cfg.deploymentKeyId(sender.KeyId)
+		policy, ok := s.hostPolicy.Deployments[keyId]
+		if !ok && s.hostPolicy.Strict {
+			return reject("policy", errors.Wrapf(accord.ErrUnknownDeployment, "Key id %d", keyId))
+		}
+		if ok {
+			auth.HostnamePolicy = &protocol.HostnamePolicy{
+				Principals:       policy.Principals,
+				HostnamePatterns: policy.HostnamePatterns,
+			}
+		}
+	}
+	maxValidity, err := cfg.certManager.MaxHostCertValidity()
+	if err != nil {
+		return reject("no_active_ca", err)
+	}
+	auth.MaxCertValiditySeconds = int64(maxValidity / time.Second)
+
+	uuid := makeUUID()
+	now := time.Now()
+	session := &db.HostSession{
+		Id:        string(uuid),
+		KeyId:     sender.KeyId,
+		UsesLeft:  s.hostSessionUses,
+		Requester: peerAddr(ctx),
+		IssuedAt:  now,
+		ExpiresAt: now.Add(s.hostSessionTTL),
+	}
+	err = s.hostSessions.AddHostSession(session)
+	if err != nil {
+		log.Printf("Failed to save the host session. %s", err)
+		return reject("session", errors.New("Failed to save the host session"))
+	}
+	auth.Id = uuid
+	auth.UsesLeft = int32(session.UsesLeft)
+	auth.ExpiresAt, _ = ptypes.TimestampProto(session.ExpiresAt)
+	s.audit(ctx, hostAuthEvent(nil, map[string]string{
+		"session_id": session.Id,
+		"expires_at": session.ExpiresAt.Format(time.RFC3339),
+	}))
+	return s.respondHostAuth(ctx, authRequest, msg, auth)
+}
+
+func (s *AccordServer) HostCert(ctx context.Context, certRequest *protocol.HostCertRequest) (resp *protocol.HostCertResponse, err error) {
+	cfg := s.current()
+	event := &accord.AuditEvent{
+		Type:  accord.AuditHostCert,
+		Actor: fmt.Sprintf("deployment %d", cfg.deploymentKeyId(certRequest.KeyId)),
+		Details: map[string]string{
+			"session_id": string(certRequest.Id),
+			"hostnames":  strings.Join(certRequest.Hostnames, ","),
+		},
+	}
+	defer func() {
+		if err != nil {
+			event.Error = err.Error()
+		}
+		s.audit(ctx, event)
+	}()
+	// only hosts that have gone through HostAuth with a PSK get certs
+	session, err := s.hostSessions.UseHostSession(string(certRequest.Id), certRequest.KeyId, time.Now())
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to validate the host session")
+	}
+	keyId := cfg.deploymentKeyId(session.KeyId)
+	principals := certRequest.Hostnames
+	var dropped []string
+	if s.hostPolicy != nil {
+		identity, err := s.hostIdentity(certRequest.HostMetadata)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to verify the host identity")
+		}
+		if err := s.hostPolicy.Check(keyId, identity); err != nil {
+			authzDeniedRequests.WithLabelValues("host").Inc()
+			return nil, errors.Wrapf(err, "Host isn't allowed in the deployment")
+		}
+		// a session id that leaked can't be used by another instance
+		if identity != nil {
+			if err := s.hostSessions.BindHostSession(session.Id, identity.InstanceID); err != nil {
+				return nil, errors.Wrapf(err, "Failed to validate the host session")
+			}
+		}
+		principals, dropped, err = s.hostPolicy.CheckPrincipals(keyId, certRequest.Hostnames,
+			cloud_metadata.HostNames(identity))
+		if len(dropped) > 0 {
+			authzDeniedPrincipals.WithLabelValues("host").Add(float64(len(dropped)))
+			log.Printf("Dropped hostnames %v requested by %s for key %d", dropped, peerAddr(ctx), keyId)
+		}
+		if err != nil {
+			authzDeniedRequests.WithLabelValues("host").Inc()
+			return nil, errors.Wrapf(err, "Host isn't allowed the hostnames")
+		}
+	}
 	validFrom, _ := ptypes.Timestamp(certRequest.ValidFrom)
 	validUntil, _ := ptypes.Timestamp(certRequest.ValidUntil)
 	srq := &accord.CertSignRequest{
@@ -86,22 +506,42 @@ func (s *AccordServer) HostCert(ctx context.Context, certRequest *protocol.HostC
 		ValidFrom:  validFrom,
 		ValidUntil: validUntil,
 		Id:         string(certRequest.Id),
-		Serial:     1,
-		Principals: certRequest.Hostnames,
+		Principals: principals,
+		Requester:  peerAddr(ctx),
+		Deployment: strconv.FormatUint(uint64(keyId), 10),
 	}
-	hostCert, err := s.certManager.SignHostCert(srq)
+	event.Details["principals"] = strings.Join(principals, ",")
+	event.Details["dropped_hostnames"] = strings.Join(dropped, ",")
+	hostCert, err := cfg.certManager.SignHostCert(srq)
 	if err != nil {
 		return &protocol.HostCertResponse{
-			Metadata: replyMetadata(certRequest.GetRequestTime()),
-		}, errors.Wrapf(err, "Failed to sign host cert for hostnames: %s", certRequest.Hostnames)
+			Metadata: replyMetadata(ctx, certRequest.GetRequestTime()),
+		}, errors.Wrapf(err, "Failed to sign host cert for hostnames: %s", principals)
 	}
+	event.Serial = certSerial(hostCert)
+	event.Details["valid_until"] = validUntil.Format(time.RFC3339)
 	return &protocol.HostCertResponse{
-		Metadata: replyMetadata(certRequest.GetRequestTime()),
-		HostCert: hostCert,
+		Metadata:         replyMetadata(ctx, certRequest.GetRequestTime()),
+		HostCert:         hostCert,
+		DroppedHostnames: dropped,
 	}, nil
 }
 
-func (s *AccordServer) UserAuth(ctx context.Context, userAuthRequest *protocol.UserAuthRequest) (*protocol.UserAuthResponse, error) {
+func (s *AccordServer) UserAuth(ctx context.Context, userAuthRequest *protocol.UserAuthRequest) (resp *protocol.UserAuthResponse, err error) {
+	event := &accord.AuditEvent{
+		Type:    accord.AuditUserAuth,
+		Actor:   userAuthRequest.GetUsername(),
+		Details: map[string]string{"username": userAuthRequest.GetUsername()},
+	}
+	defer func() {
+		switch {
+		case err != nil:
+			event.Error = err.Error()
+		case !resp.Valid:
+			event.Error = "The token isn't valid"
+		}
+		s.audit(ctx, event)
+	}()
 	oauthToken, err := accord.OAuth2Token(userAuthRequest.Token)
 	if err != nil {
 		return nil, errors.Wrapf(err, "Cannot convert pb token to *oauth2.Token")
@@ -116,75 +556,314 @@ func (s *AccordServer) UserAuth(ctx context.Context, userAuthRequest *protocol.U
 	if err != nil {
 		return nil, errors.Wrapf(err, "Failed to validate token")
 	}
+	if email != "" {
+		event.Actor = email
+	}
+	if valid {
+		if err := s.checkEnabled(email); err != nil {
+			return nil, err
+		}
+	}
+	if !valid {
+		return &protocol.UserAuthResponse{
+			Metadata: replyMetadata(ctx, userAuthRequest.GetRequestTime()),
+			UserId:   email,
+		}, nil
+	}
 	log.Printf("Valid user: %s email: %s", userAuthRequest.GetUsername(), email)
+	token, session, err := s.sessions.Issue(email, userAuthRequest.GetUsername())
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to create a session for %s", email)
+	}
+	expiry, err := ptypes.TimestampProto(session.ExpiresAt)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Invalid session expiry")
+	}
 	return &protocol.UserAuthResponse{
-		UserId: email,
-		Valid:  valid,
+		Metadata:      replyMetadata(ctx, userAuthRequest.GetRequestTime()),
+		Username:      userAuthRequest.GetUsername(),
+		UserId:        email,
+		Valid:         valid,
+		AuthResponse:  token,
+		SessionExpiry: expiry,
 	}, nil
 }
 
-func (s *AccordServer) UserCert(ctx context.Context, certRequest *protocol.UserCertRequest) (*protocol.UserCertResponse, error) {
+// SetUserStore makes the server turn away the users that were disabled
+func (s *AccordServer) SetUserStore(users db.UserStore) {
+	s.users = users
+}
+
+// checkEnabled fails for the disabled users, or when it can't tell
+func (s *AccordServer) checkEnabled(email string) error {
+	if s.users == nil {
+		return nil
+	}
+	disabled, err := s.users.DisabledUser(email)
+	if err != nil {
+		return err
+	}
+	if disabled != nil {
+		return errors.Errorf("User %s is disabled, talk to your administrator", email)
+	}
+	return nil
+}
+
+// verifySession also checks the user wasn't disabled since the session was
+// issued
+func (s *AccordServer) verifySession(token []byte) (*accord.UserSession, error) {
+	session, err := s.sessions.Verify(token)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed authentication")
+	}
+	if err := s.checkEnabled(session.Email); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// userPermissions is what the authz allows for the user narrowed down
+// to what they asked for
+func (s *AccordServer) userPermissions(cfg *serverConfig, email string, certRequest *protocol.UserCertRequest, principals []string) (accord.CertPermissions, error) {
+	allowed, err := cfg.authz.Permissions(email, principals)
+	if err != nil {
+		return allowed, errors.Wrapf(err, "Failed to get the permissions for %s", email)
+	}
+	if len(certRequest.ForceCommands) > 1 {
+		return allowed, errors.Errorf("Only one force command can be in a cert, got %d", len(certRequest.ForceCommands))
+	}
+	requested := accord.CertPermissions{
+		SourceAddresses: certRequest.SourceAddresses,
+	}
+	if len(certRequest.ForceCommands) == 1 {
+		requested.ForceCommand = certRequest.ForceCommands[0]
+	}
+	if certRequest.RestrictExtensions {
+		requested.Extensions = append([]string{}, certRequest.Extensions...)
+	}
+	permissions, err := allowed.Narrow(requested)
+	if err != nil {
+		return permissions, errors.Wrapf(err, "Permissions requested for %s aren't allowed", email)
+	}
+	return permissions, nil
+}
+
+func (s *AccordServer) UserCert(ctx context.Context, certRequest *protocol.UserCertRequest) (resp *protocol.UserCertResponse, err error) {
+	cfg := s.current()
 
 	validFrom, _ := ptypes.Timestamp(certRequest.ValidFrom)
 	validUntil, _ := ptypes.Timestamp(certRequest.ValidUntil)
 
-	authorizedPrincipals, err := s.authz.Authorized(certRequest.UserId, certRequest.AuthorizedPrincipals)
+	// until the session is checked all there is, is who it says it's for
+	event := &accord.AuditEvent{
+		Type:  accord.AuditUserCert,
+		Actor: certRequest.UserId,
+		Details: map[string]string{
+			"requested_principals": strings.Join(certRequest.AuthorizedPrincipals, ","),
+			"valid_until":          validUntil.Format(time.RFC3339),
+		},
+	}
+	// everything before signing that fails is a denial
+	signing := false
+	defer func() {
+		if err != nil {
+			if !signing {
+				event.Type = accord.AuditUserCertDenied
+			}
+			event.Error = err.Error()
+		}
+		s.audit(ctx, event)
+	}()
+
+	// the identity only comes from the session, the userId in the request
+	// is just checked so that a confused client finds out
+	session, err := s.verifySession(certRequest.Session)
 	if err != nil {
+		return nil, err
+	}
+	event.Actor = session.Email
+	if certRequest.UserId != "" && certRequest.UserId != session.Email {
+		return nil, errors.Errorf("The session is for %s, not %s", session.Email, certRequest.UserId)
+	}
+
+	authorizedPrincipals, denied, err := accord.Grant(cfg.authz, session.Email, certRequest.AuthorizedPrincipals)
+	authzEvent := &accord.AuditEvent{
+		Type:  accord.AuditAuthz,
+		Actor: session.Email,
+		Details: map[string]string{
+			"requested": strings.Join(certRequest.AuthorizedPrincipals, ","),
+			"granted":   strings.Join(authorizedPrincipals, ","),
+			"denied":    accord.FormatDenials(denied),
+		},
+	}
+	if err != nil {
+		authzEvent.Error = err.Error()
+	}
+	s.audit(ctx, authzEvent)
+	authzDeniedPrincipals.WithLabelValues("user").Add(float64(len(denied)))
+	if err != nil {
+		authzDeniedRequests.WithLabelValues("user").Inc()
 		return nil, errors.Wrapf(err, "Failed authorization")
 	}
+	if len(denied) > 0 {
+		log.Printf("Denied %s the principals %s", session.Email, accord.FormatDenials(denied))
+	}
+	deniedPrincipals := []*protocol.DeniedPrincipal{}
+	for _, d := range denied {
+		deniedPrincipals = append(deniedPrincipals, &protocol.DeniedPrincipal{Principal: d.Principal, Reason: d.Reason})
+	}
+
+	permissions, err := s.userPermissions(cfg, session.Email, certRequest, authorizedPrincipals)
+	if err != nil {
+		return nil, err
+	}
+	// counted from now too, the certs with elevated principals can't
+	// outlive the elevation by starting later
+	validity := validUntil.Sub(validFrom)
+	if fromNow := time.Until(validUntil); fromNow > validity {
+		validity = fromNow
+	}
+	if err := permissions.CheckValidity(validity); err != nil {
+		return nil, errors.Wrapf(err, "Cert validity for %s isn't allowed", session.Email)
+	}
+
+	keyId := session.Username
+	if keyId == "" {
+		keyId = certRequest.Username
+	}
 
-	// TODO: increase serial every time user requests for a certificate
-	// this requires some way to keep track of state, so shelving until
-	// the project gets a database of some sort
+	// the serial is assigned by the ledger in the certManager
 	srq := &accord.CertSignRequest{
-		PubKey:     certRequest.PublicKey,
-		ValidFrom:  validFrom,
-		ValidUntil: validUntil,
-		Id:         certRequest.Username,
-		Serial:     1,
-		Principals: authorizedPrincipals,
+		PubKey:      certRequest.PublicKey,
+		ValidFrom:   validFrom,
+		ValidUntil:  validUntil,
+		Id:          keyId,
+		Principals:  authorizedPrincipals,
+		Permissions: permissions.SSHPermissions(),
+		Requester:   peerAddr(ctx),
+		Email:       session.Email,
 	}
 
-	userCert, err := s.certManager.SignUserCert(srq)
+	event.Details["principals"] = strings.Join(authorizedPrincipals, ",")
+	event.Details["key_id"] = keyId
+	event.Details["force_command"] = permissions.ForceCommand
+	event.Details["source_addresses"] = strings.Join(permissions.SourceAddresses, ",")
+	signing = true
+	userCert, err := cfg.certManager.SignUserCert(srq)
 	if err != nil {
 		return &protocol.UserCertResponse{
-			Metadata: replyMetadata(certRequest.GetRequestTime()),
-		}, errors.Wrapf(err, "Failed to sign user cert for %s", certRequest.Username)
+			Metadata: replyMetadata(ctx, certRequest.GetRequestTime()),
+		}, errors.Wrapf(err, "Failed to sign user cert for %s", keyId)
 	}
+	event.Serial = certSerial(userCert)
 	return &protocol.UserCertResponse{
-		Metadata: replyMetadata(certRequest.GetRequestTime()),
-		UserCert: userCert,
+		Metadata:          replyMetadata(ctx, certRequest.GetRequestTime()),
+		UserCert:          userCert,
+		GrantedPrincipals: authorizedPrincipals,
+		DeniedPrincipals:  deniedPrincipals,
 	}, nil
 }
 
-// This doesn't populate the RevokedCerts yet
+// revokedCAs returns the CA revocations keyed by the marshaled public key
+// so that they can be matched against the CAs the certManager has
+func (s *AccordServer) revokedCAs() (map[string]*db.Revocation, error) {
+	revocations, _, err := s.revocations.Revocations()
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to read the revocations")
+	}
+	revoked := make(map[string]*db.Revocation)
+	for _, r := range revocations {
+		if r.Type != db.RevokeCA {
+			continue
+		}
+		key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(r.Value))
+		if err != nil {
+			return nil, errors.Wrapf(err, "Invalid public key for revoked CA")
+		}
+		revoked[string(key.Marshal())] = r
+	}
+	return revoked, nil
+}
+
+func isRevoked(revoked map[string]*db.Revocation, publicKey []byte) bool {
+	key, _, _, _, err := ssh.ParseAuthorizedKey(publicKey)
+	if err != nil {
+		// if we can't tell, don't ask anyone to trust it
+		return true
+	}
+	_, ok := revoked[string(key.Marshal())]
+	return ok
+}
+
 // TODO: try to use same data structure
 func (s *AccordServer) PublicTrustedCA(ctx context.Context, trustedCARequest *protocol.PublicTrustedCARequest) (*protocol.PublicTrustedCAResponse, error) {
-	hostCAs := s.certManager.HostCAs()
-	userCAs := s.certManager.UserCAs()
+	cfg := s.current()
+	hostCAs := cfg.certManager.HostCAs()
+	userCAs := cfg.certManager.UserCAs()
+
+	revoked, err := s.revokedCAs()
+	if err != nil {
+		return nil, err
+	}
 
 	pbHostCAs := []*protocol.HostCA{}
 	pbUserCAs := []*protocol.UserCA{}
+	pbRevokedHostCAs := []*protocol.HostCA{}
+	pbRevokedUserCAs := []*protocol.UserCA{}
 
 	for _, h := range hostCAs {
-		pbHostCAs = append(pbHostCAs, accord.ToHostCA(h))
+		if !isRevoked(revoked, h.PublicKey) {
+			pbHostCAs = append(pbHostCAs, accord.ToHostCA(h))
+		}
 	}
 
 	for _, u := range userCAs {
-		pbUserCAs = append(pbUserCAs, accord.ToUserCA(u))
+		if !isRevoked(revoked, u.PublicKey) {
+			pbUserCAs = append(pbUserCAs, accord.ToUserCA(u))
+		}
+	}
+
+	// the revoked CAs may not be loaded anymore, all we have is the public key
+	for _, r := range revoked {
+		switch r.CAType {
+		case db.HostCertType:
+			pbRevokedHostCAs = append(pbRevokedHostCAs, &protocol.HostCA{PublicKey: []byte(r.Value)})
+		case db.UserCertType:
+			pbRevokedUserCAs = append(pbRevokedUserCAs, &protocol.UserCA{PublicKey: []byte(r.Value)})
+		}
 	}
 
 	return &protocol.PublicTrustedCAResponse{
-		Metadata: replyMetadata(trustedCARequest.GetRequestTime()),
-		HostCAs:  pbHostCAs,
-		UserCAs:  pbUserCAs,
+		Metadata:       replyMetadata(ctx, trustedCARequest.GetRequestTime()),
+		HostCAs:        pbHostCAs,
+		UserCAs:        pbUserCAs,
+		RevokedHostCAs: pbRevokedHostCAs,
+		RevokedUserCAs: pbRevokedUserCAs,
 	}, nil
 
 }
 
+func (s *AccordServer) RevokedKeys(ctx context.Context, req *protocol.RevokedKeysRequest) (*protocol.RevokedKeysResponse, error) {
+	cfg := s.current()
+	revocations, version, err := s.revocations.Revocations()
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to read the revocations")
+	}
+	caKeys := append(cfg.certManager.RootCAPublicKeys(), cfg.certManager.UserCAPublicKeys()...)
+	krl, err := accord.NewKRL(revocations, version, caKeys)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to compile the KRL")
+	}
+	return &protocol.RevokedKeysResponse{
+		Metadata: replyMetadata(ctx, req.GetRequestTime()),
+		Version:  version,
+		Krl:      krl.Marshal(),
+	}, nil
+}
+
 func (s *AccordServer) Ping(ctx context.Context, req *protocol.PingRequest) (*protocol.PingResponse, error) {
 	return &protocol.PingResponse{
-		Metadata: replyMetadata(req.GetRequestTime()),
+		Metadata: replyMetadata(ctx, req.GetRequestTime()),
 		Message:  "Hello " + req.GetName(),
 	}, nil
 }
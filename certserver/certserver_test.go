@@ -0,0 +1,462 @@
+package certserver
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/mistsys/accord"
+	"github.com/mistsys/accord/cloud_metadata"
+	"github.com/mistsys/accord/db"
+	"github.com/mistsys/accord/protocol"
+	"github.com/pkg/errors"
+	"go.mozilla.org/pkcs7"
+	"golang.org/x/crypto/ssh"
+)
+
+const testKeyId = 912090709
+
+// fakePSKStore has a single unversioned PSK for each key id
+type fakePSKStore map[uint32][]byte
+
+func (f fakePSKStore) GetPSK(key []byte) ([]byte, error) {
+	if len(key) != 4 {
+		return nil, errors.New("Only unversioned key ids")
+	}
+	psk, ok := f[binary.BigEndian.Uint32(key)]
+	if !ok {
+		return nil, accord.ErrKeyNotFound
+	}
+	return psk, nil
+}
+
+type testServer struct {
+	*AccordServer
+	store      *db.BoltStore
+	sessionKey []byte
+	psks       fakePSKStore
+}
+
+func newTestServer(t *testing.T) (*testServer, func()) {
+	dir, err := ioutil.TempDir("", "accord-certserver")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %s", err)
+	}
+	store, err := db.NewBoltStore(filepath.Join(dir, "accord.db"))
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("Failed to open bolt store: %s", err)
+	}
+	cleanup := func() {
+		store.Close()
+		os.RemoveAll(dir)
+	}
+	certManager, err := accord.NewCertManagerWithPasswords("../test_assets/root_ca_20170927", "staple horse apple newton",
+		"../test_assets/user_ca_20170927", "staple horse apple thatcher")
+	if err != nil {
+		cleanup()
+		t.Fatalf("Failed to create cert manager: %s", err)
+	}
+	certManager.SetLedger(store)
+	sessionKey := []byte("0123456789abcdef0123456789abcdef")
+	sessions, err := accord.NewSessionSigner(sessionKey, time.Minute)
+	if err != nil {
+		cleanup()
+		t.Fatalf("Failed to create the session signer: %s", err)
+	}
+	psks := fakePSKStore{
+		testKeyId: []byte(`JpUtbRukLuIFyjeKpA4fIpjgs6MTV8eH`),
+		1:         []byte(`qvFyLbJdNF6Cx0hpKzT0w3nqsTEbgvSe`),
+	}
+	s := NewAccordServer(psks, certManager, "", "", accord.GrantAll{}, store, sessions, store)
+	s.SetUserStore(store)
+	return &testServer{AccordServer: s, store: store, sessionKey: sessionKey, psks: psks}, cleanup
+}
+
+func sealHostAuth(t *testing.T, psks accord.PSKStore, keyId uint32) ([]byte, *accord.EnvelopeHeader) {
+	sealed, sent, err := accord.NewEnvelope(psks, accord.AEADAESGCM).Seal([]byte("Host Login"),
+		accord.PSKRef{KeyId: keyId, Unversioned: true}, accord.HostToServer, nil)
+	if err != nil {
+		t.Fatalf("Failed to seal the host auth: %s", err)
+	}
+	return sealed, sent
+}
+
+// sendHostAuth returns what the server sealed in the response
+func (s *testServer) sendHostAuth(t *testing.T, sealed []byte, sent *accord.EnvelopeHeader) *protocol.HostAuth {
+	resp, err := s.HostAuth(context.Background(), &protocol.HostAuthRequest{
+		RequestTime: ptypes.TimestampNow(),
+		AuthInfo:    sealed,
+	})
+	if err != nil {
+		t.Fatalf("AccordServer.HostAuth() error = %v", err)
+	}
+	plaintext, _, err := accord.NewEnvelope(s.psks, 0).Open(resp.AuthResponse, accord.ServerToHost, sent.Nonce)
+	if err != nil {
+		t.Fatalf("Failed to open the host auth response: %s", err)
+	}
+	auth := &protocol.HostAuth{}
+	if err := proto.Unmarshal(plaintext, auth); err != nil {
+		t.Fatalf("Failed to parse the host auth response: %s", err)
+	}
+	return auth
+}
+
+// hostSession authenticates as a host of the deployment
+func (s *testServer) hostSession(t *testing.T, keyId uint32) []byte {
+	sealed, sent := sealHostAuth(t, s.psks, keyId)
+	auth := s.sendHostAuth(t, sealed, sent)
+	if len(auth.Errors) > 0 || len(auth.Id) == 0 {
+		t.Fatalf("AccordServer.HostAuth() failed: %v", auth.Errors)
+	}
+	return auth.Id
+}
+
+func testHostCertRequest(t *testing.T, id []byte, keyId uint32, hostnames []string, metadata []byte) *protocol.HostCertRequest {
+	pubKey, err := ioutil.ReadFile("../test_assets/test_pubkeys/test_hostkey.pub")
+	if err != nil {
+		t.Fatalf("Failed to read the public key: %s", err)
+	}
+	validFrom, _ := ptypes.TimestampProto(time.Now().Add(10 * time.Second))
+	validUntil, _ := ptypes.TimestampProto(time.Now().Add(time.Hour))
+	return &protocol.HostCertRequest{
+		RequestTime:  ptypes.TimestampNow(),
+		ValidFrom:    validFrom,
+		ValidUntil:   validUntil,
+		Id:           id,
+		KeyId:        keyId,
+		Hostnames:    hostnames,
+		PublicKey:    pubKey,
+		HostMetadata: metadata,
+	}
+}
+
+func parseCert(t *testing.T, signed []byte) *ssh.Certificate {
+	key, _, _, _, err := ssh.ParseAuthorizedKey(signed)
+	if err != nil {
+		t.Fatalf("Failed to parse the signed cert: %s", err)
+	}
+	return key.(*ssh.Certificate)
+}
+
+func TestAccordServer_HostAuthReplay(t *testing.T) {
+	s, cleanup := newTestServer(t)
+	defer cleanup()
+
+	sealed, sent := sealHostAuth(t, s.psks, testKeyId)
+	if auth := s.sendHostAuth(t, sealed, sent); len(auth.Errors) > 0 || len(auth.Id) == 0 {
+		t.Fatalf("AccordServer.HostAuth() failed the first time: %v", auth.Errors)
+	}
+	auth := s.sendHostAuth(t, sealed, sent)
+	if len(auth.Errors) != 1 || auth.Errors[0].Type != "replay" || len(auth.Id) != 0 {
+		t.Errorf("AccordServer.HostAuth() = %v for a replayed request, want a replay error", auth)
+	}
+}
+
+func TestAccordServer_HostAuthClockSkew(t *testing.T) {
+	s, cleanup := newTestServer(t)
+	defer cleanup()
+	s.SetReplayLimits(50*time.Millisecond, accord.DefaultReplayCacheSize)
+
+	sealed, sent := sealHostAuth(t, s.psks, testKeyId)
+	time.Sleep(100 * time.Millisecond)
+	auth := s.sendHostAuth(t, sealed, sent)
+	if len(auth.Errors) != 1 || auth.Errors[0].Type != "skew" || len(auth.Id) != 0 {
+		t.Errorf("AccordServer.HostAuth() = %v for a stale request, want a skew error", auth)
+	}
+}
+
+func TestAccordServer_HostAuthLegacy(t *testing.T) {
+	s, cleanup := newTestServer(t)
+	defer cleanup()
+	aesgcm := accord.InitAESGCM(s.psks)
+	nonce := make([]byte, 12)
+
+	tests := []struct {
+		name string
+		seal func() ([]byte, error)
+	}{
+		// what the hosts sent before the request timestamps
+		{"baseline", func() ([]byte, error) {
+			return aesgcm.EncryptWithNonce([]byte("Host Login"), nonce, testKeyId)
+		}},
+		{"timestamped", func() ([]byte, error) {
+			return aesgcm.EncryptWithTimestamp([]byte("Host Login"), nonce, time.Now(), accord.PSKRef{KeyId: testKeyId, Unversioned: true})
+		}},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nonce[0] = byte(i)
+			sealed, err := tt.seal()
+			if err != nil {
+				t.Fatalf("Failed to seal the host auth: %s", err)
+			}
+			req := &protocol.HostAuthRequest{RequestTime: ptypes.TimestampNow(), AuthInfo: sealed}
+
+			s.SetLegacyHostAuth(false)
+			if _, err := s.HostAuth(context.Background(), req); err == nil {
+				t.Errorf("AccordServer.HostAuth() error = nil for the legacy envelope with it turned off")
+			}
+
+			s.SetLegacyHostAuth(true)
+			resp, err := s.HostAuth(context.Background(), req)
+			if err != nil {
+				t.Fatalf("AccordServer.HostAuth() error = %v", err)
+			}
+			id, gotNonce, sender, err := aesgcm.Decrypt(resp.AuthResponse)
+			if err != nil {
+				t.Fatalf("Failed to decrypt the host auth response: %s", err)
+			}
+			if !bytes.Equal(gotNonce, nonce) || sender != testKeyId {
+				t.Errorf("AccordServer.HostAuth() responded with nonce %x from %d, want %x from %d", gotNonce, sender, nonce, testKeyId)
+			}
+			if _, err := s.HostCert(context.Background(), testHostCertRequest(t, id, testKeyId, []string{"host.example.com"}, nil)); err != nil {
+				t.Errorf("AccordServer.HostCert() error = %v with the legacy session", err)
+			}
+
+			if _, err := s.HostAuth(context.Background(), req); err == nil || !strings.Contains(err.Error(), "replay") {
+				t.Errorf("AccordServer.HostAuth() error = %v for a replayed request, want a replay error", err)
+			}
+		})
+	}
+}
+
+func TestAccordServer_HostCertSession(t *testing.T) {
+	s, cleanup := newTestServer(t)
+	defer cleanup()
+	s.SetHostSessionLimits(time.Minute, 1)
+
+	hostnames := []string{"host.example.com"}
+	used := s.hostSession(t, testKeyId)
+	if _, err := s.HostCert(context.Background(), testHostCertRequest(t, used, testKeyId, hostnames, nil)); err != nil {
+		t.Fatalf("AccordServer.HostCert() error = %v", err)
+	}
+	otherDeployment := s.hostSession(t, testKeyId)
+	// added last, issuing a session prunes the expired ones
+	now := time.Now()
+	err := s.store.AddHostSession(&db.HostSession{
+		Id:        "expired",
+		KeyId:     testKeyId,
+		UsesLeft:  1,
+		IssuedAt:  now.Add(-time.Hour),
+		ExpiresAt: now.Add(-time.Minute),
+	})
+	if err != nil {
+		t.Fatalf("BoltStore.AddHostSession() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		id      []byte
+		keyId   uint32
+		wantErr error
+	}{
+		{"unknown session", []byte("not-a-session"), testKeyId, db.ErrHostSessionNotFound},
+		{"expired session", []byte("expired"), testKeyId, db.ErrHostSessionExpired},
+		{"used session", used, testKeyId, db.ErrHostSessionUsedUp},
+		{"another deployment", otherDeployment, 1, db.ErrHostSessionWrongKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := s.HostCert(context.Background(), testHostCertRequest(t, tt.id, tt.keyId, hostnames, nil))
+			if errors.Cause(err) != tt.wantErr {
+				t.Errorf("AccordServer.HostCert() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(resp.GetHostCert()) > 0 {
+				t.Errorf("AccordServer.HostCert() signed a cert for a bad session")
+			}
+		})
+	}
+}
+
+// a self signed cert standing in for the one AWS publishes
+func testAWSCert(t *testing.T) (*x509.Certificate, *rsa.PrivateKey, []byte) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %s", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "aws"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create cert: %s", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Failed to parse cert: %s", err)
+	}
+	return cert, key, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+// testHostMetadata is what the client sends, the identity document signed
+// and the public names as the host claims them
+func testHostMetadata(t *testing.T, cert *x509.Certificate, key *rsa.PrivateKey, instanceId string, info cloud_metadata.AWSInstanceInfo) []byte {
+	document, err := json.Marshal(map[string]string{
+		"accountId":  "123456789012",
+		"region":     "us-west-2",
+		"instanceId": instanceId,
+		"privateIp":  "10.0.1.2",
+	})
+	if err != nil {
+		t.Fatalf("Failed to marshal the identity document: %s", err)
+	}
+	signedData, err := pkcs7.NewSignedData(document)
+	if err != nil {
+		t.Fatalf("Failed to create signed data: %s", err)
+	}
+	if err := signedData.AddSigner(cert, key, pkcs7.SignerInfoConfig{}); err != nil {
+		t.Fatalf("Failed to add signer: %s", err)
+	}
+	der, err := signedData.Finish()
+	if err != nil {
+		t.Fatalf("Failed to sign: %s", err)
+	}
+	info.IdentitySignature = base64.StdEncoding.EncodeToString(der)
+	metadata, err := json.Marshal(info)
+	if err != nil {
+		t.Fatalf("Failed to marshal the host metadata: %s", err)
+	}
+	return metadata
+}
+
+func TestAccordServer_HostCertIdentity(t *testing.T) {
+	s, cleanup := newTestServer(t)
+	defer cleanup()
+
+	cert, key, certPEM := testAWSCert(t)
+	verifier, err := cloud_metadata.NewAWSIdentityVerifier(certPEM)
+	if err != nil {
+		t.Fatalf("NewAWSIdentityVerifier() error = %v", err)
+	}
+	s.SetHostPolicy(&accord.HostPolicy{
+		Deployments: map[uint32]accord.DeploymentPolicy{
+			testKeyId: {AWSAccounts: []string{"123456789012"}, Principals: accord.PrincipalsStrip},
+		},
+	}, verifier)
+
+	// the public names aren't signed, the host can claim anything
+	metadata := testHostMetadata(t, cert, key, "i-1", cloud_metadata.AWSInstanceInfo{
+		PublicIPv4:     "52.1.2.3",
+		PublicHostname: "bank.example.com",
+	})
+	hostnames := []string{"10.0.1.2", "ip-10-0-1-2.us-west-2.compute.internal", "52.1.2.3", "bank.example.com"}
+	id := s.hostSession(t, testKeyId)
+	resp, err := s.HostCert(context.Background(), testHostCertRequest(t, id, testKeyId, hostnames, metadata))
+	if err != nil {
+		t.Fatalf("AccordServer.HostCert() error = %v", err)
+	}
+	if got := parseCert(t, resp.HostCert).ValidPrincipals; !reflect.DeepEqual(got, hostnames[:2]) {
+		t.Errorf("AccordServer.HostCert() principals = %v, want %v", got, hostnames[:2])
+	}
+	if !reflect.DeepEqual(resp.DroppedHostnames, hostnames[2:]) {
+		t.Errorf("AccordServer.HostCert() dropped = %v, want %v", resp.DroppedHostnames, hostnames[2:])
+	}
+
+	// the session is bound to the instance that used it first
+	other := testHostMetadata(t, cert, key, "i-2", cloud_metadata.AWSInstanceInfo{})
+	_, err = s.HostCert(context.Background(), testHostCertRequest(t, id, testKeyId, hostnames[:2], other))
+	if errors.Cause(err) != db.ErrHostSessionWrongHost {
+		t.Errorf("AccordServer.HostCert() error = %v from another instance, want %v", err, db.ErrHostSessionWrongHost)
+	}
+	if _, err := s.HostCert(context.Background(), testHostCertRequest(t, id, testKeyId, hostnames[:2], metadata)); err != nil {
+		t.Errorf("AccordServer.HostCert() error = %v from the bound instance", err)
+	}
+}
+
+func testUserCertRequest(t *testing.T, session []byte, userId string) *protocol.UserCertRequest {
+	pubKey, err := ioutil.ReadFile("../test_assets/test_pubkeys/test_hostkey.pub")
+	if err != nil {
+		t.Fatalf("Failed to read the public key: %s", err)
+	}
+	validFrom, _ := ptypes.TimestampProto(time.Now().Add(10 * time.Second))
+	validUntil, _ := ptypes.TimestampProto(time.Now().Add(time.Hour))
+	return &protocol.UserCertRequest{
+		RequestTime:          ptypes.TimestampNow(),
+		UserId:               userId,
+		Username:             "user1",
+		PublicKey:            pubKey,
+		AuthorizedPrincipals: []string{"zones-db"},
+		ValidFrom:            validFrom,
+		ValidUntil:           validUntil,
+		Session:              session,
+	}
+}
+
+func TestAccordServer_UserCertSession(t *testing.T) {
+	s, cleanup := newTestServer(t)
+	defer cleanup()
+
+	issue := func(ttl time.Duration, email string) []byte {
+		signer, err := accord.NewSessionSigner(s.sessionKey, ttl)
+		if err != nil {
+			t.Fatalf("NewSessionSigner() error = %v", err)
+		}
+		token, _, err := signer.Issue(email, "user1")
+		if err != nil {
+			t.Fatalf("SessionSigner.Issue() error = %v", err)
+		}
+		return token
+	}
+	valid := issue(time.Minute, "user1@ex.ample.com")
+	tampered := append([]byte{}, valid...)
+	tampered[5] ^= 1
+	otherKey, _ := accord.NewSessionSigner([]byte("another key that isn't the server's"), time.Minute)
+	forged, _, _ := otherKey.Issue("user1@ex.ample.com", "user1")
+	if err := s.store.DisableUser(&db.DisabledUser{Email: "user2@ex.ample.com", Reason: "left", DisabledBy: "admin"}); err != nil {
+		t.Fatalf("BoltStore.DisableUser() error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		session   []byte
+		userId    string
+		wantCause error
+		wantErr   bool
+	}{
+		{name: "valid", session: valid, userId: "user1@ex.ample.com"},
+		{name: "no session", userId: "user1@ex.ample.com", wantCause: accord.ErrNoSession, wantErr: true},
+		{name: "tampered", session: tampered, userId: "user1@ex.ample.com", wantCause: accord.ErrInvalidSession, wantErr: true},
+		{name: "another key", session: forged, userId: "user1@ex.ample.com", wantCause: accord.ErrInvalidSession, wantErr: true},
+		{name: "expired", session: issue(time.Nanosecond, "user1@ex.ample.com"), userId: "user1@ex.ample.com", wantCause: accord.ErrSessionExpired, wantErr: true},
+		{name: "another user", session: valid, userId: "user3@ex.ample.com", wantErr: true},
+		{name: "disabled user", session: issue(time.Minute, "user2@ex.ample.com"), userId: "user2@ex.ample.com", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := s.UserCert(context.Background(), testUserCertRequest(t, tt.session, tt.userId))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AccordServer.UserCert() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantCause != nil && errors.Cause(err) != tt.wantCause {
+				t.Errorf("AccordServer.UserCert() error = %v, want %v", err, tt.wantCause)
+			}
+			if err != nil {
+				return
+			}
+			if got := parseCert(t, resp.UserCert).KeyId; got != "user1" {
+				t.Errorf("AccordServer.UserCert() key id = %s, want the session's user1", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package certserver
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/mistsys/accord"
+	"github.com/mistsys/accord/protocol"
+	"github.com/pkg/errors"
+)
+
+// elevatedAuthz is the authz when the server has an elevation policy
+func (s *AccordServer) elevatedAuthz(cfg *serverConfig) (*accord.ElevatedAuthz, error) {
+	elevated, ok := cfg.authz.(*accord.ElevatedAuthz)
+	if !ok {
+		return nil, errors.New("This server doesn't have an elevation policy")
+	}
+	return elevated, nil
+}
+
+func elevationPb(e *accord.Elevation) *protocol.Elevation {
+	elevation := &protocol.Elevation{
+		Id:              e.Id,
+		UserId:          e.User,
+		Principals:      e.Principals,
+		Justification:   e.Justification,
+		DurationSeconds: int64(e.Duration / time.Second),
+		State:           e.State,
+		DecidedBy:       e.DecidedBy,
+		Reason:          e.Reason,
+	}
+	elevation.RequestedAt, _ = ptypes.TimestampProto(e.RequestedAt)
+	if !e.ExpiresAt.IsZero() {
+		elevation.ExpiresAt, _ = ptypes.TimestampProto(e.ExpiresAt)
+	}
+	return elevation
+}
+
+func (s *AccordServer) RequestElevation(ctx context.Context, req *protocol.ElevationRequest) (*protocol.ElevationResponse, error) {
+	cfg := s.current()
+	session, err := s.verifySession(req.Session)
+	if err != nil {
+		return nil, err
+	}
+	elevated, err := s.elevatedAuthz(cfg)
+	if err != nil {
+		return nil, err
+	}
+	elevation, err := elevated.Request(session.Email, req.Principals, req.Justification, time.Duration(req.DurationSeconds)*time.Second)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to request the elevation")
+	}
+	log.Printf("%s from %s asked for an elevation to %v", session.Email, peerAddr(ctx), elevation.Principals)
+	return &protocol.ElevationResponse{
+		Metadata:  replyMetadata(ctx, req.GetRequestTime()),
+		Elevation: elevationPb(elevation),
+	}, nil
+}
+
+func (s *AccordServer) DecideElevation(ctx context.Context, req *protocol.ElevationDecision) (*protocol.ElevationResponse, error) {
+	cfg := s.current()
+	session, err := s.verifySession(req.Session)
+	if err != nil {
+		return nil, err
+	}
+	elevated, err := s.elevatedAuthz(cfg)
+	if err != nil {
+		return nil, err
+	}
+	elevation, err := elevated.Decide(session.Email, req.Id, req.Approve, req.Reason)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to decide on the elevation")
+	}
+	log.Printf("%s from %s %s the elevation %s", session.Email, peerAddr(ctx), elevation.State, elevation.Id)
+	return &protocol.ElevationResponse{
+		Metadata:  replyMetadata(ctx, req.GetRequestTime()),
+		Elevation: elevationPb(elevation),
+	}, nil
+}
+
+func (s *AccordServer) ListElevations(ctx context.Context, req *protocol.ElevationListRequest) (*protocol.ElevationListResponse, error) {
+	cfg := s.current()
+	session, err := s.verifySession(req.Session)
+	if err != nil {
+		return nil, err
+	}
+	elevated, err := s.elevatedAuthz(cfg)
+	if err != nil {
+		return nil, err
+	}
+	resp := &protocol.ElevationListResponse{
+		Metadata: replyMetadata(ctx, req.GetRequestTime()),
+	}
+	for _, elevation := range elevated.List(session.Email) {
+		resp.Elevations = append(resp.Elevations, elevationPb(elevation))
+	}
+	return resp, nil
+}
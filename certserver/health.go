@@ -0,0 +1,83 @@
+package certserver
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mistsys/accord"
+	"github.com/pkg/errors"
+)
+
+// HealthCheck is how one of what the server needs to sign certs is doing
+type HealthCheck struct {
+	Name string
+	// the server can't take requests
+	Err error
+	// the server can still take requests, for now
+	Warning string
+}
+
+// CheckHealth test-signs with every CA, and checks the PSKs and the authz.
+// There has to be a CA of each type that can sign right now, the ones that
+// only sign later are warnings. So is every CA of the type expiring within
+// expiryWarning
+func (s *AccordServer) CheckHealth(expiryWarning time.Duration) []HealthCheck {
+	cfg := s.current()
+	// every key signs once, whatever the type
+	cas := cfg.certManager.TestSign()
+	checks := []HealthCheck{
+		caHealth(cas, accord.Host, expiryWarning),
+		caHealth(cas, accord.User, expiryWarning),
+	}
+	pskCheck := HealthCheck{Name: "psks"}
+	if err := accord.HealthCheck(cfg.pskStore); err != nil {
+		pskCheck.Err = errors.Wrapf(err, "The PSK store isn't usable")
+	}
+	authzCheck := HealthCheck{Name: "authz"}
+	if err := accord.HealthCheck(cfg.authz); err != nil {
+		authzCheck.Err = errors.Wrapf(err, "The authz isn't usable")
+	}
+	return append(checks, pskCheck, authzCheck)
+}
+
+func caHealth(cas []accord.CACheck, caType accord.CAType, expiryWarning time.Duration) HealthCheck {
+	check := HealthCheck{Name: fmt.Sprintf("ca_%s", caType)}
+	now := time.Now()
+	warnings := []string{}
+	var signing *accord.CACheck
+	// when the last of the CAs that can sign expires, unless one never does
+	var lastUntil time.Time
+	neverExpires := false
+	for _, ca := range cas {
+		ca := ca
+		if ca.Type != caType {
+			continue
+		}
+		switch {
+		case ca.Err != nil:
+		case ca.ValidUntil.IsZero():
+			neverExpires = true
+		case ca.ValidUntil.After(lastUntil):
+			lastUntil = ca.ValidUntil
+		}
+		if ca.Signing {
+			signing = &ca
+			continue
+		}
+		if ca.Err != nil {
+			warnings = append(warnings, fmt.Sprintf("CA %d can't sign. %s", ca.Id, ca.Err))
+		}
+	}
+	switch {
+	case signing == nil:
+		check.Err = errors.Errorf("No %s CA can sign right now", caType)
+	case signing.Err != nil:
+		check.Err = errors.Wrapf(signing.Err, "The %s CA %d can't sign", caType, signing.Id)
+	case !neverExpires && lastUntil.Sub(now) < expiryWarning:
+		warnings = append(warnings, fmt.Sprintf("No %s CA is valid past %s, load the next one",
+			caType, lastUntil.Format(time.RFC3339)))
+	}
+	check.Warning = strings.Join(warnings, ", ")
+	return check
+}
@@ -0,0 +1,50 @@
+package certserver
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mistsys/accord"
+	"github.com/pkg/errors"
+)
+
+func TestCaHealth(t *testing.T) {
+	now := time.Now()
+	ca := func(id int, caType accord.CAType, signing bool, until time.Time, err error) accord.CACheck {
+		return accord.CACheck{
+			CAStatus: accord.CAStatus{
+				CAPublic: accord.CAPublic{Id: id, ValidFrom: now.Add(-time.Hour), ValidUntil: until},
+				Type:     caType,
+				Signing:  signing,
+			},
+			Err: err,
+		}
+	}
+	later := now.Add(365 * 24 * time.Hour)
+	soon := now.Add(time.Hour)
+	tests := []struct {
+		name        string
+		cas         []accord.CACheck
+		caType      accord.CAType
+		wantErr     bool
+		wantWarning string
+	}{
+		{"healthy", []accord.CACheck{ca(1, accord.Host, true, later, nil), ca(2, accord.User, false, soon, errors.New("HSM"))}, accord.Host, false, ""},
+		{"other type signs", []accord.CACheck{ca(1, accord.Host, true, later, nil)}, accord.User, true, ""},
+		{"signing fails", []accord.CACheck{ca(1, accord.User, true, later, errors.New("HSM"))}, accord.User, true, ""},
+		{"next fails", []accord.CACheck{ca(1, accord.User, true, later, nil), ca(2, accord.User, false, later, errors.New("HSM"))}, accord.User, false, "CA 2 can't sign"},
+		{"expiring", []accord.CACheck{ca(1, accord.Host, true, soon, nil)}, accord.Host, false, "No host CA is valid past"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check := caHealth(tt.cas, tt.caType, 30*24*time.Hour)
+			if (check.Err != nil) != tt.wantErr {
+				t.Errorf("caHealth() error = %v, wantErr %v", check.Err, tt.wantErr)
+			}
+			if !strings.Contains(check.Warning, tt.wantWarning) || (tt.wantWarning == "" && check.Warning != "") {
+				t.Errorf("caHealth() warning = %q, want %q", check.Warning, tt.wantWarning)
+			}
+		})
+	}
+}
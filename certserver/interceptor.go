@@ -0,0 +1,122 @@
+package certserver
+
+import (
+	"context"
+	"log"
+	"path"
+	"runtime/debug"
+	"time"
+
+	"github.com/mistsys/accord"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultRPCTimeout is how long the RPCs get when the client doesn't ask for
+// less, UserAuth asks Google so it can't be too short
+const DefaultRPCTimeout = 30 * time.Second
+
+// the clients can send their own request id so that their logs line up with
+// the audit events
+const requestIdHeader = "x-request-id"
+
+type requestIdKey struct{}
+
+// withRequestId picks the request id for the RPC, every audit event from it
+// has the same one
+func withRequestId(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(requestIdKey{}).(string); ok {
+		return ctx
+	}
+	id := ""
+	if md, ok := metadata.FromIncomingContext(ctx); ok && len(md[requestIdHeader]) > 0 {
+		id = md[requestIdHeader][0]
+	}
+	if id == "" || len(id) > 64 {
+		id = string(accord.RandAsciiBytes(16))
+	}
+	return context.WithValue(ctx, requestIdKey{}, id)
+}
+
+func requestId(ctx context.Context) string {
+	id, _ := ctx.Value(requestIdKey{}).(string)
+	return id
+}
+
+// UnaryInterceptor is what every RPC goes through, in order: the request id,
+// the log line, the metrics, recovering from panics and the deadline. The
+// RPCs are cut off after timeout unless the client set a shorter deadline
+func UnaryInterceptor(timeout time.Duration) grpc.UnaryServerInterceptor {
+	return chainUnary(
+		requestIdInterceptor,
+		logInterceptor,
+		metricsInterceptor,
+		recoverInterceptor,
+		deadlineInterceptor(timeout),
+	)
+}
+
+// chainUnary runs the interceptors in order, the first one is the outermost
+func chainUnary(interceptors ...grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		next := handler
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor, inner := interceptors[i], next
+			next = func(ctx context.Context, req interface{}) (interface{}, error) {
+				return interceptor(ctx, req, info, inner)
+			}
+		}
+		return next(ctx, req)
+	}
+}
+
+// requestIdInterceptor also sends the request id back in the header, for the
+// RPCs that fail before there's a ReplyMetadata
+func requestIdInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx = withRequestId(ctx)
+	if err := grpc.SetHeader(ctx, metadata.Pairs(requestIdHeader, requestId(ctx))); err != nil {
+		log.Printf("Failed to set the request id header. %s", err)
+	}
+	return handler(ctx, req)
+}
+
+func logInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	if err != nil {
+		log.Printf("rpc method=%s request_id=%s peer=%s duration=%s code=%s error_type=%s error=%q",
+			path.Base(info.FullMethod), requestId(ctx), peerAddr(ctx), time.Since(start), status.Code(err), accord.ErrorType(err), err)
+	} else {
+		log.Printf("rpc method=%s request_id=%s peer=%s duration=%s code=%s",
+			path.Base(info.FullMethod), requestId(ctx), peerAddr(ctx), time.Since(start), codes.OK)
+	}
+	return resp, err
+}
+
+// recoverInterceptor turns a panic in a handler into an error for that RPC,
+// rather than taking the server down
+func recoverInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Panic in %s for request %s. %v\n%s", info.FullMethod, requestId(ctx), r, debug.Stack())
+			resp, err = nil, status.Errorf(codes.Internal, "Internal error, request id %s", requestId(ctx))
+		}
+	}()
+	return handler(ctx, req)
+}
+
+func deadlineInterceptor(timeout time.Duration) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if timeout <= 0 {
+			return handler(ctx, req)
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= timeout {
+			return handler(ctx, req)
+		}
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		return handler(ctx, req)
+	}
+}
@@ -0,0 +1,149 @@
+package certserver
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/mistsys/accord/protocol"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// pingServer only implements Ping, the other RPCs aren't called
+type pingServer struct {
+	protocol.CertServer
+}
+
+func (pingServer) Ping(ctx context.Context, req *protocol.PingRequest) (*protocol.PingResponse, error) {
+	if req.GetName() == "panic" {
+		panic("the handler panicked")
+	}
+	return &protocol.PingResponse{
+		Metadata: replyMetadata(ctx, req.GetRequestTime()),
+		Message:  "Hello " + req.GetName(),
+	}, nil
+}
+
+func newInterceptedClient(t *testing.T) (protocol.CertClient, func()) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err)
+	}
+	server := grpc.NewServer(grpc.UnaryInterceptor(UnaryInterceptor(time.Minute)))
+	protocol.RegisterCertServer(server, pingServer{})
+	go server.Serve(lis)
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		server.Stop()
+		t.Fatalf("Failed to dial: %s", err)
+	}
+	return protocol.NewCertClient(conn), func() {
+		conn.Close()
+		server.Stop()
+	}
+}
+
+func TestUnaryInterceptor_Panic(t *testing.T) {
+	client, cleanup := newInterceptedClient(t)
+	defer cleanup()
+
+	_, err := client.Ping(context.Background(), &protocol.PingRequest{Name: "panic"})
+	if status.Code(err) != codes.Internal {
+		t.Errorf("Ping() error = %v, want code %s", err, codes.Internal)
+	}
+	resp, err := client.Ping(context.Background(), &protocol.PingRequest{Name: "again"})
+	if err != nil {
+		t.Fatalf("Ping() error = %v after a panic", err)
+	}
+	if resp.Message != "Hello again" {
+		t.Errorf("Ping() = %q, want %q", resp.Message, "Hello again")
+	}
+}
+
+func TestUnaryInterceptor_RequestId(t *testing.T) {
+	client, cleanup := newInterceptedClient(t)
+	defer cleanup()
+
+	tests := []struct {
+		name   string
+		sent   string
+		wantId string
+	}{
+		{"client's id", "client-request-1", "client-request-1"},
+		{"generated", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.sent != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, requestIdHeader, tt.sent)
+			}
+			var header metadata.MD
+			resp, err := client.Ping(ctx, &protocol.PingRequest{RequestTime: ptypes.TimestampNow()}, grpc.Header(&header))
+			if err != nil {
+				t.Fatalf("Ping() error = %v", err)
+			}
+			got := resp.GetMetadata().GetRequestId()
+			if got == "" || (tt.wantId != "" && got != tt.wantId) {
+				t.Errorf("Ping() request id = %q, want %q", got, tt.wantId)
+			}
+			if ids := header.Get(requestIdHeader); len(ids) != 1 || ids[0] != got {
+				t.Errorf("Ping() header request id = %v, want %q", ids, got)
+			}
+		})
+	}
+
+	// the panics have the request id too, to find them in the logs
+	ctx := metadata.AppendToOutgoingContext(context.Background(), requestIdHeader, "client-request-2")
+	var header metadata.MD
+	_, err := client.Ping(ctx, &protocol.PingRequest{Name: "panic"}, grpc.Header(&header))
+	if ids := header.Get(requestIdHeader); len(ids) != 1 || ids[0] != "client-request-2" {
+		t.Errorf("Ping() header request id = %v for a panic, error = %v", ids, err)
+	}
+}
+
+func TestDeadlineInterceptor(t *testing.T) {
+	tests := []struct {
+		name         string
+		timeout      time.Duration
+		clientBudget time.Duration
+		want         time.Duration
+		wantDeadline bool
+	}{
+		{"shortened", time.Second, time.Hour, time.Second, true},
+		{"client's is shorter", time.Hour, time.Second, time.Second, true},
+		{"no client deadline", time.Second, 0, time.Second, true},
+		{"no timeout", 0, 0, 0, false},
+		{"no timeout keeps the client's", 0, time.Hour, time.Hour, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.clientBudget > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.clientBudget)
+				defer cancel()
+			}
+			var remaining time.Duration
+			var hasDeadline bool
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				var deadline time.Time
+				deadline, hasDeadline = ctx.Deadline()
+				remaining = time.Until(deadline)
+				return nil, nil
+			}
+			deadlineInterceptor(tt.timeout)(ctx, nil, &grpc.UnaryServerInfo{}, handler)
+			if hasDeadline != tt.wantDeadline {
+				t.Fatalf("deadlineInterceptor() deadline set = %v, want %v", hasDeadline, tt.wantDeadline)
+			}
+			if hasDeadline && (remaining > tt.want || remaining < tt.want-time.Second) {
+				t.Errorf("deadlineInterceptor() deadline in %s, want %s", remaining, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package certserver
+
+import (
+	"context"
+	"math"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/mistsys/accord"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+)
+
+var rpcRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "accord",
+	Name:      "rpc_requests_total",
+	Help:      "The RPCs handled, by the method.",
+}, []string{"method"})
+
+var rpcErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "accord",
+	Name:      "rpc_errors_total",
+	Help:      "The RPCs that failed, by the method and what kind of error it was.",
+}, []string{"method", "type"})
+
+var rpcDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "accord",
+	Name:      "rpc_duration_seconds",
+	Help:      "How long the RPCs take, by the method.",
+	Buckets:   prometheus.ExponentialBuckets(0.001, 2, 16),
+}, []string{"method"})
+
+// the HostAuth rejections are sent to the host encrypted, the RPC itself
+// doesn't fail
+var hostAuthRejectionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "accord",
+	Name:      "hostauth_rejections_total",
+	Help:      "The HostAuth requests that were turned away, by the reason.",
+}, []string{"reason"})
+
+var authzDeniedRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "accord",
+	Name:      "authz_denied_requests_total",
+	Help:      "The cert requests that weren't allowed at all, by the cert type.",
+}, []string{"cert_type"})
+
+var authzDeniedPrincipals = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "accord",
+	Name:      "authz_denied_principals_total",
+	Help:      "The principals and hostnames that were left out of the certs, by the cert type.",
+}, []string{"cert_type"})
+
+func init() {
+	prometheus.MustRegister(rpcRequests, rpcErrors, rpcDuration,
+		hostAuthRejectionsTotal, authzDeniedRequests, authzDeniedPrincipals)
+}
+
+func rejectHostAuth(reason string) {
+	hostAuthRejections.Add(reason, 1)
+	hostAuthRejectionsTotal.WithLabelValues(reason).Inc()
+}
+
+// metricsInterceptor counts and times the RPCs for /metrics
+func metricsInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	method := path.Base(info.FullMethod)
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	rpcDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+	rpcRequests.WithLabelValues(method).Inc()
+	if err != nil {
+		rpcErrors.WithLabelValues(method, accord.ErrorType(err)).Inc()
+	}
+	return resp, err
+}
+
+var caValidDesc = prometheus.NewDesc("accord_ca_valid_seconds_remaining",
+	"How long until the CA expires, negative once it has and +Inf when it never does.",
+	[]string{"type", "id", "fingerprint", "signing"}, nil)
+
+// caCollector reads the CAs when /metrics is scraped, so that it follows
+// the reloads
+type caCollector struct {
+	server *AccordServer
+}
+
+// NewCACollector exports how long the CAs the server has loaded are valid for
+func NewCACollector(server *AccordServer) prometheus.Collector {
+	return caCollector{server: server}
+}
+
+func (c caCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- caValidDesc
+}
+
+func (c caCollector) Collect(ch chan<- prometheus.Metric) {
+	now := time.Now()
+	for _, ca := range c.server.CertManager().CAs() {
+		validFor := ca.ValidUntil.Sub(now).Seconds()
+		if ca.ValidUntil.IsZero() {
+			validFor = math.Inf(1)
+		}
+		ch <- prometheus.MustNewConstMetric(caValidDesc, prometheus.GaugeValue,
+			validFor,
+			string(ca.Type), strconv.Itoa(ca.Id), ca.Fingerprint, strconv.FormatBool(ca.Signing))
+	}
+}
@@ -0,0 +1,110 @@
+package certserver
+
+import (
+	"context"
+	"log"
+	"strconv"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/mistsys/accord"
+	"github.com/mistsys/accord/db"
+	"github.com/mistsys/accord/protocol"
+	"github.com/pkg/errors"
+)
+
+// SetCertLister makes ListCerts answer from the ledger
+func (s *AccordServer) SetCertLister(certs db.CertLister) {
+	s.certs = certs
+}
+
+func issuedCertPb(record *db.CertRecord) *protocol.IssuedCert {
+	cert := &protocol.IssuedCert{
+		Serial:      record.Serial,
+		CertType:    record.CertType,
+		KeyId:       record.KeyId,
+		Fingerprint: record.Fingerprint,
+		Principals:  record.Principals,
+		Requester:   record.Requester,
+		Deployment:  record.Deployment,
+		Email:       record.Email,
+	}
+	cert.ValidFrom, _ = ptypes.TimestampProto(record.ValidFrom)
+	cert.ValidUntil, _ = ptypes.TimestampProto(record.ValidUntil)
+	cert.IssuedAt, _ = ptypes.TimestampProto(record.IssuedAt)
+	return cert
+}
+
+// certFilter turns the request into the filter for the ledger, the page
+// token is the serial the page starts after
+func certFilter(req *protocol.CertListRequest) (*db.CertFilter, error) {
+	filter := &db.CertFilter{
+		CertType:   req.CertType,
+		Email:      req.Email,
+		Hostname:   req.Hostname,
+		Deployment: req.Deployment,
+		Principal:  req.Principal,
+		Serial:     req.Serial,
+		Latest:     req.Latest,
+		PageSize:   int(req.PageSize),
+	}
+	var err error
+	if req.ExpiresAfter != nil {
+		if filter.ExpiresAfter, err = ptypes.Timestamp(req.ExpiresAfter); err != nil {
+			return nil, errors.Wrapf(err, "Invalid expiresAfter")
+		}
+	}
+	if req.ExpiresBefore != nil {
+		if filter.ExpiresBefore, err = ptypes.Timestamp(req.ExpiresBefore); err != nil {
+			return nil, errors.Wrapf(err, "Invalid expiresBefore")
+		}
+	}
+	if req.PageToken != "" {
+		if filter.After, err = strconv.ParseUint(req.PageToken, 10, 64); err != nil {
+			return nil, errors.Errorf("Invalid page token %q", req.PageToken)
+		}
+	}
+	return filter, filter.Validate()
+}
+
+// visibleCert is what the users who aren't admins can list, their own user
+// certs and all of the host certs. Which principals the others have isn't for
+// everyone to see, the host certs are shown to anyone who connects to sshd
+// and aren't scoped by the authz, its principals aren't hostnames
+func visibleCert(email string) func(record *db.CertRecord) bool {
+	return func(record *db.CertRecord) bool {
+		return record.CertType == db.HostCertType || (record.CertType == db.UserCertType && record.Email == email)
+	}
+}
+
+func (s *AccordServer) ListCerts(ctx context.Context, req *protocol.CertListRequest) (*protocol.CertListResponse, error) {
+	cfg := s.current()
+	session, err := s.verifySession(req.Session)
+	if err != nil {
+		return nil, err
+	}
+	if s.certs == nil {
+		return nil, errors.New("This server doesn't keep a ledger of the certs")
+	}
+	filter, err := certFilter(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Invalid query")
+	}
+	if !accord.IsAdmin(cfg.authz, session.Email) {
+		filter.Visible = visibleCert(session.Email)
+	}
+	records, next, err := s.certs.ListCerts(filter)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to list the certs")
+	}
+	log.Printf("%s from %s listed %d certs", session.Email, peerAddr(ctx), len(records))
+	resp := &protocol.CertListResponse{
+		Metadata: replyMetadata(ctx, req.GetRequestTime()),
+	}
+	for _, record := range records {
+		resp.Certs = append(resp.Certs, issuedCertPb(record))
+	}
+	if next != 0 {
+		resp.NextPageToken = strconv.FormatUint(next, 10)
+	}
+	return resp, nil
+}
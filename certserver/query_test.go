@@ -0,0 +1,72 @@
+package certserver
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mistsys/accord"
+	"github.com/mistsys/accord/db"
+	"github.com/mistsys/accord/protocol"
+)
+
+func TestAccordServer_ListCerts(t *testing.T) {
+	s, cleanup := newTestServer(t)
+	defer cleanup()
+	s.SetCertLister(s.store)
+	authz, err := accord.NewSimpleAuthFromBuffer([]byte(`
+principals: [zones-db]
+admin_users: [admin@ex.ample.com]
+access_map:
+  user@ex.ample.com: [zones-db]
+  other@ex.ample.com: [zones-db]
+`))
+	if err != nil {
+		t.Fatalf("NewSimpleAuthFromBuffer() error = %v", err)
+	}
+	s.Reload(nil, nil, authz)
+
+	now := time.Now()
+	for _, record := range []*db.CertRecord{
+		{Serial: 1, CertType: db.HostCertType, Principals: []string{"web-1.ex.ample.com"}, Deployment: "1"},
+		{Serial: 2, CertType: db.UserCertType, Principals: []string{"zones-db"}, Email: "user@ex.ample.com"},
+		{Serial: 3, CertType: db.UserCertType, Principals: []string{"zones-db"}, Email: "other@ex.ample.com"},
+		{Serial: 4, CertType: db.HostCertType, Principals: []string{"db-1.ex.ample.com"}, Deployment: "2"},
+	} {
+		record.ValidFrom, record.ValidUntil, record.IssuedAt = now, now.Add(time.Hour), now
+		if err := s.store.Record(record); err != nil {
+			t.Fatalf("BoltStore.Record() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		email       string
+		wantSerials []uint64
+	}{
+		{"admins see everything", "admin@ex.ample.com", []uint64{1, 2, 3, 4}},
+		// the host certs aren't scoped by the authz
+		{"everyone else sees the host certs and their own", "user@ex.ample.com", []uint64{1, 2, 4}},
+		{"users without any certs", "nobody@ex.ample.com", []uint64{1, 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session, _, err := s.sessions.Issue(tt.email, "user1")
+			if err != nil {
+				t.Fatalf("SessionSigner.Issue() error = %v", err)
+			}
+			resp, err := s.ListCerts(context.Background(), &protocol.CertListRequest{Session: session})
+			if err != nil {
+				t.Fatalf("AccordServer.ListCerts() error = %v", err)
+			}
+			serials := []uint64{}
+			for _, cert := range resp.Certs {
+				serials = append(serials, cert.Serial)
+			}
+			if !reflect.DeepEqual(serials, tt.wantSerials) {
+				t.Errorf("AccordServer.ListCerts() = %v, want %v", serials, tt.wantSerials)
+			}
+		})
+	}
+}
@@ -59,7 +59,43 @@ func updateUsersCertAuthority(filePath string, trustedUserCAs [][]byte) error {
 	return nil
 }
 
-func updateKnownHostsCertAuthority(filePath string, trustedHostCAs [][]byte) error {
+// writeFileAtomic writes to a temporary file in the same directory and renames it
+// over the original so that sshd never sees a partially written file
+func writeFileAtomic(filePath string, content []byte, perm os.FileMode) error {
+	f, err := ioutil.TempFile(filepath.Dir(filePath), "."+filepath.Base(filePath))
+	if err != nil {
+		return errors.Wrapf(err, "Failed to create temporary file for %s", filePath)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		return errors.Wrapf(err, "Failed to write to %s", f.Name())
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return errors.Wrapf(err, "Failed to sync %s", f.Name())
+	}
+	if err := f.Close(); err != nil {
+		return errors.Wrapf(err, "Failed to close %s", f.Name())
+	}
+	if err := os.Chmod(f.Name(), perm); err != nil {
+		return errors.Wrapf(err, "Failed to chmod %s", f.Name())
+	}
+	err = os.Rename(f.Name(), filePath)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to rename %s to %s", f.Name(), filePath)
+	}
+	return nil
+}
+
+func knownHostsLine(marker string, b []byte) string {
+	if b[len(b)-1] == '\n' {
+		return marker + " * " + string(b[:len(b)-1])
+	}
+	return marker + " * " + string(b)
+}
+
+func updateKnownHostsCertAuthority(filePath string, trustedHostCAs [][]byte, revokedHostCAs [][]byte) error {
 	input, err := ioutil.ReadFile(filePath)
 	if err != nil {
 		return errors.Wrapf(err, "Failed to read %s", filePath)
@@ -71,13 +107,12 @@ func updateKnownHostsCertAuthority(filePath string, trustedHostCAs [][]byte) err
 	newlines = deleteEmpty(newlines)
 	newlines = append(newlines, "#accord-trusted-hosts-start")
 	for _, b := range trustedHostCAs {
-
-		if b[len(b)-1] == '\n' {
-			newlines = append(newlines, "@cert-authority * "+string(b[:len(b)-1]))
-		} else {
-			newlines = append(newlines, "@cert-authority * "+string(b))
-		}
-
+		newlines = append(newlines, knownHostsLine("@cert-authority", b))
+	}
+	// ssh refuses the hosts with certs signed by these even if there's
+	// another line trusting them
+	for _, b := range revokedHostCAs {
+		newlines = append(newlines, knownHostsLine("@revoked", b))
 	}
 	newlines = append(newlines, "#accord-trusted-hosts-end", "\n")
 	backupFile := filePath + ".bak"
@@ -0,0 +1,63 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/mistsys/accord/protocol"
+	"github.com/pkg/errors"
+)
+
+// RequestElevation asks for the principals that need an approval, the
+// certs can have them once someone else approves it. Like RequestCerts it
+// needs CheckAuthorization first
+func (u *User) RequestElevation(ctx context.Context, principals []string, justification string, duration time.Duration) (*protocol.Elevation, error) {
+	if len(u.session) == 0 {
+		return nil, errors.New("No session from the server, call CheckAuthorization first")
+	}
+	resp, err := u.c.RequestElevation(ctx, &protocol.ElevationRequest{
+		RequestTime:     ptypes.TimestampNow(),
+		Session:         u.session,
+		Principals:      principals,
+		Justification:   justification,
+		DurationSeconds: int64(duration / time.Second),
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to request the elevation")
+	}
+	return resp.Elevation, nil
+}
+
+// DecideElevation approves or denies someone else's elevation
+func (u *User) DecideElevation(ctx context.Context, id string, approve bool, reason string) (*protocol.Elevation, error) {
+	if len(u.session) == 0 {
+		return nil, errors.New("No session from the server, call CheckAuthorization first")
+	}
+	resp, err := u.c.DecideElevation(ctx, &protocol.ElevationDecision{
+		RequestTime: ptypes.TimestampNow(),
+		Session:     u.session,
+		Id:          id,
+		Approve:     approve,
+		Reason:      reason,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to decide on elevation %s", id)
+	}
+	return resp.Elevation, nil
+}
+
+// Elevations are the user's own and the pending ones they can decide on
+func (u *User) Elevations(ctx context.Context) ([]*protocol.Elevation, error) {
+	if len(u.session) == 0 {
+		return nil, errors.New("No session from the server, call CheckAuthorization first")
+	}
+	resp, err := u.c.ListElevations(ctx, &protocol.ElevationListRequest{
+		RequestTime: ptypes.TimestampNow(),
+		Session:     u.session,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to list the elevations")
+	}
+	return resp.Elevations, nil
+}
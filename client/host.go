@@ -1,14 +1,15 @@
 package client
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"strings"
 	"time"
 
+	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes"
 	"github.com/mistsys/accord"
 	"github.com/mistsys/accord/cloud_metadata"
@@ -31,6 +32,21 @@ type Host struct {
 	KeysDir      string
 	Hostnames    []string
 	UUID         []byte
+	// the PSK id Authenticate used, the UUID only works with it
+	KeyId uint32
+	// the version of the PSK in the store, 0 for a PSK from before the versions
+	PSKVersion uint32
+	// the per-installation secret for the HMAC key ids, the MD5 one with the
+	// salt is used without it
+	KeyIDSecret []byte
+	// the AEAD of the HostAuth envelope, AES-GCM when it's 0
+	AEAD byte
+	// what the server said about the UUID in HostAuth, the cert requests
+	// have to fit in them
+	SessionExpiresAt time.Time
+	SessionUses      int
+	HostnamePolicy   *protocol.HostnamePolicy
+	MaxCertValidity  time.Duration
 }
 
 func NewHost(client protocol.CertClient) *Host {
@@ -41,22 +57,23 @@ func NewHost(client protocol.CertClient) *Host {
 
 // For now the response doesn't do a proper challenge auth
 func (h *Host) Authenticate(ctx context.Context) (string, error) {
-	keyId, err := id.KeyID(h.DeploymentId, h.Salt)
+	keyId, _, err := id.DeploymentKeyID(h.DeploymentId, h.Salt, h.KeyIDSecret)
 	if err != nil {
 		return "", errors.Wrapf(err, "Failed to get the KeyId based on deploymentId")
 	}
 	//log.Printf("keyId: %d", keyId)
-	aesgcm := accord.InitAESGCM(h.PSKStore)
-
-	nonce, err := accord.GenerateNonce(accord.NonceSize)
-	if err != nil {
-		return "", errors.Wrapf(err, "Failed to generate nonce")
+	aead := h.AEAD
+	if aead == 0 {
+		aead = accord.AEADAESGCM
 	}
+	envelope := accord.NewEnvelope(h.PSKStore, aead)
 
 	metadata := []byte("Host Login")
 
-	// sends the data to the server to keep for records
-	encrypted, err := aesgcm.EncryptWithNonce(metadata, nonce, keyId)
+	// sends the data to the server to keep for records, the envelope has
+	// the time for the server to tell that it isn't an old request replayed
+	psk := accord.PSKRef{KeyId: keyId, Version: h.PSKVersion, Unversioned: h.PSKVersion == 0}
+	encrypted, sent, err := envelope.Seal(metadata, psk, accord.HostToServer, nil)
 	if err != nil {
 		return "", errors.Wrapf(err, "Failed to encrypt the message")
 	}
@@ -71,16 +88,37 @@ func (h *Host) Authenticate(ctx context.Context) (string, error) {
 		return "", errors.Wrapf(err, "Failed to send the authentication challenge")
 	}
 
-	uuid, nonce2, id2, err := aesgcm.Decrypt(resp.AuthResponse)
-	if !bytes.Equal(nonce, nonce2) {
-		return "", errors.Wrapf(err, "Server didn't use the same nonce to encrypt the message, something's wrong")
+	// the response is bound to the nonce of the request, it only opens if
+	// it's the answer to this one
+	plaintext, header, err := envelope.Open(resp.AuthResponse, accord.ServerToHost, sent.Nonce)
+	if err != nil {
+		return "", errors.Wrapf(err, "Failed to open the response, it isn't from a server with the PSK or not for this request")
 	}
 
-	if id2 != keyId {
-		return "", errors.Wrapf(err, "Ids don't match")
+	if header.PSK.KeyId != keyId || header.PSK.Unversioned != psk.Unversioned {
+		return "", errors.New("Ids don't match")
 	}
 
-	h.UUID = uuid
+	auth := &protocol.HostAuth{}
+	if err := proto.Unmarshal(plaintext, auth); err != nil {
+		return "", errors.Wrapf(err, "Failed to parse the response")
+	}
+	if len(auth.Errors) > 0 {
+		return "", fmt.Errorf("Server rejected the authentication: %s", formatErrors(auth.Errors))
+	}
+	if len(auth.Id) == 0 {
+		return "", errors.New("Server didn't send an id")
+	}
+
+	h.UUID = auth.Id
+	h.KeyId = keyId
+	h.SessionExpiresAt, _ = ptypes.Timestamp(auth.ExpiresAt)
+	h.SessionUses = int(auth.UsesLeft)
+	h.HostnamePolicy = auth.HostnamePolicy
+	h.MaxCertValidity = time.Duration(auth.MaxCertValiditySeconds) * time.Second
+	if h.HostnamePolicy != nil && h.HostnamePolicy.Principals != accord.PrincipalsUnchecked {
+		log.Printf("The server will %s the hostnames that aren't the host's own or match %v", h.HostnamePolicy.Principals, h.HostnamePolicy.HostnamePatterns)
+	}
 
 	return string(h.UUID), nil
 }
@@ -123,6 +161,11 @@ func (h *Host) RequestCerts(ctx context.Context, duration time.Duration) error {
 	// 2. the server doesn't reject this for being too far in past
 	// taking the number from Oauth2's implementation
 	validFrom := time.Now().Add(10 * time.Second)
+	// the server would turn the requests away
+	if h.MaxCertValidity > 0 && duration > h.MaxCertValidity-10*time.Second {
+		log.Printf("Server only signs host certs for up to %s, requesting that instead of %s", h.MaxCertValidity, duration)
+		duration = h.MaxCertValidity - 10*time.Second
+	}
 	validUntil := validFrom.Add(duration)
 	for _, f := range files {
 		contents, err := ioutil.ReadFile(f)
@@ -150,11 +193,15 @@ func (h *Host) RequestCerts(ctx context.Context, duration time.Duration) error {
 			Id:           h.UUID,
 			Hostnames:    h.Hostnames,
 			HostMetadata: metadata,
+			KeyId:        h.KeyId,
 		}
 		resp, err := h.Client.HostCert(ctx, certRequest)
 		if err != nil {
 			return errors.Wrapf(err, "Error when trying to get cert for %s", f)
 		}
+		if len(resp.DroppedHostnames) > 0 {
+			log.Printf("The server left %v out of the cert for %s", resp.DroppedHostnames, f)
+		}
 		certFileName := certPath(f)
 		log.Printf("Writing to %s", certFileName)
 		err = ioutil.WriteFile(certFileName, resp.HostCert, 0644)
@@ -179,3 +226,30 @@ func (h *Host) UpdateUserCertAuthority(filePath string) error {
 	}
 	return updateUsersCertAuthority(filePath, userCerts)
 }
+
+// UpdateRevokedKeys installs the KRL from the server as the RevokedKeys file
+// sshd reads the file on every connection, so it's replaced atomically
+func (h *Host) UpdateRevokedKeys(ctx context.Context, filePath string) (uint64, error) {
+	resp, err := h.Client.RevokedKeys(ctx, &protocol.RevokedKeysRequest{
+		RequestTime: ptypes.TimestampNow(),
+	})
+	if err != nil {
+		return 0, errors.Wrapf(err, "Failed to get the revoked keys")
+	}
+	if len(resp.Krl) == 0 {
+		return 0, errors.New("Server sent an empty KRL")
+	}
+	err = writeFileAtomic(filePath, resp.Krl, 0644)
+	if err != nil {
+		return 0, errors.Wrapf(err, "Failed to install the KRL")
+	}
+	return resp.Version, nil
+}
+
+func formatErrors(errs []*protocol.Error) string {
+	msgs := make([]string, 0, len(errs))
+	for _, e := range errs {
+		msgs = append(msgs, fmt.Sprintf("%s (%s)", e.Msg, e.Type))
+	}
+	return strings.Join(msgs, "; ")
+}
@@ -0,0 +1,32 @@
+package client
+
+import (
+	"context"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/mistsys/accord/protocol"
+	"github.com/pkg/errors"
+)
+
+// ListCerts goes through all the pages of the certs the server signed that
+// match the query. It needs the session from CheckAuthorization
+func (u *User) ListCerts(ctx context.Context, q protocol.CertQueryClient, query *protocol.CertListRequest) ([]*protocol.IssuedCert, error) {
+	if len(u.session) == 0 {
+		return nil, errors.New("No session from the server, call CheckAuthorization first")
+	}
+	certs := []*protocol.IssuedCert{}
+	query.PageToken = ""
+	for {
+		query.RequestTime = ptypes.TimestampNow()
+		query.Session = u.session
+		resp, err := q.ListCerts(ctx, query)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to list the certs")
+		}
+		certs = append(certs, resp.Certs...)
+		if resp.NextPageToken == "" {
+			return certs, nil
+		}
+		query.PageToken = resp.NextPageToken
+	}
+}
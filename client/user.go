@@ -23,7 +23,10 @@ type User struct {
 	username       string
 	remoteUsername string
 	principals     []string
+	permissions    accord.CertPermissions
 	token          *oauth2.Token
+	// from UserAuth, the server needs it for the cert requests
+	session []byte
 }
 
 func NewUser(client protocol.CertClient) *User {
@@ -55,6 +58,12 @@ func (u *User) SetPrincipals(principals []string) {
 	u.principals = principals
 }
 
+// SetPermissions asks for certs that can do less than the server allows
+// the server refuses to sign if they ask for more
+func (u *User) SetPermissions(permissions accord.CertPermissions) {
+	u.permissions = permissions
+}
+
 // Validate the Oauth2.0 token against the server
 // it needs to have been generated by the same clientID
 // and have valid expiry date, etc
@@ -78,10 +87,16 @@ func (u *User) CheckAuthorization(ctx context.Context) (bool, string, error) {
 	if err != nil {
 		return false, "", errors.Wrapf(err, "Failed to authenticate user with server")
 	}
+	u.session = resp.GetAuthResponse()
 	return resp.GetValid(), resp.GetUserId(), nil
 }
 
+// RequestCerts needs CheckAuthorization to have been called first, the
+// session from it is what the server uses to tell who the user is
 func (u *User) RequestCerts(ctx context.Context, userId string, duration time.Duration) error {
+	if len(u.session) == 0 {
+		return errors.New("No session from the server, call CheckAuthorization first")
+	}
 	if len(u.principals) == 0 {
 		return errors.New("No principals provided to request certificates for")
 	}
@@ -125,12 +140,12 @@ func (u *User) RequestCerts(ctx context.Context, userId string, duration time.Du
 		if _, err := os.Stat(certFileName); err != nil {
 			// we should just create the file if it doesn't exist
 			if !os.IsNotExist(err) {
-				return errors.Wrapf(err, "Unknown error reading cert file %s")
+				return errors.Wrapf(err, "Unknown error reading cert file %s", certFileName)
 			}
 		} else {
 			currentCert, err = ioutil.ReadFile(certFileName)
 			if err != nil {
-				return errors.Wrapf(err, "Found cert file %s but can't read")
+				return errors.Wrapf(err, "Found cert file %s but can't read", certFileName)
 			}
 		}
 
@@ -144,11 +159,20 @@ func (u *User) RequestCerts(ctx context.Context, userId string, duration time.Du
 			ValidFrom:            protoValidFrom,
 			ValidUntil:           protoValidUntil,
 			AuthorizedPrincipals: u.principals,
+			SourceAddresses:      u.permissions.SourceAddresses,
+			Extensions:           u.permissions.Extensions,
+			RestrictExtensions:   u.permissions.Extensions != nil,
+			Session:              u.session,
+		}
+		if u.permissions.ForceCommand != "" {
+			certRequest.ForceCommands = []string{u.permissions.ForceCommand}
 		}
 		resp, err := u.c.UserCert(ctx, certRequest)
 		if err != nil {
 			return errors.Wrapf(err, "Error when trying to get cert for %s", f)
 		}
+		logPrincipals(resp)
+		logCertPermissions(resp.UserCert)
 
 		log.Printf("Writing to %s", certFileName)
 		err = ioutil.WriteFile(certFileName, resp.UserCert, 0644)
@@ -159,6 +183,35 @@ func (u *User) RequestCerts(ctx context.Context, userId string, duration time.Du
 	return nil
 }
 
+// the server can grant only some of the principals, so show which ones and
+// why the rest were left out
+func logPrincipals(resp *protocol.UserCertResponse) {
+	log.Printf("The cert is for the principals: %v", resp.GrantedPrincipals)
+	for _, denied := range resp.DeniedPrincipals {
+		log.Printf("Not granted %s: %s", denied.Principal, denied.Reason)
+	}
+}
+
+// the server can restrict the cert more than asked for, so show what it's good for
+func logCertPermissions(userCert []byte) {
+	key, _, _, _, err := ssh.ParseAuthorizedKey(userCert)
+	if err != nil {
+		return
+	}
+	cert, ok := key.(*ssh.Certificate)
+	if !ok {
+		return
+	}
+	permissions := accord.PermissionsFromCert(cert)
+	if permissions.ForceCommand != "" {
+		log.Printf("The cert can only run: %s", permissions.ForceCommand)
+	}
+	if len(permissions.SourceAddresses) > 0 {
+		log.Printf("The cert can only be used from: %v", permissions.SourceAddresses)
+	}
+	log.Printf("The cert allows: %v", permissions.Extensions)
+}
+
 func (u *User) UpdateHostCertAuthority(knownHostsFile string) error {
 	resp, err := u.c.PublicTrustedCA(context.Background(), &protocol.PublicTrustedCARequest{
 		RequestTime: ptypes.TimestampNow(),
@@ -171,5 +224,9 @@ func (u *User) UpdateHostCertAuthority(knownHostsFile string) error {
 	for _, hostCA := range resp.HostCAs {
 		hostCerts = append(hostCerts, hostCA.PublicKey)
 	}
-	return updateKnownHostsCertAuthority(knownHostsFile, hostCerts)
+	revokedHostCerts := [][]byte{}
+	for _, hostCA := range resp.RevokedHostCAs {
+		revokedHostCerts = append(revokedHostCerts, hostCA.PublicKey)
+	}
+	return updateKnownHostsCertAuthority(knownHostsFile, hostCerts, revokedHostCerts)
 }
@@ -76,6 +76,9 @@ type AWSInstanceInfo struct {
 	VPCId            string                                  `json:"vpc_id"`
 	SubnetId         string                                  `json:"subnet_id"`
 	SSHKey           string                                  `json:"ssh_key"`
+	// the identity document signed by AWS, base64 encoded PKCS7 as it comes
+	// from the metadata service. It's the only part the server can trust
+	IdentitySignature string `json:"identity_pkcs7"`
 }
 
 // TODO: possibly
@@ -89,6 +92,13 @@ func GetAWSInstanceInfo() (instanceInfo *AWSInstanceInfo, err error) {
 		return
 	}
 	instanceInfo.IdentityDocument = identityDocument
+	// the RSA signature, the default one uses DSA which Go can't verify
+	signature, err := meta.GetDynamicData("instance-identity/rsa2048")
+	if err != nil {
+		err = errors.Wrapf(err, "Failed to get the signed Instance Document")
+		return
+	}
+	instanceInfo.IdentitySignature = signature
 	iamInfo, err := meta.IAMInfo()
 	if err != nil {
 		err = errors.Wrapf(err, "Failed to get iam role")
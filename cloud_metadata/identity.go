@@ -0,0 +1,103 @@
+package cloud_metadata
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/ec2metadata"
+	"github.com/pkg/errors"
+	"go.mozilla.org/pkcs7"
+)
+
+// AWSIdentityVerifier checks the instance identity documents against the
+// public certificates AWS publishes for each region, see
+// https://docs.aws.amazon.com/AWSEC2/latest/UserGuide/verify-rsa2048.html
+type AWSIdentityVerifier struct {
+	certs []*x509.Certificate
+}
+
+// NewAWSIdentityVerifier takes the PEM encoded AWS certificates, all the
+// regions that are used can be in the same file
+func NewAWSIdentityVerifier(pemCerts []byte) (*AWSIdentityVerifier, error) {
+	v := &AWSIdentityVerifier{}
+	for {
+		var block *pem.Block
+		block, pemCerts = pem.Decode(pemCerts)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to parse the AWS certificate")
+		}
+		v.certs = append(v.certs, cert)
+	}
+	if len(v.certs) == 0 {
+		return nil, errors.New("No certificates found")
+	}
+	return v, nil
+}
+
+func NewAWSIdentityVerifierFromFile(path string) (*AWSIdentityVerifier, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Cannot read file %s", path)
+	}
+	return NewAWSIdentityVerifier(content)
+}
+
+// Verify checks the signature and returns the document that was signed
+// nothing else the host sent about itself should be trusted
+func (v *AWSIdentityVerifier) Verify(signature string) (*ec2metadata.EC2InstanceIdentityDocument, error) {
+	if signature == "" {
+		return nil, errors.New("No signed identity document")
+	}
+	// the metadata service returns it without the PEM headers
+	der, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(signature), ""))
+	if err != nil {
+		return nil, errors.Wrapf(err, "The identity signature isn't base64")
+	}
+	p7, err := pkcs7.Parse(der)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to parse the identity signature")
+	}
+	// AWS doesn't include its certificate, it has to be one of ours
+	p7.Certificates = v.certs
+	if err := p7.Verify(); err != nil {
+		return nil, errors.Wrapf(err, "The identity document isn't signed by AWS")
+	}
+	document := &ec2metadata.EC2InstanceIdentityDocument{}
+	if err := json.Unmarshal(p7.Content, document); err != nil {
+		return nil, errors.Wrapf(err, "Failed to parse the identity document")
+	}
+	return document, nil
+}
+
+// HostNames are the names and addresses the instance can be reached at, only
+// what can be derived from the signed document: the private IP and the
+// internal DNS name. The public IP and hostname aren't in it, the host could
+// claim any of them
+func HostNames(identity *ec2metadata.EC2InstanceIdentityDocument) []string {
+	if identity == nil {
+		return nil
+	}
+	names := []string{}
+	if identity.PrivateIP != "" {
+		names = append(names, identity.PrivateIP)
+		local := "ip-" + strings.Replace(identity.PrivateIP, ".", "-", -1)
+		// us-east-1 has its own domain for the internal names
+		if identity.Region == "us-east-1" {
+			names = append(names, local+".ec2.internal")
+		} else {
+			names = append(names, local+"."+identity.Region+".compute.internal")
+		}
+	}
+	return names
+}
@@ -0,0 +1,135 @@
+package cloud_metadata
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/ec2metadata"
+	"go.mozilla.org/pkcs7"
+)
+
+// a self signed cert standing in for the one AWS publishes
+func testSigningCert(t *testing.T, name string) (*x509.Certificate, *rsa.PrivateKey, []byte) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %s", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create cert: %s", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Failed to parse cert: %s", err)
+	}
+	return cert, key, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+// the signer's certificate ends up in the signature, which shows that it's
+// the configured certificates that are trusted and not the embedded ones
+func signDocument(t *testing.T, document []byte, cert *x509.Certificate, key *rsa.PrivateKey) string {
+	signedData, err := pkcs7.NewSignedData(document)
+	if err != nil {
+		t.Fatalf("Failed to create signed data: %s", err)
+	}
+	if err := signedData.AddSigner(cert, key, pkcs7.SignerInfoConfig{}); err != nil {
+		t.Fatalf("Failed to add signer: %s", err)
+	}
+	der, err := signedData.Finish()
+	if err != nil {
+		t.Fatalf("Failed to sign: %s", err)
+	}
+	return base64.StdEncoding.EncodeToString(der)
+}
+
+func TestAWSIdentityVerifier_Verify(t *testing.T) {
+	cert, key, certPEM := testSigningCert(t, "aws")
+	otherCert, otherKey, _ := testSigningCert(t, "not aws")
+	document := []byte(`{"accountId": "123456789012", "region": "us-west-2", "instanceId": "i-0123456789"}`)
+
+	verifier, err := NewAWSIdentityVerifier(certPEM)
+	if err != nil {
+		t.Fatalf("NewAWSIdentityVerifier() error = %v", err)
+	}
+	tests := []struct {
+		name        string
+		signature   string
+		wantAccount string
+		wantErr     bool
+	}{
+		{
+			name:        "signed by aws",
+			signature:   signDocument(t, document, cert, key),
+			wantAccount: "123456789012",
+		},
+		{
+			name:      "signed by someone else",
+			signature: signDocument(t, document, otherCert, otherKey),
+			wantErr:   true,
+		},
+		{
+			name:    "no signature",
+			wantErr: true,
+		},
+		{
+			name:      "not base64",
+			signature: "not a signature!",
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity, err := verifier.Verify(tt.signature)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("AWSIdentityVerifier.Verify() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil && identity.AccountID != tt.wantAccount {
+				t.Errorf("AWSIdentityVerifier.Verify() account = %s, want %s", identity.AccountID, tt.wantAccount)
+			}
+		})
+	}
+}
+
+func TestHostNames(t *testing.T) {
+	tests := []struct {
+		name     string
+		identity *ec2metadata.EC2InstanceIdentityDocument
+		want     []string
+	}{
+		{
+			name:     "private",
+			identity: &ec2metadata.EC2InstanceIdentityDocument{InstanceID: "i-1", PrivateIP: "10.0.1.2", Region: "us-west-2"},
+			want:     []string{"10.0.1.2", "ip-10-0-1-2.us-west-2.compute.internal"},
+		},
+		{
+			name:     "us-east-1",
+			identity: &ec2metadata.EC2InstanceIdentityDocument{PrivateIP: "10.0.1.2", Region: "us-east-1"},
+			want:     []string{"10.0.1.2", "ip-10-0-1-2.ec2.internal"},
+		},
+		{
+			name: "not verified",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HostNames(tt.identity); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("HostNames() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,24 +2,33 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/base64"
 	"encoding/binary"
-	"encoding/json"
 	"encoding/pem"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"net"
 	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
 	"time"
 
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/timestamp"
 	"github.com/mistsys/accord"
+	"github.com/mistsys/accord/db"
 	"github.com/mistsys/accord/id"
+	"github.com/mistsys/accord/protocol"
 
 	"golang.org/x/crypto/ssh"
+	"google.golang.org/grpc"
 )
 
 var (
@@ -83,6 +92,100 @@ func EncodeRSAPublicKey(key interface{}, comment string) (string, error) {
 	return "", fmt.Errorf("unknown key type: %T", key)
 }
 
+// readPSKs reads the PSK file, it doesn't have to exist yet
+func readPSKs(path string) db.Deployments {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return db.Deployments{}
+	}
+	deployments, err := db.ReadPSKFile(path)
+	if err != nil {
+		log.Fatalf("Unable to read %s. %s", path, err)
+	}
+	return deployments
+}
+
+func writePSKs(path string, deployments db.Deployments) {
+	if err := db.WritePSKFile(path, deployments); err != nil {
+		log.Fatalf("Failed to write to file %s. %s", path, err)
+	}
+}
+
+// lookupDeployment finds the deployment to change it, the ones from the PSK
+// files from before the names are named first, found with the MD5 key id
+func lookupDeployment(deployments db.Deployments, name string, salt string) *db.Deployment {
+	legacyKeyId, err := id.KeyID(name, salt)
+	if err != nil {
+		log.Fatalf("Failed to generate keyID %s", err)
+	}
+	deployments.NameLegacy(name, legacyKeyId)
+	deployment, err := deployments.Lookup(name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return deployment
+}
+
+// legacyKeyId is the MD5 key id, the server finds the deployments from before
+// the names with it
+func legacyKeyId(name string, salt string) uint32 {
+	keyId, err := id.KeyID(name, salt)
+	if err != nil {
+		log.Fatalf("Failed to generate keyID %s", err)
+	}
+	return keyId
+}
+
+func readKeyIDSecret(path string) []byte {
+	if path == "" {
+		log.Fatalf("-keyid.secretfile is needed for the HMAC key ids, generate one with -task=gen-keyid-secret")
+	}
+	secret, err := ioutil.ReadFile(path)
+	if err != nil {
+		log.Fatalf("Failed to read the key id secret %s", err)
+	}
+	return bytes.TrimSpace(secret)
+}
+
+// dialAdmin connects to the admin socket of the running server, it checks
+// who we are from the socket so there's nothing else to authenticate with
+func dialAdmin(path string) (protocol.AdminClient, func()) {
+	conn, err := grpc.Dial(path, grpc.WithInsecure(), grpc.WithBlock(), grpc.WithTimeout(5*time.Second),
+		grpc.WithDialer(func(addr string, timeout time.Duration) (net.Conn, error) {
+			return net.DialTimeout("unix", addr, timeout)
+		}))
+	if err != nil {
+		log.Fatalf("Unable to connect to the admin socket %s, is the server running? %s", path, err)
+	}
+	return protocol.NewAdminClient(conn), func() { conn.Close() }
+}
+
+func adminContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), time.Minute)
+}
+
+func formatTimestamp(ts *timestamp.Timestamp) string {
+	if ts == nil {
+		return "-"
+	}
+	t, err := ptypes.Timestamp(ts)
+	if err != nil {
+		return "-"
+	}
+	return t.Local().Format(time.RFC3339)
+}
+
+func printDeployment(deployment *protocol.AdminDeployment) {
+	versions := []string{}
+	for _, psk := range deployment.Psks {
+		versions = append(versions, fmt.Sprintf("%d %s", psk.Version, psk.State))
+	}
+	legacy := ""
+	if deployment.LegacyKeyId != 0 {
+		legacy = fmt.Sprintf(", MD5 key id %d still accepted", deployment.LegacyKeyId)
+	}
+	fmt.Printf("%s\tkey id %d%s\tversions %s\n", deployment.Name, deployment.KeyId, legacy, strings.Join(versions, ", "))
+}
+
 func main() {
 	certKeyPath := flag.String("certkey", "", "Path for the certificate to use for signing")
 	pubKeyPath := flag.String("pubkey", "", "SSH Public Key to sign with the cert")
@@ -91,7 +194,16 @@ func main() {
 	password := flag.String("password", "", "Password to encrypt the root key with")
 	task := flag.String("task", "genusercert", "Task to do")
 	psksFile := flag.String("path.psk", "deployments.json", "PSK Files for deployed servers shared keys")
-	hostSalt := flag.String("hostsalt", defaultSalt, "Randomly generated string to prefix requests when creating host requests")
+	hostSalt := flag.String("hostsalt", defaultSalt, "The salt of the MD5 key ids, only used to find the deployments that haven't been migrated")
+	keyIdSecretFile := flag.String("keyid.secretfile", "", "File with the installation's secret for the HMAC key ids")
+	ledgerFile := flag.String("path.ledger", "accord.db", "Path to the server's database, for revoking with -offline")
+	adminSocket := flag.String("admin.socket", "/run/accord/admin.sock", "The admin socket of the running server")
+	offline := flag.Bool("offline", false, "Edit the PSK file or the ledger directly instead of asking the server, only while it's stopped")
+	disableReason := flag.String("disable.reason", "", "Why the user is being disabled, for the records")
+	disableRevoke := flag.Bool("disable.revoke", false, "Also revoke the user certs of the disabled user that are still valid")
+	revokeType := flag.String("revoke.type", "serial", "What to revoke: serial, key_id, fingerprint or ca")
+	revokeReason := flag.String("revoke.reason", "", "Why this is being revoked, for the records")
+	caType := flag.String("catype", "", "Whether the CA being revoked is the user or host CA")
 	flag.Parse()
 
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
@@ -214,7 +326,7 @@ func main() {
 		}
 
 		fmt.Println("==== PUBLIC KEY ====")
-		fmt.Fprintf(wPubKey, sshRSA)
+		fmt.Fprint(wPubKey, sshRSA)
 
 		//fmt.Printf(string(MarshalCert(cert, comment)))
 		//fmt.Println("Public Key")
@@ -291,42 +403,297 @@ func main() {
 		//fmt.Printf("Signature: %#v\n", cert.Signature)
 		//fmt.Printf(string(MarshalCert(cert)))
 		//fmt.Println(comment)
+	case "gen-keyid-secret":
+		fmt.Println(string(accord.RandAsciiBytes(32)))
 	case "add-deployment":
 		args := flag.Args()
 		if len(args) == 0 {
 			log.Fatalf("No arguments given, usage: add-deployment <deploymentId>")
 		}
-		psks := make(map[uint32][]byte)
-		if _, err := os.Stat(*psksFile); !os.IsNotExist(err) {
-			dat, err := ioutil.ReadFile(*psksFile)
+		keyId, err := id.KeyIDHMAC(args[0], readKeyIDSecret(*keyIdSecretFile))
+		if err != nil {
+			log.Fatalf("Failed to generate keyID %s", err)
+		}
+		if !*offline {
+			admin, done := dialAdmin(*adminSocket)
+			defer done()
+			ctx, cancel := adminContext()
+			defer cancel()
+			resp, err := admin.RegisterDeployment(ctx, &protocol.AdminRegisterDeploymentRequest{Name: args[0], KeyId: keyId})
+			if err != nil {
+				log.Fatalf("%s", err)
+			}
+			fmt.Println(string(resp.Key))
+			break
+		}
+		deployments := readPSKs(*psksFile)
+		key := accord.GenerateKey()
+		if err := deployments.Register(args[0], keyId, id.KeyIDVersionHMAC, key, time.Now()); err != nil {
+			log.Fatalf("Failed to add deployment %s. %s", args[0], err)
+		}
+		fmt.Println(string(key))
+		writePSKs(*psksFile, deployments)
+	// hosts can be moved to the new version one at a time with -pskversion,
+	// the previous version keeps working until it's retired
+	case "rotate-psk":
+		args := flag.Args()
+		if len(args) == 0 {
+			log.Fatalf("No arguments given, usage: rotate-psk <deploymentId>")
+		}
+		if !*offline {
+			admin, done := dialAdmin(*adminSocket)
+			defer done()
+			ctx, cancel := adminContext()
+			defer cancel()
+			resp, err := admin.RotatePSK(ctx, &protocol.AdminRotatePSKRequest{Name: args[0], LegacyKeyId: legacyKeyId(args[0], *hostSalt)})
 			if err != nil {
-				log.Fatalf("Unable to read %s. %s", *psksFile, err)
+				log.Fatalf("%s", err)
 			}
-			err = json.Unmarshal(dat, &psks)
+			fmt.Printf("PSK=%s\nVERSION=%d\n", resp.Key, resp.Version)
+			break
+		}
+		deployments := readPSKs(*psksFile)
+		key := accord.GenerateKey()
+		version, err := lookupDeployment(deployments, args[0], *hostSalt).Rotate(key, time.Now())
+		if err != nil {
+			log.Fatalf("Failed to rotate the psk for deployment %s. %s", args[0], err)
+		}
+		fmt.Printf("PSK=%s\nVERSION=%d\n", key, version)
+		writePSKs(*psksFile, deployments)
+	case "retire-psk":
+		args := flag.Args()
+		if len(args) != 2 {
+			log.Fatalf("usage: retire-psk <deploymentId> <version>")
+		}
+		version, err := strconv.ParseUint(args[1], 10, 32)
+		if err != nil {
+			log.Fatalf("Invalid version %s", args[1])
+		}
+		if !*offline {
+			admin, done := dialAdmin(*adminSocket)
+			defer done()
+			ctx, cancel := adminContext()
+			defer cancel()
+			_, err := admin.RetirePSK(ctx, &protocol.AdminRetirePSKRequest{Name: args[0], LegacyKeyId: legacyKeyId(args[0], *hostSalt), Version: uint32(version)})
 			if err != nil {
-				log.Fatalf("Unable to unmarshal contents from %s. %s", *psksFile, err)
+				log.Fatalf("%s", err)
 			}
+			fmt.Printf("Retired version %d of deployment %s\n", version, args[0])
+			break
 		}
-		deploymentID, err := id.KeyID(args[0], *hostSalt)
+		deployments := readPSKs(*psksFile)
+		if err := lookupDeployment(deployments, args[0], *hostSalt).Retire(uint32(version)); err != nil {
+			log.Fatalf("Failed to retire the psk for deployment %s. %s", args[0], err)
+		}
+		fmt.Printf("Retired version %d of deployment %s, restart the server to stop accepting it\n", version, args[0])
+		writePSKs(*psksFile, deployments)
+	// the MD5 key id keeps working until retire-legacy-keyid, the hosts can
+	// be given -keyid.secretfile one at a time
+	case "migrate-keyid":
+		args := flag.Args()
+		if len(args) == 0 {
+			log.Fatalf("No arguments given, usage: migrate-keyid <deploymentId>")
+		}
+		keyId, err := id.KeyIDHMAC(args[0], readKeyIDSecret(*keyIdSecretFile))
 		if err != nil {
 			log.Fatalf("Failed to generate keyID %s", err)
 		}
-		if val, ok := psks[deploymentID]; ok {
-			fmt.Printf("PSK=%s\n", val)
-			log.Fatalf("psk for deployment %s=%d has already been generated", args[0], deploymentID)
+		if !*offline {
+			admin, done := dialAdmin(*adminSocket)
+			defer done()
+			ctx, cancel := adminContext()
+			defer cancel()
+			_, err := admin.MigrateKeyID(ctx, &protocol.AdminMigrateKeyIDRequest{Name: args[0], LegacyKeyId: legacyKeyId(args[0], *hostSalt), KeyId: keyId})
+			if err != nil {
+				log.Fatalf("%s", err)
+			}
+			fmt.Printf("Deployment %s has the key id %d, the MD5 one is still accepted\n", args[0], keyId)
+			break
 		}
-		key := accord.GenerateKey()
-		psks[deploymentID] = key
-		fmt.Println(string(key))
-
-		content, err := json.Marshal(psks)
+		deployments := readPSKs(*psksFile)
+		lookupDeployment(deployments, args[0], *hostSalt)
+		if err := deployments.MigrateKeyID(args[0], keyId); err != nil {
+			log.Fatalf("Failed to migrate deployment %s. %s", args[0], err)
+		}
+		fmt.Printf("Deployment %s has the key id %d, the MD5 one is still accepted\n", args[0], keyId)
+		writePSKs(*psksFile, deployments)
+	case "retire-legacy-keyid":
+		args := flag.Args()
+		if len(args) == 0 {
+			log.Fatalf("No arguments given, usage: retire-legacy-keyid <deploymentId>")
+		}
+		if !*offline {
+			admin, done := dialAdmin(*adminSocket)
+			defer done()
+			ctx, cancel := adminContext()
+			defer cancel()
+			if _, err := admin.RetireLegacyKeyID(ctx, &protocol.AdminRetireLegacyKeyIDRequest{Name: args[0]}); err != nil {
+				log.Fatalf("%s", err)
+			}
+			fmt.Printf("Deployment %s only accepts its HMAC key id now\n", args[0])
+			break
+		}
+		deployments := readPSKs(*psksFile)
+		if err := deployments.RetireLegacyKeyID(args[0]); err != nil {
+			log.Fatalf("Failed to retire the legacy key id of %s. %s", args[0], err)
+		}
+		fmt.Printf("Deployment %s only accepts its HMAC key id now, restart the server\n", args[0])
+		writePSKs(*psksFile, deployments)
+	// the deployments that still need migrating, the server counts which of
+	// them still have hosts using the MD5 key ids in hostauth_legacy_keyids
+	case "keyid-report":
+		deployments := readPSKs(*psksFile)
+		for _, name := range deployments.Legacy() {
+			deployment := deployments[name]
+			if deployment.KeyIDVersion == id.KeyIDVersionMD5 {
+				fmt.Printf("%s\tMD5 key id %d, not migrated\n", name, deployment.KeyID)
+			} else {
+				fmt.Printf("%s\tkey id %d, MD5 key id %d still accepted\n", name, deployment.KeyID, deployment.LegacyKeyID)
+			}
+		}
+	case "revoke":
+		args := flag.Args()
+		if len(args) == 0 {
+			log.Fatalf("No arguments given, usage: revoke <serial|key_id|fingerprint|ca public key file>")
+		}
+		value := args[0]
+		if db.RevocationType(*revokeType) == db.RevokeCA {
+			contents, err := ioutil.ReadFile(value)
+			if err != nil {
+				log.Fatalf("Failed to read file %s. %s", value, err)
+			}
+			value = string(bytes.TrimSpace(contents))
+		}
+		if !*offline {
+			admin, done := dialAdmin(*adminSocket)
+			defer done()
+			ctx, cancel := adminContext()
+			defer cancel()
+			resp, err := admin.Revoke(ctx, &protocol.AdminRevokeRequest{
+				Type:   *revokeType,
+				Value:  value,
+				CaType: *caType,
+				Reason: *revokeReason,
+			})
+			if err != nil {
+				log.Fatalf("%s", err)
+			}
+			fmt.Printf("Revoked %s %s, revocation list is now at version %d\n", *revokeType, args[0], resp.Version)
+			break
+		}
+		store, err := db.NewBoltStore(*ledgerFile)
+		if err != nil {
+			log.Fatalf("Failed to open %s, is the server still running? %s", *ledgerFile, err)
+		}
+		defer store.Close()
+		revokedBy := os.Getenv("USER")
+		err = store.Revoke(&db.Revocation{
+			Type:      db.RevocationType(*revokeType),
+			Value:     value,
+			CAType:    *caType,
+			Reason:    *revokeReason,
+			RevokedBy: revokedBy,
+		})
+		if err != nil {
+			log.Fatalf("Failed to revoke %s. %s", args[0], err)
+		}
+		_, version, err := store.Revocations()
+		if err != nil {
+			log.Fatalf("Failed to read the revocations %s", err)
+		}
+		fmt.Printf("Revoked %s %s, revocation list is now at version %d\n", *revokeType, args[0], version)
+	// the rest only work with the server running
+	case "users":
+		admin, done := dialAdmin(*adminSocket)
+		defer done()
+		ctx, cancel := adminContext()
+		defer cancel()
+		resp, err := admin.ListUsers(ctx, &protocol.AdminListUsersRequest{})
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "EMAIL\tCERTS\tLAST ISSUED\tLAST VALID UNTIL\tDISABLED")
+		for _, user := range resp.Users {
+			disabled := "-"
+			if user.Disabled {
+				disabled = fmt.Sprintf("by %s at %s: %s", user.DisabledBy, formatTimestamp(user.DisabledAt), user.DisabledReason)
+			}
+			fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\n", user.Email, user.Certs, formatTimestamp(user.LastIssuedAt),
+				formatTimestamp(user.LastValidUntil), disabled)
+		}
+		w.Flush()
+	case "disable-user", "enable-user":
+		args := flag.Args()
+		if len(args) != 1 {
+			log.Fatalf("usage: %s <email>", *task)
+		}
+		admin, done := dialAdmin(*adminSocket)
+		defer done()
+		ctx, cancel := adminContext()
+		defer cancel()
+		if *task == "enable-user" {
+			if _, err := admin.EnableUser(ctx, &protocol.AdminEnableUserRequest{Email: args[0]}); err != nil {
+				log.Fatalf("%s", err)
+			}
+			fmt.Printf("Enabled %s\n", args[0])
+			break
+		}
+		resp, err := admin.DisableUser(ctx, &protocol.AdminDisableUserRequest{
+			Email:  args[0],
+			Reason: *disableReason,
+			Revoke: *disableRevoke,
+		})
 		if err != nil {
-			log.Fatalf("Failed to marshal psks %s", err)
+			log.Fatalf("%s", err)
 		}
-		err = ioutil.WriteFile(*psksFile, content, 0644)
+		fmt.Printf("Disabled %s, revoked %d certs %v\n", args[0], len(resp.Revoked), resp.Revoked)
+	case "deployments":
+		admin, done := dialAdmin(*adminSocket)
+		defer done()
+		ctx, cancel := adminContext()
+		defer cancel()
+		resp, err := admin.ListDeployments(ctx, &protocol.AdminListDeploymentsRequest{})
 		if err != nil {
-			log.Fatalf("Failed to write to file %s. %s", *psksFile, err)
+			log.Fatalf("%s", err)
+		}
+		for _, deployment := range resp.Deployments {
+			printDeployment(deployment)
+		}
+	case "reload":
+		admin, done := dialAdmin(*adminSocket)
+		defer done()
+		ctx, cancel := adminContext()
+		defer cancel()
+		if _, err := admin.Reload(ctx, &protocol.AdminReloadRequest{}); err != nil {
+			log.Fatalf("%s", err)
+		}
+		fmt.Println("Reloaded the PSKs, the CAs and the authz")
+	case "cas":
+		admin, done := dialAdmin(*adminSocket)
+		defer done()
+		ctx, cancel := adminContext()
+		defer cancel()
+		resp, err := admin.ListCAs(ctx, &protocol.AdminListCAsRequest{})
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "TYPE\tID\tVALID FROM\tVALID UNTIL\tFINGERPRINT\tSTATE")
+		for _, ca := range resp.Cas {
+			state := "published"
+			switch {
+			case ca.Expired:
+				state = "expired"
+			case ca.Signing:
+				state = "signing"
+			}
+			fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\n", ca.Type, ca.Id, formatTimestamp(ca.ValidFrom),
+				formatTimestamp(ca.ValidUntil), ca.Fingerprint, state)
 		}
+		w.Flush()
+	default:
+		log.Fatalf("Don't know the task %s", *task)
 	}
 
 }
@@ -1,20 +1,26 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"log"
+	"os"
 	"os/user"
 	"path/filepath"
 	"strings"
+	"text/tabwriter"
 	"time"
 
+	"github.com/golang/protobuf/jsonpb"
 	"github.com/golang/protobuf/ptypes"
 	"github.com/mistsys/accord"
 	"github.com/mistsys/accord/client"
 	"github.com/mistsys/accord/db"
+	"github.com/mistsys/accord/id"
 	"github.com/mistsys/accord/protocol"
 
 	"google.golang.org/grpc"
@@ -53,10 +59,13 @@ func main() {
 	//overwrite := flag.Bool("o", false, "Overwrite the files")
 	//duration := flag.Duration("duration", 1*time.Hour, "How long to get the cert for")
 	deploymentId := flag.String("deploymentId", "", "ID to use for authenticating with the server")
+	pskVersion := flag.Uint("pskversion", 0, "Version of the PSK, leave it out for PSKs from before the versions")
+	hostAuthAEAD := flag.String("hostauth.aead", "aes-gcm", "Cipher for the host auth envelope: aes-gcm or chacha20-poly1305, for hosts without AES instructions")
 	hostKeysPath := flag.String("hostkeys", "/etc/ssh", "Where to read the public keys")
 	remoteUsername := flag.String("remoteusername", "", "What remote username to allow")
 	serverCert := flag.String("cert", "", "Server cert to use")
 	hostSalt := flag.String("hostsalt", defaultSalt, "Randomly generated string to prefix requests when creating host requests")
+	keyIdSecretFile := flag.String("keyid.secretfile", "", "File with the installation's secret for the HMAC key ids, the salt is used without it")
 	userKeysPath := flag.String("userkeys", "", "Where to find the user's public keys")
 	googleClientId := flag.String("google.clientid", "", "Which Google Apps ClientID to use")
 	googleClientSecret := flag.String("google.clientsecret", "", "Which Google Apps Client Secret to use, if not baked in already")
@@ -65,13 +74,31 @@ func main() {
 	userCACertsFile := flag.String("userca", "", "Where the userca file should be, defaults to /etc/ssh/users_ca.pub")
 	webserverPort := flag.Int("webserver.port", 8091, "Which port to run the auth webserver on")
 	sshdFile := flag.String("sshdconfig", "", "SSHD Configuration file, defaults to /etc/ssh/sshd_config")
+	revokedKeysFile := flag.String("revokedkeys", "/etc/ssh/revoked_keys", "Where to install the KRL, needs to match RevokedKeys in sshd_config")
 	certDuration := flag.Duration("duration", 24*time.Hour, "Duration to request certificate for")
+	forceCommand := flag.String("forcecommand", "", "The only command the user cert can run")
+	extensions := flag.String("extensions", "", "Comma separated permit-* extensions for the user cert, set it empty for none")
+	justification := flag.String("justification", "", "Why the elevation to the principals is needed")
+	elevationDuration := flag.Duration("elevation.duration", 0, "How long the elevation should last, 0 for the longest the server allows")
+	elevationId := flag.String("elevation", "", "The elevation to approve or deny")
+	reason := flag.String("reason", "", "Why the elevation was approved or denied")
+	certsType := flag.String("certs.type", "", "Only list the user or the host certs")
+	certsEmail := flag.String("certs.email", "", "Only list the user certs of this email")
+	certsHostname := flag.String("certs.hostname", "", "Only list the host certs for this hostname, can be a pattern like web-*")
+	certsDeployment := flag.String("certs.deployment", "", "Only list the host certs of this deployment")
+	certsPrincipal := flag.String("certs.principal", "", "Only list the certs with this principal, can be a pattern")
+	certsSerial := flag.Uint64("certs.serial", 0, "Only list the cert with this serial")
+	certsExpiresIn := flag.Duration("certs.expiresin", 0, "Only list the certs that are still valid and expire within this long, like 168h for this week")
+	certsRenewed := flag.Bool("certs.renewed", false, "Also list the certs that were renewed since")
+	output := flag.String("output", "table", "How to print the certs, table or json")
 	var (
-		hostnames  = stringSlice{}
-		principals = stringSlice{}
+		hostnames       = stringSlice{}
+		principals      = stringSlice{}
+		sourceAddresses = stringSlice{}
 	)
 	flag.Var(&hostnames, "host", "Hostnames to sign for")
 	flag.Var(&principals, "p", "Principals to validate for, these are usernames and server class, etc")
+	flag.Var(&sourceAddresses, "sourceaddress", "Addresses or CIDRs the user cert can be used from")
 	flag.Parse()
 
 	argv := flag.Args()
@@ -125,39 +152,9 @@ func main() {
 		defer conn.Close()
 	}
 
-	switch *task {
-	case "hostcert":
-		pskStore := db.NewSinglePSKStore(*deploymentId, *hostSalt, *psk)
-		if pskStore == nil {
-			log.Fatalf("cannot initialize the Simple PSK Store")
-		}
-		c := protocol.NewCertClient(conn)
-		log.Println("Starting authentication for host")
-		host := &client.Host{
-			Client:       c,
-			Dryrun:       *dryrun,
-			PSKStore:     pskStore,
-			Salt:         *hostSalt,
-			DeploymentId: *deploymentId,
-			KeysDir:      *hostKeysPath,
-			Hostnames:    hostnames,
-		}
-		if *insecure {
-			host.Dryrun = true
-		}
-
-		uuid, err := host.Authenticate(context.Background())
-		if err != nil {
-			log.Fatalf("Failed to authenticate the host with cert server %s", err)
-		}
-		log.Printf("uuid: %s\n", uuid)
-		err = host.RequestCerts(context.Background(), 30*24*time.Hour)
-		if err != nil {
-			log.Fatalf("Failed to get the certs %s", err)
-		}
-		close(done)
-	case "usercert":
-		c := protocol.NewCertClient(conn)
+	// signs in with Google and gets a session from the server, for the
+	// tasks done as the user
+	authenticateUser := func(c protocol.CertClient) (*client.User, string) {
 		log.Println("Starting authentication for user")
 		var (
 			clientId     string
@@ -190,6 +187,71 @@ func main() {
 			log.Fatalf("Failed to authenticate user: %s", err)
 		}
 
+		usr, err := user.Current()
+		if err != nil {
+			log.Fatal(err)
+		}
+		u := client.NewUserWithToken(c, tok)
+		u.SetUsername(usr.Username)
+		ok, email, err := u.CheckAuthorization(context.Background())
+		if err != nil {
+			log.Fatalf("Failed to check authorization for the user: %s", err)
+		}
+		if !ok {
+			log.Fatalf("Invalid state reached for user cert, cannot continue further")
+		}
+		return u, email
+	}
+
+	switch *task {
+	case "hostcert":
+		var keyIdSecret []byte
+		if *keyIdSecretFile != "" {
+			keyIdSecret, err = ioutil.ReadFile(*keyIdSecretFile)
+			if err != nil {
+				log.Fatalf("Failed to read the key id secret %s", err)
+			}
+			keyIdSecret = bytes.TrimSpace(keyIdSecret)
+		}
+		keyId, _, err := id.DeploymentKeyID(*deploymentId, *hostSalt, keyIdSecret)
+		if err != nil {
+			log.Fatalf("cannot get the key id for the deployment %s", err)
+		}
+		pskStore := db.NewSinglePSKStore(keyId, *psk, uint32(*pskVersion))
+		aead, err := accord.ParseAEAD(*hostAuthAEAD)
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+		c := protocol.NewCertClient(conn)
+		log.Println("Starting authentication for host")
+		host := &client.Host{
+			Client:       c,
+			Dryrun:       *dryrun,
+			PSKStore:     pskStore,
+			Salt:         *hostSalt,
+			KeyIDSecret:  keyIdSecret,
+			DeploymentId: *deploymentId,
+			PSKVersion:   uint32(*pskVersion),
+			AEAD:         aead,
+			KeysDir:      *hostKeysPath,
+			Hostnames:    hostnames,
+		}
+		if *insecure {
+			host.Dryrun = true
+		}
+
+		uuid, err := host.Authenticate(context.Background())
+		if err != nil {
+			log.Fatalf("Failed to authenticate the host with cert server %s", err)
+		}
+		log.Printf("uuid: %s\n", uuid)
+		err = host.RequestCerts(context.Background(), 30*24*time.Hour)
+		if err != nil {
+			log.Fatalf("Failed to get the certs %s", err)
+		}
+		close(done)
+	case "usercert":
+		c := protocol.NewCertClient(conn)
 		usr, err := user.Current()
 		if err != nil {
 			log.Fatal(err)
@@ -205,18 +267,27 @@ func main() {
 		}
 		// I don't like the pattern a lot, but I'm not sure the gain is for making a builder pattern
 		// TODO: think more about this
-		user := client.NewUserWithToken(c, tok)
-		user.SetUsername(usr.Username)
+		user, email := authenticateUser(c)
 		user.SetRemoteUsername(*remoteUsername)
 		user.SetKeysDir(keysDir)
 		user.SetPrincipals(principals.Value())
-		ok, email, err := user.CheckAuthorization(context.Background())
-		if err != nil {
-			log.Fatalf("Failed to check authorization for the user: %s", err)
-		}
-		if !ok {
-			log.Fatalf("Invalid state reached for user cert, cannot continue further")
+		permissions := accord.CertPermissions{
+			ForceCommand:    *forceCommand,
+			SourceAddresses: sourceAddresses.Value(),
 		}
+		// only restrict the extensions if the flag was given, even if it's empty
+		flag.Visit(func(f *flag.Flag) {
+			if f.Name != "extensions" {
+				return
+			}
+			permissions.Extensions = []string{}
+			for _, extension := range strings.Split(*extensions, ",") {
+				if extension != "" {
+					permissions.Extensions = append(permissions.Extensions, extension)
+				}
+			}
+		})
+		user.SetPermissions(permissions)
 
 		err = user.RequestCerts(context.Background(), email, *certDuration)
 		if err != nil {
@@ -224,6 +295,81 @@ func main() {
 		}
 		close(done)
 		//log.Fatalf("Not done yet")
+	case "elevate":
+		c := protocol.NewCertClient(conn)
+		user, _ := authenticateUser(c)
+		elevation, err := user.RequestElevation(context.Background(), principals.Value(), *justification, *elevationDuration)
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+		log.Printf("Asked for elevation %s, once it's approved get the certs with -task=usercert for at most %s", elevation.Id, time.Duration(elevation.DurationSeconds)*time.Second)
+		close(done)
+	case "approve", "deny":
+		c := protocol.NewCertClient(conn)
+		user, _ := authenticateUser(c)
+		elevation, err := user.DecideElevation(context.Background(), *elevationId, *task == "approve", *reason)
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+		log.Printf("Elevation %s of %s to %v is %s", elevation.Id, elevation.UserId, elevation.Principals, elevation.State)
+		close(done)
+	case "elevations":
+		c := protocol.NewCertClient(conn)
+		user, _ := authenticateUser(c)
+		elevations, err := user.Elevations(context.Background())
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+		for _, e := range elevations {
+			fmt.Printf("%s\t%s\t%s\t%s\t%s\t%s\n", e.Id, e.State, e.UserId, strings.Join(e.Principals, ","),
+				time.Duration(e.DurationSeconds)*time.Second, e.Justification)
+		}
+		close(done)
+	case "certs":
+		if *output != "table" && *output != "json" {
+			log.Fatalf("Unknown output %s, it's table or json", *output)
+		}
+		c := protocol.NewCertClient(conn)
+		user, _ := authenticateUser(c)
+		query := &protocol.CertListRequest{
+			CertType:   *certsType,
+			Email:      *certsEmail,
+			Hostname:   *certsHostname,
+			Deployment: *certsDeployment,
+			Principal:  *certsPrincipal,
+			Serial:     *certsSerial,
+			Latest:     !*certsRenewed,
+		}
+		if *certsExpiresIn > 0 {
+			now := time.Now()
+			query.ExpiresAfter, _ = ptypes.TimestampProto(now)
+			query.ExpiresBefore, _ = ptypes.TimestampProto(now.Add(*certsExpiresIn))
+		}
+		certs, err := user.ListCerts(context.Background(), protocol.NewCertQueryClient(conn), query)
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+		if *output == "json" {
+			m := jsonpb.Marshaler{Indent: "  "}
+			if err := m.Marshal(os.Stdout, &protocol.CertListResponse{Certs: certs}); err != nil {
+				log.Fatalf("Failed to print the certs as json: %s", err)
+			}
+			fmt.Println()
+		} else {
+			w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+			fmt.Fprintln(w, "SERIAL\tTYPE\tVALID UNTIL\tKEY ID\tPRINCIPALS\tDEPLOYMENT/EMAIL")
+			for _, cert := range certs {
+				validUntil, _ := ptypes.Timestamp(cert.ValidUntil)
+				owner := cert.Email
+				if cert.CertType == db.HostCertType {
+					owner = cert.Deployment
+				}
+				fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n", cert.Serial, cert.CertType,
+					validUntil.Local().Format(time.RFC3339), cert.KeyId, strings.Join(cert.Principals, ","), owner)
+			}
+			w.Flush()
+		}
+		close(done)
 	case "trustedcerts":
 		c := protocol.NewCertClient(conn)
 		// Queries and prints out the trusted certs
@@ -277,6 +423,16 @@ func main() {
 		}
 		close(done)
 
+	case "revokedkeys":
+		c := protocol.NewCertClient(conn)
+		host := client.NewHost(c)
+		version, err := host.UpdateRevokedKeys(context.Background(), *revokedKeysFile)
+		if err != nil {
+			log.Fatalf("Failed to update revoked keys file: %s. %s", *revokedKeysFile, err)
+		}
+		log.Printf("Installed revoked keys version %d to %s", version, *revokedKeysFile)
+		close(done)
+
 	case "updatesshd":
 		if *sshdFile == "" {
 			defaultPath := "/etc/ssh/sshd_config"
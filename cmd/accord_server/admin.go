@@ -0,0 +1,445 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/mistsys/accord"
+	"github.com/mistsys/accord/certserver"
+	"github.com/mistsys/accord/db"
+	"github.com/mistsys/accord/id"
+	"github.com/mistsys/accord/protocol"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+)
+
+// adminServer does what used to be editing the files and restarting the
+// server, everything that changes something is audited
+type adminServer struct {
+	store   *db.BoltStore
+	server  *certserver.AccordServer
+	reloads *reloader
+	auditor accord.Auditor
+	// empty when the PSKs aren't from a file
+	psksFile string
+	// the PSK file is read, changed and written back
+	mu sync.Mutex
+}
+
+func (a *adminServer) audit(ctx context.Context, event *accord.AuditEvent) {
+	event.Time = time.Now()
+	event.Actor = adminActor(ctx)
+	if err := a.auditor.Audit(event); err != nil {
+		log.Printf("Failed to audit %s. %s", event.Type, err)
+	}
+}
+
+func (a *adminServer) Revoke(ctx context.Context, req *protocol.AdminRevokeRequest) (*protocol.AdminRevokeResponse, error) {
+	err := a.store.Revoke(&db.Revocation{
+		Type:      db.RevocationType(req.Type),
+		Value:     req.Value,
+		CAType:    req.CaType,
+		Reason:    req.Reason,
+		RevokedBy: adminActor(ctx),
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to revoke %s %s", req.Type, req.Value)
+	}
+	event := &accord.AuditEvent{
+		Type:    accord.AuditRevoke,
+		Details: map[string]string{"type": req.Type, "value": req.Value, "ca_type": req.CaType, "reason": req.Reason},
+	}
+	if db.RevocationType(req.Type) == db.RevokeSerial {
+		event.Serial, _ = strconv.ParseUint(req.Value, 10, 64)
+	}
+	a.audit(ctx, event)
+	_, version, err := a.store.Revocations()
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to read the revocations")
+	}
+	return &protocol.AdminRevokeResponse{Version: version}, nil
+}
+
+func adminUserPb(user *db.UserSummary) *protocol.AdminUser {
+	pb := &protocol.AdminUser{
+		Email: user.Email,
+		Certs: uint32(user.Certs),
+	}
+	if user.Certs > 0 {
+		pb.LastIssuedAt, _ = ptypes.TimestampProto(user.LastIssuedAt)
+		pb.LastValidUntil, _ = ptypes.TimestampProto(user.LastValidUntil)
+	}
+	if user.Disabled != nil {
+		pb.Disabled = true
+		pb.DisabledReason = user.Disabled.Reason
+		pb.DisabledBy = user.Disabled.DisabledBy
+		pb.DisabledAt, _ = ptypes.TimestampProto(user.Disabled.DisabledAt)
+	}
+	return pb
+}
+
+func (a *adminServer) ListUsers(ctx context.Context, req *protocol.AdminListUsersRequest) (*protocol.AdminListUsersResponse, error) {
+	users, err := a.store.Users()
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to list the users")
+	}
+	resp := &protocol.AdminListUsersResponse{}
+	for _, user := range users {
+		resp.Users = append(resp.Users, adminUserPb(user))
+	}
+	return resp, nil
+}
+
+func (a *adminServer) user(email string) (*protocol.AdminUser, error) {
+	users, err := a.store.Users()
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to list the users")
+	}
+	for _, user := range users {
+		if strings.EqualFold(user.Email, email) {
+			return adminUserPb(user), nil
+		}
+	}
+	return &protocol.AdminUser{Email: email}, nil
+}
+
+// revokeUserCerts revokes the certs of the user that haven't expired yet
+func (a *adminServer) revokeUserCerts(ctx context.Context, email string, reason string) ([]uint64, error) {
+	filter := &db.CertFilter{CertType: db.UserCertType, Email: email, ExpiresAfter: time.Now()}
+	revoked := []uint64{}
+	for {
+		records, next, err := a.store.ListCerts(filter)
+		if err != nil {
+			return revoked, err
+		}
+		for _, record := range records {
+			err := a.store.Revoke(&db.Revocation{
+				Type:      db.RevokeSerial,
+				Value:     strconv.FormatUint(record.Serial, 10),
+				Reason:    reason,
+				RevokedBy: adminActor(ctx),
+			})
+			if err != nil {
+				return revoked, err
+			}
+			revoked = append(revoked, record.Serial)
+		}
+		if next == 0 {
+			return revoked, nil
+		}
+		filter.After = next
+	}
+}
+
+func (a *adminServer) DisableUser(ctx context.Context, req *protocol.AdminDisableUserRequest) (*protocol.AdminUserResponse, error) {
+	err := a.store.DisableUser(&db.DisabledUser{
+		Email:      req.Email,
+		Reason:     req.Reason,
+		DisabledBy: adminActor(ctx),
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to disable %s", req.Email)
+	}
+	event := &accord.AuditEvent{
+		Type:    accord.AuditUserDisabled,
+		Details: map[string]string{"email": req.Email, "reason": req.Reason},
+	}
+	resp := &protocol.AdminUserResponse{}
+	if req.Revoke {
+		resp.Revoked, err = a.revokeUserCerts(ctx, req.Email, fmt.Sprintf("%s was disabled: %s", req.Email, req.Reason))
+		serials := []string{}
+		for _, serial := range resp.Revoked {
+			serials = append(serials, strconv.FormatUint(serial, 10))
+		}
+		event.Details["revoked"] = strings.Join(serials, ",")
+		if err != nil {
+			event.Error = err.Error()
+		}
+	}
+	a.audit(ctx, event)
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s is disabled but revoking the certs failed after %d", req.Email, len(resp.Revoked))
+	}
+	if resp.User, err = a.user(req.Email); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (a *adminServer) EnableUser(ctx context.Context, req *protocol.AdminEnableUserRequest) (*protocol.AdminUserResponse, error) {
+	if err := a.store.EnableUser(req.Email); err != nil {
+		return nil, errors.Wrapf(err, "Failed to enable %s", req.Email)
+	}
+	a.audit(ctx, &accord.AuditEvent{
+		Type:    accord.AuditUserEnabled,
+		Details: map[string]string{"email": req.Email},
+	})
+	user, err := a.user(req.Email)
+	if err != nil {
+		return nil, err
+	}
+	return &protocol.AdminUserResponse{User: user}, nil
+}
+
+func adminDeploymentPb(name string, deployment *db.Deployment) *protocol.AdminDeployment {
+	pb := &protocol.AdminDeployment{
+		Name:         name,
+		KeyId:        deployment.KeyID,
+		KeyIdVersion: int32(deployment.KeyIDVersion),
+		LegacyKeyId:  deployment.LegacyKeyID,
+	}
+	for _, psk := range deployment.PSKs {
+		createdAt, _ := ptypes.TimestampProto(psk.CreatedAt)
+		pb.Psks = append(pb.Psks, &protocol.AdminPSKVersion{
+			Version:   psk.Version,
+			State:     string(psk.State),
+			CreatedAt: createdAt,
+		})
+	}
+	return pb
+}
+
+func (a *adminServer) readPSKs() (db.Deployments, error) {
+	if a.psksFile == "" {
+		return nil, errors.New("The server isn't using a PSK file, start it with -path.psks")
+	}
+	return db.ReadPSKFile(a.psksFile)
+}
+
+// editPSKs changes the PSK file and reloads it, the server only uses the
+// change once it's written
+func (a *adminServer) editPSKs(ctx context.Context, edit func(deployments db.Deployments) error) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	deployments, err := a.readPSKs()
+	if err != nil {
+		return err
+	}
+	if err := edit(deployments); err != nil {
+		return err
+	}
+	if err := db.WritePSKFile(a.psksFile, deployments); err != nil {
+		return errors.Wrapf(err, "Failed to write %s", a.psksFile)
+	}
+	return a.reloads.reload(adminActor(ctx), false)
+}
+
+func (a *adminServer) ListDeployments(ctx context.Context, req *protocol.AdminListDeploymentsRequest) (*protocol.AdminListDeploymentsResponse, error) {
+	deployments, err := a.readPSKs()
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for name := range deployments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	resp := &protocol.AdminListDeploymentsResponse{}
+	for _, name := range names {
+		resp.Deployments = append(resp.Deployments, adminDeploymentPb(name, deployments[name]))
+	}
+	return resp, nil
+}
+
+func (a *adminServer) RegisterDeployment(ctx context.Context, req *protocol.AdminRegisterDeploymentRequest) (*protocol.AdminDeploymentResponse, error) {
+	if req.Name == "" || req.KeyId == 0 {
+		return nil, errors.New("Registering a deployment needs its name and HMAC key id")
+	}
+	key := accord.GenerateKey()
+	resp := &protocol.AdminDeploymentResponse{Key: key, Version: 1}
+	err := a.editPSKs(ctx, func(deployments db.Deployments) error {
+		if err := deployments.Register(req.Name, req.KeyId, id.KeyIDVersionHMAC, key, time.Now()); err != nil {
+			return err
+		}
+		resp.Deployment = adminDeploymentPb(req.Name, deployments[req.Name])
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to register deployment %s", req.Name)
+	}
+	a.audit(ctx, &accord.AuditEvent{
+		Type:    accord.AuditDeploymentRegistered,
+		Details: map[string]string{"deployment": req.Name, "key_id": strconv.FormatUint(uint64(req.KeyId), 10)},
+	})
+	return resp, nil
+}
+
+func (a *adminServer) RotatePSK(ctx context.Context, req *protocol.AdminRotatePSKRequest) (*protocol.AdminDeploymentResponse, error) {
+	key := accord.GenerateKey()
+	resp := &protocol.AdminDeploymentResponse{Key: key}
+	err := a.editPSKs(ctx, func(deployments db.Deployments) error {
+		deployments.NameLegacy(req.Name, req.LegacyKeyId)
+		deployment, err := deployments.Lookup(req.Name)
+		if err != nil {
+			return err
+		}
+		if resp.Version, err = deployment.Rotate(key, time.Now()); err != nil {
+			return err
+		}
+		resp.Deployment = adminDeploymentPb(req.Name, deployment)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to rotate the PSK of deployment %s", req.Name)
+	}
+	a.audit(ctx, &accord.AuditEvent{
+		Type:    accord.AuditPSKRotated,
+		Details: map[string]string{"deployment": req.Name, "version": strconv.FormatUint(uint64(resp.Version), 10)},
+	})
+	return resp, nil
+}
+
+func (a *adminServer) RetirePSK(ctx context.Context, req *protocol.AdminRetirePSKRequest) (*protocol.AdminDeploymentResponse, error) {
+	resp := &protocol.AdminDeploymentResponse{Version: req.Version}
+	err := a.editPSKs(ctx, func(deployments db.Deployments) error {
+		deployments.NameLegacy(req.Name, req.LegacyKeyId)
+		deployment, err := deployments.Lookup(req.Name)
+		if err != nil {
+			return err
+		}
+		if err := deployment.Retire(req.Version); err != nil {
+			return err
+		}
+		resp.Deployment = adminDeploymentPb(req.Name, deployment)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to retire version %d of deployment %s", req.Version, req.Name)
+	}
+	a.audit(ctx, &accord.AuditEvent{
+		Type:    accord.AuditPSKRetired,
+		Details: map[string]string{"deployment": req.Name, "version": strconv.FormatUint(uint64(req.Version), 10)},
+	})
+	return resp, nil
+}
+
+func (a *adminServer) MigrateKeyID(ctx context.Context, req *protocol.AdminMigrateKeyIDRequest) (*protocol.AdminDeploymentResponse, error) {
+	if req.Name == "" || req.KeyId == 0 {
+		return nil, errors.New("Migrating a deployment needs its name and HMAC key id")
+	}
+	resp := &protocol.AdminDeploymentResponse{}
+	err := a.editPSKs(ctx, func(deployments db.Deployments) error {
+		deployments.NameLegacy(req.Name, req.LegacyKeyId)
+		if err := deployments.MigrateKeyID(req.Name, req.KeyId); err != nil {
+			return err
+		}
+		resp.Deployment = adminDeploymentPb(req.Name, deployments[req.Name])
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to migrate deployment %s", req.Name)
+	}
+	a.audit(ctx, &accord.AuditEvent{
+		Type:    accord.AuditKeyIDMigrated,
+		Details: map[string]string{"deployment": req.Name, "key_id": strconv.FormatUint(uint64(req.KeyId), 10)},
+	})
+	return resp, nil
+}
+
+func (a *adminServer) RetireLegacyKeyID(ctx context.Context, req *protocol.AdminRetireLegacyKeyIDRequest) (*protocol.AdminDeploymentResponse, error) {
+	resp := &protocol.AdminDeploymentResponse{}
+	err := a.editPSKs(ctx, func(deployments db.Deployments) error {
+		if err := deployments.RetireLegacyKeyID(req.Name); err != nil {
+			return err
+		}
+		resp.Deployment = adminDeploymentPb(req.Name, deployments[req.Name])
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to retire the legacy key id of deployment %s", req.Name)
+	}
+	a.audit(ctx, &accord.AuditEvent{
+		Type:    accord.AuditLegacyKeyIDRetired,
+		Details: map[string]string{"deployment": req.Name},
+	})
+	return resp, nil
+}
+
+// Reload is audited by the reloader
+func (a *adminServer) Reload(ctx context.Context, req *protocol.AdminReloadRequest) (*protocol.AdminReloadResponse, error) {
+	if err := a.reloads.reload(adminActor(ctx), true); err != nil {
+		return nil, err
+	}
+	return &protocol.AdminReloadResponse{}, nil
+}
+
+func (a *adminServer) ListCAs(ctx context.Context, req *protocol.AdminListCAsRequest) (*protocol.AdminListCAsResponse, error) {
+	resp := &protocol.AdminListCAsResponse{}
+	for _, ca := range a.server.CertManager().CAs() {
+		pb := &protocol.AdminCA{
+			Type:        string(ca.Type),
+			Id:          uint64(ca.Id),
+			PublicKey:   ca.PublicKey,
+			Fingerprint: ca.Fingerprint,
+			Signing:     ca.Signing,
+			Expired:     ca.Expired,
+		}
+		pb.ValidFrom, _ = ptypes.TimestampProto(ca.ValidFrom)
+		// the CAs that never expire have none
+		if !ca.ValidUntil.IsZero() {
+			pb.ValidUntil, _ = ptypes.TimestampProto(ca.ValidUntil)
+		}
+		resp.Cas = append(resp.Cas, pb)
+	}
+	return resp, nil
+}
+
+// parseIds reads a comma separated list of uids or gids
+func parseIds(list string) (map[uint32]bool, error) {
+	ids := map[uint32]bool{}
+	for _, s := range strings.Split(list, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		n, err := strconv.ParseUint(s, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid id %q", s)
+		}
+		ids[uint32(n)] = true
+	}
+	return ids, nil
+}
+
+// serveAdmin serves the admin service on the unix socket, only to the
+// allowed uids and gids. The socket is only accessible by the owner and
+// the group as well
+func serveAdmin(path string, creds *peerCredentials, admin *adminServer, opts ...grpc.ServerOption) error {
+	if err := peerCredSupported(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return errors.Wrapf(err, "Failed to create the directory of the admin socket %s", path)
+	}
+	// a socket left behind by a server that didn't stop cleanly, the ledger
+	// can only be open in one server so it's not in use
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+	lis, err := net.Listen("unix", path)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to listen on the admin socket %s", path)
+	}
+	if err := os.Chmod(path, 0660); err != nil {
+		lis.Close()
+		return errors.Wrapf(err, "Failed to restrict the admin socket %s", path)
+	}
+	server := grpc.NewServer(append(opts, grpc.Creds(creds))...)
+	protocol.RegisterAdminServer(server, admin)
+	go func() {
+		if err := server.Serve(lis); err != nil {
+			log.Printf("The admin socket stopped. %s", err)
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	"github.com/mistsys/accord/certserver"
+	"github.com/mistsys/accord/status"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// the gRPC services the health service answers for, besides the server as a
+// whole
+var healthServices = []string{"", "protocol.Cert", "protocol.CertQuery"}
+
+// healthChecker runs the readiness checks, for /readyz on the status port and
+// the gRPC health service
+type healthChecker struct {
+	server        *certserver.AccordServer
+	health        *health.Server
+	expiryWarning time.Duration
+}
+
+func newHealthChecker(server *certserver.AccordServer, expiryWarning time.Duration) *healthChecker {
+	h := &healthChecker{
+		server:        server,
+		health:        health.NewServer(),
+		expiryWarning: expiryWarning,
+	}
+	// not serving until the first check
+	for _, service := range healthServices {
+		h.health.SetServingStatus(service, healthpb.HealthCheckResponse_NOT_SERVING)
+	}
+	return h
+}
+
+func (h *healthChecker) check() {
+	now := time.Now()
+	for _, check := range h.server.CheckHealth(h.expiryWarning) {
+		result := status.Check{
+			Ready:   check.Err == nil,
+			Warning: check.Warning,
+			Checked: now,
+		}
+		if check.Err != nil {
+			result.Error = check.Err.Error()
+			log.Printf("Health check %s failed. %s", check.Name, check.Err)
+		}
+		if check.Warning != "" {
+			log.Printf("Health check %s warning. %s", check.Name, check.Warning)
+		}
+		status.SetCheck(check.Name, result)
+	}
+	serving := healthpb.HealthCheckResponse_NOT_SERVING
+	if ready, _ := status.Ready(); ready {
+		serving = healthpb.HealthCheckResponse_SERVING
+	}
+	for _, service := range healthServices {
+		h.health.SetServingStatus(service, serving)
+	}
+}
+
+// watch checks every interval, the first one right away
+func (h *healthChecker) watch(interval time.Duration) {
+	h.check()
+	for range time.Tick(interval) {
+		h.check()
+	}
+}
@@ -0,0 +1,21 @@
+//go:build !linux
+// +build !linux
+
+package main
+
+import (
+	"errors"
+	"net"
+)
+
+var errNoPeerCred = errors.New("The peer credentials of the admin socket can only be checked on Linux, run with -admin.socket= to not serve it")
+
+// peerCredSupported fails the admin socket up front, otherwise every
+// connection would be turned away
+func peerCredSupported() error {
+	return errNoPeerCred
+}
+
+func getPeerCred(conn *net.UnixConn) (peerCred, error) {
+	return peerCred{}, errNoPeerCred
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/mistsys/accord"
+	"github.com/mistsys/accord/certserver"
+	"github.com/mistsys/accord/db"
+)
+
+// update is what a reload hands over to the server, the parts that didn't
+// change are nil
+type update struct {
+	pskStore    accord.PSKStore
+	certManager *accord.CertManager
+	authz       accord.Authz
+}
+
+// component is a part of the server that's loaded from files
+type component struct {
+	name  string
+	files []string
+	// loads and checks the new one, returns what's in it for the audit event
+	load func(u *update) ([]string, error)
+
+	fingerprint string
+	summary     []string
+	// the last fingerprint that failed to load, or the error reading the
+	// files, so it's not retried until the files change again
+	failed string
+}
+
+// reloader reloads the PSKs, the CAs and the authz when their files change or
+// on SIGHUP. Everything that changed is loaded and checked first, then the
+// server switches to all of it at once. If anything fails to load the server
+// keeps what it has
+type reloader struct {
+	server     *certserver.AccordServer
+	auditor    accord.Auditor
+	components []*component
+	mu         sync.Mutex
+}
+
+func newReloader(server *certserver.AccordServer, auditor accord.Auditor) *reloader {
+	return &reloader{
+		server:  server,
+		auditor: auditor,
+	}
+}
+
+// add takes the summary of what the server was started with
+func (r *reloader) add(name string, files []string, summary []string, load func(u *update) ([]string, error)) {
+	fingerprint, err := fingerprintFiles(files)
+	if err != nil {
+		log.Printf("Cannot fingerprint the %s files, it's reloaded on the first check. %s", name, err)
+	}
+	r.components = append(r.components, &component{
+		name:        name,
+		files:       files,
+		load:        load,
+		fingerprint: fingerprint,
+		summary:     summary,
+	})
+}
+
+// reload loads the components whose files changed, or all of them if forced
+func (r *reloader) reload(actor string, force bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	u := &update{}
+	details := map[string]string{}
+	changed := []*component{}
+	fingerprints := map[string]string{}
+	summaries := map[string][]string{}
+	for _, c := range r.components {
+		fingerprint, err := fingerprintFiles(c.files)
+		if err != nil {
+			// the files can't be read, they're likely being replaced
+			if !force && c.failed == err.Error() {
+				continue
+			}
+			return r.failed(actor, c, err.Error(), err)
+		}
+		if !force && (fingerprint == c.fingerprint || fingerprint == c.failed) {
+			continue
+		}
+		summary, err := c.load(u)
+		if err != nil {
+			return r.failed(actor, c, fingerprint, err)
+		}
+		changed = append(changed, c)
+		fingerprints[c.name] = fingerprint
+		summaries[c.name] = summary
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+
+	r.server.Reload(u.pskStore, u.certManager, u.authz)
+
+	names := []string{}
+	for _, c := range changed {
+		added, removed := diffSummaries(c.summary, summaries[c.name])
+		details[c.name+".added"] = strings.Join(added, "; ")
+		details[c.name+".removed"] = strings.Join(removed, "; ")
+		details[c.name+".sha256"] = fingerprints[c.name]
+		c.fingerprint = fingerprints[c.name]
+		c.summary = summaries[c.name]
+		c.failed = ""
+		names = append(names, c.name)
+	}
+	log.Printf("Reloaded %s", strings.Join(names, ", "))
+	return r.auditor.Audit(&accord.AuditEvent{
+		Time:    time.Now(),
+		Type:    accord.AuditReload,
+		Actor:   actor,
+		Details: details,
+	})
+}
+
+func (r *reloader) failed(actor string, c *component, failed string, err error) error {
+	c.failed = failed
+	auditErr := r.auditor.Audit(&accord.AuditEvent{
+		Time:  time.Now(),
+		Type:  accord.AuditReloadFailed,
+		Actor: actor,
+		Error: err.Error(),
+		Details: map[string]string{
+			"component": c.name,
+		},
+	})
+	if auditErr != nil {
+		log.Printf("Failed to audit %s for %s. %s", accord.AuditReloadFailed, c.name, auditErr)
+	}
+	return fmt.Errorf("Failed to reload %s, keeping the current one. %s", c.name, err)
+}
+
+// watch reloads on SIGHUP, and checks the files for changes every interval
+// unless it's 0
+func (r *reloader) watch(interval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	var tick <-chan time.Time
+	if interval > 0 {
+		tick = time.NewTicker(interval).C
+	}
+	for {
+		var err error
+		select {
+		case <-hup:
+			err = r.reload("SIGHUP", true)
+		case <-tick:
+			err = r.reload("file-watch", false)
+		}
+		if err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// fingerprintFiles hashes the contents of the files, and of the files right
+// in the directories
+func fingerprintFiles(paths []string) (string, error) {
+	h := sha256.New()
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return "", err
+		}
+		files := []string{path}
+		if info.IsDir() {
+			entries, err := ioutil.ReadDir(path)
+			if err != nil {
+				return "", err
+			}
+			files = files[:0]
+			for _, entry := range entries {
+				if entry.Mode().IsRegular() {
+					files = append(files, filepath.Join(path, entry.Name()))
+				}
+			}
+		}
+		for _, f := range files {
+			contents, err := ioutil.ReadFile(f)
+			if err != nil {
+				return "", err
+			}
+			fmt.Fprintf(h, "%s %d\n", f, len(contents))
+			h.Write(contents)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func diffSummaries(before []string, after []string) ([]string, []string) {
+	was := map[string]bool{}
+	for _, s := range before {
+		was[s] = true
+	}
+	is := map[string]bool{}
+	added := []string{}
+	for _, s := range after {
+		is[s] = true
+		if !was[s] {
+			added = append(added, s)
+		}
+	}
+	removed := []string{}
+	for _, s := range before {
+		if !is[s] {
+			removed = append(removed, s)
+		}
+	}
+	return added, removed
+}
+
+// the summaries are what the audit events say changed, never the keys
+
+func pskSummary(deployments db.Deployments) []string {
+	summary := []string{}
+	for name, deployment := range deployments {
+		for _, psk := range deployment.PSKs {
+			summary = append(summary, fmt.Sprintf("%s key id %d version %d %s", name, deployment.KeyID, psk.Version, psk.State))
+		}
+		if deployment.LegacyKeyID != 0 {
+			summary = append(summary, fmt.Sprintf("%s legacy key id %d", name, deployment.LegacyKeyID))
+		}
+	}
+	sort.Strings(summary)
+	return summary
+}
+
+func certSummary(certManager *accord.CertManager) []string {
+	summary := []string{}
+	for _, ca := range certManager.HostCAs() {
+		summary = append(summary, fmt.Sprintf("host CA %d valid %s", ca.Id, caValidity(ca)))
+	}
+	for _, ca := range certManager.UserCAs() {
+		summary = append(summary, fmt.Sprintf("user CA %d valid %s", ca.Id, caValidity(ca)))
+	}
+	return summary
+}
+
+func caValidity(ca accord.CAPublic) string {
+	if ca.ValidUntil.IsZero() {
+		return fmt.Sprintf("from %s, never expires", ca.ValidFrom.Format(time.RFC3339))
+	}
+	return fmt.Sprintf("%s to %s", ca.ValidFrom.Format(time.RFC3339), ca.ValidUntil.Format(time.RFC3339))
+}
+
+func authzSummary(authz accord.Authz) []string {
+	summary := []string{}
+	switch a := authz.(type) {
+	case *accord.ElevatedAuthz:
+		summary = authzSummary(a.Authz())
+		for _, rule := range a.Policy().Rules {
+			summary = append(summary, fmt.Sprintf("elevation %s: approvers %s for %s", strings.Join(rule.Principals, ","),
+				strings.Join(rule.Approvers, ","), rule.MaxDuration))
+		}
+	case *accord.SimpleAuth:
+		for _, p := range a.Principals {
+			summary = append(summary, "principal "+p)
+		}
+		for _, user := range a.AdminUsers {
+			summary = append(summary, "admin "+user)
+		}
+		for user, principals := range a.AccessMap {
+			summary = append(summary, fmt.Sprintf("user %s: %s", user, strings.Join(principals, ",")))
+		}
+		for _, rule := range a.Deny {
+			summary = append(summary, fmt.Sprintf("deny %s: %s", strings.Join(rule.Users, ","), strings.Join(rule.Principals, ",")))
+		}
+		summary = append(summary, permissionsSummary(a.UserPermissions, a.PrincipalPermissions)...)
+	case *accord.GroupAuth:
+		for _, p := range a.Principals {
+			summary = append(summary, "principal "+p)
+		}
+		for _, group := range a.AdminGroups {
+			summary = append(summary, "admin group "+group)
+		}
+		for group, principals := range a.GroupPrincipals {
+			summary = append(summary, fmt.Sprintf("group %s: %s", group, strings.Join(principals, ",")))
+		}
+		for user, override := range a.UserOverrides {
+			summary = append(summary, fmt.Sprintf("user %s: grant %s deny %s", user, strings.Join(override.Grant, ","), strings.Join(override.Deny, ",")))
+		}
+		for _, rule := range a.Deny {
+			summary = append(summary, fmt.Sprintf("deny %s: %s", strings.Join(rule.Users, ","), strings.Join(rule.Principals, ",")))
+		}
+		summary = append(summary, permissionsSummary(a.UserPermissions, a.PrincipalPermissions)...)
+	}
+	sort.Strings(summary)
+	return summary
+}
+
+// permissionsSummary has a line for each user and principal with restrictions,
+// any change to them is a different line
+func permissionsSummary(userPermissions map[string]accord.CertPermissions, principalPermissions map[string]accord.CertPermissions) []string {
+	summary := []string{}
+	for user, permissions := range userPermissions {
+		summary = append(summary, fmt.Sprintf("user %s permissions: %s", user, formatPermissions(permissions)))
+	}
+	for principal, permissions := range principalPermissions {
+		summary = append(summary, fmt.Sprintf("principal %s permissions: %s", principal, formatPermissions(permissions)))
+	}
+	return summary
+}
+
+func formatPermissions(p accord.CertPermissions) string {
+	extensions := "default"
+	if p.Extensions != nil {
+		extensions = "[" + strings.Join(p.Extensions, ",") + "]"
+	}
+	return fmt.Sprintf("force_command %q source_addresses [%s] extensions %s max_validity %s",
+		p.ForceCommand, strings.Join(p.SourceAddresses, ","), extensions, p.MaxValidity)
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mistsys/accord"
+	"github.com/mistsys/accord/certserver"
+)
+
+type fakeAuditor struct {
+	events []*accord.AuditEvent
+	err    error
+}
+
+func (f *fakeAuditor) Audit(event *accord.AuditEvent) error {
+	f.events = append(f.events, event)
+	return f.err
+}
+
+// testComponent loads a cert manager for every version of the file, the
+// file saying "bad" fails to load
+type testComponent struct {
+	name  string
+	file  string
+	loads int
+}
+
+func (c *testComponent) load(u *update) ([]string, error) {
+	c.loads++
+	contents, err := ioutil.ReadFile(c.file)
+	if err != nil {
+		return nil, err
+	}
+	if string(contents) == "bad" {
+		return nil, errors.New("The file is bad")
+	}
+	u.certManager = &accord.CertManager{}
+	return strings.Split(string(contents), "\n"), nil
+}
+
+func (c *testComponent) write(t *testing.T, contents string) {
+	if err := ioutil.WriteFile(c.file, []byte(contents), 0600); err != nil {
+		t.Fatalf("Failed to write %s: %s", c.file, err)
+	}
+}
+
+func newTestReloader(t *testing.T, names ...string) (*reloader, []*testComponent, *fakeAuditor, func()) {
+	dir, err := ioutil.TempDir("", "accord-reload")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %s", err)
+	}
+	auditor := &fakeAuditor{}
+	server := certserver.NewAccordServer(nil, &accord.CertManager{}, "", "", nil, nil, nil, nil)
+	r := newReloader(server, auditor)
+	components := []*testComponent{}
+	for _, name := range names {
+		c := &testComponent{name: name, file: filepath.Join(dir, name)}
+		c.write(t, "a\nb")
+		r.add(name, []string{c.file}, []string{"a", "b"}, c.load)
+		components = append(components, c)
+	}
+	return r, components, auditor, func() { os.RemoveAll(dir) }
+}
+
+func TestReloader_Reload(t *testing.T) {
+	r, components, auditor, cleanup := newTestReloader(t, "cas")
+	defer cleanup()
+	c := components[0]
+	started := r.server.CertManager()
+
+	// unchanged
+	if err := r.reload("file-watch", false); err != nil {
+		t.Fatalf("reloader.reload() error = %v", err)
+	}
+	if c.loads != 0 || len(auditor.events) != 0 {
+		t.Errorf("reloader.reload() loaded %d times and audited %v with the files unchanged", c.loads, auditor.events)
+	}
+
+	// the server keeps what it has when the load fails
+	c.write(t, "bad")
+	if err := r.reload("file-watch", false); err == nil {
+		t.Errorf("reloader.reload() error = nil for a bad file")
+	}
+	if r.server.CertManager() != started {
+		t.Errorf("reloader.reload() switched the server to a config that failed to load")
+	}
+	if len(auditor.events) != 1 || auditor.events[0].Type != accord.AuditReloadFailed || auditor.events[0].Details["component"] != "cas" {
+		t.Fatalf("reloader.reload() audited %v, want a %s event for cas", auditor.events, accord.AuditReloadFailed)
+	}
+
+	// not retried until the file changes again
+	if err := r.reload("file-watch", false); err != nil {
+		t.Errorf("reloader.reload() error = %v retrying the same bad file", err)
+	}
+	if c.loads != 1 || len(auditor.events) != 1 {
+		t.Errorf("reloader.reload() retried the bad file, %d loads", c.loads)
+	}
+
+	c.write(t, "b\nc")
+	if err := r.reload("file-watch", false); err != nil {
+		t.Fatalf("reloader.reload() error = %v", err)
+	}
+	if r.server.CertManager() == started {
+		t.Errorf("reloader.reload() didn't switch the server to the new config")
+	}
+	if len(auditor.events) != 2 {
+		t.Fatalf("reloader.reload() audited %d events, want 2", len(auditor.events))
+	}
+	event := auditor.events[1]
+	if event.Type != accord.AuditReload || event.Actor != "file-watch" {
+		t.Errorf("reloader.reload() audited %s by %s, want %s by file-watch", event.Type, event.Actor, accord.AuditReload)
+	}
+	if event.Details["cas.added"] != "c" || event.Details["cas.removed"] != "a" || event.Details["cas.sha256"] == "" {
+		t.Errorf("reloader.reload() details = %v, want c added and a removed", event.Details)
+	}
+}
+
+func TestReloader_ReloadForced(t *testing.T) {
+	r, components, auditor, cleanup := newTestReloader(t, "psks", "cas", "authz")
+	defer cleanup()
+
+	if err := r.reload("SIGHUP", true); err != nil {
+		t.Fatalf("reloader.reload() error = %v", err)
+	}
+	for _, c := range components {
+		if c.loads != 1 {
+			t.Errorf("reloader.reload() loaded %s %d times, want 1", c.name, c.loads)
+		}
+	}
+	if len(auditor.events) != 1 || auditor.events[0].Actor != "SIGHUP" {
+		t.Fatalf("reloader.reload() audited %v, want one event by SIGHUP", auditor.events)
+	}
+	// nothing changed in them
+	for _, c := range components {
+		if added := auditor.events[0].Details[c.name+".added"]; added != "" {
+			t.Errorf("reloader.reload() %s added = %q, want nothing", c.name, added)
+		}
+	}
+
+	// forced also retries the files that failed
+	components[1].write(t, "bad")
+	if err := r.reload("file-watch", false); err == nil {
+		t.Fatalf("reloader.reload() error = nil for a bad file")
+	}
+	if err := r.reload("SIGHUP", true); err == nil {
+		t.Errorf("reloader.reload() error = nil for a bad file on SIGHUP")
+	}
+	if components[1].loads != 3 {
+		t.Errorf("reloader.reload() loaded the bad file %d times, want 3", components[1].loads)
+	}
+}
+
+func TestReloader_AuditFails(t *testing.T) {
+	r, components, auditor, cleanup := newTestReloader(t, "cas")
+	defer cleanup()
+	auditor.err = errors.New("The audit log is full")
+
+	components[0].write(t, "bad")
+	err := r.reload("file-watch", false)
+	if err == nil || !strings.Contains(err.Error(), "The file is bad") {
+		t.Errorf("reloader.reload() error = %v, want the load error", err)
+	}
+}
+
+func TestAuthzSummary_Permissions(t *testing.T) {
+	load := func(policy string) []string {
+		authz, err := accord.NewSimpleAuthFromBuffer([]byte(policy))
+		if err != nil {
+			t.Fatalf("NewSimpleAuthFromBuffer() error = %v", err)
+		}
+		return authzSummary(authz)
+	}
+	before := load(`
+principals: [deploy-*]
+access_map:
+  user@ex.ample.com: [deploy-*]
+principal_permissions:
+  deploy-*: {force_command: /usr/local/bin/deploy}
+`)
+	after := load(`
+principals: [deploy-*]
+access_map:
+  user@ex.ample.com: [deploy-*]
+deny:
+  - users: [intern@ex.ample.com]
+    principals: [deploy-prod]
+user_permissions:
+  user@ex.ample.com: {max_validity: 1h}
+principal_permissions:
+  deploy-*: {force_command: /usr/local/bin/deploy, extensions: []}
+`)
+	added, removed := diffSummaries(before, after)
+	wantAdded := []string{
+		"deny intern@ex.ample.com: deploy-prod",
+		`principal deploy-* permissions: force_command "/usr/local/bin/deploy" source_addresses [] extensions [] max_validity 0s`,
+		`user user@ex.ample.com permissions: force_command "" source_addresses [] extensions default max_validity 1h0m0s`,
+	}
+	wantRemoved := []string{
+		`principal deploy-* permissions: force_command "/usr/local/bin/deploy" source_addresses [] extensions default max_validity 0s`,
+	}
+	if !reflect.DeepEqual(added, wantAdded) || !reflect.DeepEqual(removed, wantRemoved) {
+		t.Errorf("diffSummaries() added = %q, removed = %q, want %q and %q", added, removed, wantAdded, wantRemoved)
+	}
+}
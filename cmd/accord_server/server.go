@@ -1,28 +1,34 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
-	"encoding/json"
+	"expvar"
 	"flag"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"net"
 	"net/http"
+	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"golang.org/x/crypto/acme/autocert"
 
 	"github.com/mistsys/accord"
+	"github.com/mistsys/accord/audit"
 	"github.com/mistsys/accord/certserver"
+	"github.com/mistsys/accord/cloud_metadata"
 	"github.com/mistsys/accord/db"
+	"github.com/mistsys/accord/hsm"
 	"github.com/mistsys/accord/protocol"
 	"github.com/mistsys/accord/status"
-	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
 )
 
@@ -43,18 +49,6 @@ func GetTLS(host, cacheDir, contactEmail string) (*tls.Config, error) {
 	return &tls.Config{GetCertificate: manager.GetCertificate}, nil
 }
 
-func readPSKsFile(path string, psks *map[uint32][]byte) error {
-	dat, err := ioutil.ReadFile(path)
-	if err != nil {
-		return errors.Wrapf(err, "Failed to read file %s", path)
-	}
-	err = json.Unmarshal(dat, psks)
-	if err != nil {
-		return errors.Wrapf(err, "Failed to unmarshal json in %s", path)
-	}
-	return nil
-}
-
 func grpcHandlerFunc(rpcServer *grpc.Server, other http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ct := r.Header.Get("Content-Type")
@@ -69,7 +63,6 @@ func grpcHandlerFunc(rpcServer *grpc.Server, other http.Handler) http.Handler {
 func main() {
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
 	insecure := flag.Bool("insecure", false, "Is this for development, and disable TLS?")
-	// this is where reading from a HSM could be implemented
 	rootCA := flag.String("rootca", "", "Path to the root CA cert. They need to be encrypted")
 	userCA := flag.String("userca", "", "Path to the user CA cert. They need to be encrypted")
 	port := flag.Int("port", defaultPort, "Port to use. This is overriden to 443 because letsencrypt will be used")
@@ -84,61 +77,217 @@ func main() {
 	psksFile := flag.String("path.psks", "", "A JSON file with all the PSKs that we're creating servers with")
 	certsDir := flag.String("path.certs", "", "Path where certificates are -- used if role-arn is set")
 	authzFile := flag.String("path.authz", "", "Path where the authorization file is")
+	directoryFile := flag.String("path.directory", "", "A JSON file with the groups of each user, makes the authz file grant principals by group")
+	googleDirectoryCredentials := flag.String("google.directory.credentials", "", "Service account credentials for reading the groups from Google Apps, makes the authz file grant principals by group")
+	googleDirectorySubject := flag.String("google.directory.subject", "", "The Google Apps admin the service account reads the groups as")
+	directoryTTL := flag.Duration("directory.ttl", 10*time.Minute, "How long the groups of a user are cached")
+	elevationFile := flag.String("path.elevation", "", "The principals that need an approved elevation, the authz doesn't grant them on its own")
+	elevationPending := flag.Duration("elevation.pending", time.Hour, "How long an elevation waits for an approval")
+	auditFile := flag.String("audit.file", "", "A file to also write the audit events to as JSON lines")
+	auditFileMaxSize := flag.Int64("audit.file.maxsize", 100<<20, "The size in bytes the audit file is rotated at")
+	auditFileBackups := flag.Int("audit.file.backups", 10, "How many rotated audit files to keep")
+	auditSyslog := flag.String("audit.syslog", "", "Where to also send the audit events as RFC 5424 syslog, udp://host:port, tcp://host:port or unix:///dev/log")
+	auditWebhook := flag.String("audit.webhook", "", "A URL to also post the audit events to")
+	auditWebhookSpool := flag.String("audit.webhook.spool", "audit-spool", "Where the audit events wait until the webhook takes them, needs to be writable")
+	ledgerFile := flag.String("path.ledger", "accord.db", "Path to the database that keeps track of issued certs")
+	adminSocket := flag.String("admin.socket", "/run/accord/admin.sock", "The unix socket for the admin service, empty to not serve it")
+	adminUids := flag.String("admin.uids", "", "Comma separated uids allowed on the admin socket, root and the server's own uid when empty")
+	adminGids := flag.String("admin.gids", "", "Comma separated gids allowed on the admin socket")
 	region := flag.String("aws.region", "us-east-1", "Which AWS region are we on?")
 	paramsPrefix := flag.String("params-prefix", "", "Where to look for the passphrase to decrypt the HostCA and UserCA keys")
+	// with anything but file, only the public keys need to be in path.certs
+	signerBackend := flag.String("signer", "file", "Where the CA private keys are kept: file, agent or pkcs11")
+	agentSocket := flag.String("signer.agent.socket", os.Getenv("SSH_AUTH_SOCK"), "The ssh-agent socket that has the CA keys")
+	pkcs11Module := flag.String("signer.pkcs11.module", "", "Path to the PKCS#11 library for the token with the CA keys")
+	pkcs11Token := flag.String("signer.pkcs11.token", "", "Label of the PKCS#11 token with the CA keys")
+	pkcs11PIN := flag.String("signer.pkcs11.pin", "", "PIN to log in to the PKCS#11 token")
 	// these should only be used for testing
 	sslKey := flag.String("sslkey", "", "Path to the SSL key")
 	sslCert := flag.String("sslcert", "", "Path to the SSL cert")
 	googleClientId := flag.String("google.clientid", "", "Which Google Apps ClientID to use")
 	oauthDomain := flag.String("domain", "mistsys.com", "Domain to use for Oauth2")
 	hostname := flag.String("hostname", "localhost", "Hostname to use")
+	sessionTTL := flag.Duration("session.ttl", accord.DefaultSessionTTL, "How long users have to request certs after authenticating")
+	hostSessionTTL := flag.Duration("hostsession.ttl", certserver.DefaultHostSessionTTL, "How long hosts have to request certs after authenticating")
+	hostSessionUses := flag.Int("hostsession.uses", certserver.DefaultHostSessionUses, "How many host certs can be requested after authenticating once")
+	maxClockSkew := flag.Duration("hostauth.skew", accord.DefaultMaxClockSkew, "How far the host clocks can be from the server's")
+	awsCertsFile := flag.String("path.awscerts", "", "PEM file with the AWS certificates for verifying the instance identity documents")
+	hostPolicyFile := flag.String("path.hostpolicy", "", "Path to the policy for which AWS accounts and regions each PSK can get host certs for")
+	legacyHostAuth := flag.Bool("hostauth.legacy", false, "Deprecated, only for migrating: accept host auth requests in the old envelope that uses the PSK directly, from the hosts that haven't been updated")
+	reloadInterval := flag.Duration("reload.interval", 30*time.Second, "How often to check the PSK, authz and CA files for changes, 0 to only reload on SIGHUP")
+	rpcTimeout := flag.Duration("rpc.timeout", certserver.DefaultRPCTimeout, "How long the RPCs can take when the client doesn't set a shorter deadline, 0 for no limit")
+	healthInterval := flag.Duration("health.interval", time.Minute, "How often to test-sign with the CAs and check the PSKs and authz for /readyz")
+	caExpiryWarning := flag.Duration("health.ca.warning", 14*24*time.Hour, "Warn when none of the CAs of a type are valid for longer than this")
+	replayCacheSize := flag.Int("hostauth.replaycache", accord.DefaultReplayCacheSize, "How many host auth requests to remember for each PSK within the skew")
 	// if sslcerts aren't explicity
 	flag.Parse()
 
 	var err error
 
-	psks := make(map[uint32][]byte)
+	// this could be loaded from another store too
+	var loadPSKs func() (accord.PSKStore, []string, error)
+	var pskStore accord.PSKStore
+	var psks []string
 	if *psksFile == "" {
 		log.Println("path.psks was empty, so initializing with default test key")
-		psks[912090709] = []byte(`JpUtbRukLuIFyjeKpA4fIpjgs6MTV8eH`)
+		pskStore = db.NewLocalPSKStore(map[uint32][]byte{912090709: []byte(`JpUtbRukLuIFyjeKpA4fIpjgs6MTV8eH`)})
 	} else { // this should be in a key in parameter store too
-		err := readPSKsFile(*psksFile, &psks)
+		loadPSKs = func() (accord.PSKStore, []string, error) {
+			deployments, err := db.ReadPSKFile(*psksFile)
+			if err != nil {
+				return nil, nil, err
+			}
+			if legacy := deployments.Legacy(); len(legacy) > 0 {
+				log.Printf("Deployments still accepting MD5 key ids: %s", strings.Join(legacy, ", "))
+			}
+			return db.NewDeploymentsPSKStore(deployments), pskSummary(deployments), nil
+		}
+		pskStore, psks, err = loadPSKs()
 		if err != nil {
 			log.Fatalf("Failed to read psk file %s. %s", *psksFile, err)
 		}
 	}
-	// this could be loaded from another store too
-	pskStore := db.NewLocalPSKStore(psks)
 
-	var certManager *accord.CertManager
+	var loadCertManager func() (*accord.CertManager, error)
+	var certFiles []string
 
-	if *roleArn != "" {
+	switch {
+	case *signerBackend == "agent" || *signerBackend == "pkcs11":
+		if *certsDir == "" {
+			log.Fatalf("path.certs is needed for the public keys of the CAs with the %s signer", *signerBackend)
+		}
+		var backend accord.SignerBackend
+		if *signerBackend == "agent" {
+			backend = accord.AgentBackend(*agentSocket)
+		} else {
+			token, err := hsm.Open(*pkcs11Module, *pkcs11Token, *pkcs11PIN)
+			if err != nil {
+				log.Fatalf("Cannot open the PKCS#11 token: %s", err)
+			}
+			defer token.Close()
+			backend = func(id int, pair *accord.CACertPair) (accord.CASigner, error) {
+				signer, err := token.Signer(pair.PublicKey)
+				if err != nil {
+					return nil, err
+				}
+				return signer, nil
+			}
+		}
+		loadCertManager = func() (*accord.CertManager, error) {
+			return accord.NewCertManagerWithBackend(*certsDir, backend)
+		}
+		certFiles = []string{*certsDir}
+	case *signerBackend != "file":
+		log.Fatalf("Unknown signer %s", *signerBackend)
+	case *roleArn != "":
 		if *certsDir == "" {
 			log.Fatal("role-arn is set but certs directory isn't")
 		}
-		certManager, err = accord.NewCertManagerWithParameters(*certsDir, *region, *roleArn, *paramsPrefix)
-		if err != nil {
-			log.Fatalf("Cannot load cert manager: %s", err)
+		loadCertManager = func() (*accord.CertManager, error) {
+			return accord.NewCertManagerWithParameters(*certsDir, *region, *roleArn, *paramsPrefix)
 		}
-	} else {
+		certFiles = []string{*certsDir}
+	default:
 		// TODO: make it so that the certmanager scans a directory and finds IDs, then queries
 		// the corresponding keys' parameters on demand. This allows us to revoke the keys as needed
-		certManager, err = accord.NewCertManagerWithPasswords(*rootCA, *rootCAPassword, *userCA, *userCAPassword)
-		if err != nil {
-			log.Fatalf("Failed to initialize cert manager. %s", err)
+		loadCertManager = func() (*accord.CertManager, error) {
+			return accord.NewCertManagerWithPasswords(*rootCA, *rootCAPassword, *userCA, *userCAPassword)
 		}
+		certFiles = []string{*rootCA, *rootCA + ".pub", *userCA, *userCA + ".pub"}
+	}
+	certManager, err := loadCertManager()
+	if err != nil {
+		log.Fatalf("Cannot load cert manager: %s", err)
 	}
 
-	status.ServePort(*healthCheckPort)
+	store, err := db.NewBoltStore(*ledgerFile)
+	if err != nil {
+		log.Fatalf("Failed to open the ledger %s. %s", *ledgerFile, err)
+	}
+	defer store.Close()
+	certManager.SetLedger(store)
+
+	statusMux := status.ServePort(*healthCheckPort)
+	statusMux.Handle("/debug/vars", expvar.Handler())
+
+	// the Google directory is set up once, the local one is reloaded with the authz
+	var googleDirectory accord.Directory
+	if *directoryFile == "" && *googleDirectoryCredentials != "" {
+		googleDirectory, err = accord.NewGoogleDirectory(context.Background(), *googleDirectoryCredentials, *googleDirectorySubject)
+		if err != nil {
+			log.Fatalf("Failed to set up the directory. %s", err)
+		}
+	}
+	loadBaseAuthz := func() (accord.Authz, error) {
+		if *directoryFile == "" && googleDirectory == nil {
+			return accord.NewSimpleAuthFromFile(*authzFile)
+		}
+		directory := googleDirectory
+		if *directoryFile != "" {
+			local, err := accord.NewLocalDirectoryFromFile(*directoryFile)
+			if err != nil {
+				return nil, err
+			}
+			directory = local
+		}
+		return accord.NewGroupAuthFromFile(*authzFile, accord.NewCachedDirectory(directory, *directoryTTL))
+	}
+	// the audit events always go to the log too
+	auditor := audit.Multi{accord.LogAuditor{}}
+	if *auditFile != "" {
+		file, err := audit.NewFile(*auditFile, *auditFileMaxSize, *auditFileBackups)
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+		defer file.Close()
+		auditor = append(auditor, file)
+	}
+	if *auditSyslog != "" {
+		syslog, err := audit.NewSyslog(*auditSyslog)
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+		defer syslog.Close()
+		prometheus.MustRegister(prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: "accord",
+			Name:      "audit_syslog_dropped_total",
+			Help:      "The audit events dropped because the syslog queue was full.",
+		}, func() float64 { return float64(syslog.Dropped()) }))
+		auditor = append(auditor, syslog)
+	}
+	if *auditWebhook != "" {
+		webhook, err := audit.NewWebhook(*auditWebhook, *auditWebhookSpool)
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+		defer webhook.Close()
+		auditor = append(auditor, webhook)
+	}
+	// the elevations outlive the reloads of the policy
+	elevations := accord.NewElevations(auditor, *elevationPending)
+	loadAuthz := func() (accord.Authz, error) {
+		authz, err := loadBaseAuthz()
+		if err != nil || *elevationFile == "" {
+			return authz, err
+		}
+		policy, err := accord.NewElevationPolicyFromFile(*elevationFile)
+		if err != nil {
+			return nil, err
+		}
+		return accord.NewElevatedAuthz(authz, policy, elevations), nil
+	}
 
 	var authz accord.Authz
 	if *authzFile == "" {
+		if *elevationFile != "" {
+			log.Fatalf("The elevation policy needs an authz file, GrantAll grants everything anyway")
+		}
 		log.Printf("No authz file created, using GrantAll -- do not use this in Production")
 		authz = accord.GrantAll{}
 	} else {
-		authz, err = accord.NewSimpleAuthFromFile(*authzFile)
+		authz, err = loadAuthz()
 		if err != nil {
-			log.Fatalf("Failed to read auth file: %s", *authzFile)
+			log.Fatalf("Failed to read auth file: %s. %s", *authzFile, err)
 		}
 	}
 
@@ -152,23 +301,112 @@ func main() {
 		clientId = accord.ClientID
 	}
 
-	certAccorder := certserver.NewAccordServer(pskStore, certManager, clientId, *oauthDomain, authz)
+	// the key is random so the sessions only work on this instance until it restarts
+	sessions, err := accord.NewSessionSigner(nil, *sessionTTL)
+	if err != nil {
+		log.Fatalf("Failed to create the session signer. %s", err)
+	}
 
-	server := grpc.NewServer()
-	protocol.RegisterCertServer(server, certAccorder)
-	reflection.Register(server)
-	addr := ":" + strconv.Itoa(*port)
-	if *insecure {
-		lis, err := net.Listen("tcp", addr)
+	certAccorder := certserver.NewAccordServer(pskStore, certManager, clientId, *oauthDomain, authz, store, sessions, store)
+	certAccorder.SetHostSessionLimits(*hostSessionTTL, *hostSessionUses)
+	certAccorder.SetReplayLimits(*maxClockSkew, *replayCacheSize)
+	certAccorder.SetLegacyHostAuth(*legacyHostAuth)
+	certAccorder.SetAuditor(auditor)
+	certAccorder.SetCertLister(store)
+	certAccorder.SetUserStore(store)
+	prometheus.MustRegister(certserver.NewCACollector(certAccorder))
+	if *hostPolicyFile != "" {
+		hostPolicy, err := accord.NewHostPolicyFromFile(*hostPolicyFile)
 		if err != nil {
-			log.Fatalf("Failed to listen: %v", err)
+			log.Fatalf("Failed to read the host policy %s. %s", *hostPolicyFile, err)
 		}
-		if err := server.Serve(lis); err != nil {
-			log.Fatalf("Failed to serve: %v", err)
+		var verifier *cloud_metadata.AWSIdentityVerifier
+		if *awsCertsFile != "" {
+			verifier, err = cloud_metadata.NewAWSIdentityVerifierFromFile(*awsCertsFile)
+			if err != nil {
+				log.Fatalf("Failed to read the AWS certificates %s. %s", *awsCertsFile, err)
+			}
+		} else {
+			log.Printf("path.awscerts isn't set, only the deployments without a policy can get host certs")
 		}
-	} else {
+		certAccorder.SetHostPolicy(hostPolicy, verifier)
+	}
+
+	reloads := newReloader(certAccorder, auditor)
+	if loadPSKs != nil {
+		reloads.add("psks", []string{*psksFile}, psks, func(u *update) ([]string, error) {
+			pskStore, summary, err := loadPSKs()
+			u.pskStore = pskStore
+			return summary, err
+		})
+	}
+	reloads.add("cas", certFiles, certSummary(certManager), func(u *update) ([]string, error) {
+		certManager, err := loadCertManager()
+		if err != nil {
+			return nil, err
+		}
+		if err := certManager.Check(); err != nil {
+			return nil, err
+		}
+		certManager.SetLedger(store)
+		u.certManager = certManager
+		return certSummary(certManager), nil
+	})
+	if *authzFile != "" {
+		authzFiles := []string{*authzFile}
+		if *directoryFile != "" {
+			authzFiles = append(authzFiles, *directoryFile)
+		}
+		if *elevationFile != "" {
+			authzFiles = append(authzFiles, *elevationFile)
+		}
+		reloads.add("authz", authzFiles, authzSummary(authz), func(u *update) ([]string, error) {
+			authz, err := loadAuthz()
+			if err != nil {
+				return nil, err
+			}
+			u.authz = authz
+			return authzSummary(authz), nil
+		})
+	}
+	go reloads.watch(*reloadInterval)
+	// the same for every server, the admin socket too
+	interceptor := grpc.UnaryInterceptor(certserver.UnaryInterceptor(*rpcTimeout))
+	healthChecks := newHealthChecker(certAccorder, *caExpiryWarning)
+	go healthChecks.watch(*healthInterval)
+	if *adminSocket != "" {
+		creds := &peerCredentials{}
+		if creds.uids, err = parseIds(*adminUids); err != nil {
+			log.Fatalf("Invalid admin.uids. %s", err)
+		}
+		if creds.gids, err = parseIds(*adminGids); err != nil {
+			log.Fatalf("Invalid admin.gids. %s", err)
+		}
+		if len(creds.uids) == 0 {
+			creds.uids = map[uint32]bool{0: true, uint32(os.Getuid()): true}
+		}
+		admin := &adminServer{
+			store:    store,
+			server:   certAccorder,
+			reloads:  reloads,
+			auditor:  auditor,
+			psksFile: *psksFile,
+		}
+		if err := serveAdmin(*adminSocket, creds, admin, interceptor); err != nil {
+			log.Fatal(err)
+		}
+		defer os.Remove(*adminSocket)
+	}
+	go func() {
+		for range time.Tick(time.Minute) {
+			elevations.Expire()
+		}
+	}()
 
-		var tlsConfig *tls.Config
+	addr := ":" + strconv.Itoa(*port)
+	opts := []grpc.ServerOption{interceptor}
+	var tlsConfig *tls.Config
+	if !*insecure {
 		var creds credentials.TransportCredentials
 		if *sslKey != "" && *sslCert != "" {
 			creds, err = credentials.NewServerTLSFromFile(*sslCert, *sslKey)
@@ -197,12 +435,23 @@ func main() {
 			tlsConfig = &tls.Config{GetCertificate: certMgr.GetCertificate}
 			creds = credentials.NewTLS(tlsConfig)
 		}
+		opts = append(opts, grpc.Creds(creds))
+	}
 
-		// TODO: refactor this, check for certs first or use config
-		server := grpc.NewServer(grpc.Creds(creds))
-
-		protocol.RegisterCertServer(server, certAccorder)
-		reflection.Register(server)
+	server := grpc.NewServer(opts...)
+	protocol.RegisterCertServer(server, certAccorder)
+	protocol.RegisterCertQueryServer(server, certAccorder)
+	healthpb.RegisterHealthServer(server, healthChecks.health)
+	reflection.Register(server)
+	if *insecure {
+		lis, err := net.Listen("tcp", addr)
+		if err != nil {
+			log.Fatalf("Failed to listen: %v", err)
+		}
+		if err := server.Serve(lis); err != nil {
+			log.Fatalf("Failed to serve: %v", err)
+		}
+	} else {
 		mux := http.DefaultServeMux
 		mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 			fmt.Fprintf(w, "Hi there, I love %s!", r.URL.Path[1:])
@@ -7,6 +7,7 @@ import (
 	"encoding/binary"
 	"io"
 	"log"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -14,6 +15,8 @@ import (
 const (
 	KeySize   = 32
 	NonceSize = 12
+	// unix time in nanoseconds in front of the message
+	TimestampSize = 8
 )
 
 var (
@@ -73,7 +76,9 @@ func GenerateKey() []byte {
 
 // Initialize the AESGCM with a PSK store, this can be anything from a local instance
 // or something that reads from a HSM or memory, the logic for getting the key securely
-// will be in the PSKStore implmentation
+// will be in the PSKStore implmentation.
+// Deprecated: it's only the legacy HostAuth envelope, kept while the hosts
+// move to Envelope
 type AESGCM struct {
 	store PSKStore
 }
@@ -84,32 +89,69 @@ func InitAESGCM(store PSKStore) *AESGCM {
 	}
 }
 
-func (a *AESGCM) EncryptWithNonce(message []byte, nonce []byte, sender uint32) ([]byte, error) {
-	buf := make([]byte, 4)
-	binary.BigEndian.PutUint32(buf, sender)
+// PSKRef is the PSK a message is encrypted with. The envelope has the key id
+// and the version of the PSK in front of the nonce, unless it's unversioned,
+// the format from before the PSKs had versions that only has the key id
+type PSKRef struct {
+	KeyId   uint32
+	Version uint32
+	// the version isn't in the envelope, when decrypting Version is the one
+	// that worked, when encrypting 0 means the active version
+	Unversioned bool
+}
 
-	psk, err := a.store.GetPSK(buf)
-	if err != nil {
-		log.Printf("Err: failed to find the PSK for id: %d. %s", sender, err)
-		return nil, ErrKeyNotFound
+func (r PSKRef) header() []byte {
+	if r.Unversioned {
+		buf := make([]byte, 4)
+		binary.BigEndian.PutUint32(buf, r.KeyId)
+		return buf
+	}
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint32(buf, r.KeyId)
+	binary.BigEndian.PutUint32(buf[4:], r.Version)
+	return buf
+}
+
+// the stores look up the active PSK with the 4 byte key id, and a specific
+// version with the key id followed by the version
+func (a *AESGCM) psk(ref PSKRef) ([]byte, error) {
+	if ref.Version == 0 {
+		return a.store.GetPSK(ref.header()[:4])
 	}
+	return a.store.GetPSK(PSKRef{KeyId: ref.KeyId, Version: ref.Version}.header())
+}
+
+func newGCM(psk []byte) (cipher.AEAD, error) {
 	c, err := aes.NewCipher(psk)
 	if err != nil {
-		log.Printf("Err: %s", err)
-		return nil, ErrEncrypt
+		return nil, err
 	}
+	return cipher.NewGCM(c)
+}
 
-	//log.Printf("Cipher Block Size: %d", c.BlockSize())
-
-	gcm, err := cipher.NewGCM(c)
+// Seal encrypts the message with the PSK, the envelope is versioned unless the ref is unversioned
+func (a *AESGCM) Seal(message []byte, nonce []byte, ref PSKRef) ([]byte, error) {
+	if !ref.Unversioned && ref.Version == 0 {
+		return nil, errors.New("Versioned envelopes need the PSK version")
+	}
+	psk, err := a.psk(ref)
+	if err != nil {
+		log.Printf("Err: failed to find the PSK for id: %d version: %d. %s", ref.KeyId, ref.Version, err)
+		return nil, ErrKeyNotFound
+	}
+	gcm, err := newGCM(psk)
 	if err != nil {
+		log.Printf("Err: %s", err)
 		return nil, ErrEncrypt
 	}
-
-	//log.Printf("Nonce: %d %q", len(nonce), nonce)
+	buf := ref.header()
+	headerSize := len(buf)
 	buf = append(buf, nonce...)
-	newBuf := gcm.Seal(buf, nonce, message, buf[:4])
-	return newBuf, nil
+	return gcm.Seal(buf, nonce, message, buf[:headerSize]), nil
+}
+
+func (a *AESGCM) EncryptWithNonce(message []byte, nonce []byte, sender uint32) ([]byte, error) {
+	return a.Seal(message, nonce, PSKRef{KeyId: sender, Unversioned: true})
 }
 
 func (a *AESGCM) Encrypt(message []byte, sender uint32) ([]byte, error) {
@@ -120,40 +162,96 @@ func (a *AESGCM) Encrypt(message []byte, sender uint32) ([]byte, error) {
 	return a.EncryptWithNonce(message, nonce, sender)
 }
 
-// Decrypt returns plaintext, nonce, senderid, error
-func (a *AESGCM) Decrypt(message []byte) ([]byte, []byte, uint32, error) {
+// Open decrypts messages in both envelopes, it returns plaintext, nonce and
+// which PSK it was encrypted with. The unversioned ones are tried with every
+// version the store still accepts, the hosts that haven't been updated only
+// know one of them
+func (a *AESGCM) Open(message []byte) ([]byte, []byte, PSKRef, error) {
 	if len(message) <= NonceSize+4 {
 		log.Println("message < noncesize + 4")
-		return nil, nil, 0, ErrDecrypt
+		return nil, nil, PSKRef{}, ErrDecrypt
+	}
+	keyId := binary.BigEndian.Uint32(message[:4])
+	// the version is only there if the store has it, otherwise they're the
+	// first bytes of the nonce of an unversioned envelope
+	if len(message) > 8+NonceSize {
+		ref := PSKRef{KeyId: keyId, Version: binary.BigEndian.Uint32(message[4:8])}
+		if out, nonce, err := a.open(ref, message); err == nil {
+			return out, nonce, ref, nil
+		}
 	}
-	buf := message[:4]
-	sender := binary.BigEndian.Uint32(buf)
-	psk, err := a.store.GetPSK(message[:4])
+	versions := []uint32{0}
+	if store, ok := a.store.(VersionedPSKStore); ok {
+		versions = store.AcceptedVersions(keyId)
+	}
+	for _, version := range versions {
+		ref := PSKRef{KeyId: keyId, Version: version, Unversioned: true}
+		if out, nonce, err := a.open(ref, message); err == nil {
+			return out, nonce, ref, nil
+		}
+	}
+	log.Printf("Err: failed to decrypt the message from id: %d", keyId)
+	return nil, nil, PSKRef{KeyId: keyId}, ErrDecrypt
+}
+
+func (a *AESGCM) open(ref PSKRef, message []byte) ([]byte, []byte, error) {
+	if !ref.Unversioned && ref.Version == 0 {
+		return nil, nil, ErrDecrypt
+	}
+	psk, err := a.psk(ref)
 	if err != nil {
-		log.Printf("Err: failed to find the PSK for id: %q. %s", buf, err)
-		return nil, nil, sender, ErrKeyNotFound
+		return nil, nil, ErrKeyNotFound
 	}
-	c, err := aes.NewCipher(psk)
+	gcm, err := newGCM(psk)
 	if err != nil {
-		log.Printf("failed at cipher. %s", err)
-		return nil, nil, sender, ErrDecrypt
+		return nil, nil, ErrDecrypt
 	}
-
-	gcm, err := cipher.NewGCM(c)
+	headerSize := len(ref.header())
+	nonce := make([]byte, NonceSize)
+	copy(nonce, message[headerSize:])
+	out, err := gcm.Open(nil, nonce, message[headerSize+NonceSize:], message[:headerSize])
 	if err != nil {
-		log.Printf("failed at gcm. %s", err)
-		return nil, nil, sender, ErrDecrypt
+		return nil, nil, ErrDecrypt
 	}
+	return out, nonce, nil
+}
 
-	nonce := make([]byte, NonceSize)
-	copy(nonce, message[4:])
-	log.Printf("Nonce: %d %q", len(nonce), nonce)
+// Decrypt returns plaintext, nonce, senderid, error
+func (a *AESGCM) Decrypt(message []byte) ([]byte, []byte, uint32, error) {
+	out, nonce, ref, err := a.Open(message)
+	return out, nonce, ref.KeyId, err
+}
 
-	out, err := gcm.Open(nil, nonce, message[4+NonceSize:], message[:4])
+// EncryptWithTimestamp puts the time in front of the message before encrypting
+// it, so it's authenticated with the rest of the message and the receiver
+// can tell how old the message is
+func (a *AESGCM) EncryptWithTimestamp(message []byte, nonce []byte, timestamp time.Time, ref PSKRef) ([]byte, error) {
+	plaintext := make([]byte, TimestampSize, TimestampSize+len(message))
+	binary.BigEndian.PutUint64(plaintext, uint64(timestamp.UnixNano()))
+	plaintext = append(plaintext, message...)
+	return a.Seal(plaintext, nonce, ref)
+}
+
+// DecryptWithTimestamp returns plaintext, nonce, the PSK and the time from EncryptWithTimestamp
+func (a *AESGCM) DecryptWithTimestamp(message []byte) ([]byte, []byte, PSKRef, time.Time, error) {
+	plaintext, nonce, ref, err := a.Open(message)
+	if err != nil {
+		return nil, nil, ref, time.Time{}, err
+	}
+	plaintext, timestamp, err := SplitTimestamp(plaintext)
 	if err != nil {
-		log.Printf("failed at open. %s", err)
-		return nil, nil, sender, ErrDecrypt
+		return nil, nil, ref, time.Time{}, err
 	}
+	return plaintext, nonce, ref, timestamp, nil
+}
 
-	return out, nonce, sender, nil
+// SplitTimestamp takes the time EncryptWithTimestamp put in front of the
+// opened message
+func SplitTimestamp(plaintext []byte) ([]byte, time.Time, error) {
+	if len(plaintext) < TimestampSize {
+		log.Println("message < timestampsize")
+		return nil, time.Time{}, ErrDecrypt
+	}
+	timestamp := time.Unix(0, int64(binary.BigEndian.Uint64(plaintext[:TimestampSize])))
+	return plaintext[TimestampSize:], timestamp, nil
 }
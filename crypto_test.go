@@ -5,6 +5,7 @@ import (
 	"crypto/rand"
 	"io"
 	"testing"
+	"time"
 
 	"github.com/mistsys/accord/db"
 )
@@ -103,3 +104,104 @@ func BenchmarkAESEncrypt100BytesNKeys(b *testing.B) {
 		}
 	}
 }
+
+func TestAESGCMWithTimestamp(t *testing.T) {
+	psks := map[uint32][]byte{1: []byte(`JpUtbRukLuIFyjeKpA4fIpjgs6MTV8eH`)}
+	aesgcm := InitAESGCM(db.NewLocalPSKStore(psks))
+	nonce, err := GenerateNonce(NonceSize)
+	if err != nil {
+		t.Fatalf("Failed to generate nonce: %s", err)
+	}
+	sent := time.Unix(1506500000, 123456789)
+	encrypted, err := aesgcm.EncryptWithTimestamp([]byte("Host Login"), nonce, sent, PSKRef{KeyId: 1, Unversioned: true})
+	if err != nil {
+		t.Fatalf("AESGCM.EncryptWithTimestamp() error = %v", err)
+	}
+	decrypted, nonce2, sender, timestamp, err := aesgcm.DecryptWithTimestamp(encrypted)
+	if err != nil {
+		t.Fatalf("AESGCM.DecryptWithTimestamp() error = %v", err)
+	}
+	if string(decrypted) != "Host Login" || !bytes.Equal(nonce, nonce2) || sender.KeyId != 1 || !timestamp.Equal(sent) {
+		t.Errorf("AESGCM.DecryptWithTimestamp() = %q, %x, %v, %s", decrypted, nonce2, sender, timestamp)
+	}
+
+	// the timestamp is authenticated, changing it breaks the message
+	encrypted[4+NonceSize] ^= 0xff
+	if _, _, _, _, err := aesgcm.DecryptWithTimestamp(encrypted); err == nil {
+		t.Errorf("AESGCM.DecryptWithTimestamp() should fail when the message was changed")
+	}
+}
+
+// the hosts move to a new PSK version one at a time, the ones that haven't
+// been updated and the ones from before the versions keep working
+func TestAESGCMRotation(t *testing.T) {
+	psks := db.VersionedPSKs{}
+	psks.Add(1, []byte(`JpUtbRukLuIFyjeKpA4fIpjgs6MTV8eH`), time.Now())
+	psks.Rotate(1, []byte(`qvFyLbJdNF6Cx0hpKzT0w3nqsTEbgvSe`), time.Now())
+	server := InitAESGCM(db.NewVersionedPSKStore(psks))
+
+	tests := []struct {
+		name    string
+		psk     string
+		ref     PSKRef
+		wantErr bool
+	}{
+		{
+			name: "active version",
+			psk:  `qvFyLbJdNF6Cx0hpKzT0w3nqsTEbgvSe`,
+			ref:  PSKRef{KeyId: 1, Version: 2},
+		},
+		{
+			name: "accept-only version",
+			psk:  `JpUtbRukLuIFyjeKpA4fIpjgs6MTV8eH`,
+			ref:  PSKRef{KeyId: 1, Version: 1},
+		},
+		{
+			name: "unversioned with the old PSK",
+			psk:  `JpUtbRukLuIFyjeKpA4fIpjgs6MTV8eH`,
+			ref:  PSKRef{KeyId: 1, Version: 1, Unversioned: true},
+		},
+		{
+			name:    "wrong version",
+			psk:     `JpUtbRukLuIFyjeKpA4fIpjgs6MTV8eH`,
+			ref:     PSKRef{KeyId: 1, Version: 2},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the hosts only have their own PSK
+			clientVersion := tt.ref.Version
+			if tt.ref.Unversioned {
+				clientVersion = 0
+			}
+			client := InitAESGCM(db.NewVersionedPSKStore(db.VersionedPSKs{
+				1: {{Version: clientVersion, Key: []byte(tt.psk), State: db.PSKActive}},
+			}))
+			sent := PSKRef{KeyId: 1, Version: clientVersion, Unversioned: tt.ref.Unversioned}
+			nonce, _ := GenerateNonce(NonceSize)
+			encrypted, err := client.Seal([]byte("Host Login"), nonce, sent)
+			if err != nil {
+				t.Fatalf("AESGCM.Seal() error = %v", err)
+			}
+			_, _, ref, err := server.Open(encrypted)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AESGCM.Open() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if ref != tt.ref {
+				t.Errorf("AESGCM.Open() = %+v, want %+v", ref, tt.ref)
+			}
+			// the response has to be readable by the host
+			response, err := server.Seal([]byte("uuid"), nonce, ref)
+			if err != nil {
+				t.Fatalf("AESGCM.Seal() error = %v", err)
+			}
+			if decrypted, _, _, err := client.Open(response); err != nil || string(decrypted) != "uuid" {
+				t.Errorf("AESGCM.Open() of the response = %q, %v", decrypted, err)
+			}
+		})
+	}
+}
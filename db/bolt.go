@@ -0,0 +1,178 @@
+package db
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+var certsBucket = []byte("certs")
+
+// every bucket the store uses is created when it's opened
+// so that none of the accessors need to check for nil buckets
+var buckets = [][]byte{
+	certsBucket,
+	revocationsBucket,
+	hostSessionsBucket,
+	disabledUsersBucket,
+}
+
+// BoltStore keeps the server state in a single BoltDB file so the server
+// doesn't need anything running next to it. Only one process can have the
+// file open at a time, which is fine for the single server we run
+type BoltStore struct {
+	db *bolt.DB
+}
+
+func NewBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to open bolt db at %s", path)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range buckets {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, errors.Wrapf(err, "Failed to create buckets in %s", path)
+	}
+	return &BoltStore{db: db}, nil
+}
+
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
+
+func serialKey(serial uint64) []byte {
+	// big endian so the keys sort in the same order as the serials
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, serial)
+	return b
+}
+
+// NextSerial uses the sequence of the certs bucket, it's persisted with the
+// transaction so the serials keep increasing across restarts. Bolt starts
+// the sequence at 1, 0 is never handed out
+func (s *BoltStore) NextSerial() (uint64, error) {
+	var serial uint64
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		var err error
+		serial, err = tx.Bucket(certsBucket).NextSequence()
+		return err
+	})
+	if err != nil {
+		return 0, errors.Wrapf(err, "Failed to get the next serial")
+	}
+	return serial, nil
+}
+
+func (s *BoltStore) Record(record *CertRecord) error {
+	if record.Serial == 0 {
+		return errors.New("Cannot record a cert without a serial")
+	}
+	value, err := json.Marshal(record)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to marshal the record for serial %d", record.Serial)
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(certsBucket)
+		key := serialKey(record.Serial)
+		if b.Get(key) != nil {
+			return errors.Errorf("Cert with serial %d is already recorded", record.Serial)
+		}
+		return b.Put(key, value)
+	})
+}
+
+func (s *BoltStore) Get(serial uint64) (*CertRecord, error) {
+	record := &CertRecord{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(certsBucket).Get(serialKey(serial))
+		if value == nil {
+			return ErrCertNotFound
+		}
+		return json.Unmarshal(value, record)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+func latestKey(record *CertRecord) string {
+	return record.CertType + "/" + record.Fingerprint
+}
+
+func (s *BoltStore) ListCerts(filter *CertFilter) ([]*CertRecord, uint64, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, 0, errors.Wrapf(err, "Invalid filter")
+	}
+	pageSize := filter.PageSize
+	if pageSize == 0 {
+		pageSize = DefaultCertPageSize
+	}
+	start := filter.After + 1
+	if filter.Serial > start {
+		start = filter.Serial
+	}
+	var (
+		records []*CertRecord
+		next    uint64
+	)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(certsBucket)
+		// the serial of the last cert for every key, going back from the
+		// newest the first one seen is the last. Only the later certs can
+		// renew the ones in the page, it stops at the start of the page
+		latest := map[string]uint64{}
+		if filter.Latest {
+			startKey := serialKey(start)
+			c := b.Cursor()
+			for k, v := c.Last(); k != nil && bytes.Compare(k, startKey) >= 0; k, v = c.Prev() {
+				record := &CertRecord{}
+				if err := json.Unmarshal(v, record); err != nil {
+					return errors.Wrapf(err, "Failed to unmarshal the record for serial %d", binary.BigEndian.Uint64(k))
+				}
+				if _, ok := latest[latestKey(record)]; !ok {
+					latest[latestKey(record)] = record.Serial
+				}
+			}
+		}
+		c := b.Cursor()
+		for k, v := c.Seek(serialKey(start)); k != nil; k, v = c.Next() {
+			record := &CertRecord{}
+			if err := json.Unmarshal(v, record); err != nil {
+				return errors.Wrapf(err, "Failed to unmarshal the record for serial %d", binary.BigEndian.Uint64(k))
+			}
+			if filter.Latest && latest[latestKey(record)] != record.Serial {
+				continue
+			}
+			if !filter.Matches(record) {
+				continue
+			}
+			// there's one more after the page, so it's not the last one
+			if len(records) == pageSize {
+				next = records[len(records)-1].Serial
+				break
+			}
+			records = append(records, record)
+			if filter.Serial != 0 {
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return records, next, nil
+}
@@ -0,0 +1,506 @@
+package db
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestBoltStore(t *testing.T) (*BoltStore, func()) {
+	dir, err := ioutil.TempDir("", "accord-bolt")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %s", err)
+	}
+	store, err := NewBoltStore(filepath.Join(dir, "accord.db"))
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("Failed to open bolt store: %s", err)
+	}
+	return store, func() {
+		store.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestBoltStore_NextSerial(t *testing.T) {
+	dir, err := ioutil.TempDir("", "accord-bolt")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "accord.db")
+
+	store, err := NewBoltStore(path)
+	if err != nil {
+		t.Fatalf("Failed to open bolt store: %s", err)
+	}
+	var last uint64
+	for i := 0; i < 5; i++ {
+		serial, err := store.NextSerial()
+		if err != nil {
+			t.Fatalf("BoltStore.NextSerial() error = %v", err)
+		}
+		if serial <= last {
+			t.Errorf("BoltStore.NextSerial() = %d, want > %d", serial, last)
+		}
+		last = serial
+	}
+	store.Close()
+
+	// the serials need to keep going up after a restart
+	store, err = NewBoltStore(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen bolt store: %s", err)
+	}
+	defer store.Close()
+	serial, err := store.NextSerial()
+	if err != nil {
+		t.Fatalf("BoltStore.NextSerial() error = %v", err)
+	}
+	if serial <= last {
+		t.Errorf("BoltStore.NextSerial() after reopen = %d, want > %d", serial, last)
+	}
+}
+
+func TestBoltStore_Record(t *testing.T) {
+	store, cleanup := newTestBoltStore(t)
+	defer cleanup()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	record := &CertRecord{
+		Serial:      1,
+		CertType:    UserCertType,
+		KeyId:       "testuser",
+		Fingerprint: "SHA256:test",
+		Principals:  []string{"zones-db"},
+		ValidFrom:   now,
+		ValidUntil:  now.Add(time.Hour),
+		Requester:   "127.0.0.1:1234",
+		Email:       "user1@ex.ample.com",
+		IssuedAt:    now,
+	}
+	if err := store.Record(record); err != nil {
+		t.Fatalf("BoltStore.Record() error = %v", err)
+	}
+	if err := store.Record(record); err == nil {
+		t.Errorf("BoltStore.Record() should fail when the serial is reused")
+	}
+	if err := store.Record(&CertRecord{}); err == nil {
+		t.Errorf("BoltStore.Record() should fail without a serial")
+	}
+
+	got, err := store.Get(1)
+	if err != nil {
+		t.Fatalf("BoltStore.Get() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, record) {
+		t.Errorf("BoltStore.Get() = %v, want %v", got, record)
+	}
+	if _, err := store.Get(2); err != ErrCertNotFound {
+		t.Errorf("BoltStore.Get() error = %v, want %v", err, ErrCertNotFound)
+	}
+}
+
+func TestBoltStore_ListCerts(t *testing.T) {
+	store, cleanup := newTestBoltStore(t)
+	defer cleanup()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	records := []*CertRecord{
+		{CertType: HostCertType, Fingerprint: "SHA256:web1", Principals: []string{"web-1.ex.ample.com"}, Deployment: "1", ValidUntil: now.Add(24 * time.Hour)},
+		{CertType: HostCertType, Fingerprint: "SHA256:db1", Principals: []string{"db-1.ex.ample.com"}, Deployment: "2", ValidUntil: now.Add(3 * 24 * time.Hour)},
+		{CertType: UserCertType, Fingerprint: "SHA256:user1", Principals: []string{"zones-db"}, Email: "user1@ex.ample.com", ValidUntil: now.Add(time.Hour)},
+		// web-1 renewed its cert
+		{CertType: HostCertType, Fingerprint: "SHA256:web1", Principals: []string{"web-1.ex.ample.com"}, Deployment: "1", ValidUntil: now.Add(30 * 24 * time.Hour)},
+		{CertType: UserCertType, Fingerprint: "SHA256:user2", Principals: []string{"web", "zones-db"}, Email: "User2@ex.ample.com", ValidUntil: now.Add(-time.Hour)},
+	}
+	for i, record := range records {
+		record.Serial = uint64(i + 1)
+		if err := store.Record(record); err != nil {
+			t.Fatalf("BoltStore.Record() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		filter      *CertFilter
+		wantSerials []uint64
+		wantNext    uint64
+		wantErr     bool
+	}{
+		{
+			name:        "everything",
+			filter:      &CertFilter{},
+			wantSerials: []uint64{1, 2, 3, 4, 5},
+		},
+		{
+			name:        "host certs by hostname pattern",
+			filter:      &CertFilter{Hostname: "web-*"},
+			wantSerials: []uint64{1, 4},
+		},
+		{
+			name:        "emails aren't case sensitive",
+			filter:      &CertFilter{Email: "user2@ex.ample.com"},
+			wantSerials: []uint64{5},
+		},
+		{
+			name:        "by principal",
+			filter:      &CertFilter{Principal: "zones-db"},
+			wantSerials: []uint64{3, 5},
+		},
+		{
+			name:        "by deployment",
+			filter:      &CertFilter{Deployment: "2"},
+			wantSerials: []uint64{2},
+		},
+		{
+			name:        "by serial",
+			filter:      &CertFilter{Serial: 3},
+			wantSerials: []uint64{3},
+		},
+		{
+			name:        "hosts that expire this week",
+			filter:      &CertFilter{CertType: HostCertType, ExpiresAfter: now, ExpiresBefore: now.Add(7 * 24 * time.Hour)},
+			wantSerials: []uint64{1, 2},
+		},
+		{
+			name:        "without the renewed certs",
+			filter:      &CertFilter{CertType: HostCertType, ExpiresAfter: now, ExpiresBefore: now.Add(7 * 24 * time.Hour), Latest: true},
+			wantSerials: []uint64{2},
+		},
+		{
+			name:        "the latest, a page at a time",
+			filter:      &CertFilter{Latest: true, PageSize: 2},
+			wantSerials: []uint64{2, 3},
+			wantNext:    3,
+		},
+		{
+			name:        "the latest after a page",
+			filter:      &CertFilter{Latest: true, PageSize: 2, After: 3},
+			wantSerials: []uint64{4, 5},
+		},
+		{
+			name:        "only what's visible",
+			filter:      &CertFilter{Visible: func(r *CertRecord) bool { return r.CertType == HostCertType }},
+			wantSerials: []uint64{1, 2, 4},
+		},
+		{
+			name:        "first page",
+			filter:      &CertFilter{PageSize: 2},
+			wantSerials: []uint64{1, 2},
+			wantNext:    2,
+		},
+		{
+			name:        "next page",
+			filter:      &CertFilter{PageSize: 2, After: 2},
+			wantSerials: []uint64{3, 4},
+			wantNext:    4,
+		},
+		{
+			name:        "a full last page is the last one",
+			filter:      &CertFilter{Principal: "zones-db", PageSize: 2},
+			wantSerials: []uint64{3, 5},
+		},
+		{
+			name:    "invalid pattern",
+			filter:  &CertFilter{Hostname: "web-["},
+			wantErr: true,
+		},
+		{
+			name:    "page too big",
+			filter:  &CertFilter{PageSize: MaxCertPageSize + 1},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, next, err := store.ListCerts(tt.filter)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("BoltStore.ListCerts() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			serials := []uint64{}
+			for _, record := range got {
+				serials = append(serials, record.Serial)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(serials, tt.wantSerials) {
+				t.Errorf("BoltStore.ListCerts() = %v, want %v", serials, tt.wantSerials)
+			}
+			if next != tt.wantNext {
+				t.Errorf("BoltStore.ListCerts() next = %d, want %d", next, tt.wantNext)
+			}
+		})
+	}
+}
+
+func TestBoltStore_Revoke(t *testing.T) {
+	store, cleanup := newTestBoltStore(t)
+	defer cleanup()
+
+	tests := []struct {
+		name        string
+		revocation  *Revocation
+		wantErr     bool
+		wantVersion uint64
+	}{
+		{
+			name:        "revoke by serial",
+			revocation:  &Revocation{Type: RevokeSerial, Value: "10"},
+			wantVersion: 1,
+		},
+		{
+			name:        "revoking the same serial again doesn't change the version",
+			revocation:  &Revocation{Type: RevokeSerial, Value: "10"},
+			wantVersion: 1,
+		},
+		{
+			name:        "revoke by key id",
+			revocation:  &Revocation{Type: RevokeKeyId, Value: "user1"},
+			wantVersion: 2,
+		},
+		{
+			name:        "invalid serials are rejected",
+			revocation:  &Revocation{Type: RevokeSerial, Value: "ten"},
+			wantErr:     true,
+			wantVersion: 2,
+		},
+		{
+			name:        "CAs need a type",
+			revocation:  &Revocation{Type: RevokeCA, Value: "ssh-rsa AAAA"},
+			wantErr:     true,
+			wantVersion: 2,
+		},
+		{
+			name:        "unknown revocation types are rejected",
+			revocation:  &Revocation{Type: "everything", Value: "*"},
+			wantErr:     true,
+			wantVersion: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := store.Revoke(tt.revocation)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("BoltStore.Revoke() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			_, version, err := store.Revocations()
+			if err != nil {
+				t.Fatalf("BoltStore.Revocations() error = %v", err)
+			}
+			if version != tt.wantVersion {
+				t.Errorf("BoltStore.Revocations() version = %d, want %d", version, tt.wantVersion)
+			}
+		})
+	}
+	revocations, _, err := store.Revocations()
+	if err != nil {
+		t.Fatalf("BoltStore.Revocations() error = %v", err)
+	}
+	if len(revocations) != 2 {
+		t.Errorf("BoltStore.Revocations() = %d revocations, want 2", len(revocations))
+	}
+}
+
+func TestBoltStore_DisableUser(t *testing.T) {
+	store, cleanup := newTestBoltStore(t)
+	defer cleanup()
+
+	if err := store.DisableUser(&DisabledUser{Email: "User1@ex.ample.com", Reason: "left", DisabledBy: "root"}); err != nil {
+		t.Fatalf("BoltStore.DisableUser() error = %v", err)
+	}
+	// disabling again keeps the first reason
+	if err := store.DisableUser(&DisabledUser{Email: "user1@ex.ample.com", Reason: "again"}); err != nil {
+		t.Fatalf("BoltStore.DisableUser() error = %v", err)
+	}
+	if err := store.DisableUser(&DisabledUser{}); err == nil {
+		t.Errorf("BoltStore.DisableUser() should fail without an email")
+	}
+
+	user, err := store.DisabledUser("user1@EX.ample.com")
+	if err != nil || user == nil || user.Reason != "left" {
+		t.Errorf("BoltStore.DisabledUser() = %v, %v, want the first one", user, err)
+	}
+	if user, err := store.DisabledUser("user2@ex.ample.com"); err != nil || user != nil {
+		t.Errorf("BoltStore.DisabledUser() = %v, %v, want nil", user, err)
+	}
+	users, err := store.DisabledUsers()
+	if err != nil || len(users) != 1 {
+		t.Errorf("BoltStore.DisabledUsers() = %v, %v, want 1 user", users, err)
+	}
+
+	if err := store.EnableUser("user1@ex.ample.com"); err != nil {
+		t.Errorf("BoltStore.EnableUser() error = %v", err)
+	}
+	if err := store.EnableUser("user1@ex.ample.com"); err != ErrUserNotDisabled {
+		t.Errorf("BoltStore.EnableUser() error = %v, want %v", err, ErrUserNotDisabled)
+	}
+	if user, err := store.DisabledUser("user1@ex.ample.com"); err != nil || user != nil {
+		t.Errorf("BoltStore.DisabledUser() after enabling = %v, %v, want nil", user, err)
+	}
+}
+
+func TestBoltStore_Users(t *testing.T) {
+	store, cleanup := newTestBoltStore(t)
+	defer cleanup()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	records := []*CertRecord{
+		{CertType: UserCertType, Email: "user2@ex.ample.com", IssuedAt: now.Add(-time.Hour), ValidUntil: now},
+		{CertType: HostCertType, Deployment: "1", IssuedAt: now, ValidUntil: now.Add(time.Hour)},
+		{CertType: UserCertType, Email: "User2@ex.ample.com", IssuedAt: now, ValidUntil: now.Add(time.Hour)},
+		{CertType: UserCertType, Email: "user1@ex.ample.com", IssuedAt: now, ValidUntil: now.Add(time.Hour)},
+	}
+	for i, record := range records {
+		record.Serial = uint64(i + 1)
+		if err := store.Record(record); err != nil {
+			t.Fatalf("BoltStore.Record() error = %v", err)
+		}
+	}
+	if err := store.DisableUser(&DisabledUser{Email: "user1@ex.ample.com"}); err != nil {
+		t.Fatalf("BoltStore.DisableUser() error = %v", err)
+	}
+	if err := store.DisableUser(&DisabledUser{Email: "user3@ex.ample.com"}); err != nil {
+		t.Fatalf("BoltStore.DisableUser() error = %v", err)
+	}
+
+	users, err := store.Users()
+	if err != nil {
+		t.Fatalf("BoltStore.Users() error = %v", err)
+	}
+	got := []string{}
+	for _, user := range users {
+		got = append(got, fmt.Sprintf("%s %d %v", strings.ToLower(user.Email), user.Certs, user.Disabled != nil))
+	}
+	want := []string{"user1@ex.ample.com 1 true", "user2@ex.ample.com 2 false", "user3@ex.ample.com 0 true"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("BoltStore.Users() = %v, want %v", got, want)
+	}
+	if !users[1].LastValidUntil.Equal(now.Add(time.Hour)) {
+		t.Errorf("BoltStore.Users() last valid until = %s, want %s", users[1].LastValidUntil, now.Add(time.Hour))
+	}
+}
+
+func TestBoltStore_UseHostSession(t *testing.T) {
+	store, cleanup := newTestBoltStore(t)
+	defer cleanup()
+
+	now := time.Now()
+	err := store.AddHostSession(&HostSession{
+		Id:        "session-1",
+		KeyId:     912090709,
+		UsesLeft:  2,
+		IssuedAt:  now,
+		ExpiresAt: now.Add(time.Minute),
+	})
+	if err != nil {
+		t.Fatalf("BoltStore.AddHostSession() error = %v", err)
+	}
+	if err := store.AddHostSession(&HostSession{Id: "session-2", KeyId: 1}); err == nil {
+		t.Errorf("BoltStore.AddHostSession() should fail for a session that can't be used")
+	}
+
+	tests := []struct {
+		name    string
+		id      string
+		keyId   uint32
+		now     time.Time
+		wantErr error
+	}{
+		{
+			name:    "unknown session",
+			id:      "session-0",
+			keyId:   912090709,
+			now:     now,
+			wantErr: ErrHostSessionNotFound,
+		},
+		{
+			name:    "another deployment",
+			id:      "session-1",
+			keyId:   1,
+			now:     now,
+			wantErr: ErrHostSessionWrongKey,
+		},
+		{
+			name:    "expired",
+			id:      "session-1",
+			keyId:   912090709,
+			now:     now.Add(time.Hour),
+			wantErr: ErrHostSessionExpired,
+		},
+		{
+			name:  "first use",
+			id:    "session-1",
+			keyId: 912090709,
+			now:   now,
+		},
+		{
+			name:  "second use",
+			id:    "session-1",
+			keyId: 912090709,
+			now:   now,
+		},
+		{
+			name:    "used up",
+			id:      "session-1",
+			keyId:   912090709,
+			now:     now,
+			wantErr: ErrHostSessionUsedUp,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := store.UseHostSession(tt.id, tt.keyId, tt.now)
+			if err != tt.wantErr {
+				t.Errorf("BoltStore.UseHostSession() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// adding a session later cleans up the expired ones
+	later := now.Add(time.Hour)
+	err = store.AddHostSession(&HostSession{Id: "session-3", KeyId: 1, UsesLeft: 1, IssuedAt: later, ExpiresAt: later.Add(time.Minute)})
+	if err != nil {
+		t.Fatalf("BoltStore.AddHostSession() error = %v", err)
+	}
+	if _, err := store.UseHostSession("session-1", 912090709, now); err != ErrHostSessionNotFound {
+		t.Errorf("Expired session wasn't cleaned up, error = %v", err)
+	}
+}
+
+func TestBoltStore_BindHostSession(t *testing.T) {
+	store, cleanup := newTestBoltStore(t)
+	defer cleanup()
+
+	now := time.Now()
+	err := store.AddHostSession(&HostSession{Id: "session-1", KeyId: 1, UsesLeft: 3, IssuedAt: now, ExpiresAt: now.Add(time.Minute)})
+	if err != nil {
+		t.Fatalf("BoltStore.AddHostSession() error = %v", err)
+	}
+	tests := []struct {
+		name       string
+		id         string
+		instanceId string
+		wantErr    error
+	}{
+		{"unknown session", "session-0", "i-1", ErrHostSessionNotFound},
+		{"first bind", "session-1", "i-1", nil},
+		{"same instance", "session-1", "i-1", nil},
+		{"another instance", "session-1", "i-2", ErrHostSessionWrongHost},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := store.BindHostSession(tt.id, tt.instanceId); err != tt.wantErr {
+				t.Errorf("BoltStore.BindHostSession() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+	session, err := store.UseHostSession("session-1", 1, now)
+	if err != nil || session.InstanceId != "i-1" {
+		t.Errorf("BoltStore.UseHostSession() = %v, %v, want the session bound to i-1", session, err)
+	}
+}
@@ -4,61 +4,255 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
-	"log"
+	"sort"
+	"time"
 
 	"github.com/mistsys/accord/id"
 )
 
+// PSKState is what a version of a PSK can still be used for
+type PSKState string
+
+const (
+	// the version the hosts should use, there's exactly one for each key id
+	PSKActive PSKState = "active"
+	// still accepted from the hosts that haven't been moved to the active version
+	PSKAcceptOnly PSKState = "accept-only"
+	// not accepted anymore, kept so that the version isn't reused
+	PSKRetired PSKState = "retired"
+)
+
+// PSKVersion is one version of the PSK of a deployment
+type PSKVersion struct {
+	Version   uint32    `json:"version"`
+	Key       []byte    `json:"key"`
+	State     PSKState  `json:"state"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// VersionedPSKs are all the versions of the PSKs by key id
+type VersionedPSKs map[uint32][]*PSKVersion
+
+// Validate checks that every key id has exactly one active version and that
+// the versions are unique
+func (v VersionedPSKs) Validate() error {
+	for keyId, versions := range v {
+		active := 0
+		seen := map[uint32]bool{}
+		for _, version := range versions {
+			if version.Version == 0 {
+				return fmt.Errorf("Key id %d has a PSK without a version, they start from 1", keyId)
+			}
+			if seen[version.Version] {
+				return fmt.Errorf("Key id %d has version %d more than once", keyId, version.Version)
+			}
+			seen[version.Version] = true
+			switch version.State {
+			case PSKActive:
+				active++
+			case PSKAcceptOnly, PSKRetired:
+			default:
+				return fmt.Errorf("Key id %d version %d has unknown state %q", keyId, version.Version, version.State)
+			}
+			switch len(version.Key) {
+			case 16, 24, 32:
+			default:
+				return fmt.Errorf("Key id %d version %d isn't an AES key", keyId, version.Version)
+			}
+		}
+		if active != 1 {
+			return fmt.Errorf("Key id %d has %d active versions, it needs exactly one", keyId, active)
+		}
+	}
+	return nil
+}
+
+// Add adds a new deployment with its first version
+func (v VersionedPSKs) Add(keyId uint32, key []byte, now time.Time) error {
+	if _, ok := v[keyId]; ok {
+		return fmt.Errorf("Key id %d already has a PSK", keyId)
+	}
+	v[keyId] = []*PSKVersion{{Version: 1, Key: key, State: PSKActive, CreatedAt: now}}
+	return nil
+}
+
+// Rotate adds a new active version, the version that was active is still
+// accepted until it's retired. Returns the new version
+func (v VersionedPSKs) Rotate(keyId uint32, key []byte, now time.Time) (uint32, error) {
+	versions, ok := v[keyId]
+	if !ok {
+		return 0, fmt.Errorf("Key id %d doesn't have a PSK to rotate", keyId)
+	}
+	var latest uint32
+	for _, version := range versions {
+		if version.State == PSKActive {
+			version.State = PSKAcceptOnly
+		}
+		if version.Version > latest {
+			latest = version.Version
+		}
+	}
+	v[keyId] = append(versions, &PSKVersion{Version: latest + 1, Key: key, State: PSKActive, CreatedAt: now})
+	return latest + 1, nil
+}
+
+// Retire stops accepting a version, the active one has to be rotated out first
+func (v VersionedPSKs) Retire(keyId uint32, version uint32) error {
+	for _, psk := range v[keyId] {
+		if psk.Version != version {
+			continue
+		}
+		if psk.State == PSKActive {
+			return fmt.Errorf("Version %d is the active one for key id %d, rotate it first", version, keyId)
+		}
+		psk.State = PSKRetired
+		return nil
+	}
+	return fmt.Errorf("Key id %d doesn't have version %d", keyId, version)
+}
+
 type LocalPSKStore struct {
 	// pre-shared keys by ID to different environments
 	// the id should be checked against the PSK and encrypt
 	// the response back
 	// This is intended to be used with AES-GCM, where the ID
 	// is sent by the server at the beginning of the exchange
-	psks map[uint32][]byte
+	psks VersionedPSKs
+	// the MD5 key ids, of the deployments that haven't moved to HMAC ones
+	// and the ones still accepted from those that have
+	legacy map[uint32]legacyKeyID
+}
+
+type legacyKeyID struct {
+	deployment string
+	keyId      uint32
 }
 
+// GetPSK returns the active PSK for a 4 byte key id, or the version after
+// the key id if it's 8 bytes. Retired versions aren't returned
 func (l *LocalPSKStore) GetPSK(key []byte) ([]byte, error) {
-	if len(key) != 4 {
-		return nil, errors.New("Key size != 4, cannot lookup the key in local PSK store")
+	if len(key) != 4 && len(key) != 8 {
+		return nil, errors.New("Key size isn't 4 or 8, cannot lookup the key in local PSK store")
 	}
 	id := binary.BigEndian.Uint32(key)
-	k, ok := l.psks[id]
+	versions, ok := l.psks[id]
 	if !ok {
 		return nil, fmt.Errorf("Cannot lookup key for id: %d", id)
 	}
-	return k, nil
+	for _, version := range versions {
+		if len(key) == 4 && version.State == PSKActive {
+			return version.Key, nil
+		}
+		if len(key) == 8 && version.Version == binary.BigEndian.Uint32(key[4:]) {
+			if version.State == PSKRetired {
+				return nil, fmt.Errorf("Version %d for id: %d is retired", version.Version, id)
+			}
+			return version.Key, nil
+		}
+	}
+	return nil, fmt.Errorf("Cannot lookup key for id: %d", id)
 }
 
+// AcceptedVersions are the versions that aren't retired, the active one first
+func (l *LocalPSKStore) AcceptedVersions(keyId uint32) []uint32 {
+	accepted := []*PSKVersion{}
+	for _, version := range l.psks[keyId] {
+		if version.State != PSKRetired {
+			accepted = append(accepted, version)
+		}
+	}
+	sort.Slice(accepted, func(i, j int) bool {
+		if (accepted[i].State == PSKActive) != (accepted[j].State == PSKActive) {
+			return accepted[i].State == PSKActive
+		}
+		return accepted[i].Version > accepted[j].Version
+	})
+	versions := []uint32{}
+	for _, version := range accepted {
+		versions = append(versions, version.Version)
+	}
+	return versions
+}
+
+// HealthCheck fails when there's a key id none of the hosts could
+// authenticate with
+func (l *LocalPSKStore) HealthCheck() error {
+	if len(l.psks) == 0 {
+		return errors.New("No PSKs loaded")
+	}
+	for keyId, versions := range l.psks {
+		active := false
+		for _, version := range versions {
+			if version.State == PSKActive && len(version.Key) > 0 {
+				active = true
+			}
+		}
+		if !active {
+			return fmt.Errorf("Key id %d has no active PSK", keyId)
+		}
+	}
+	return nil
+}
+
+// NewLocalPSKStore takes a single PSK for each key id, they're version 1
 func NewLocalPSKStore(psks map[uint32][]byte) *LocalPSKStore {
+	versioned := VersionedPSKs{}
+	for keyId, key := range psks {
+		versioned[keyId] = []*PSKVersion{{Version: 1, Key: key, State: PSKActive}}
+	}
+	return &LocalPSKStore{
+		psks: versioned,
+	}
+}
+
+func NewVersionedPSKStore(psks VersionedPSKs) *LocalPSKStore {
 	return &LocalPSKStore{
 		psks: psks,
 	}
 }
 
+// NewDeploymentsPSKStore has the PSKs of the deployments, both under their
+// key ids and the legacy ones that are still accepted
+func NewDeploymentsPSKStore(deployments Deployments) *LocalPSKStore {
+	legacy := map[uint32]legacyKeyID{}
+	for name, deployment := range deployments {
+		if deployment.KeyIDVersion == id.KeyIDVersionMD5 {
+			legacy[deployment.KeyID] = legacyKeyID{deployment: name, keyId: deployment.KeyID}
+		}
+		if deployment.LegacyKeyID != 0 {
+			legacy[deployment.LegacyKeyID] = legacyKeyID{deployment: name, keyId: deployment.KeyID}
+		}
+	}
+	return &LocalPSKStore{
+		psks:   deployments.PSKs(),
+		legacy: legacy,
+	}
+}
+
+// LegacyKeyID tells if the key id is an MD5 one and returns the deployment
+// and its current key id, which is the same one until it's moved to HMAC
+func (l *LocalPSKStore) LegacyKeyID(keyId uint32) (string, uint32, bool) {
+	legacy, ok := l.legacy[keyId]
+	return legacy.deployment, legacy.keyId, ok
+}
+
 // LocalDB is for using locally and testing
 func NewDummyPSKStore() *LocalPSKStore {
 	psks := make(map[uint32][]byte)
 	// test = random key generated by
 	// < /dev/urandom tr -dc _A-Z-a-z-0-9 | head -c32
 	psks[160394189] = []byte(`JpUtbRukLuIFyjeKpA4fIpjgs6MTV8eH`)
-	return &LocalPSKStore{
-		psks: psks,
-	}
+	return NewLocalPSKStore(psks)
 }
 
 // This is another easy to use store that can be initialize dwith a single psk
 // and a keyId, this is useful for creating client or supplying the psk from
 // commandline. This shouldn't be used for production workload.
-func NewSinglePSKStore(deploymentId string, salt string, psk string) *LocalPSKStore {
-	psks := make(map[uint32][]byte)
-	keyId, err := id.KeyID(deploymentId, salt)
-	if err != nil {
-		log.Printf("Failed to get the keyId: %s", err)
-		return nil
-	}
-	psks[keyId] = []byte(psk)
+// The version is what the server knows the PSK as, 0 if it's from before the versions
+func NewSinglePSKStore(keyId uint32, psk string, version uint32) *LocalPSKStore {
 	return &LocalPSKStore{
-		psks: psks,
+		psks: VersionedPSKs{
+			keyId: {{Version: version, Key: []byte(psk), State: PSKActive}},
+		},
 	}
 }
@@ -1,8 +1,12 @@
 package db
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/mistsys/accord/id"
 )
@@ -42,3 +46,129 @@ func TestLocalPSKStore_GetPSK(t *testing.T) {
 		})
 	}
 }
+
+func TestVersionedPSKs_Rotate(t *testing.T) {
+	now := time.Now()
+	psks := VersionedPSKs{}
+	if err := psks.Add(1, []byte(`JpUtbRukLuIFyjeKpA4fIpjgs6MTV8eH`), now); err != nil {
+		t.Fatalf("VersionedPSKs.Add() error = %v", err)
+	}
+	if err := psks.Add(1, []byte(`JpUtbRukLuIFyjeKpA4fIpjgs6MTV8eH`), now); err == nil {
+		t.Errorf("VersionedPSKs.Add() should fail for a key id that has a PSK")
+	}
+	version, err := psks.Rotate(1, []byte(`qvFyLbJdNF6Cx0hpKzT0w3nqsTEbgvSe`), now)
+	if err != nil || version != 2 {
+		t.Fatalf("VersionedPSKs.Rotate() = %d, %v, want 2", version, err)
+	}
+	if err := psks.Validate(); err != nil {
+		t.Errorf("VersionedPSKs.Validate() error = %v after rotating", err)
+	}
+	store := NewVersionedPSKStore(psks)
+	if got := store.AcceptedVersions(1); !reflect.DeepEqual(got, []uint32{2, 1}) {
+		t.Errorf("LocalPSKStore.AcceptedVersions() = %v, want [2 1]", got)
+	}
+	active, _ := store.GetPSK([]byte{0, 0, 0, 1})
+	if string(active) != `qvFyLbJdNF6Cx0hpKzT0w3nqsTEbgvSe` {
+		t.Errorf("LocalPSKStore.GetPSK() = %s, want the rotated key", active)
+	}
+
+	if err := psks.Retire(1, 2); err == nil {
+		t.Errorf("VersionedPSKs.Retire() should fail for the active version")
+	}
+	if err := psks.Retire(1, 1); err != nil {
+		t.Fatalf("VersionedPSKs.Retire() error = %v", err)
+	}
+	if _, err := store.GetPSK([]byte{0, 0, 0, 1, 0, 0, 0, 1}); err == nil {
+		t.Errorf("LocalPSKStore.GetPSK() should fail for a retired version")
+	}
+	if got := store.AcceptedVersions(1); !reflect.DeepEqual(got, []uint32{2}) {
+		t.Errorf("LocalPSKStore.AcceptedVersions() = %v, want [2]", got)
+	}
+}
+
+func TestLocalPSKStore_HealthCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		psks    VersionedPSKs
+		wantErr bool
+	}{
+		{"no psks", VersionedPSKs{}, true},
+		{"active", VersionedPSKs{1: {{Version: 1, Key: []byte(`JpUtbRukLuIFyjeKpA4fIpjgs6MTV8eH`), State: PSKActive}}}, false},
+		{"only retired", VersionedPSKs{
+			1: {{Version: 1, Key: []byte(`JpUtbRukLuIFyjeKpA4fIpjgs6MTV8eH`), State: PSKActive}},
+			2: {{Version: 1, Key: []byte(`qvFyLbJdNF6Cx0hpKzT0w3nqsTEbgvSe`), State: PSKRetired}},
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewVersionedPSKStore(tt.psks).HealthCheck()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("LocalPSKStore.HealthCheck() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReadPSKFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "accord-psks")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name    string
+		content string
+		want    []uint32
+		wantErr bool
+	}{
+		{
+			name:    "from before the versions",
+			content: `{"912090709": "SnBVdGJSdWtMdUlGeWplS3BBNElmcGpnczZNVFY4ZUg="}`,
+			want:    []uint32{1},
+		},
+		{
+			name: "versioned",
+			content: `{"912090709": [
+				{"version": 1, "key": "SnBVdGJSdWtMdUlGeWplS3BBNElmcGpnczZNVFY4ZUg=", "state": "accept-only"},
+				{"version": 2, "key": "SnBVdGJSdWtMdUlGeWplS3BBNElmcGpnczZNVFY4ZUg=", "state": "active"},
+				{"version": 3, "key": "SnBVdGJSdWtMdUlGeWplS3BBNElmcGpnczZNVFY4ZUg=", "state": "retired"}
+			]}`,
+			want: []uint32{2, 1},
+		},
+		{
+			name: "two active versions",
+			content: `{"912090709": [
+				{"version": 1, "key": "SnBVdGJSdWtMdUlGeWplS3BBNElmcGpnczZNVFY4ZUg=", "state": "active"},
+				{"version": 2, "key": "SnBVdGJSdWtMdUlGeWplS3BBNElmcGpnczZNVFY4ZUg=", "state": "active"}
+			]}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, "psks.json")
+			if err := ioutil.WriteFile(path, []byte(tt.content), 0600); err != nil {
+				t.Fatalf("Failed to write %s: %s", path, err)
+			}
+			psks, err := ReadPSKFile(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadPSKFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got := NewDeploymentsPSKStore(psks).AcceptedVersions(912090709); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadPSKFile() accepted versions = %v, want %v", got, tt.want)
+			}
+			// and it's written back in the versioned format
+			if err := WritePSKFile(path, psks); err != nil {
+				t.Fatalf("WritePSKFile() error = %v", err)
+			}
+			again, err := ReadPSKFile(path)
+			if err != nil || !reflect.DeepEqual(again, psks) {
+				t.Errorf("ReadPSKFile() after WritePSKFile() = %v, %v", again, err)
+			}
+		})
+	}
+}
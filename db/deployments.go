@@ -0,0 +1,246 @@
+package db
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/mistsys/accord/id"
+)
+
+var ErrKeyIDCollision = errors.New("The key id is already used by another deployment")
+
+// Deployment has the key ids and the PSK versions of a deployment
+type Deployment struct {
+	KeyID        uint32 `json:"key_id"`
+	KeyIDVersion int    `json:"key_id_version"`
+	// the MD5 key id that's still accepted from the hosts that haven't been
+	// moved to the HMAC one, 0 once the migration is done
+	LegacyKeyID uint32        `json:"legacy_key_id,omitempty"`
+	PSKs        []*PSKVersion `json:"psks"`
+}
+
+// Deployments are all the deployments by name, it's what's in the PSK file
+type Deployments map[string]*Deployment
+
+type pskFile struct {
+	Deployments Deployments `json:"deployments"`
+}
+
+// owner returns the deployment that uses the key id, either as its key id or
+// as the legacy one
+func (d Deployments) owner(keyId uint32) (string, bool) {
+	for name, deployment := range d {
+		if deployment.KeyID == keyId || (deployment.LegacyKeyID != 0 && deployment.LegacyKeyID == keyId) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// Validate checks that no two deployments share a key id and that the PSKs are valid
+func (d Deployments) Validate() error {
+	seen := map[uint32]string{}
+	for name, deployment := range d {
+		switch deployment.KeyIDVersion {
+		case id.KeyIDVersionMD5, id.KeyIDVersionHMAC:
+		default:
+			return fmt.Errorf("Deployment %s has unknown key id version %d", name, deployment.KeyIDVersion)
+		}
+		keyIds := []uint32{deployment.KeyID}
+		if deployment.LegacyKeyID != 0 {
+			keyIds = append(keyIds, deployment.LegacyKeyID)
+		}
+		for _, keyId := range keyIds {
+			if other, ok := seen[keyId]; ok {
+				return fmt.Errorf("Deployments %s and %s both use key id %d", other, name, keyId)
+			}
+			seen[keyId] = name
+		}
+		if err := (VersionedPSKs{deployment.KeyID: deployment.PSKs}).Validate(); err != nil {
+			return fmt.Errorf("Deployment %s: %s", name, err)
+		}
+	}
+	return nil
+}
+
+// Register adds a new deployment with its first PSK version. A key id that
+// another deployment already has is an error, the hosts of both would share
+// the PSKs on the server
+func (d Deployments) Register(name string, keyId uint32, keyIdVersion int, key []byte, now time.Time) error {
+	if _, ok := d[name]; ok {
+		return fmt.Errorf("Deployment %s already has a PSK", name)
+	}
+	if other, ok := d.owner(keyId); ok {
+		return fmt.Errorf("%s: %d is used by %s, pick another deployment id", ErrKeyIDCollision, keyId, other)
+	}
+	psks := VersionedPSKs{}
+	if err := psks.Add(keyId, key, now); err != nil {
+		return err
+	}
+	d[name] = &Deployment{KeyID: keyId, KeyIDVersion: keyIdVersion, PSKs: psks[keyId]}
+	return nil
+}
+
+// Lookup finds the deployment by name, the ones from before the deployments
+// had names need NameLegacy first
+func (d Deployments) Lookup(name string) (*Deployment, error) {
+	if deployment, ok := d[name]; ok {
+		return deployment, nil
+	}
+	return nil, fmt.Errorf("No deployment %s", name)
+}
+
+// NameLegacy gives the name to the deployment from the files from before the
+// deployments had names, it's under its MD5 key id. False when there's no
+// such deployment, including when it already has the name
+func (d Deployments) NameLegacy(name string, legacyKeyId uint32) bool {
+	if _, ok := d[name]; ok {
+		return false
+	}
+	unnamed := strconv.FormatUint(uint64(legacyKeyId), 10)
+	deployment, ok := d[unnamed]
+	if !ok || deployment.KeyID != legacyKeyId {
+		return false
+	}
+	delete(d, unnamed)
+	d[name] = deployment
+	return true
+}
+
+// Rotate adds a new active PSK version to the deployment, see VersionedPSKs.Rotate
+func (d *Deployment) Rotate(key []byte, now time.Time) (uint32, error) {
+	psks := VersionedPSKs{d.KeyID: d.PSKs}
+	version, err := psks.Rotate(d.KeyID, key, now)
+	d.PSKs = psks[d.KeyID]
+	return version, err
+}
+
+// Retire stops accepting a PSK version, see VersionedPSKs.Retire
+func (d *Deployment) Retire(version uint32) error {
+	return VersionedPSKs{d.KeyID: d.PSKs}.Retire(d.KeyID, version)
+}
+
+// MigrateKeyID moves the deployment to the HMAC key id, the MD5 one is still
+// accepted until RetireLegacyKeyID so the hosts can be moved one at a time
+func (d Deployments) MigrateKeyID(name string, keyId uint32) error {
+	deployment, ok := d[name]
+	if !ok {
+		return fmt.Errorf("No deployment %s", name)
+	}
+	if deployment.KeyIDVersion == id.KeyIDVersionHMAC {
+		return fmt.Errorf("Deployment %s already has an HMAC key id", name)
+	}
+	if other, ok := d.owner(keyId); ok {
+		return fmt.Errorf("%s: %d is used by %s", ErrKeyIDCollision, keyId, other)
+	}
+	deployment.LegacyKeyID = deployment.KeyID
+	deployment.KeyID = keyId
+	deployment.KeyIDVersion = id.KeyIDVersionHMAC
+	return nil
+}
+
+// RetireLegacyKeyID stops accepting the MD5 key id of the deployment
+func (d Deployments) RetireLegacyKeyID(name string) error {
+	deployment, ok := d[name]
+	if !ok {
+		return fmt.Errorf("No deployment %s", name)
+	}
+	if deployment.LegacyKeyID == 0 {
+		return fmt.Errorf("Deployment %s doesn't have a legacy key id", name)
+	}
+	deployment.LegacyKeyID = 0
+	return nil
+}
+
+// Legacy are the names of the deployments whose hosts can still use MD5 key ids
+func (d Deployments) Legacy() []string {
+	names := []string{}
+	for name, deployment := range d {
+		if deployment.KeyIDVersion == id.KeyIDVersionMD5 || deployment.LegacyKeyID != 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// PSKs are the PSK versions by key id, the legacy key ids share them with
+// the HMAC ones
+func (d Deployments) PSKs() VersionedPSKs {
+	psks := VersionedPSKs{}
+	for _, deployment := range d {
+		psks[deployment.KeyID] = deployment.PSKs
+		if deployment.LegacyKeyID != 0 {
+			psks[deployment.LegacyKeyID] = deployment.PSKs
+		}
+	}
+	return psks
+}
+
+// ReadPSKFile reads the deployments. The files from before the deployments
+// had names, with either a single PSK or the versions for each key id, are
+// read as MD5 key ids named after the key id, the first PSK is version 1
+func ReadPSKFile(path string) (Deployments, error) {
+	dat, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read file %s. %s", path, err)
+	}
+	raw := map[string]json.RawMessage{}
+	if err := json.Unmarshal(dat, &raw); err != nil {
+		return nil, fmt.Errorf("Failed to unmarshal json in %s. %s", path, err)
+	}
+	deployments := Deployments{}
+	if _, ok := raw["deployments"]; ok {
+		file := &pskFile{}
+		if err := json.Unmarshal(dat, file); err != nil {
+			return nil, fmt.Errorf("Failed to unmarshal json in %s. %s", path, err)
+		}
+		deployments = file.Deployments
+	} else {
+		for name, value := range raw {
+			keyId, err := strconv.ParseUint(name, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid key id %s in %s", name, path)
+			}
+			deployment := &Deployment{KeyID: uint32(keyId), KeyIDVersion: id.KeyIDVersionMD5}
+			if bytes.HasPrefix(bytes.TrimSpace(value), []byte(`"`)) {
+				var key []byte
+				if err := json.Unmarshal(value, &key); err != nil {
+					return nil, fmt.Errorf("Failed to unmarshal the PSK for key id %s in %s. %s", name, path, err)
+				}
+				deployment.PSKs = []*PSKVersion{{Version: 1, Key: key, State: PSKActive}}
+			} else if err := json.Unmarshal(value, &deployment.PSKs); err != nil {
+				return nil, fmt.Errorf("Failed to unmarshal the PSKs for key id %s in %s. %s", name, path, err)
+			}
+			deployments[name] = deployment
+		}
+	}
+	if err := deployments.Validate(); err != nil {
+		return nil, fmt.Errorf("Invalid PSKs in %s. %s", path, err)
+	}
+	return deployments, nil
+}
+
+// WritePSKFile writes the deployments in the current format
+func WritePSKFile(path string, deployments Deployments) error {
+	if err := deployments.Validate(); err != nil {
+		return err
+	}
+	content, err := json.MarshalIndent(&pskFile{Deployments: deployments}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Failed to marshal psks %s", err)
+	}
+	// the server reads it while it's running, so it's never half written
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, content, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
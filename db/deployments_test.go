@@ -0,0 +1,88 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mistsys/accord/id"
+)
+
+func TestDeployments_MigrateKeyID(t *testing.T) {
+	now := time.Now()
+	deployments := Deployments{}
+	if err := deployments.Register("staging", 1, id.KeyIDVersionMD5, []byte(`JpUtbRukLuIFyjeKpA4fIpjgs6MTV8eH`), now); err != nil {
+		t.Fatalf("Deployments.Register() error = %v", err)
+	}
+	if err := deployments.Register("production", 1, id.KeyIDVersionHMAC, []byte(`qvFyLbJdNF6Cx0hpKzT0w3nqsTEbgvSe`), now); err == nil {
+		t.Errorf("Deployments.Register() should fail when the key id collides")
+	}
+	if err := deployments.Register("production", 2, id.KeyIDVersionHMAC, []byte(`qvFyLbJdNF6Cx0hpKzT0w3nqsTEbgvSe`), now); err != nil {
+		t.Fatalf("Deployments.Register() error = %v", err)
+	}
+	if got := deployments.Legacy(); !reflect.DeepEqual(got, []string{"staging"}) {
+		t.Errorf("Deployments.Legacy() = %v, want [staging]", got)
+	}
+	if err := deployments.MigrateKeyID("staging", 2); err == nil {
+		t.Errorf("Deployments.MigrateKeyID() should fail when the key id collides")
+	}
+	if err := deployments.MigrateKeyID("staging", 3); err != nil {
+		t.Fatalf("Deployments.MigrateKeyID() error = %v", err)
+	}
+	if err := deployments.Validate(); err != nil {
+		t.Errorf("Deployments.Validate() error = %v", err)
+	}
+
+	// both key ids work during the migration
+	store := NewDeploymentsPSKStore(deployments)
+	for _, key := range [][]byte{{0, 0, 0, 1}, {0, 0, 0, 3}} {
+		if psk, err := store.GetPSK(key); err != nil || string(psk) != `JpUtbRukLuIFyjeKpA4fIpjgs6MTV8eH` {
+			t.Errorf("LocalPSKStore.GetPSK(%v) = %s, %v", key, psk, err)
+		}
+	}
+	if name, keyId, ok := store.LegacyKeyID(1); !ok || name != "staging" || keyId != 3 {
+		t.Errorf("LocalPSKStore.LegacyKeyID(1) = %s, %d, %v, want staging, 3", name, keyId, ok)
+	}
+	if _, _, ok := store.LegacyKeyID(3); ok {
+		t.Errorf("LocalPSKStore.LegacyKeyID(3) should be false for the HMAC key id")
+	}
+	if got := deployments.Legacy(); !reflect.DeepEqual(got, []string{"staging"}) {
+		t.Errorf("Deployments.Legacy() = %v, want [staging] until the MD5 key id is retired", got)
+	}
+
+	if err := deployments.RetireLegacyKeyID("staging"); err != nil {
+		t.Fatalf("Deployments.RetireLegacyKeyID() error = %v", err)
+	}
+	if _, err := NewDeploymentsPSKStore(deployments).GetPSK([]byte{0, 0, 0, 1}); err == nil {
+		t.Errorf("LocalPSKStore.GetPSK() should fail for the retired MD5 key id")
+	}
+	if got := deployments.Legacy(); len(got) != 0 {
+		t.Errorf("Deployments.Legacy() = %v, want none", got)
+	}
+}
+
+func TestDeployments_Lookup(t *testing.T) {
+	deployments := Deployments{
+		"160394189": {KeyID: 160394189, KeyIDVersion: id.KeyIDVersionMD5},
+	}
+	legacyKeyId, _ := id.KeyID("test", "")
+	if _, err := deployments.Lookup("test"); err == nil || len(deployments) != 1 || deployments["160394189"] == nil {
+		t.Fatalf("Deployments.Lookup() error = %v, changed the deployments to %v", err, deployments)
+	}
+	if !deployments.NameLegacy("test", legacyKeyId) {
+		t.Fatalf("Deployments.NameLegacy() didn't find the deployment")
+	}
+	if deployments.NameLegacy("test", legacyKeyId) {
+		t.Errorf("Deployments.NameLegacy() named the deployment twice")
+	}
+	deployment, err := deployments.Lookup("test")
+	if err != nil {
+		t.Fatalf("Deployments.Lookup() error = %v", err)
+	}
+	if deployment.KeyID != 160394189 || len(deployments) != 1 {
+		t.Errorf("Deployments.NameLegacy() didn't name the deployment, %v", deployments)
+	}
+	if _, err := deployments.Lookup("other"); err == nil {
+		t.Errorf("Deployments.Lookup() should fail for an unknown deployment")
+	}
+}
@@ -0,0 +1,144 @@
+package db
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+var hostSessionsBucket = []byte("host_sessions")
+
+var (
+	ErrHostSessionNotFound  = errors.New("Unknown host session, authenticate with HostAuth first")
+	ErrHostSessionExpired   = errors.New("The host session has expired")
+	ErrHostSessionUsedUp    = errors.New("The host session has been used as many times as it's allowed")
+	ErrHostSessionWrongKey  = errors.New("The host session was issued to a different deployment")
+	ErrHostSessionWrongHost = errors.New("The host session is bound to a different instance")
+)
+
+// HostSession is handed out by HostAuth to a host that proved it has the PSK
+// for KeyId. It can only be used for a few cert requests, one for each of the
+// host keys, and only for a short time
+type HostSession struct {
+	Id        string    `json:"id"`
+	KeyId     uint32    `json:"key_id"`
+	UsesLeft  int       `json:"uses_left"`
+	Requester string    `json:"requester"`
+	IssuedAt  time.Time `json:"issued_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	// the verified instance the first cert request came from, the rest of
+	// the requests have to come from the same one
+	InstanceId string `json:"instance_id,omitempty"`
+}
+
+type HostSessionStore interface {
+	AddHostSession(session *HostSession) error
+	// UseHostSession checks the session is still good for a host with keyId
+	// and uses it up once
+	UseHostSession(id string, keyId uint32, now time.Time) (*HostSession, error)
+	// BindHostSession ties the session to the instance the first time, and
+	// fails for any other instance after that
+	BindHostSession(id string, instanceId string) error
+}
+
+// AddHostSession also drops the sessions that have expired, there aren't
+// many of them and it keeps the bucket from growing forever
+func (s *BoltStore) AddHostSession(session *HostSession) error {
+	if session.Id == "" {
+		return errors.New("Cannot add a host session without an id")
+	}
+	if session.UsesLeft <= 0 {
+		return errors.New("Host sessions have to be usable at least once")
+	}
+	value, err := json.Marshal(session)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to marshal the host session")
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(hostSessionsBucket)
+		expired := [][]byte{}
+		err := b.ForEach(func(k, v []byte) error {
+			existing := &HostSession{}
+			if err := json.Unmarshal(v, existing); err != nil || !session.IssuedAt.Before(existing.ExpiresAt) {
+				expired = append(expired, k)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range expired {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		if b.Get([]byte(session.Id)) != nil {
+			return errors.Errorf("Host session %s already exists", session.Id)
+		}
+		return b.Put([]byte(session.Id), value)
+	})
+}
+
+func (s *BoltStore) UseHostSession(id string, keyId uint32, now time.Time) (*HostSession, error) {
+	session := &HostSession{}
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(hostSessionsBucket)
+		value := b.Get([]byte(id))
+		if value == nil {
+			return ErrHostSessionNotFound
+		}
+		if err := json.Unmarshal(value, session); err != nil {
+			return errors.Wrapf(err, "Failed to unmarshal host session")
+		}
+		if session.KeyId != keyId {
+			return ErrHostSessionWrongKey
+		}
+		if !now.Before(session.ExpiresAt) {
+			return ErrHostSessionExpired
+		}
+		if session.UsesLeft <= 0 {
+			return ErrHostSessionUsedUp
+		}
+		session.UsesLeft--
+		value, err := json.Marshal(session)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(id), value)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+func (s *BoltStore) BindHostSession(id string, instanceId string) error {
+	if instanceId == "" {
+		return errors.New("Cannot bind a host session to an instance without an id")
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(hostSessionsBucket)
+		value := b.Get([]byte(id))
+		if value == nil {
+			return ErrHostSessionNotFound
+		}
+		session := &HostSession{}
+		if err := json.Unmarshal(value, session); err != nil {
+			return errors.Wrapf(err, "Failed to unmarshal host session")
+		}
+		if session.InstanceId == instanceId {
+			return nil
+		}
+		if session.InstanceId != "" {
+			return ErrHostSessionWrongHost
+		}
+		session.InstanceId = instanceId
+		value, err := json.Marshal(session)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(id), value)
+	})
+}
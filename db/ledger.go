@@ -0,0 +1,130 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+var ErrCertNotFound = errors.New("No cert found for the serial")
+
+const (
+	UserCertType = "user"
+	HostCertType = "host"
+)
+
+// CertRecord is everything the server remembers about a cert it signed
+// the cert itself isn't kept, it can be reconstructed from the public key
+// but there's no reason to, the fingerprint is enough to match it up later
+type CertRecord struct {
+	Serial      uint64    `json:"serial"`
+	CertType    string    `json:"cert_type"`
+	KeyId       string    `json:"key_id"`
+	Fingerprint string    `json:"fingerprint"`
+	Principals  []string  `json:"principals"`
+	ValidFrom   time.Time `json:"valid_from"`
+	ValidUntil  time.Time `json:"valid_until"`
+	// where the request came from, usually the peer address or the local username
+	Requester string `json:"requester"`
+	// only one of these is set depending on the CertType
+	Deployment string    `json:"deployment,omitempty"`
+	Email      string    `json:"email,omitempty"`
+	IssuedAt   time.Time `json:"issued_at"`
+}
+
+// Ledger hands out the serials for the certs and keeps a record of
+// every cert issued with them. The serials have to be unique and
+// only ever go up, so that revoking by serial is meaningful
+type Ledger interface {
+	NextSerial() (uint64, error)
+	Record(record *CertRecord) error
+	Get(serial uint64) (*CertRecord, error)
+}
+
+const (
+	DefaultCertPageSize = 100
+	MaxCertPageSize     = 1000
+)
+
+// CertFilter picks the certs out of the ledger, the empty fields match
+// everything. Hostname and Principal can be patterns like web-*
+type CertFilter struct {
+	CertType   string
+	Email      string
+	Hostname   string
+	Deployment string
+	Principal  string
+	Serial     uint64
+	// ValidUntil in [ExpiresAfter, ExpiresBefore)
+	ExpiresAfter  time.Time
+	ExpiresBefore time.Time
+	// drop the certs that were renewed, the same key got a later cert
+	Latest bool
+	// checked after everything else, for what the caller is allowed to see
+	Visible func(record *CertRecord) bool
+	// the certs are in the order of their serials, the page starts after
+	// this one
+	After    uint64
+	PageSize int
+}
+
+func (f *CertFilter) Validate() error {
+	for _, pattern := range []string{f.Hostname, f.Principal} {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("Invalid pattern %q", pattern)
+		}
+	}
+	switch f.CertType {
+	case "", UserCertType, HostCertType:
+	default:
+		return fmt.Errorf("Unknown cert type %q", f.CertType)
+	}
+	if f.PageSize < 0 || f.PageSize > MaxCertPageSize {
+		return fmt.Errorf("Page size has to be between 1 and %d", MaxCertPageSize)
+	}
+	return nil
+}
+
+func anyMatches(pattern string, values []string) bool {
+	for _, value := range values {
+		if ok, _ := path.Match(pattern, value); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Matches is everything but Latest, that needs the rest of the ledger
+func (f *CertFilter) Matches(record *CertRecord) bool {
+	switch {
+	case f.CertType != "" && record.CertType != f.CertType:
+		return false
+	case f.Email != "" && !strings.EqualFold(record.Email, f.Email):
+		return false
+	case f.Deployment != "" && record.Deployment != f.Deployment:
+		return false
+	case f.Serial != 0 && record.Serial != f.Serial:
+		return false
+	// the principals of the host certs are the hostnames
+	case f.Hostname != "" && (record.CertType != HostCertType || !anyMatches(f.Hostname, record.Principals)):
+		return false
+	case f.Principal != "" && !anyMatches(f.Principal, record.Principals):
+		return false
+	case !f.ExpiresAfter.IsZero() && record.ValidUntil.Before(f.ExpiresAfter):
+		return false
+	case !f.ExpiresBefore.IsZero() && !record.ValidUntil.Before(f.ExpiresBefore):
+		return false
+	case f.Visible != nil && !f.Visible(record):
+		return false
+	}
+	return true
+}
+
+// CertLister is the read only side of the Ledger, for finding out what was
+// signed. ListCerts returns a page of the certs that match and the serial
+// the next page starts after, 0 when it's the last page
+type CertLister interface {
+	ListCerts(filter *CertFilter) ([]*CertRecord, uint64, error)
+}
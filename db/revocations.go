@@ -0,0 +1,121 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+var revocationsBucket = []byte("revocations")
+
+type RevocationType string
+
+const (
+	// Value is the serial of the cert in decimal
+	RevokeSerial RevocationType = "serial"
+	// Value is the KeyId the cert was signed with
+	RevokeKeyId RevocationType = "key_id"
+	// Value is the SHA256 fingerprint of the key, as printed by ssh-keygen -l
+	RevokeFingerprint RevocationType = "fingerprint"
+	// Value is the public key of the CA in authorized_keys format
+	// everything signed by the CA is revoked with it
+	RevokeCA RevocationType = "ca"
+)
+
+type Revocation struct {
+	Type  RevocationType `json:"type"`
+	Value string         `json:"value"`
+	// only for CA revocations, whether it was the user or the host CA
+	CAType    string    `json:"ca_type,omitempty"`
+	Reason    string    `json:"reason"`
+	RevokedBy string    `json:"revoked_by"`
+	RevokedAt time.Time `json:"revoked_at"`
+}
+
+func (r *Revocation) valid() error {
+	switch r.Type {
+	case RevokeSerial:
+		serial, err := strconv.ParseUint(r.Value, 10, 64)
+		if err != nil || serial == 0 {
+			return fmt.Errorf("Invalid serial %q", r.Value)
+		}
+	case RevokeKeyId, RevokeFingerprint:
+		if r.Value == "" {
+			return fmt.Errorf("Empty value for %s revocation", r.Type)
+		}
+	case RevokeCA:
+		if r.Value == "" {
+			return errors.New("Empty CA public key")
+		}
+		if r.CAType != UserCertType && r.CAType != HostCertType {
+			return fmt.Errorf("Unknown CA type %q", r.CAType)
+		}
+	default:
+		return fmt.Errorf("Unknown revocation type %q", r.Type)
+	}
+	return nil
+}
+
+func (r *Revocation) key() []byte {
+	return []byte(string(r.Type) + "/" + r.Value)
+}
+
+// RevocationStore keeps everything that shouldn't be trusted anymore
+// the version increases every time something is revoked so the hosts
+// can tell if the KRL they have is stale
+type RevocationStore interface {
+	Revoke(revocation *Revocation) error
+	Revocations() ([]*Revocation, uint64, error)
+}
+
+func (s *BoltStore) Revoke(revocation *Revocation) error {
+	if err := revocation.valid(); err != nil {
+		return errors.Wrapf(err, "Invalid revocation")
+	}
+	if revocation.RevokedAt.IsZero() {
+		revocation.RevokedAt = time.Now()
+	}
+	value, err := json.Marshal(revocation)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to marshal the revocation")
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(revocationsBucket)
+		// revoking the same thing twice is harmless, keep the original
+		if b.Get(revocation.key()) != nil {
+			return nil
+		}
+		if _, err := b.NextSequence(); err != nil {
+			return err
+		}
+		return b.Put(revocation.key(), value)
+	})
+}
+
+// Revocations returns everything revoked so far along with the version
+func (s *BoltStore) Revocations() ([]*Revocation, uint64, error) {
+	var (
+		revocations []*Revocation
+		version     uint64
+	)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(revocationsBucket)
+		version = b.Sequence()
+		return b.ForEach(func(k, v []byte) error {
+			revocation := &Revocation{}
+			if err := json.Unmarshal(v, revocation); err != nil {
+				return errors.Wrapf(err, "Failed to unmarshal revocation %s", k)
+			}
+			revocations = append(revocations, revocation)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return revocations, version, nil
+}
@@ -0,0 +1,169 @@
+package db
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+var disabledUsersBucket = []byte("disabled_users")
+
+var ErrUserNotDisabled = errors.New("The user isn't disabled")
+
+// DisabledUser can't authenticate or get certs until it's enabled again,
+// whatever the authz grants it
+type DisabledUser struct {
+	Email      string    `json:"email"`
+	Reason     string    `json:"reason"`
+	DisabledBy string    `json:"disabled_by"`
+	DisabledAt time.Time `json:"disabled_at"`
+}
+
+type UserStore interface {
+	DisableUser(user *DisabledUser) error
+	EnableUser(email string) error
+	// nil when the user isn't disabled
+	DisabledUser(email string) (*DisabledUser, error)
+	DisabledUsers() ([]*DisabledUser, error)
+}
+
+// the emails are compared without the case, like the Google accounts
+func userKey(email string) []byte {
+	return []byte(strings.ToLower(email))
+}
+
+// DisableUser keeps the original when the user is already disabled
+func (s *BoltStore) DisableUser(user *DisabledUser) error {
+	if user.Email == "" {
+		return errors.New("Cannot disable a user without an email")
+	}
+	if user.DisabledAt.IsZero() {
+		user.DisabledAt = time.Now()
+	}
+	value, err := json.Marshal(user)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to marshal the disabled user")
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(disabledUsersBucket)
+		if b.Get(userKey(user.Email)) != nil {
+			return nil
+		}
+		return b.Put(userKey(user.Email), value)
+	})
+}
+
+func (s *BoltStore) EnableUser(email string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(disabledUsersBucket)
+		if b.Get(userKey(email)) == nil {
+			return ErrUserNotDisabled
+		}
+		return b.Delete(userKey(email))
+	})
+}
+
+func (s *BoltStore) DisabledUser(email string) (*DisabledUser, error) {
+	var user *DisabledUser
+	err := s.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(disabledUsersBucket).Get(userKey(email))
+		if value == nil {
+			return nil
+		}
+		user = &DisabledUser{}
+		return json.Unmarshal(value, user)
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to look up whether %s is disabled", email)
+	}
+	return user, nil
+}
+
+func (s *BoltStore) DisabledUsers() ([]*DisabledUser, error) {
+	users := []*DisabledUser{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(disabledUsersBucket).ForEach(func(k, v []byte) error {
+			user := &DisabledUser{}
+			if err := json.Unmarshal(v, user); err != nil {
+				return errors.Wrapf(err, "Failed to unmarshal disabled user %s", k)
+			}
+			users = append(users, user)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// UserSummary is what the ledger knows about a user, along with whether
+// they're disabled
+type UserSummary struct {
+	Email          string        `json:"email"`
+	Certs          int           `json:"certs"`
+	LastIssuedAt   time.Time     `json:"last_issued_at"`
+	LastValidUntil time.Time     `json:"last_valid_until"`
+	Disabled       *DisabledUser `json:"disabled,omitempty"`
+}
+
+// Users are everyone who got a user cert or was disabled, by email
+func (s *BoltStore) Users() ([]*UserSummary, error) {
+	users := map[string]*UserSummary{}
+	summary := func(email string) *UserSummary {
+		key := string(userKey(email))
+		if _, ok := users[key]; !ok {
+			users[key] = &UserSummary{Email: email}
+		}
+		return users[key]
+	}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		err := tx.Bucket(certsBucket).ForEach(func(k, v []byte) error {
+			record := &CertRecord{}
+			if err := json.Unmarshal(v, record); err != nil {
+				return errors.Wrapf(err, "Failed to unmarshal the record for serial %d", binary.BigEndian.Uint64(k))
+			}
+			if record.CertType != UserCertType || record.Email == "" {
+				return nil
+			}
+			user := summary(record.Email)
+			user.Certs++
+			if record.IssuedAt.After(user.LastIssuedAt) {
+				user.LastIssuedAt = record.IssuedAt
+			}
+			if record.ValidUntil.After(user.LastValidUntil) {
+				user.LastValidUntil = record.ValidUntil
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(disabledUsersBucket).ForEach(func(k, v []byte) error {
+			disabled := &DisabledUser{}
+			if err := json.Unmarshal(v, disabled); err != nil {
+				return errors.Wrapf(err, "Failed to unmarshal disabled user %s", k)
+			}
+			summary(disabled.Email).Disabled = disabled
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	keys := []string{}
+	for key := range users {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	summaries := make([]*UserSummary, 0, len(keys))
+	for _, key := range keys {
+		summaries = append(summaries, users[key])
+	}
+	return summaries, nil
+}
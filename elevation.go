@@ -0,0 +1,451 @@
+package accord
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	ElevationPending  = "pending"
+	ElevationApproved = "approved"
+	ElevationDenied   = "denied"
+	ElevationExpired  = "expired"
+
+	// how long the denied and expired elevations are kept so the users can
+	// see what happened to them
+	elevationRetention = 24 * time.Hour
+)
+
+// ElevationRule makes the principals only available just in time, a user
+// asks for them with a justification and one of the approvers has to
+// approve it. The principals and the users can be patterns
+type ElevationRule struct {
+	Principals []string `json:"principals" yaml:"principals"`
+	// who can ask for them, anyone if empty
+	Users     []string `json:"users" yaml:"users"`
+	Approvers []string `json:"approvers" yaml:"approvers"`
+	// the longest an approved elevation lasts
+	MaxDuration time.Duration `json:"max_duration" yaml:"max_duration"`
+}
+
+// ElevationPolicy has the principals that need an elevation. The authz
+// doesn't grant them on its own, not even to the admins
+type ElevationPolicy struct {
+	Rules []ElevationRule `json:"rules" yaml:"rules"`
+}
+
+func NewElevationPolicyFromFile(filePath string) (*ElevationPolicy, error) {
+	content, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Cannot read file %s", filePath)
+	}
+	return NewElevationPolicyFromBuffer(content)
+}
+
+func NewElevationPolicyFromBuffer(content []byte) (*ElevationPolicy, error) {
+	p := &ElevationPolicy{}
+	if err := decodePolicy(content, p, "elevation policy"); err != nil {
+		return nil, err
+	}
+	for i, rule := range p.Rules {
+		if len(rule.Principals) == 0 || len(rule.Approvers) == 0 {
+			return nil, fmt.Errorf("Elevation rule %d needs both principals and approvers", i+1)
+		}
+		if rule.MaxDuration < time.Minute {
+			return nil, fmt.Errorf("Elevation rule %d needs a max duration of at least a minute, with a unit like 1h", i+1)
+		}
+		patterns := append(append(append([]string{}, rule.Principals...), rule.Users...), rule.Approvers...)
+		if err := validPatterns(patterns); err != nil {
+			return nil, errors.Wrapf(err, "Invalid elevation rule %d", i+1)
+		}
+	}
+	return p, nil
+}
+
+// rule is the first rule for the principal, nil if it doesn't need an elevation
+func (p *ElevationPolicy) rule(principal string) *ElevationRule {
+	for i := range p.Rules {
+		if matchesAny(principal, p.Rules[i].Principals) {
+			return &p.Rules[i]
+		}
+	}
+	return nil
+}
+
+// Elevation is a request for principals that need an approval, and what
+// became of it
+type Elevation struct {
+	Id            string
+	User          string
+	Principals    []string
+	Justification string
+	Duration      time.Duration
+	State         string
+	RequestedAt   time.Time
+	// who approved or denied it and why
+	DecidedBy string
+	Reason    string
+	DecidedAt time.Time
+	// only set once it's approved
+	ExpiresAt time.Time
+}
+
+func (e *Elevation) auditDetails() map[string]string {
+	details := map[string]string{
+		"id":            e.Id,
+		"user":          e.User,
+		"principals":    strings.Join(e.Principals, ","),
+		"justification": e.Justification,
+		"duration":      e.Duration.String(),
+	}
+	if e.DecidedBy != "" {
+		details["decided_by"] = e.DecidedBy
+		details["reason"] = e.Reason
+	}
+	if !e.ExpiresAt.IsZero() {
+		details["expires_at"] = e.ExpiresAt.Format(time.RFC3339)
+	}
+	return details
+}
+
+// Elevations keeps the requested and approved elevations in memory, like the
+// sessions they're gone when the server restarts. Every change is audited
+type Elevations struct {
+	auditor Auditor
+	// how long a request waits for an approval
+	pendingTTL time.Duration
+	now        func() time.Time
+
+	mu         sync.Mutex
+	elevations map[string]*Elevation
+}
+
+func NewElevations(auditor Auditor, pendingTTL time.Duration) *Elevations {
+	return &Elevations{
+		auditor:    auditor,
+		pendingTTL: pendingTTL,
+		now:        time.Now,
+		elevations: map[string]*Elevation{},
+	}
+}
+
+// elevationEvent is a change to audit once the lock is released, a slow
+// auditor can't hold up every other elevation call
+type elevationEvent struct {
+	eventType string
+	actor     string
+	id        string
+	details   map[string]string
+}
+
+func newElevationEvent(eventType string, actor string, e *Elevation) elevationEvent {
+	return elevationEvent{
+		eventType: eventType,
+		actor:     actor,
+		id:        e.Id,
+		details:   e.auditDetails(),
+	}
+}
+
+// unlock releases the lock and then audits the changes made while holding it
+func (s *Elevations) unlock(events []elevationEvent) {
+	s.mu.Unlock()
+	for _, event := range events {
+		err := s.auditor.Audit(&AuditEvent{
+			Time:    s.now(),
+			Type:    event.eventType,
+			Actor:   event.actor,
+			Details: event.details,
+		})
+		if err != nil {
+			// the change already happened, it still has to show up somewhere
+			log.Printf("Failed to audit %s of elevation %s. %s", event.eventType, event.id, err)
+		}
+	}
+}
+
+// expire needs the lock held, it returns what has to be audited
+func (s *Elevations) expire(now time.Time) []elevationEvent {
+	events := []elevationEvent{}
+	for id, e := range s.elevations {
+		switch {
+		case e.State == ElevationPending && now.Sub(e.RequestedAt) > s.pendingTTL:
+			e.State = ElevationExpired
+			e.DecidedAt = now
+			events = append(events, newElevationEvent(AuditElevationExpired, "accord", e))
+		case e.State == ElevationApproved && !now.Before(e.ExpiresAt):
+			e.State = ElevationExpired
+			e.DecidedAt = now
+			events = append(events, newElevationEvent(AuditElevationExpired, "accord", e))
+		case (e.State == ElevationDenied || e.State == ElevationExpired) && now.Sub(e.DecidedAt) > elevationRetention:
+			delete(s.elevations, id)
+		}
+	}
+	return events
+}
+
+// Expire records the elevations that ran out, they're also expired on every
+// other call but this keeps the audit trail timely when nothing happens
+func (s *Elevations) Expire() {
+	s.mu.Lock()
+	s.unlock(s.expire(s.now()))
+}
+
+func (s *Elevations) request(e *Elevation) *Elevation {
+	s.mu.Lock()
+	now := s.now()
+	events := s.expire(now)
+	defer func() { s.unlock(events) }()
+	e.Id = string(RandAsciiBytes(12))
+	e.State = ElevationPending
+	e.RequestedAt = now
+	s.elevations[e.Id] = e
+	events = append(events, newElevationEvent(AuditElevationRequested, e.User, e))
+	copied := *e
+	return &copied
+}
+
+// decide approves or denies a pending elevation, check says whether the
+// approver can decide on it
+func (s *Elevations) decide(id string, approver string, approve bool, reason string, check func(e *Elevation) error) (*Elevation, error) {
+	s.mu.Lock()
+	now := s.now()
+	events := s.expire(now)
+	defer func() { s.unlock(events) }()
+	e, ok := s.elevations[id]
+	if !ok {
+		return nil, fmt.Errorf("Elevation %s doesn't exist", id)
+	}
+	if e.State != ElevationPending {
+		return nil, fmt.Errorf("Elevation %s is already %s", id, e.State)
+	}
+	if err := check(e); err != nil {
+		return nil, err
+	}
+	e.DecidedBy = approver
+	e.Reason = reason
+	e.DecidedAt = now
+	if approve {
+		e.State = ElevationApproved
+		e.ExpiresAt = now.Add(e.Duration)
+		events = append(events, newElevationEvent(AuditElevationApproved, approver, e))
+	} else {
+		e.State = ElevationDenied
+		events = append(events, newElevationEvent(AuditElevationDenied, approver, e))
+	}
+	copied := *e
+	return &copied, nil
+}
+
+// granted is when the latest approved elevation of the principal for the
+// user expires
+func (s *Elevations) granted(user string, principal string) (time.Time, bool) {
+	s.mu.Lock()
+	events := s.expire(s.now())
+	defer func() { s.unlock(events) }()
+	var expiresAt time.Time
+	for _, e := range s.elevations {
+		if e.State == ElevationApproved && e.User == user && contains(principal, e.Principals) && e.ExpiresAt.After(expiresAt) {
+			expiresAt = e.ExpiresAt
+		}
+	}
+	return expiresAt, !expiresAt.IsZero()
+}
+
+func (s *Elevations) list(include func(e *Elevation) bool) []*Elevation {
+	s.mu.Lock()
+	events := s.expire(s.now())
+	defer func() { s.unlock(events) }()
+	elevations := []*Elevation{}
+	for _, e := range s.elevations {
+		if include(e) {
+			copied := *e
+			elevations = append(elevations, &copied)
+		}
+	}
+	sort.Slice(elevations, func(i, j int) bool {
+		return elevations[i].RequestedAt.Before(elevations[j].RequestedAt)
+	})
+	return elevations
+}
+
+// ElevatedAuthz puts the elevation policy in front of another Authz, the
+// principals in the policy are only granted while an approved elevation
+// for them lasts, and the certs with them don't outlive it
+type ElevatedAuthz struct {
+	authz      Authz
+	policy     *ElevationPolicy
+	elevations *Elevations
+}
+
+func NewElevatedAuthz(authz Authz, policy *ElevationPolicy, elevations *Elevations) *ElevatedAuthz {
+	return &ElevatedAuthz{
+		authz:      authz,
+		policy:     policy,
+		elevations: elevations,
+	}
+}
+
+// Authz is what grants the principals that don't need an elevation
+func (e *ElevatedAuthz) Authz() Authz {
+	return e.authz
+}
+
+func (e *ElevatedAuthz) Policy() *ElevationPolicy {
+	return e.policy
+}
+
+// IsAdmin doesn't need an elevation, being an admin doesn't grant the
+// sensitive principals either
+func (e *ElevatedAuthz) IsAdmin(user string) bool {
+	return IsAdmin(e.authz, user)
+}
+
+func (e *ElevatedAuthz) HealthCheck() error {
+	return HealthCheck(e.authz)
+}
+
+func (e *ElevatedAuthz) Authorized(user string, principals []string) ([]string, error) {
+	ordinary := []string{}
+	elevated := []string{}
+	for _, p := range principals {
+		if e.policy.rule(p) == nil {
+			ordinary = append(ordinary, p)
+			continue
+		}
+		if _, ok := e.elevations.granted(user, p); ok {
+			elevated = append(elevated, p)
+		}
+	}
+	granted := []string{}
+	if len(ordinary) > 0 {
+		g, err := e.authz.Authorized(user, ordinary)
+		// someone only allowed in with elevations still gets those
+		if err != nil && len(elevated) == 0 {
+			return nil, err
+		}
+		granted = append(granted, g...)
+	}
+	// in the order they were asked for
+	grantedPrincipals := []string{}
+	for _, p := range principals {
+		if contains(p, granted) || contains(p, elevated) {
+			grantedPrincipals = append(grantedPrincipals, p)
+		}
+	}
+	return grantedPrincipals, nil
+}
+
+func (e *ElevatedAuthz) DenialReason(user string, principal string) string {
+	if e.policy.rule(principal) != nil {
+		return "needs an approved elevation, ask for one with -task=elevate"
+	}
+	if reasoner, ok := e.authz.(DenialReasoner); ok {
+		return reasoner.DenialReason(user, principal)
+	}
+	return "not granted"
+}
+
+// Permissions limits the validity of the certs with elevated principals to
+// what's left of the elevation
+func (e *ElevatedAuthz) Permissions(user string, principals []string) (CertPermissions, error) {
+	permissions, err := e.authz.Permissions(user, principals)
+	if err != nil {
+		return permissions, err
+	}
+	now := e.elevations.now()
+	for _, p := range principals {
+		if e.policy.rule(p) == nil {
+			continue
+		}
+		expiresAt, ok := e.elevations.granted(user, p)
+		if !ok {
+			return permissions, fmt.Errorf("The elevation for principal %s has expired", p)
+		}
+		permissions, err = permissions.Intersect(CertPermissions{MaxValidity: expiresAt.Sub(now)})
+		if err != nil {
+			return permissions, err
+		}
+	}
+	return permissions, nil
+}
+
+// Request asks for the principals, for the max duration of their rules if
+// the duration is 0
+func (e *ElevatedAuthz) Request(user string, principals []string, justification string, duration time.Duration) (*Elevation, error) {
+	if len(principals) == 0 {
+		return nil, ErrNoPrincipals
+	}
+	if strings.TrimSpace(justification) == "" {
+		return nil, errors.New("An elevation needs a justification")
+	}
+	maxDuration := time.Duration(0)
+	for _, p := range principals {
+		if isPattern(p) {
+			return nil, fmt.Errorf("Principal %s can't be a pattern", p)
+		}
+		rule := e.policy.rule(p)
+		if rule == nil {
+			return nil, fmt.Errorf("Principal %s doesn't need an elevation", p)
+		}
+		if len(rule.Users) > 0 && !matchesAny(user, rule.Users) {
+			return nil, fmt.Errorf("%s can't ask for principal %s", user, p)
+		}
+		if maxDuration == 0 || rule.MaxDuration < maxDuration {
+			maxDuration = rule.MaxDuration
+		}
+	}
+	if duration == 0 {
+		duration = maxDuration
+	}
+	if duration < 0 || duration > maxDuration {
+		return nil, fmt.Errorf("The elevation can last at most %s, asked for %s", maxDuration, duration)
+	}
+	return e.elevations.request(&Elevation{
+		User:          user,
+		Principals:    append([]string{}, principals...),
+		Justification: justification,
+		Duration:      duration,
+	}), nil
+}
+
+// canDecide is whether the approver is an approver for every principal of
+// the elevation, no one can approve their own
+func (e *ElevatedAuthz) canDecide(approver string, elevation *Elevation) error {
+	if approver == elevation.User {
+		return errors.New("An elevation has to be decided by someone else")
+	}
+	for _, p := range elevation.Principals {
+		rule := e.policy.rule(p)
+		if rule == nil {
+			return fmt.Errorf("Principal %s doesn't need an elevation anymore", p)
+		}
+		if !matchesAny(approver, rule.Approvers) {
+			return fmt.Errorf("%s can't approve principal %s", approver, p)
+		}
+	}
+	return nil
+}
+
+func (e *ElevatedAuthz) Decide(approver string, id string, approve bool, reason string) (*Elevation, error) {
+	return e.elevations.decide(id, approver, approve, reason, func(elevation *Elevation) error {
+		return e.canDecide(approver, elevation)
+	})
+}
+
+// List has the elevations of the user, and the pending ones they can decide on
+func (e *ElevatedAuthz) List(user string) []*Elevation {
+	return e.elevations.list(func(elevation *Elevation) bool {
+		if elevation.User == user {
+			return true
+		}
+		return elevation.State == ElevationPending && e.canDecide(user, elevation) == nil
+	})
+}
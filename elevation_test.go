@@ -0,0 +1,227 @@
+package accord
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type recordingAuditor struct {
+	events []*AuditEvent
+}
+
+func (r *recordingAuditor) Audit(event *AuditEvent) error {
+	r.events = append(r.events, event)
+	return nil
+}
+
+func (r *recordingAuditor) types() []string {
+	types := []string{}
+	for _, event := range r.events {
+		types = append(types, event.Type)
+	}
+	return types
+}
+
+func TestNewElevationPolicyFromBuffer(t *testing.T) {
+	tests := []struct {
+		name    string
+		buf     string
+		wantErr bool
+	}{
+		{
+			name: "valid policy",
+			buf: `
+rules:
+  - principals: [root-*]
+    users: ["*@ex.ample.com"]
+    approvers: [security@ex.ample.com]
+    max_duration: 1h
+`,
+		},
+		{
+			name:    "no approvers",
+			buf:     `rules: [{principals: [root-everywhere], max_duration: 1h}]`,
+			wantErr: true,
+		},
+		{
+			name:    "no max duration",
+			buf:     `rules: [{principals: [root-everywhere], approvers: [security@ex.ample.com]}]`,
+			wantErr: true,
+		},
+		{
+			name:    "unknown keys",
+			buf:     `rules: [{principals: [root-everywhere], approver: [security@ex.ample.com], max_duration: 1h}]`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewElevationPolicyFromBuffer([]byte(tt.buf))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewElevationPolicyFromBuffer() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestElevatedAuthz(t *testing.T) {
+	simple := &SimpleAuth{
+		Principals: []string{"root-everywhere", "zones-db"},
+		AdminUsers: []string{"admin@ex.ample.com"},
+		AccessMap: map[string][]string{
+			"user1@ex.ample.com": []string{"zones-db"},
+		},
+	}
+	policy := &ElevationPolicy{Rules: []ElevationRule{{
+		Principals:  []string{"root-*"},
+		Approvers:   []string{"admin@ex.ample.com", "security@ex.ample.com"},
+		MaxDuration: time.Hour,
+	}}}
+	auditor := &recordingAuditor{}
+	now := time.Date(2017, 10, 1, 12, 0, 0, 0, time.UTC)
+	elevations := NewElevations(auditor, 10*time.Minute)
+	elevations.now = func() time.Time { return now }
+	authz := NewElevatedAuthz(simple, policy, elevations)
+
+	// not even the admins get it without an elevation
+	granted, err := authz.Authorized("admin@ex.ample.com", []string{"root-everywhere", "zones-db"})
+	if err != nil || !reflect.DeepEqual(granted, []string{"zones-db"}) {
+		t.Fatalf("Authorized() = %v, %v before the elevation", granted, err)
+	}
+
+	if _, err := authz.Request("user1@ex.ample.com", []string{"zones-db"}, "fixing the db", 0); err == nil {
+		t.Errorf("Request() for a principal without a rule should fail")
+	}
+	if _, err := authz.Request("user1@ex.ample.com", []string{"root-everywhere"}, "", 0); err == nil {
+		t.Errorf("Request() without a justification should fail")
+	}
+	if _, err := authz.Request("user1@ex.ample.com", []string{"root-everywhere"}, "fixing the db", 2*time.Hour); err == nil {
+		t.Errorf("Request() for longer than the max duration should fail")
+	}
+	elevation, err := authz.Request("user1@ex.ample.com", []string{"root-everywhere"}, "fixing the db", 30*time.Minute)
+	if err != nil {
+		t.Fatalf("Request() error = %v", err)
+	}
+	if elevation.State != ElevationPending {
+		t.Errorf("Request() state = %s, want %s", elevation.State, ElevationPending)
+	}
+	if granted, _ := authz.Authorized("user1@ex.ample.com", []string{"root-everywhere"}); len(granted) != 0 {
+		t.Errorf("Authorized() = %v while the elevation is pending", granted)
+	}
+
+	if _, err := authz.Decide("user1@ex.ample.com", elevation.Id, true, ""); err == nil {
+		t.Errorf("Decide() by the requester should fail")
+	}
+	if _, err := authz.Decide("user2@ex.ample.com", elevation.Id, true, ""); err == nil {
+		t.Errorf("Decide() by someone that isn't an approver should fail")
+	}
+	if pending := authz.List("security@ex.ample.com"); len(pending) != 1 {
+		t.Errorf("List() for an approver = %v, want the pending elevation", pending)
+	}
+	approved, err := authz.Decide("security@ex.ample.com", elevation.Id, true, "on call")
+	if err != nil {
+		t.Fatalf("Decide() error = %v", err)
+	}
+	if approved.State != ElevationApproved || !approved.ExpiresAt.Equal(now.Add(30*time.Minute)) {
+		t.Errorf("Decide() = %s until %s", approved.State, approved.ExpiresAt)
+	}
+	if _, err := authz.Decide("admin@ex.ample.com", elevation.Id, false, ""); err == nil {
+		t.Errorf("Decide() on an approved elevation should fail")
+	}
+
+	now = now.Add(10 * time.Minute)
+	granted, err = authz.Authorized("user1@ex.ample.com", []string{"root-everywhere", "zones-db"})
+	if err != nil || !reflect.DeepEqual(granted, []string{"root-everywhere", "zones-db"}) {
+		t.Errorf("Authorized() = %v, %v with the elevation", granted, err)
+	}
+	permissions, err := authz.Permissions("user1@ex.ample.com", granted)
+	if err != nil || permissions.MaxValidity != 20*time.Minute {
+		t.Errorf("Permissions() max validity = %s, %v, want what's left of the elevation", permissions.MaxValidity, err)
+	}
+
+	now = now.Add(20 * time.Minute)
+	if granted, _ := authz.Authorized("user1@ex.ample.com", []string{"root-everywhere"}); len(granted) != 0 {
+		t.Errorf("Authorized() = %v after the elevation expired", granted)
+	}
+	if reason := authz.DenialReason("user1@ex.ample.com", "root-everywhere"); reason != "needs an approved elevation, ask for one with -task=elevate" {
+		t.Errorf("DenialReason() = %s", reason)
+	}
+
+	want := []string{"elevation_requested", "elevation_approved", "elevation_expired"}
+	if !reflect.DeepEqual(auditor.types(), want) {
+		t.Errorf("audit events = %v, want %v", auditor.types(), want)
+	}
+}
+
+func TestElevations_pendingExpire(t *testing.T) {
+	auditor := &recordingAuditor{}
+	now := time.Date(2017, 10, 1, 12, 0, 0, 0, time.UTC)
+	elevations := NewElevations(auditor, 10*time.Minute)
+	elevations.now = func() time.Time { return now }
+	policy := &ElevationPolicy{Rules: []ElevationRule{{
+		Principals:  []string{"root-everywhere"},
+		Approvers:   []string{"security@ex.ample.com"},
+		MaxDuration: time.Hour,
+	}}}
+	authz := NewElevatedAuthz(GrantAll{}, policy, elevations)
+
+	elevation, err := authz.Request("user1@ex.ample.com", []string{"root-everywhere"}, "fixing the db", 0)
+	if err != nil {
+		t.Fatalf("Request() error = %v", err)
+	}
+	if elevation.Duration != time.Hour {
+		t.Errorf("Request() duration = %s, want the max duration", elevation.Duration)
+	}
+	now = now.Add(11 * time.Minute)
+	elevations.Expire()
+	if _, err := authz.Decide("security@ex.ample.com", elevation.Id, true, ""); err == nil {
+		t.Errorf("Decide() on an expired request should fail")
+	}
+	now = now.Add(25 * time.Hour)
+	if listed := authz.List("user1@ex.ample.com"); len(listed) != 0 {
+		t.Errorf("List() = %v, the expired elevation should be gone after a day", listed)
+	}
+	want := []string{"elevation_requested", "elevation_expired"}
+	if !reflect.DeepEqual(auditor.types(), want) {
+		t.Errorf("audit events = %v, want %v", auditor.types(), want)
+	}
+}
+
+// reentrantAuditor looks at the elevations while it's auditing, like a slow
+// sink would be waiting on them
+type reentrantAuditor struct {
+	elevations *Elevations
+	seen       []int
+}
+
+func (r *reentrantAuditor) Audit(event *AuditEvent) error {
+	r.seen = append(r.seen, len(r.elevations.list(func(e *Elevation) bool { return true })))
+	return nil
+}
+
+func TestElevations_auditUnlocked(t *testing.T) {
+	auditor := &reentrantAuditor{}
+	now := time.Date(2017, 10, 1, 12, 0, 0, 0, time.UTC)
+	elevations := NewElevations(auditor, 10*time.Minute)
+	elevations.now = func() time.Time { return now }
+	auditor.elevations = elevations
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		e := elevations.request(&Elevation{User: "user1@ex.ample.com", Principals: []string{"root-everywhere"}, Duration: time.Hour})
+		now = now.Add(time.Hour)
+		elevations.Expire()
+		elevations.decide(e.Id, "admin@ex.ample.com", true, "", func(e *Elevation) error { return nil })
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("The elevations are audited while the lock is held")
+	}
+	// requested, then expired, deciding on an expired one fails
+	if !reflect.DeepEqual(auditor.seen, []int{1, 1}) {
+		t.Errorf("The auditor saw %v elevations, want [1 1]", auditor.seen)
+	}
+}
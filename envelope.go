@@ -0,0 +1,206 @@
+package accord
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+	"log"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+)
+
+// EnvelopeVersion is the first byte of the envelopes from Envelope.Seal, the
+// ones from AESGCM start with the key id instead
+const EnvelopeVersion byte = 2
+
+// the AEADs an envelope can be sealed with
+const (
+	AEADAESGCM           byte = 1
+	AEADChaCha20Poly1305 byte = 2
+)
+
+// Direction is who sealed the envelope, each direction has its own key so a
+// message can't be reflected back to whoever sent it
+type Direction byte
+
+const (
+	HostToServer Direction = 1
+	ServerToHost Direction = 2
+)
+
+// version, aead, direction, key id, psk version, timestamp and the nonce
+const envelopeHeaderSize = 1 + 1 + 1 + 4 + 4 + TimestampSize + NonceSize
+
+var ErrEnvelopeVersion = errors.New("Not a versioned envelope")
+
+// ParseAEAD takes the name of the AEAD as it's given in the flags
+func ParseAEAD(name string) (byte, error) {
+	switch name {
+	case "aes-gcm", "":
+		return AEADAESGCM, nil
+	case "chacha20-poly1305":
+		return AEADChaCha20Poly1305, nil
+	}
+	return 0, errors.Errorf("Unknown AEAD %s, use aes-gcm or chacha20-poly1305", name)
+}
+
+// EnvelopeHeader is the part of the envelope that's sent in the clear, all
+// of it is authenticated
+type EnvelopeHeader struct {
+	AEAD      byte
+	Direction Direction
+	// Unversioned means the header has version 0, the PSK is from before
+	// the versions. Version is then the one that worked
+	PSK       PSKRef
+	Timestamp time.Time
+	Nonce     []byte
+}
+
+func (h *EnvelopeHeader) marshal() []byte {
+	buf := make([]byte, envelopeHeaderSize)
+	buf[0] = EnvelopeVersion
+	buf[1] = h.AEAD
+	buf[2] = byte(h.Direction)
+	binary.BigEndian.PutUint32(buf[3:], h.PSK.KeyId)
+	if !h.PSK.Unversioned {
+		binary.BigEndian.PutUint32(buf[7:], h.PSK.Version)
+	}
+	binary.BigEndian.PutUint64(buf[11:], uint64(h.Timestamp.UnixNano()))
+	copy(buf[11+TimestampSize:], h.Nonce)
+	return buf
+}
+
+func parseEnvelopeHeader(message []byte) (*EnvelopeHeader, error) {
+	if len(message) < envelopeHeaderSize || message[0] != EnvelopeVersion {
+		return nil, ErrEnvelopeVersion
+	}
+	h := &EnvelopeHeader{
+		AEAD:      message[1],
+		Direction: Direction(message[2]),
+		PSK: PSKRef{
+			KeyId:   binary.BigEndian.Uint32(message[3:]),
+			Version: binary.BigEndian.Uint32(message[7:]),
+		},
+		Timestamp: time.Unix(0, int64(binary.BigEndian.Uint64(message[11:]))),
+		Nonce:     append([]byte{}, message[11+TimestampSize:envelopeHeaderSize]...),
+	}
+	h.PSK.Unversioned = h.PSK.Version == 0
+	return h, nil
+}
+
+// Envelope seals the HostAuth messages. Unlike AESGCM the PSK isn't used
+// directly, each direction and AEAD gets its own key derived with HKDF and
+// every message has a fresh nonce
+type Envelope struct {
+	store PSKStore
+	aead  byte
+}
+
+// NewEnvelope seals with the aead, Open takes whatever the envelope says
+func NewEnvelope(store PSKStore, aead byte) *Envelope {
+	return &Envelope{
+		store: store,
+		aead:  aead,
+	}
+}
+
+func (e *Envelope) psk(ref PSKRef) ([]byte, error) {
+	return (&AESGCM{store: e.store}).psk(ref)
+}
+
+func deriveAEAD(psk []byte, aead byte, direction Direction) (cipher.AEAD, error) {
+	info := []byte("accord hostauth v2")
+	info = append(info, aead, byte(direction))
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, psk, nil, info), key); err != nil {
+		return nil, err
+	}
+	switch aead {
+	case AEADAESGCM:
+		c, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		return cipher.NewGCM(c)
+	case AEADChaCha20Poly1305:
+		return chacha20poly1305.New(key)
+	}
+	return nil, errors.Errorf("Unknown AEAD %d", aead)
+}
+
+// Seal encrypts the message in a new envelope and returns it with its header.
+// The context is authenticated but not sent, the reply to a message passes
+// the nonce of the message so that it can't be used as the reply to any other
+func (e *Envelope) Seal(message []byte, ref PSKRef, direction Direction, context []byte) ([]byte, *EnvelopeHeader, error) {
+	if !ref.Unversioned && ref.Version == 0 {
+		return nil, nil, errors.New("Versioned envelopes need the PSK version")
+	}
+	nonce, err := GenerateNonce(NonceSize)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "Failed to generate Nonce")
+	}
+	h := &EnvelopeHeader{
+		AEAD:      e.aead,
+		Direction: direction,
+		PSK:       ref,
+		Timestamp: time.Now(),
+		Nonce:     nonce,
+	}
+	psk, err := e.psk(ref)
+	if err != nil {
+		log.Printf("Err: failed to find the PSK for id: %d version: %d. %s", ref.KeyId, ref.Version, err)
+		return nil, nil, ErrKeyNotFound
+	}
+	aead, err := deriveAEAD(psk, h.AEAD, direction)
+	if err != nil {
+		log.Printf("Err: %s", err)
+		return nil, nil, ErrEncrypt
+	}
+	header := h.marshal()
+	return aead.Seal(header, nonce, message, append(header, context...)), h, nil
+}
+
+// Open decrypts an envelope sealed in the given direction and returns the
+// plaintext and the header. Envelopes with version 0 in the header are tried
+// with every version the store still accepts
+func (e *Envelope) Open(message []byte, direction Direction, context []byte) ([]byte, *EnvelopeHeader, error) {
+	h, err := parseEnvelopeHeader(message)
+	if err != nil {
+		return nil, nil, err
+	}
+	if h.Direction != direction {
+		return nil, h, ErrDecrypt
+	}
+	header := message[:envelopeHeaderSize]
+	versions := []uint32{h.PSK.Version}
+	if h.PSK.Unversioned {
+		versions = []uint32{0}
+		if store, ok := e.store.(VersionedPSKStore); ok {
+			versions = store.AcceptedVersions(h.PSK.KeyId)
+		}
+	}
+	for _, version := range versions {
+		ref := PSKRef{KeyId: h.PSK.KeyId, Version: version, Unversioned: h.PSK.Unversioned}
+		psk, err := e.psk(ref)
+		if err != nil {
+			continue
+		}
+		aead, err := deriveAEAD(psk, h.AEAD, direction)
+		if err != nil {
+			return nil, h, ErrDecrypt
+		}
+		out, err := aead.Open(nil, h.Nonce, message[envelopeHeaderSize:], append(append([]byte{}, header...), context...))
+		if err != nil {
+			continue
+		}
+		h.PSK = ref
+		return out, h, nil
+	}
+	log.Printf("Err: failed to open the envelope from id: %d", h.PSK.KeyId)
+	return nil, h, ErrDecrypt
+}
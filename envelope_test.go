@@ -0,0 +1,107 @@
+package accord
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mistsys/accord/db"
+)
+
+func TestEnvelopeRoundTrip(t *testing.T) {
+	psks := db.VersionedPSKs{}
+	psks.Add(1, []byte(`JpUtbRukLuIFyjeKpA4fIpjgs6MTV8eH`), time.Now())
+	psks.Rotate(1, []byte(`qvFyLbJdNF6Cx0hpKzT0w3nqsTEbgvSe`), time.Now())
+	store := db.NewVersionedPSKStore(psks)
+
+	tests := []struct {
+		name string
+		aead byte
+		ref  PSKRef
+		want PSKRef
+	}{
+		{
+			name: "aes-gcm",
+			aead: AEADAESGCM,
+			ref:  PSKRef{KeyId: 1, Version: 2},
+			want: PSKRef{KeyId: 1, Version: 2},
+		},
+		{
+			name: "chacha20-poly1305",
+			aead: AEADChaCha20Poly1305,
+			ref:  PSKRef{KeyId: 1, Version: 1},
+			want: PSKRef{KeyId: 1, Version: 1},
+		},
+		{
+			name: "unversioned gets the active version",
+			aead: AEADAESGCM,
+			ref:  PSKRef{KeyId: 1, Unversioned: true},
+			want: PSKRef{KeyId: 1, Version: 2, Unversioned: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sealed, sent, err := NewEnvelope(store, tt.aead).Seal([]byte("Host Login"), tt.ref, HostToServer, nil)
+			if err != nil {
+				t.Fatalf("Envelope.Seal() error = %v", err)
+			}
+			// the server opens whatever AEAD the host picked
+			opened, header, err := NewEnvelope(store, 0).Open(sealed, HostToServer, nil)
+			if err != nil {
+				t.Fatalf("Envelope.Open() error = %v", err)
+			}
+			if string(opened) != "Host Login" || header.PSK != tt.want || header.AEAD != tt.aead {
+				t.Errorf("Envelope.Open() = %q, %+v", opened, header)
+			}
+			if !header.Timestamp.Equal(sent.Timestamp) || string(header.Nonce) != string(sent.Nonce) {
+				t.Errorf("Envelope.Open() header = %+v, sent %+v", header, sent)
+			}
+		})
+	}
+}
+
+func TestEnvelopeRejects(t *testing.T) {
+	store := db.NewLocalPSKStore(map[uint32][]byte{1: []byte(`JpUtbRukLuIFyjeKpA4fIpjgs6MTV8eH`)})
+	envelope := NewEnvelope(store, AEADAESGCM)
+	ref := PSKRef{KeyId: 1, Version: 1}
+	request, sent, err := envelope.Seal([]byte("Host Login"), ref, HostToServer, nil)
+	if err != nil {
+		t.Fatalf("Envelope.Seal() error = %v", err)
+	}
+	response, _, err := envelope.Seal([]byte("uuid"), ref, ServerToHost, sent.Nonce)
+	if err != nil {
+		t.Fatalf("Envelope.Seal() error = %v", err)
+	}
+	if _, _, err := envelope.Open(response, ServerToHost, sent.Nonce); err != nil {
+		t.Fatalf("Envelope.Open() of the response error = %v", err)
+	}
+
+	tamper := func(message []byte, i int) []byte {
+		out := append([]byte{}, message...)
+		out[i] ^= 0x01
+		return out
+	}
+	otherNonce, _ := GenerateNonce(NonceSize)
+	legacy, _ := InitAESGCM(store).Encrypt([]byte("Host Login"), 1)
+
+	tests := []struct {
+		name      string
+		message   []byte
+		direction Direction
+		context   []byte
+	}{
+		{"reflected back to the host", request, ServerToHost, nil},
+		{"changed aead", tamper(request, 1), HostToServer, nil},
+		{"changed timestamp", tamper(request, 11), HostToServer, nil},
+		{"changed nonce", tamper(request, 11+TimestampSize), HostToServer, nil},
+		{"changed ciphertext", tamper(request, len(request)-1), HostToServer, nil},
+		{"response to another request", response, ServerToHost, otherNonce},
+		{"legacy envelope", legacy, HostToServer, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := envelope.Open(tt.message, tt.direction, tt.context); err == nil {
+				t.Errorf("Envelope.Open() should fail")
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package accord
+
+import (
+	"context"
+	"io/ioutil"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2/google"
+	admin "google.golang.org/api/admin/directory/v1"
+)
+
+// DefaultGoogleDirectoryTimeout bounds every lookup, the cert request waits
+// on it and a slow Admin SDK shouldn't hold it until the client gives up
+const DefaultGoogleDirectoryTimeout = 10 * time.Second
+
+// GoogleDirectory reads the groups of the users from Google Apps with the
+// Admin SDK. The service account needs domain-wide delegation for the
+// group readonly scope, and acts as the admin in subject
+type GoogleDirectory struct {
+	service *admin.Service
+	subject string
+	timeout time.Duration
+}
+
+func NewGoogleDirectory(ctx context.Context, credentialsFile string, subject string) (*GoogleDirectory, error) {
+	content, err := ioutil.ReadFile(credentialsFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Cannot read file %s", credentialsFile)
+	}
+	conf, err := google.JWTConfigFromJSON(content, admin.AdminDirectoryGroupReadonlyScope)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to parse the service account credentials")
+	}
+	conf.Subject = subject
+	service, err := admin.New(conf.Client(ctx))
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to create the directory service")
+	}
+	return &GoogleDirectory{
+		service: service,
+		subject: subject,
+		timeout: DefaultGoogleDirectoryTimeout,
+	}, nil
+}
+
+// Groups are the emails of the groups the user is a direct member of
+func (g *GoogleDirectory) Groups(user string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), g.timeout)
+	defer cancel()
+	groups := []string{}
+	err := g.service.Groups.List().UserKey(user).Pages(ctx, func(page *admin.Groups) error {
+		for _, group := range page.Groups {
+			groups = append(groups, group.Email)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to list the groups of %s", user)
+	}
+	return groups, nil
+}
+
+// HealthCheck looks up the groups of the admin the service account acts as,
+// the credentials and the delegation have to work for that
+func (g *GoogleDirectory) HealthCheck() error {
+	ctx, cancel := context.WithTimeout(context.Background(), g.timeout)
+	defer cancel()
+	_, err := g.service.Groups.List().UserKey(g.subject).MaxResults(1).Context(ctx).Do()
+	if err != nil {
+		return errors.Wrapf(err, "Failed to list the groups of %s", g.subject)
+	}
+	return nil
+}
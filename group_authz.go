@@ -0,0 +1,245 @@
+package accord
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Directory is where the groups of the users come from, an identity
+// provider or a local file standing in for one
+type Directory interface {
+	Groups(user string) ([]string, error)
+}
+
+// LocalDirectory has the groups of each user in a file, for testing without
+// the identity provider or for setups too small to have one
+type LocalDirectory struct {
+	Users map[string][]string `json:"users" yaml:"users"`
+}
+
+func NewLocalDirectoryFromFile(filePath string) (*LocalDirectory, error) {
+	content, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Cannot read file %s", filePath)
+	}
+	d := &LocalDirectory{}
+	if err := decodePolicy(content, d, "local directory"); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Groups of a user that isn't in the directory is empty, same as a user
+// without any groups in an identity provider
+func (d *LocalDirectory) Groups(user string) ([]string, error) {
+	return d.Users[user], nil
+}
+
+type cachedGroups struct {
+	groups    []string
+	fetchedAt time.Time
+}
+
+// CachedDirectory keeps the groups from the directory for the ttl, so every
+// cert request doesn't go to the identity provider. Failed lookups aren't
+// cached
+type CachedDirectory struct {
+	directory Directory
+	ttl       time.Duration
+	now       func() time.Time
+
+	mu     sync.Mutex
+	groups map[string]cachedGroups
+}
+
+func NewCachedDirectory(directory Directory, ttl time.Duration) *CachedDirectory {
+	return &CachedDirectory{
+		directory: directory,
+		ttl:       ttl,
+		now:       time.Now,
+		groups:    map[string]cachedGroups{},
+	}
+}
+
+func (c *CachedDirectory) Groups(user string) ([]string, error) {
+	now := c.now()
+	c.mu.Lock()
+	cached, ok := c.groups[user]
+	c.mu.Unlock()
+	if ok && now.Sub(cached.fetchedAt) < c.ttl {
+		return cached.groups, nil
+	}
+	groups, err := c.directory.Groups(user)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.groups[user] = cachedGroups{groups: groups, fetchedAt: now}
+	c.mu.Unlock()
+	return groups, nil
+}
+
+func (c *CachedDirectory) HealthCheck() error {
+	return HealthCheck(c.directory)
+}
+
+// UserOverride changes what a single user gets from their groups, deny wins
+// over everything including the admin groups
+type UserOverride struct {
+	Grant []string `json:"grant" yaml:"grant"`
+	Deny  []string `json:"deny" yaml:"deny"`
+}
+
+// GroupAuth grants the principals by the groups the users are in, onboarding
+// someone is adding them to the right groups in the identity provider. The
+// principals anywhere in it can be patterns, like in SimpleAuth
+type GroupAuth struct {
+	Principals []string `json:"principals" yaml:"principals"`
+	// members of these groups can get any of the principals
+	AdminGroups []string `json:"admin_groups" yaml:"admin_groups"`
+
+	// Groups -> Principals map
+	GroupPrincipals map[string][]string `json:"group_principals" yaml:"group_principals"`
+	// Users -> the exceptions to their groups
+	UserOverrides map[string]UserOverride `json:"user_overrides" yaml:"user_overrides"`
+	// checked last with the overrides, deny wins over everything else
+	Deny []DenyRule `json:"deny" yaml:"deny"`
+
+	// same as in SimpleAuth
+	UserPermissions      map[string]CertPermissions `json:"user_permissions" yaml:"user_permissions"`
+	PrincipalPermissions map[string]CertPermissions `json:"principal_permissions" yaml:"principal_permissions"`
+
+	directory Directory
+}
+
+func NewGroupAuthFromFile(filePath string, directory Directory) (*GroupAuth, error) {
+	content, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Cannot read file %s", filePath)
+	}
+	return NewGroupAuthFromBuffer(content, directory)
+}
+
+func NewGroupAuthFromBuffer(content []byte, directory Directory) (*GroupAuth, error) {
+	g := &GroupAuth{}
+	if err := decodePolicy(content, g, "group auth"); err != nil {
+		return nil, err
+	}
+	if err := validPatterns(g.Principals); err != nil {
+		return nil, errors.Wrapf(err, "Invalid principals")
+	}
+	for group, principals := range g.GroupPrincipals {
+		if err := g.validGrants(principals); err != nil {
+			return nil, errors.Wrapf(err, "Group %s", group)
+		}
+	}
+	for user, override := range g.UserOverrides {
+		if err := g.validGrants(append(append([]string{}, override.Grant...), override.Deny...)); err != nil {
+			return nil, errors.Wrapf(err, "The override for user %s", user)
+		}
+	}
+	if err := validDenyRules(g.Deny); err != nil {
+		return nil, err
+	}
+	for user, permissions := range g.UserPermissions {
+		if err := permissions.Validate(); err != nil {
+			return nil, errors.Wrapf(err, "Invalid permissions for user %s", user)
+		}
+	}
+	for principal, permissions := range g.PrincipalPermissions {
+		if err := validPattern(principal); err != nil {
+			return nil, err
+		}
+		if err := permissions.Validate(); err != nil {
+			return nil, errors.Wrapf(err, "Invalid permissions for principal %s", principal)
+		}
+	}
+	g.directory = directory
+	return g, nil
+}
+
+// validGrants are patterns or principals of the policy, a pattern can't be
+// checked against the patterns in the principals
+func (g *GroupAuth) validGrants(principals []string) error {
+	if err := validPatterns(principals); err != nil {
+		return err
+	}
+	for _, p := range principals {
+		if !isPattern(p) && !matchesAny(p, g.Principals) {
+			return fmt.Errorf("Unknown principal %s", p)
+		}
+	}
+	return nil
+}
+
+// rules has the deny override of the user as one more deny rule
+func (g *GroupAuth) rules(user string) principalRules {
+	deny := g.Deny
+	if override := g.UserOverrides[user]; len(override.Deny) > 0 {
+		deny = append(append([]DenyRule{}, g.Deny...), DenyRule{Users: []string{user}, Principals: override.Deny})
+	}
+	return principalRules{principals: g.Principals, deny: deny}
+}
+
+func (g *GroupAuth) isAdmin(groups []string) bool {
+	for _, group := range groups {
+		if contains(group, g.AdminGroups) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAdmin is false when the groups can't be looked up
+func (g *GroupAuth) IsAdmin(user string) bool {
+	groups, err := g.directory.Groups(user)
+	return err == nil && g.isAdmin(groups)
+}
+
+// HealthCheck checks the directory the groups come from
+func (g *GroupAuth) HealthCheck() error {
+	return HealthCheck(g.directory)
+}
+
+func (g *GroupAuth) grants(groups []string) []string {
+	granted := []string{}
+	for _, group := range groups {
+		granted = append(granted, g.GroupPrincipals[group]...)
+	}
+	return granted
+}
+
+// Authorized leaves out the unknown principals like the ones that aren't
+// granted, Grant tells the user which ones they were
+func (g *GroupAuth) Authorized(user string, principals []string) ([]string, error) {
+	groups, err := g.directory.Groups(user)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to look up the groups of %s", user)
+	}
+	override, overridden := g.UserOverrides[user]
+	admin := g.isAdmin(groups)
+	granted := append(g.grants(groups), override.Grant...)
+	if !admin && len(granted) == 0 && !overridden {
+		return nil, fmt.Errorf("User not granted any access yet, talk to your administrator")
+	}
+
+	return g.rules(user).authorize(user, principals, admin, granted), nil
+}
+
+func (g *GroupAuth) DenialReason(user string, principal string) string {
+	if g.rules(user).known(principal) && matchesAny(principal, g.UserOverrides[user].Deny) {
+		return fmt.Sprintf("denied to %s by an override", user)
+	}
+	if reason := g.rules(user).denialReason(user, principal); reason != "" {
+		return reason
+	}
+	return fmt.Sprintf("none of the groups of %s grant it, talk to your administrator", user)
+}
+
+func (g *GroupAuth) Permissions(user string, principals []string) (CertPermissions, error) {
+	return combinePermissions(g.UserPermissions, g.PrincipalPermissions, user, principals)
+}
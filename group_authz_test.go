@@ -0,0 +1,243 @@
+package accord
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// countingDirectory counts the lookups to check the cache
+type countingDirectory struct {
+	LocalDirectory
+	lookups int
+}
+
+func (c *countingDirectory) Groups(user string) ([]string, error) {
+	c.lookups++
+	return c.LocalDirectory.Groups(user)
+}
+
+func TestGroupAuth_Authorized(t *testing.T) {
+	directory := &LocalDirectory{Users: map[string][]string{
+		"admin@ex.ample.com":      []string{"ssh-admins@ex.ample.com"},
+		"dba@ex.ample.com":        []string{"dbas@ex.ample.com", "everyone@ex.ample.com"},
+		"intern@ex.ample.com":     []string{"dbas@ex.ample.com"},
+		"newhire@ex.ample.com":    []string{"everyone@ex.ample.com"},
+		"contractor@ex.ample.com": []string{},
+	}}
+	g, err := NewGroupAuthFromBuffer([]byte(`{
+		"principals": ["root-everywhere", "zones-db", "zones-willywonka"],
+		"admin_groups": ["ssh-admins@ex.ample.com"],
+		"group_principals": {"dbas@ex.ample.com": ["zones-db"]},
+		"user_overrides": {
+			"intern@ex.ample.com": {"deny": ["zones-db"]},
+			"contractor@ex.ample.com": {"grant": ["zones-willywonka"]},
+			"admin@ex.ample.com": {"deny": ["zones-willywonka"]}
+		}
+	}`), directory)
+	if err != nil {
+		t.Fatalf("NewGroupAuthFromBuffer() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		user       string
+		principals []string
+		want       []string
+		wantErr    bool
+	}{
+		{
+			name:       "group members get the principals of the group",
+			user:       "dba@ex.ample.com",
+			principals: []string{"zones-db", "root-everywhere"},
+			want:       []string{"zones-db"},
+		},
+		{
+			name:       "admin groups get everything but the denied ones",
+			user:       "admin@ex.ample.com",
+			principals: []string{"root-everywhere", "zones-willywonka"},
+			want:       []string{"root-everywhere"},
+		},
+		{
+			name:       "overrides deny what the groups grant",
+			user:       "intern@ex.ample.com",
+			principals: []string{"zones-db"},
+			want:       []string{},
+		},
+		{
+			name:       "overrides grant without any groups",
+			user:       "contractor@ex.ample.com",
+			principals: []string{"zones-willywonka"},
+			want:       []string{"zones-willywonka"},
+		},
+		{
+			name:       "groups that don't grant anything",
+			user:       "newhire@ex.ample.com",
+			principals: []string{"zones-db"},
+			wantErr:    true,
+		},
+		{
+			name:       "unknown users",
+			user:       "stranger@ex.ample.com",
+			principals: []string{"zones-db"},
+			wantErr:    true,
+		},
+		{
+			name:       "unknown principals aren't granted",
+			user:       "dba@ex.ample.com",
+			principals: []string{"zones-chocolatefactory"},
+			want:       []string{},
+		},
+		{
+			name:       "unknown principals don't fail the rest",
+			user:       "admin@ex.ample.com",
+			principals: []string{"zones-chocolatefactory", "root-everywhere"},
+			want:       []string{"root-everywhere"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := g.Authorized(tt.user, tt.principals)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GroupAuth.Authorized() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GroupAuth.Authorized() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	for user, want := range map[string]bool{
+		"admin@ex.ample.com":    true,
+		"dba@ex.ample.com":      false,
+		"stranger@ex.ample.com": false,
+	} {
+		if got := IsAdmin(g, user); got != want {
+			t.Errorf("IsAdmin(%s) = %v, want %v", user, got, want)
+		}
+	}
+}
+
+func TestGroupAuth_PatternsAndDeny(t *testing.T) {
+	directory := &LocalDirectory{Users: map[string][]string{
+		"admin@contractors.ex.ample.com": []string{"ssh-admins@ex.ample.com"},
+		"deployer@ex.ample.com":          []string{"deployers@ex.ample.com"},
+		"intern@ex.ample.com":            []string{"deployers@ex.ample.com"},
+	}}
+	g, err := NewGroupAuthFromBuffer([]byte(`
+principals: [root-everywhere, deploy-*]
+admin_groups: [ssh-admins@ex.ample.com]
+group_principals:
+  deployers@ex.ample.com: [deploy-*]
+user_overrides:
+  intern@ex.ample.com: {deny: [deploy-prod]}
+deny:
+  - users: ["*@contractors.ex.ample.com"]
+    principals: [root-*]
+`), directory)
+	if err != nil {
+		t.Fatalf("NewGroupAuthFromBuffer() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		user       string
+		principals []string
+		want       []string
+		wantDenied []Denial
+	}{
+		{
+			name:       "groups grant patterns",
+			user:       "deployer@ex.ample.com",
+			principals: []string{"deploy-prod", "deploy-*", "root-everywhere"},
+			want:       []string{"deploy-prod"},
+			wantDenied: []Denial{
+				{"deploy-*", "unknown principal"},
+				{"root-everywhere", "none of the groups of deployer@ex.ample.com grant it, talk to your administrator"},
+			},
+		},
+		{
+			name:       "deny rules win over the admin groups",
+			user:       "admin@contractors.ex.ample.com",
+			principals: []string{"root-everywhere", "deploy-prod"},
+			want:       []string{"deploy-prod"},
+			wantDenied: []Denial{{"root-everywhere", "denied to admin@contractors.ex.ample.com by a deny rule"}},
+		},
+		{
+			name:       "overrides deny within a pattern",
+			user:       "intern@ex.ample.com",
+			principals: []string{"deploy-prod", "deploy-staging"},
+			want:       []string{"deploy-staging"},
+			wantDenied: []Denial{{"deploy-prod", "denied to intern@ex.ample.com by an override"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, denied, err := Grant(g, tt.user, tt.principals)
+			if err != nil {
+				t.Fatalf("Grant() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Grant() granted = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(denied, tt.wantDenied) {
+				t.Errorf("Grant() denied = %v, want %v", denied, tt.wantDenied)
+			}
+		})
+	}
+}
+
+func TestNewGroupAuthFromBuffer(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"groups with unknown principals", `{
+			"principals": ["zones-db"],
+			"group_principals": {"dbas@ex.ample.com": ["zones-dbb"]}
+		}`},
+		{"overrides with unknown principals", `{
+			"principals": ["deploy-*"],
+			"user_overrides": {"intern@ex.ample.com": {"grant": ["zones-db"]}}
+		}`},
+		{"deny rules without users", `{
+			"principals": ["zones-db"],
+			"deny": [{"principals": ["zones-db"]}]
+		}`},
+		{"invalid patterns", `{
+			"principals": ["zones-["]
+		}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewGroupAuthFromBuffer([]byte(tt.content), &LocalDirectory{}); err == nil {
+				t.Errorf("NewGroupAuthFromBuffer() should fail for %s", tt.name)
+			}
+		})
+	}
+}
+
+func TestCachedDirectory(t *testing.T) {
+	directory := &countingDirectory{LocalDirectory: LocalDirectory{Users: map[string][]string{
+		"dba@ex.ample.com": []string{"dbas@ex.ample.com"},
+	}}}
+	cached := NewCachedDirectory(directory, time.Minute)
+	now := time.Now()
+	cached.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		groups, err := cached.Groups("dba@ex.ample.com")
+		if err != nil || !reflect.DeepEqual(groups, []string{"dbas@ex.ample.com"}) {
+			t.Fatalf("CachedDirectory.Groups() = %v, %v", groups, err)
+		}
+	}
+	if directory.lookups != 1 {
+		t.Errorf("CachedDirectory.Groups() looked up %d times within the ttl, want 1", directory.lookups)
+	}
+
+	now = now.Add(time.Minute)
+	cached.Groups("dba@ex.ample.com")
+	if directory.lookups != 2 {
+		t.Errorf("CachedDirectory.Groups() looked up %d times after the ttl, want 2", directory.lookups)
+	}
+}
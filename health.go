@@ -0,0 +1,15 @@
+package accord
+
+// HealthChecker is implemented by what the server depends on that can break
+// after it was loaded, like the directory the groups come from
+type HealthChecker interface {
+	HealthCheck() error
+}
+
+// HealthCheck is nil for what can't tell whether it works
+func HealthCheck(v interface{}) error {
+	if checker, ok := v.(HealthChecker); ok {
+		return checker.HealthCheck()
+	}
+	return nil
+}
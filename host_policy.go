@@ -0,0 +1,149 @@
+package accord
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/ec2metadata"
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrNoIdentity          = errors.New("The host didn't send a verified identity document")
+	ErrUnknownDeployment   = errors.New("No host policy for the deployment")
+	ErrIdentityNotAllowed  = errors.New("The host identity isn't allowed for the deployment")
+	ErrPrincipalNotAllowed = errors.New("The host isn't allowed to have the principals")
+)
+
+// what to do with the host principals that aren't the host's own names or
+// addresses and don't match the hostname patterns
+const (
+	// sign whatever the host asks for
+	PrincipalsUnchecked = ""
+	// fail the whole request
+	PrincipalsReject = "reject"
+	// sign the cert without them
+	PrincipalsStrip = "strip"
+)
+
+// DeploymentPolicy is where the hosts of a deployment can run, an empty list
+// allows anything for that field
+type DeploymentPolicy struct {
+	AWSAccounts []string `json:"aws_accounts" yaml:"aws_accounts"`
+	AWSRegions  []string `json:"aws_regions" yaml:"aws_regions"`
+	// checking the principals is off unless this is reject or strip
+	Principals string `json:"principals" yaml:"principals"`
+	// names the hosts can have on top of their own, shell patterns like *.prod.example.com
+	HostnamePatterns []string `json:"hostname_patterns" yaml:"hostname_patterns"`
+}
+
+func (d DeploymentPolicy) Validate() error {
+	switch d.Principals {
+	case PrincipalsUnchecked, PrincipalsReject, PrincipalsStrip:
+	default:
+		return fmt.Errorf("Unknown principals check %q, use %s or %s", d.Principals, PrincipalsReject, PrincipalsStrip)
+	}
+	for _, pattern := range d.HostnamePatterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("Invalid hostname pattern %q", pattern)
+		}
+	}
+	return nil
+}
+
+func (d DeploymentPolicy) principalAllowed(principal string, hostNames []string) bool {
+	principal = strings.ToLower(principal)
+	for _, name := range hostNames {
+		if principal == strings.ToLower(name) {
+			return true
+		}
+	}
+	for _, pattern := range d.HostnamePatterns {
+		if matched, _ := path.Match(strings.ToLower(pattern), principal); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// HostPolicy ties the deployments, the PSK key ids, to the AWS accounts and
+// regions their hosts are in. A leaked PSK then can't get certs for hosts
+// outside of the deployment it belongs to
+type HostPolicy struct {
+	// reject the deployments that don't have a policy instead of letting them through
+	Strict      bool                        `json:"strict" yaml:"strict"`
+	Deployments map[uint32]DeploymentPolicy `json:"deployments" yaml:"deployments"`
+}
+
+func NewHostPolicyFromFile(filePath string) (*HostPolicy, error) {
+	content, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Cannot read file %s", filePath)
+	}
+	return NewHostPolicyFromBuffer(content)
+}
+
+func NewHostPolicyFromBuffer(content []byte) (*HostPolicy, error) {
+	p := &HostPolicy{}
+	err := json.Unmarshal(content, p)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to parse json for host policy")
+	}
+	for keyId, deployment := range p.Deployments {
+		if err := deployment.Validate(); err != nil {
+			return nil, errors.Wrapf(err, "Invalid host policy for key id %d", keyId)
+		}
+	}
+	return p, nil
+}
+
+// Check decides if the host with the identity document can get certs with
+// the PSK keyId. The document has to be one that was verified, nil if the
+// host didn't send one
+func (p *HostPolicy) Check(keyId uint32, identity *ec2metadata.EC2InstanceIdentityDocument) error {
+	policy, ok := p.Deployments[keyId]
+	if !ok {
+		if p.Strict {
+			return errors.Wrapf(ErrUnknownDeployment, "Key id %d", keyId)
+		}
+		return nil
+	}
+	if identity == nil {
+		return ErrNoIdentity
+	}
+	if len(policy.AWSAccounts) > 0 && !contains(identity.AccountID, policy.AWSAccounts) {
+		return errors.Wrapf(ErrIdentityNotAllowed, "Account %s for key id %d", identity.AccountID, keyId)
+	}
+	if len(policy.AWSRegions) > 0 && !contains(identity.Region, policy.AWSRegions) {
+		return errors.Wrapf(ErrIdentityNotAllowed, "Region %s for key id %d", identity.Region, keyId)
+	}
+	return nil
+}
+
+// CheckPrincipals splits the requested principals into the ones the host can
+// have, its own names and addresses from the verified identity and the ones
+// matching the hostname patterns, and the dropped ones. With reject, any
+// dropped principal is an error
+func (p *HostPolicy) CheckPrincipals(keyId uint32, principals []string, hostNames []string) (allowed []string, dropped []string, err error) {
+	policy, ok := p.Deployments[keyId]
+	if !ok || policy.Principals == PrincipalsUnchecked {
+		return principals, nil, nil
+	}
+	for _, principal := range principals {
+		if policy.principalAllowed(principal, hostNames) {
+			allowed = append(allowed, principal)
+		} else {
+			dropped = append(dropped, principal)
+		}
+	}
+	if len(dropped) > 0 && policy.Principals == PrincipalsReject {
+		return nil, dropped, errors.Wrapf(ErrPrincipalNotAllowed, "%v for key id %d", dropped, keyId)
+	}
+	if len(allowed) == 0 {
+		return nil, dropped, errors.Wrapf(ErrPrincipalNotAllowed, "None of %v for key id %d", principals, keyId)
+	}
+	return allowed, dropped, nil
+}
@@ -0,0 +1,172 @@
+package accord
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/ec2metadata"
+)
+
+func TestHostPolicy_Check(t *testing.T) {
+	policy, err := NewHostPolicyFromBuffer([]byte(`{
+		"deployments": {
+			"1": {"aws_accounts": ["111111111111"], "aws_regions": ["us-west-2", "eu-west-1"]},
+			"2": {"aws_accounts": ["222222222222"]}
+		}
+	}`))
+	if err != nil {
+		t.Fatalf("NewHostPolicyFromBuffer() error = %v", err)
+	}
+	strict := *policy
+	strict.Strict = true
+
+	identity := func(account, region string) *ec2metadata.EC2InstanceIdentityDocument {
+		return &ec2metadata.EC2InstanceIdentityDocument{AccountID: account, Region: region}
+	}
+	tests := []struct {
+		name     string
+		policy   *HostPolicy
+		keyId    uint32
+		identity *ec2metadata.EC2InstanceIdentityDocument
+		wantErr  bool
+	}{
+		{
+			name:     "matching account and region",
+			policy:   policy,
+			keyId:    1,
+			identity: identity("111111111111", "eu-west-1"),
+		},
+		{
+			name:     "wrong region",
+			policy:   policy,
+			keyId:    1,
+			identity: identity("111111111111", "us-east-1"),
+			wantErr:  true,
+		},
+		{
+			name:     "account of another deployment",
+			policy:   policy,
+			keyId:    1,
+			identity: identity("222222222222", "us-west-2"),
+			wantErr:  true,
+		},
+		{
+			name:     "any region",
+			policy:   policy,
+			keyId:    2,
+			identity: identity("222222222222", "ap-south-1"),
+		},
+		{
+			name:    "no identity for a deployment with a policy",
+			policy:  policy,
+			keyId:   2,
+			wantErr: true,
+		},
+		{
+			name:   "deployment without a policy",
+			policy: policy,
+			keyId:  3,
+		},
+		{
+			name:    "deployment without a policy when strict",
+			policy:  &strict,
+			keyId:   3,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.policy.Check(tt.keyId, tt.identity); (err != nil) != tt.wantErr {
+				t.Errorf("HostPolicy.Check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHostPolicy_CheckPrincipals(t *testing.T) {
+	policy, err := NewHostPolicyFromBuffer([]byte(`{
+		"deployments": {
+			"1": {"principals": "reject", "hostname_patterns": ["*.prod.example.com"]},
+			"2": {"principals": "strip"},
+			"3": {}
+		}
+	}`))
+	if err != nil {
+		t.Fatalf("NewHostPolicyFromBuffer() error = %v", err)
+	}
+	hostNames := []string{"10.0.1.2", "ip-10-0-1-2.us-west-2.compute.internal"}
+	tests := []struct {
+		name        string
+		keyId       uint32
+		principals  []string
+		wantAllowed []string
+		wantDropped []string
+		wantErr     bool
+	}{
+		{
+			name:        "own names and patterns",
+			keyId:       1,
+			principals:  []string{"10.0.1.2", "db1.PROD.example.com"},
+			wantAllowed: []string{"10.0.1.2", "db1.PROD.example.com"},
+		},
+		{
+			name:        "rejected",
+			keyId:       1,
+			principals:  []string{"db1.prod.example.com", "db1.staging.example.com"},
+			wantDropped: []string{"db1.staging.example.com"},
+			wantErr:     true,
+		},
+		{
+			name:        "stripped",
+			keyId:       2,
+			principals:  []string{"ip-10-0-1-2.us-west-2.compute.internal", "db1.prod.example.com"},
+			wantAllowed: []string{"ip-10-0-1-2.us-west-2.compute.internal"},
+			wantDropped: []string{"db1.prod.example.com"},
+		},
+		{
+			name:        "nothing left",
+			keyId:       2,
+			principals:  []string{"db1.prod.example.com"},
+			wantDropped: []string{"db1.prod.example.com"},
+			wantErr:     true,
+		},
+		{
+			name:        "unchecked",
+			keyId:       3,
+			principals:  []string{"anything.example.com"},
+			wantAllowed: []string{"anything.example.com"},
+		},
+		{
+			name:        "no policy",
+			keyId:       4,
+			principals:  []string{"anything.example.com"},
+			wantAllowed: []string{"anything.example.com"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allowed, dropped, err := policy.CheckPrincipals(tt.keyId, tt.principals, hostNames)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("HostPolicy.CheckPrincipals() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(allowed, tt.wantAllowed) {
+				t.Errorf("HostPolicy.CheckPrincipals() allowed = %v, want %v", allowed, tt.wantAllowed)
+			}
+			if !reflect.DeepEqual(dropped, tt.wantDropped) {
+				t.Errorf("HostPolicy.CheckPrincipals() dropped = %v, want %v", dropped, tt.wantDropped)
+			}
+		})
+	}
+}
+
+func TestNewHostPolicyFromBuffer_Invalid(t *testing.T) {
+	for _, content := range []string{
+		`{"deployments": {"1": {"principals": "drop"}}}`,
+		`{"deployments": {"1": {"hostname_patterns": ["[a-"]}}}`,
+	} {
+		if _, err := NewHostPolicyFromBuffer([]byte(content)); err == nil {
+			t.Errorf("NewHostPolicyFromBuffer(%s) should fail", content)
+		}
+	}
+}
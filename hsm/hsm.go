@@ -0,0 +1,294 @@
+//go:build cgo
+// +build cgo
+
+// Package hsm signs with CA keys kept on a PKCS#11 token, like a network HSM,
+// a YubiHSM or SoftHSM for testing. The private keys never leave the token.
+// The PKCS#11 library is loaded with cgo, without cgo Open always fails
+package hsm
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/asn1"
+	"io"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/miekg/pkcs11"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+)
+
+// Token is a logged in session on a PKCS#11 token. The session is shared by
+// all the keys found on it, PKCS#11 sessions can only do one operation at a
+// time so the signing is serialized
+type Token struct {
+	mu      sync.Mutex
+	ctx     *pkcs11.Ctx
+	session pkcs11.SessionHandle
+}
+
+// Open loads the PKCS#11 module, finds the token with the given label and logs in with pin
+func Open(modulePath, tokenLabel, pin string) (*Token, error) {
+	ctx := pkcs11.New(modulePath)
+	if ctx == nil {
+		return nil, errors.Errorf("Failed to load the PKCS#11 module %s", modulePath)
+	}
+	if err := ctx.Initialize(); err != nil {
+		ctx.Destroy()
+		return nil, errors.Wrapf(err, "Failed to initialize %s", modulePath)
+	}
+	token := &Token{ctx: ctx}
+	if err := token.login(tokenLabel, pin); err != nil {
+		ctx.Finalize()
+		ctx.Destroy()
+		return nil, err
+	}
+	return token, nil
+}
+
+func (t *Token) login(tokenLabel, pin string) error {
+	slots, err := t.ctx.GetSlotList(true)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to list the slots")
+	}
+	for _, slot := range slots {
+		info, err := t.ctx.GetTokenInfo(slot)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to get the token info for slot %d", slot)
+		}
+		// the labels are padded with spaces to 32 bytes
+		if strings.TrimRight(info.Label, " \x00") != tokenLabel {
+			continue
+		}
+		session, err := t.ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to open a session on %s", tokenLabel)
+		}
+		if err := t.ctx.Login(session, pkcs11.CKU_USER, pin); err != nil {
+			t.ctx.CloseSession(session)
+			return errors.Wrapf(err, "Failed to log in to %s", tokenLabel)
+		}
+		t.session = session
+		return nil
+	}
+	return errors.Errorf("No token with the label %s", tokenLabel)
+}
+
+// Close logs out and unloads the module
+func (t *Token) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.ctx.Logout(t.session)
+	t.ctx.CloseSession(t.session)
+	t.ctx.Finalize()
+	t.ctx.Destroy()
+	return nil
+}
+
+func (t *Token) findObjects(template []*pkcs11.Attribute) ([]pkcs11.ObjectHandle, error) {
+	if err := t.ctx.FindObjectsInit(t.session, template); err != nil {
+		return nil, err
+	}
+	defer t.ctx.FindObjectsFinal(t.session)
+	var handles []pkcs11.ObjectHandle
+	for {
+		found, _, err := t.ctx.FindObjects(t.session, 100)
+		if err != nil {
+			return nil, err
+		}
+		if len(found) == 0 {
+			return handles, nil
+		}
+		handles = append(handles, found...)
+	}
+}
+
+// KeySigner is a private key on the token, it's a crypto.Signer so it can
+// be used for more than the ssh certs
+type KeySigner struct {
+	token     *Token
+	handle    pkcs11.ObjectHandle
+	publicKey crypto.PublicKey
+}
+
+// Signer finds the private key on the token that goes with publicKey. The
+// public key objects are matched first since the private key objects don't
+// always expose the public parts, then the private key with the same CKA_ID is used
+func (t *Token) Signer(publicKey ssh.PublicKey) (*KeySigner, error) {
+	cryptoKey, ok := publicKey.(ssh.CryptoPublicKey)
+	if !ok {
+		return nil, errors.Errorf("Unsupported key type %s", publicKey.Type())
+	}
+	want := cryptoKey.CryptoPublicKey()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	publics, err := t.findObjects([]*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PUBLIC_KEY),
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to list the public keys on the token")
+	}
+	for _, handle := range publics {
+		key, id, err := t.publicKey(handle)
+		if err != nil {
+			// keys of types we don't support
+			continue
+		}
+		if !samePublicKey(key, want) {
+			continue
+		}
+		privates, err := t.findObjects([]*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PRIVATE_KEY),
+			pkcs11.NewAttribute(pkcs11.CKA_ID, id),
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to find the private key for %s", ssh.FingerprintSHA256(publicKey))
+		}
+		if len(privates) != 1 {
+			return nil, errors.Errorf("Found %d private keys for %s", len(privates), ssh.FingerprintSHA256(publicKey))
+		}
+		return &KeySigner{token: t, handle: privates[0], publicKey: key}, nil
+	}
+	return nil, errors.Errorf("The token doesn't have the key %s", ssh.FingerprintSHA256(publicKey))
+}
+
+// the DER encoded OIDs as they are in CKA_EC_PARAMS
+var curveOIDs = map[string]elliptic.Curve{
+	string([]byte{0x06, 0x08, 0x2a, 0x86, 0x48, 0xce, 0x3d, 0x03, 0x01, 0x07}): elliptic.P256(),
+	string([]byte{0x06, 0x05, 0x2b, 0x81, 0x04, 0x00, 0x22}):                   elliptic.P384(),
+	string([]byte{0x06, 0x05, 0x2b, 0x81, 0x04, 0x00, 0x23}):                   elliptic.P521(),
+}
+
+func (t *Token) publicKey(handle pkcs11.ObjectHandle) (crypto.PublicKey, []byte, error) {
+	attrs, err := t.ctx.GetAttributeValue(t.session, handle, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, nil),
+		pkcs11.NewAttribute(pkcs11.CKA_ID, nil),
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	keyType := new(big.Int).SetBytes(reverse(attrs[0].Value)).Uint64()
+	id := attrs[1].Value
+	switch keyType {
+	case pkcs11.CKK_RSA:
+		attrs, err := t.ctx.GetAttributeValue(t.session, handle, []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_MODULUS, nil),
+			pkcs11.NewAttribute(pkcs11.CKA_PUBLIC_EXPONENT, nil),
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(attrs[0].Value),
+			E: int(new(big.Int).SetBytes(attrs[1].Value).Int64()),
+		}, id, nil
+	case pkcs11.CKK_EC:
+		attrs, err := t.ctx.GetAttributeValue(t.session, handle, []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, nil),
+			pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, nil),
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		curve, ok := curveOIDs[string(attrs[0].Value)]
+		if !ok {
+			return nil, nil, errors.New("Unsupported curve")
+		}
+		// the point is wrapped in an OCTET STRING
+		var point []byte
+		if _, err := asn1.Unmarshal(attrs[1].Value, &point); err != nil {
+			return nil, nil, errors.Wrapf(err, "Invalid EC point")
+		}
+		x, y := elliptic.Unmarshal(curve, point)
+		if x == nil {
+			return nil, nil, errors.New("Invalid EC point")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, id, nil
+	}
+	return nil, nil, errors.Errorf("Unsupported key type %d", keyType)
+}
+
+// CK_ULONG attributes come back in the native byte order, which is little
+// endian everywhere we run this
+func reverse(b []byte) []byte {
+	r := make([]byte, len(b))
+	for i := range b {
+		r[len(b)-1-i] = b[i]
+	}
+	return r
+}
+
+func samePublicKey(a, b crypto.PublicKey) bool {
+	switch a := a.(type) {
+	case *rsa.PublicKey:
+		b, ok := b.(*rsa.PublicKey)
+		return ok && a.N.Cmp(b.N) == 0 && a.E == b.E
+	case *ecdsa.PublicKey:
+		b, ok := b.(*ecdsa.PublicKey)
+		return ok && a.Curve == b.Curve && a.X.Cmp(b.X) == 0 && a.Y.Cmp(b.Y) == 0
+	}
+	return false
+}
+
+func (k *KeySigner) Public() crypto.PublicKey {
+	return k.publicKey
+}
+
+// the DigestInfo prefixes from RFC 8017, CKM_RSA_PKCS only does the padding
+var digestInfoPrefixes = map[crypto.Hash][]byte{
+	crypto.SHA1:   {0x30, 0x21, 0x30, 0x09, 0x06, 0x05, 0x2b, 0x0e, 0x03, 0x02, 0x1a, 0x05, 0x00, 0x04, 0x14},
+	crypto.SHA256: {0x30, 0x31, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x01, 0x05, 0x00, 0x04, 0x20},
+	crypto.SHA384: {0x30, 0x41, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x02, 0x05, 0x00, 0x04, 0x30},
+	crypto.SHA512: {0x30, 0x51, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x03, 0x05, 0x00, 0x04, 0x40},
+}
+
+// Sign signs the digest on the token, the signatures are in the same
+// format as the ones from crypto/rsa and crypto/ecdsa
+func (k *KeySigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	var (
+		mechanism uint
+		data      []byte
+	)
+	switch k.publicKey.(type) {
+	case *rsa.PublicKey:
+		prefix, ok := digestInfoPrefixes[opts.HashFunc()]
+		if !ok {
+			return nil, errors.Errorf("Unsupported hash %v", opts.HashFunc())
+		}
+		mechanism = pkcs11.CKM_RSA_PKCS
+		data = append(append([]byte{}, prefix...), digest...)
+	case *ecdsa.PublicKey:
+		mechanism = pkcs11.CKM_ECDSA
+		data = digest
+	}
+
+	k.token.mu.Lock()
+	defer k.token.mu.Unlock()
+	ctx, session := k.token.ctx, k.token.session
+	if err := ctx.SignInit(session, []*pkcs11.Mechanism{pkcs11.NewMechanism(mechanism, nil)}, k.handle); err != nil {
+		return nil, errors.Wrapf(err, "Failed to start signing on the token")
+	}
+	signature, err := ctx.Sign(session, data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to sign on the token")
+	}
+	if mechanism != pkcs11.CKM_ECDSA {
+		return signature, nil
+	}
+	// the token returns r || s, crypto.Signer callers expect it DER encoded
+	half := len(signature) / 2
+	return asn1.Marshal(struct{ R, S *big.Int }{
+		new(big.Int).SetBytes(signature[:half]),
+		new(big.Int).SetBytes(signature[half:]),
+	})
+}
+
+// Signer returns the ssh.Signer for the key, it makes the KeySigner an accord.CASigner
+func (k *KeySigner) Signer() (ssh.Signer, error) {
+	return ssh.NewSignerFromSigner(k)
+}
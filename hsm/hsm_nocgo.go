@@ -0,0 +1,32 @@
+//go:build !cgo
+// +build !cgo
+
+package hsm
+
+import (
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+)
+
+var errNoCgo = errors.New("PKCS#11 support needs cgo, this binary was built without it")
+
+// Token is only usable when built with cgo
+type Token struct{}
+
+func Open(modulePath, tokenLabel, pin string) (*Token, error) {
+	return nil, errNoCgo
+}
+
+func (t *Token) Close() error {
+	return nil
+}
+
+type KeySigner struct{}
+
+func (t *Token) Signer(publicKey ssh.PublicKey) (*KeySigner, error) {
+	return nil, errNoCgo
+}
+
+func (k *KeySigner) Signer() (ssh.Signer, error) {
+	return nil, errNoCgo
+}
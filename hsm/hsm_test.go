@@ -0,0 +1,131 @@
+//go:build cgo
+// +build cgo
+
+package hsm
+
+import (
+	"bytes"
+	"crypto/rand"
+	"os"
+	"testing"
+
+	"github.com/miekg/pkcs11"
+	"golang.org/x/crypto/ssh"
+)
+
+// These run against SoftHSM, set up a token with
+//
+//	softhsm2-util --init-token --free --label accord-test --pin 1234 --so-pin 1234
+//
+// and point ACCORD_PKCS11_MODULE at libsofthsm2.so
+func testToken(t *testing.T) *Token {
+	module := os.Getenv("ACCORD_PKCS11_MODULE")
+	if module == "" {
+		t.Skip("ACCORD_PKCS11_MODULE isn't set")
+	}
+	label := os.Getenv("ACCORD_PKCS11_TOKEN")
+	if label == "" {
+		label = "accord-test"
+	}
+	pin := os.Getenv("ACCORD_PKCS11_PIN")
+	if pin == "" {
+		pin = "1234"
+	}
+	token, err := Open(module, label, pin)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	return token
+}
+
+// generateKey creates a session key pair on the token so the tests don't leave anything behind
+func generateKey(t *testing.T, token *Token, mechanism uint, public []*pkcs11.Attribute) {
+	id := make([]byte, 8)
+	rand.Read(id)
+	public = append(public,
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, false),
+		pkcs11.NewAttribute(pkcs11.CKA_VERIFY, true),
+		pkcs11.NewAttribute(pkcs11.CKA_ID, id),
+	)
+	private := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, false),
+		pkcs11.NewAttribute(pkcs11.CKA_SIGN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_ID, id),
+	}
+	_, _, err := token.ctx.GenerateKeyPair(token.session, []*pkcs11.Mechanism{pkcs11.NewMechanism(mechanism, nil)}, public, private)
+	if err != nil {
+		t.Fatalf("Failed to generate a key pair: %s", err)
+	}
+}
+
+func TestToken_Signer(t *testing.T) {
+	token := testToken(t)
+	defer token.Close()
+
+	tests := []struct {
+		name      string
+		mechanism uint
+		public    []*pkcs11.Attribute
+	}{
+		{
+			name:      "rsa",
+			mechanism: pkcs11.CKM_RSA_PKCS_KEY_PAIR_GEN,
+			public: []*pkcs11.Attribute{
+				pkcs11.NewAttribute(pkcs11.CKA_MODULUS_BITS, 2048),
+				pkcs11.NewAttribute(pkcs11.CKA_PUBLIC_EXPONENT, []byte{1, 0, 1}),
+			},
+		},
+		{
+			name:      "ecdsa p256",
+			mechanism: pkcs11.CKM_EC_KEY_PAIR_GEN,
+			public: []*pkcs11.Attribute{
+				pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, []byte{0x06, 0x08, 0x2a, 0x86, 0x48, 0xce, 0x3d, 0x03, 0x01, 0x07}),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before, err := token.findObjects([]*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PUBLIC_KEY)})
+			if err != nil {
+				t.Fatalf("Failed to list the keys: %s", err)
+			}
+			generateKey(t, token, tt.mechanism, tt.public)
+			after, err := token.findObjects([]*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PUBLIC_KEY)})
+			if err != nil {
+				t.Fatalf("Failed to list the keys: %s", err)
+			}
+			if len(after) != len(before)+1 {
+				t.Fatalf("Expected one new public key, found %d", len(after)-len(before))
+			}
+			key, _, err := token.publicKey(after[len(after)-1])
+			if err != nil {
+				t.Fatalf("Failed to read the public key: %s", err)
+			}
+			publicKey, err := ssh.NewPublicKey(key)
+			if err != nil {
+				t.Fatalf("Failed to convert the public key: %s", err)
+			}
+
+			keySigner, err := token.Signer(publicKey)
+			if err != nil {
+				t.Fatalf("Token.Signer() error = %v", err)
+			}
+			signer, err := keySigner.Signer()
+			if err != nil {
+				t.Fatalf("KeySigner.Signer() error = %v", err)
+			}
+			if !bytes.Equal(signer.PublicKey().Marshal(), publicKey.Marshal()) {
+				t.Errorf("KeySigner.Signer() has the wrong public key")
+			}
+			data := []byte("accord")
+			signature, err := signer.Sign(rand.Reader, data)
+			if err != nil {
+				t.Fatalf("Sign() error = %v", err)
+			}
+			if err := publicKey.Verify(data, signature); err != nil {
+				t.Errorf("Signature doesn't verify: %s", err)
+			}
+		})
+	}
+}
@@ -1,7 +1,9 @@
 package id
 
 import (
+	"crypto/hmac"
 	"crypto/md5"
+	"crypto/sha256"
 	"encoding/binary"
 	"errors"
 	"fmt"
@@ -31,3 +33,40 @@ func KeyIDBytes(s string, salt string) ([]byte, error) {
 	sum := h.Sum(nil)
 	return sum[:4], nil
 }
+
+// the key id schemes, the version is kept with the deployment so the server
+// knows which hosts still need to be moved to the HMAC ids
+const (
+	KeyIDVersionMD5  = 1
+	KeyIDVersionHMAC = 2
+)
+
+// short secrets are probably a salt copied by mistake
+const MinKeyIDSecretSize = 16
+
+// KeyIDHMAC is the key id from the first 4 bytes of HMAC-SHA256 of the
+// deploymentId with a secret that's different for each installation. Unlike
+// the MD5 ones, they can't be computed without the secret
+func KeyIDHMAC(s string, secret []byte) (uint32, error) {
+	if s == "" {
+		return 0, errors.New("Empty string given for ID")
+	}
+	if len(secret) < MinKeyIDSecretSize {
+		return 0, fmt.Errorf("The key id secret needs to be at least %d bytes", MinKeyIDSecretSize)
+	}
+	h := hmac.New(sha256.New, secret)
+	io.WriteString(h, "accord-keyid-v2:")
+	io.WriteString(h, s)
+	return binary.BigEndian.Uint32(h.Sum(nil)[:4]), nil
+}
+
+// DeploymentKeyID is the HMAC key id when there's a secret, otherwise the
+// MD5 one with the salt. It returns the key id and the scheme version
+func DeploymentKeyID(s string, salt string, secret []byte) (uint32, int, error) {
+	if len(secret) > 0 {
+		keyId, err := KeyIDHMAC(s, secret)
+		return keyId, KeyIDVersionHMAC, err
+	}
+	keyId, err := KeyID(s, salt)
+	return keyId, KeyIDVersionMD5, err
+}
@@ -73,3 +73,37 @@ func TestKeyID(t *testing.T) {
 		})
 	}
 }
+
+func TestKeyIDHMAC(t *testing.T) {
+	secret := []byte("0123456789abcdef")
+	got, err := KeyIDHMAC("staging_ec2_660610034966_us-east-1", secret)
+	if err != nil {
+		t.Fatalf("KeyIDHMAC() error = %v", err)
+	}
+	if again, _ := KeyIDHMAC("staging_ec2_660610034966_us-east-1", secret); again != got {
+		t.Errorf("KeyIDHMAC() = %d then %d, want the same", got, again)
+	}
+	if other, _ := KeyIDHMAC("staging_ec2_660610034966_us-east-1", []byte("fedcba9876543210")); other == got {
+		t.Errorf("KeyIDHMAC() is the same with a different secret")
+	}
+	if legacy, _ := KeyID("staging_ec2_660610034966_us-east-1", string(secret)); legacy == got {
+		t.Errorf("KeyIDHMAC() is the same as KeyID()")
+	}
+	if _, err := KeyIDHMAC("staging", []byte("hUYh5x4N2DOnTIc")); err == nil {
+		t.Errorf("KeyIDHMAC() should fail with a short secret")
+	}
+	if _, err := KeyIDHMAC("", secret); err == nil {
+		t.Errorf("KeyIDHMAC() should fail for an empty string")
+	}
+}
+
+func TestDeploymentKeyID(t *testing.T) {
+	if keyId, version, err := DeploymentKeyID("test", "", nil); err != nil || keyId != 160394189 || version != KeyIDVersionMD5 {
+		t.Errorf("DeploymentKeyID() without a secret = %d, %d, %v", keyId, version, err)
+	}
+	secret := []byte("0123456789abcdef")
+	want, _ := KeyIDHMAC("test", secret)
+	if keyId, version, err := DeploymentKeyID("test", "", secret); err != nil || keyId != want || version != KeyIDVersionHMAC {
+		t.Errorf("DeploymentKeyID() with a secret = %d, %d, %v", keyId, version, err)
+	}
+}
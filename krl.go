@@ -0,0 +1,208 @@
+package accord
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mistsys/accord/db"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+)
+
+// The binary format is described in PROTOCOL.krl in openssh-portable
+// https://github.com/openssh/openssh-portable/blob/master/PROTOCOL.krl
+// only the parts we need are implemented, the KRL isn't signed since
+// it's delivered over the same TLS connection as the certs
+const (
+	krlMagic         uint64 = 0x5353484b524c0a00
+	krlFormatVersion uint32 = 1
+
+	krlSectionCertificates      byte = 1
+	krlSectionExplicitKey       byte = 2
+	krlSectionFingerprintSHA256 byte = 5
+
+	krlSectionCertSerialList byte = 0x20
+	krlSectionCertKeyId      byte = 0x23
+)
+
+// KRL is an OpenSSH Key Revocation List that sshd can use directly as
+// the RevokedKeys file
+type KRL struct {
+	Version       uint64
+	GeneratedDate time.Time
+	Comment       string
+	// the serials and key ids are revoked for the certs signed by each of these
+	CAKeys  []ssh.PublicKey
+	Serials []uint64
+	KeyIds  []string
+	// raw SHA256 hashes of the public key blobs
+	SHA256Hashes [][]byte
+	// these are revoked as keys, and for a CA every cert signed by it
+	Keys []ssh.PublicKey
+}
+
+// NewKRL compiles the revocations into a KRL, the cert revocations are listed
+// under every CA key given since the serials are unique across all of our CAs
+func NewKRL(revocations []*db.Revocation, version uint64, caKeys []ssh.PublicKey) (*KRL, error) {
+	krl := &KRL{
+		Version:       version,
+		GeneratedDate: time.Now(),
+		Comment:       "accord revoked keys version " + strconv.FormatUint(version, 10),
+		CAKeys:        caKeys,
+	}
+	for _, r := range revocations {
+		switch r.Type {
+		case db.RevokeSerial:
+			serial, err := strconv.ParseUint(r.Value, 10, 64)
+			if err != nil {
+				return nil, errors.Wrapf(err, "Invalid serial %s", r.Value)
+			}
+			krl.Serials = append(krl.Serials, serial)
+		case db.RevokeKeyId:
+			krl.KeyIds = append(krl.KeyIds, r.Value)
+		case db.RevokeFingerprint:
+			hash, err := base64.RawStdEncoding.DecodeString(strings.TrimPrefix(r.Value, "SHA256:"))
+			if err != nil || len(hash) != 32 {
+				return nil, errors.Errorf("Invalid SHA256 fingerprint %s", r.Value)
+			}
+			krl.SHA256Hashes = append(krl.SHA256Hashes, hash)
+		case db.RevokeCA:
+			key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(r.Value))
+			if err != nil {
+				return nil, errors.Wrapf(err, "Invalid CA public key %s", r.Value)
+			}
+			krl.Keys = append(krl.Keys, key)
+		}
+	}
+	return krl, nil
+}
+
+func writeUint32(b *bytes.Buffer, v uint32) {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], v)
+	b.Write(buf[:])
+}
+
+func writeUint64(b *bytes.Buffer, v uint64) {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], v)
+	b.Write(buf[:])
+}
+
+func writeString(b *bytes.Buffer, s []byte) {
+	writeUint32(b, uint32(len(s)))
+	b.Write(s)
+}
+
+// sshd wants the blobs in each section sorted and without duplicates
+// this is the same ordering it uses to compare them
+func sortBlobs(blobs [][]byte) [][]byte {
+	sort.Slice(blobs, func(i, j int) bool {
+		a, b := blobs[i], blobs[j]
+		if len(a) != len(b) {
+			n := len(a)
+			if len(b) < n {
+				n = len(b)
+			}
+			if c := bytes.Compare(a[:n], b[:n]); c != 0 {
+				return c < 0
+			}
+			return len(a) < len(b)
+		}
+		return bytes.Compare(a, b) < 0
+	})
+	unique := blobs[:0]
+	for i, blob := range blobs {
+		if i > 0 && bytes.Equal(blob, blobs[i-1]) {
+			continue
+		}
+		unique = append(unique, blob)
+	}
+	return unique
+}
+
+func (k *KRL) certSection(caKey ssh.PublicKey) []byte {
+	section := &bytes.Buffer{}
+	writeString(section, caKey.Marshal())
+	// reserved
+	writeString(section, nil)
+
+	if len(k.Serials) > 0 {
+		serials := append([]uint64{}, k.Serials...)
+		sort.Slice(serials, func(i, j int) bool { return serials[i] < serials[j] })
+		data := &bytes.Buffer{}
+		for i, serial := range serials {
+			if i > 0 && serial == serials[i-1] {
+				continue
+			}
+			writeUint64(data, serial)
+		}
+		section.WriteByte(krlSectionCertSerialList)
+		writeString(section, data.Bytes())
+	}
+
+	if len(k.KeyIds) > 0 {
+		keyIds := append([]string{}, k.KeyIds...)
+		sort.Strings(keyIds)
+		data := &bytes.Buffer{}
+		for i, keyId := range keyIds {
+			if i > 0 && keyId == keyIds[i-1] {
+				continue
+			}
+			writeString(data, []byte(keyId))
+		}
+		section.WriteByte(krlSectionCertKeyId)
+		writeString(section, data.Bytes())
+	}
+	return section.Bytes()
+}
+
+func blobsSection(blobs [][]byte) []byte {
+	data := &bytes.Buffer{}
+	for _, blob := range sortBlobs(blobs) {
+		writeString(data, blob)
+	}
+	return data.Bytes()
+}
+
+// Marshal returns the KRL in the binary format sshd and ssh-keygen -Q read
+func (k *KRL) Marshal() []byte {
+	b := &bytes.Buffer{}
+	writeUint64(b, krlMagic)
+	writeUint32(b, krlFormatVersion)
+	writeUint64(b, k.Version)
+	writeUint64(b, uint64(k.GeneratedDate.Unix()))
+	// flags
+	writeUint64(b, 0)
+	// reserved
+	writeString(b, nil)
+	writeString(b, []byte(k.Comment))
+
+	if len(k.Serials) > 0 || len(k.KeyIds) > 0 {
+		for _, caKey := range k.CAKeys {
+			b.WriteByte(krlSectionCertificates)
+			writeString(b, k.certSection(caKey))
+		}
+	}
+
+	if len(k.Keys) > 0 {
+		blobs := [][]byte{}
+		for _, key := range k.Keys {
+			blobs = append(blobs, key.Marshal())
+		}
+		b.WriteByte(krlSectionExplicitKey)
+		writeString(b, blobsSection(blobs))
+	}
+
+	if len(k.SHA256Hashes) > 0 {
+		hashes := append([][]byte{}, k.SHA256Hashes...)
+		b.WriteByte(krlSectionFingerprintSHA256)
+		writeString(b, blobsSection(hashes))
+	}
+	return b.Bytes()
+}
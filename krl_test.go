@@ -0,0 +1,92 @@
+package accord
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"testing"
+
+	"github.com/mistsys/accord/db"
+	"golang.org/x/crypto/ssh"
+)
+
+func readTestPublicKey(t *testing.T, path string) (ssh.PublicKey, []byte) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %s", path, err)
+	}
+	key, _, _, _, err := ssh.ParseAuthorizedKey(contents)
+	if err != nil {
+		t.Fatalf("Failed to parse %s: %s", path, err)
+	}
+	return key, contents
+}
+
+func TestNewKRL(t *testing.T) {
+	caKey, _ := readTestPublicKey(t, "test_assets/user_ca_20170927.pub")
+	hostKey, _ := readTestPublicKey(t, "test_assets/host_key.pub")
+	_, rootCA := readTestPublicKey(t, "test_assets/root_ca_20170927.pub")
+
+	tests := []struct {
+		name        string
+		revocations []*db.Revocation
+		wantErr     bool
+	}{
+		{
+			name: "all the revocation types compile",
+			revocations: []*db.Revocation{
+				{Type: db.RevokeSerial, Value: "5"},
+				{Type: db.RevokeSerial, Value: "3"},
+				{Type: db.RevokeKeyId, Value: "user1"},
+				{Type: db.RevokeFingerprint, Value: ssh.FingerprintSHA256(hostKey)},
+				{Type: db.RevokeCA, Value: string(rootCA), CAType: db.HostCertType},
+			},
+		},
+		{
+			name: "invalid serials are rejected",
+			revocations: []*db.Revocation{
+				{Type: db.RevokeSerial, Value: "five"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "MD5 fingerprints are rejected",
+			revocations: []*db.Revocation{
+				{Type: db.RevokeFingerprint, Value: ssh.FingerprintLegacyMD5(hostKey)},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			krl, err := NewKRL(tt.revocations, 3, []ssh.PublicKey{caKey})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewKRL() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+			b := krl.Marshal()
+			if binary.BigEndian.Uint64(b[:8]) != krlMagic {
+				t.Errorf("KRL.Marshal() doesn't start with the KRL magic")
+			}
+			if binary.BigEndian.Uint32(b[8:12]) != krlFormatVersion {
+				t.Errorf("KRL.Marshal() format version = %d", binary.BigEndian.Uint32(b[8:12]))
+			}
+			if binary.BigEndian.Uint64(b[12:20]) != 3 {
+				t.Errorf("KRL.Marshal() version = %d, want 3", binary.BigEndian.Uint64(b[12:20]))
+			}
+			// serials should be listed in order under the CA
+			serials := make([]byte, 16)
+			binary.BigEndian.PutUint64(serials, 3)
+			binary.BigEndian.PutUint64(serials[8:], 5)
+			if !bytes.Contains(b, serials) {
+				t.Errorf("KRL.Marshal() doesn't contain the sorted serials")
+			}
+			if !bytes.Contains(b, caKey.Marshal()) {
+				t.Errorf("KRL.Marshal() doesn't list the serials under the CA")
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package accord
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/mistsys/accord/db"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// the metrics are on /metrics of the status port
+
+var signingDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "accord",
+	Name:      "signing_duration_seconds",
+	Help:      "How long the CAs take to sign a cert, by the CA.",
+	Buckets:   prometheus.ExponentialBuckets(0.001, 2, 14),
+}, []string{"ca_type", "ca_id", "result"})
+
+var tokenValidationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "accord",
+	Name:      "google_token_validation_duration_seconds",
+	Help:      "How long validating the Google tokens takes.",
+	Buckets:   prometheus.ExponentialBuckets(0.01, 2, 12),
+}, []string{"result"})
+
+func init() {
+	prometheus.MustRegister(signingDuration, tokenValidationDuration)
+}
+
+func result(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
+
+func observeSigning(ca *caKey, start time.Time, err error) {
+	signingDuration.WithLabelValues(string(ca.Type), strconv.Itoa(ca.Metadata.Id), result(err)).Observe(time.Since(start).Seconds())
+}
+
+// ErrorType is what kind of error the RPCs failed with, so that the metrics
+// can tell the clients doing something wrong from the server being broken
+func ErrorType(err error) string {
+	if err == nil {
+		return ""
+	}
+	switch errors.Cause(err) {
+	case ErrNoSession, ErrInvalidSession, ErrSessionExpired,
+		db.ErrHostSessionNotFound, db.ErrHostSessionExpired, db.ErrHostSessionUsedUp, db.ErrHostSessionWrongKey, db.ErrHostSessionWrongHost,
+		ErrDecrypt, ErrKeyNotFound, ErrEnvelopeVersion:
+		return "unauthenticated"
+	case ErrClockSkew, ErrReplay, ErrReplayCacheFull:
+		return "replay"
+	case ErrNoPrincipalsGranted, ErrNoIdentity, ErrUnknownDeployment, ErrIdentityNotAllowed, ErrPrincipalNotAllowed:
+		return "denied"
+	case ErrNoPrincipals, ErrNoUserPrincipals, ErrInvalidStartTime, ErrEndBeforeStartTime,
+		ErrEmptyID, ErrValidityTooLong:
+		return "invalid"
+	case ErrNoActiveCA, ErrOutlivesCA:
+		return "ca"
+	case context.Canceled:
+		return "canceled"
+	case context.DeadlineExceeded:
+		return "deadline"
+	}
+	return "other"
+}
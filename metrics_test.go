@@ -0,0 +1,35 @@
+package accord
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mistsys/accord/db"
+	"github.com/pkg/errors"
+)
+
+func TestErrorType(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"no error", nil, ""},
+		{"expired session", ErrSessionExpired, "unauthenticated"},
+		{"wrapped host session", errors.Wrapf(db.ErrHostSessionUsedUp, "Failed to validate the host session"), "unauthenticated"},
+		{"replay", ErrReplay, "replay"},
+		{"nothing granted", errors.Wrapf(ErrNoPrincipalsGranted, "Failed authorization"), "denied"},
+		{"host policy", errors.Wrapf(ErrIdentityNotAllowed, "Host isn't allowed in the deployment"), "denied"},
+		{"too long", ErrValidityTooLong, "invalid"},
+		{"outlives the CA", errors.Wrapf(ErrOutlivesCA, "CA 1 is only valid until then"), "ca"},
+		{"deadline", errors.Wrapf(context.DeadlineExceeded, "Failed to validate token"), "deadline"},
+		{"anything else", errors.New("Failed to save the host session"), "other"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ErrorType(tt.err); got != tt.want {
+				t.Errorf("ErrorType() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
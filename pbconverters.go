@@ -1,13 +1,25 @@
 package accord
 
 import (
+	"time"
+
 	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/timestamp"
 	"github.com/mistsys/accord/protocol"
 )
 
+// caTimestamp leaves out the ValidUntil of the CAs that never expire
+func caTimestamp(t time.Time) *timestamp.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	ts, _ := ptypes.TimestampProto(t)
+	return ts
+}
+
 func ToHostCA(public CAPublic) *protocol.HostCA {
-	validFrom, _ := ptypes.TimestampProto(public.ValidFrom)
-	validUntil, _ := ptypes.TimestampProto(public.ValidUntil)
+	validFrom := caTimestamp(public.ValidFrom)
+	validUntil := caTimestamp(public.ValidUntil)
 	return &protocol.HostCA{
 		Id:         uint64(public.Id),
 		PublicKey:  public.PublicKey,
@@ -17,8 +29,8 @@ func ToHostCA(public CAPublic) *protocol.HostCA {
 }
 
 func ToUserCA(public CAPublic) *protocol.UserCA {
-	validFrom, _ := ptypes.TimestampProto(public.ValidFrom)
-	validUntil, _ := ptypes.TimestampProto(public.ValidUntil)
+	validFrom := caTimestamp(public.ValidFrom)
+	validUntil := caTimestamp(public.ValidUntil)
 	return &protocol.UserCA{
 		Id:         uint64(public.Id),
 		PublicKey:  public.PublicKey,
@@ -0,0 +1,255 @@
+package accord
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+)
+
+// the extensions sshd understands for user certs, see CERTIFICATES in ssh-keygen(1)
+var knownExtensions = []string{
+	"permit-X11-forwarding",
+	"permit-agent-forwarding",
+	"permit-port-forwarding",
+	"permit-pty",
+	"permit-user-rc",
+}
+
+// what the certs got before they could be restricted, and what ssh-keygen gives by default
+var defaultExtensions = []string{
+	"permit-X11-forwarding",
+	"permit-agent-forwarding",
+	"permit-pty",
+	"permit-user-rc",
+}
+
+// CertPermissions are the restrictions for a user cert, they become the
+// force-command and source-address critical options and the extensions,
+// and limit how long the cert can be valid for
+type CertPermissions struct {
+	// the only command that can be run with the cert, empty for any command
+	ForceCommand string `json:"force_command,omitempty" yaml:"force_command"`
+	// addresses or CIDRs the cert can be used from, empty for anywhere
+	SourceAddresses []string `json:"source_addresses,omitempty" yaml:"source_addresses"`
+	// nil means the default extensions, an empty list means none at all
+	Extensions []string `json:"extensions" yaml:"extensions"`
+	// the longest the cert can be valid for, 0 for up to MaxCertValidity
+	MaxValidity time.Duration `json:"max_validity,omitempty" yaml:"max_validity"`
+}
+
+// DefaultPermissions has no critical options and the same extensions ssh-keygen uses
+func DefaultPermissions() CertPermissions {
+	return CertPermissions{}
+}
+
+func (p CertPermissions) extensions() []string {
+	if p.Extensions == nil {
+		return defaultExtensions
+	}
+	return p.Extensions
+}
+
+// sshd accepts bare addresses too, they're turned into a single address CIDR
+// so that they can be compared
+func parseSourceAddress(address string) (*net.IPNet, error) {
+	if !strings.Contains(address, "/") {
+		ip := net.ParseIP(address)
+		if ip == nil {
+			return nil, fmt.Errorf("Invalid source address %s", address)
+		}
+		bits := 8 * net.IPv6len
+		if ip.To4() != nil {
+			ip = ip.To4()
+			bits = 8 * net.IPv4len
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, network, err := net.ParseCIDR(address)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid source address %s", address)
+	}
+	return network, nil
+}
+
+// within checks that the network is entirely inside one of the others
+func within(network *net.IPNet, others []*net.IPNet) bool {
+	ones, bits := network.Mask.Size()
+	for _, other := range others {
+		otherOnes, otherBits := other.Mask.Size()
+		if bits == otherBits && otherOnes <= ones && other.Contains(network.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseSourceAddresses(addresses []string) ([]*net.IPNet, error) {
+	networks := []*net.IPNet{}
+	for _, address := range addresses {
+		network, err := parseSourceAddress(address)
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// Validate checks that sshd will understand the permissions
+func (p CertPermissions) Validate() error {
+	if strings.ContainsAny(p.ForceCommand, "\n\x00") {
+		return errors.New("The force command can't have newlines")
+	}
+	if _, err := parseSourceAddresses(p.SourceAddresses); err != nil {
+		return err
+	}
+	for _, extension := range p.Extensions {
+		if !contains(extension, knownExtensions) {
+			return fmt.Errorf("Unknown extension %s", extension)
+		}
+	}
+	// a number without a unit is read as nanoseconds
+	if p.MaxValidity < 0 || (p.MaxValidity > 0 && p.MaxValidity < time.Minute) {
+		return fmt.Errorf("Invalid max validity %s, it needs a unit like 8h", p.MaxValidity)
+	}
+	return nil
+}
+
+// CheckValidity checks that a cert valid for the duration is allowed
+func (p CertPermissions) CheckValidity(validity time.Duration) error {
+	if p.MaxValidity > 0 && validity > p.MaxValidity {
+		return fmt.Errorf("The cert can be valid for at most %s, asked for %s", p.MaxValidity, validity)
+	}
+	return nil
+}
+
+// Intersect returns the permissions allowed by both, it's used to combine the
+// restrictions from different parts of the policy. Two different force
+// commands can't both be honored so that's an error
+func (p CertPermissions) Intersect(other CertPermissions) (CertPermissions, error) {
+	result := CertPermissions{MaxValidity: p.MaxValidity}
+	if other.MaxValidity > 0 && (p.MaxValidity == 0 || other.MaxValidity < p.MaxValidity) {
+		result.MaxValidity = other.MaxValidity
+	}
+	switch {
+	case p.ForceCommand == "" || other.ForceCommand == "":
+		result.ForceCommand = p.ForceCommand + other.ForceCommand
+	case p.ForceCommand == other.ForceCommand:
+		result.ForceCommand = p.ForceCommand
+	default:
+		return result, fmt.Errorf("Conflicting force commands %q and %q", p.ForceCommand, other.ForceCommand)
+	}
+
+	ours, err := parseSourceAddresses(p.SourceAddresses)
+	if err != nil {
+		return result, err
+	}
+	theirs, err := parseSourceAddresses(other.SourceAddresses)
+	if err != nil {
+		return result, err
+	}
+	switch {
+	case len(ours) == 0:
+		result.SourceAddresses = other.SourceAddresses
+	case len(theirs) == 0:
+		result.SourceAddresses = p.SourceAddresses
+	default:
+		// keep the narrower networks from each side, that's exact as long as
+		// the networks either nest or don't overlap, which CIDRs always do
+		for i, network := range ours {
+			if within(network, theirs) {
+				result.SourceAddresses = append(result.SourceAddresses, p.SourceAddresses[i])
+			}
+		}
+		for i, network := range theirs {
+			if within(network, ours) && !within(network, result.networks()) {
+				result.SourceAddresses = append(result.SourceAddresses, other.SourceAddresses[i])
+			}
+		}
+		if len(result.SourceAddresses) == 0 {
+			return result, fmt.Errorf("No source address is allowed by both %v and %v", p.SourceAddresses, other.SourceAddresses)
+		}
+	}
+
+	if p.Extensions == nil && other.Extensions == nil {
+		return result, nil
+	}
+	result.Extensions = []string{}
+	for _, extension := range p.extensions() {
+		if contains(extension, other.extensions()) {
+			result.Extensions = append(result.Extensions, extension)
+		}
+	}
+	return result, nil
+}
+
+func (p CertPermissions) networks() []*net.IPNet {
+	networks, _ := parseSourceAddresses(p.SourceAddresses)
+	return networks
+}
+
+// Narrow applies what the client asked for on top of what it's allowed, the
+// client can only give up permissions. Asking for more than allowed is an error
+// instead of silently getting less so that automation notices
+func (p CertPermissions) Narrow(requested CertPermissions) (CertPermissions, error) {
+	if err := requested.Validate(); err != nil {
+		return p, errors.Wrapf(err, "Invalid permissions requested")
+	}
+	if p.ForceCommand != "" && requested.ForceCommand != "" && requested.ForceCommand != p.ForceCommand {
+		return p, fmt.Errorf("Only the command %q is allowed", p.ForceCommand)
+	}
+	if len(p.SourceAddresses) > 0 {
+		allowed := p.networks()
+		for _, address := range requested.SourceAddresses {
+			network, _ := parseSourceAddress(address)
+			if !within(network, allowed) {
+				return p, fmt.Errorf("Source address %s isn't within %v", address, p.SourceAddresses)
+			}
+		}
+	}
+	for _, extension := range requested.Extensions {
+		if !contains(extension, p.extensions()) {
+			return p, fmt.Errorf("Extension %s isn't allowed", extension)
+		}
+	}
+	return p.Intersect(requested)
+}
+
+// SSHPermissions are the critical options and extensions to put in the cert
+func (p CertPermissions) SSHPermissions() ssh.Permissions {
+	permissions := ssh.Permissions{
+		CriticalOptions: map[string]string{},
+		Extensions:      map[string]string{},
+	}
+	if p.ForceCommand != "" {
+		permissions.CriticalOptions["force-command"] = p.ForceCommand
+	}
+	if len(p.SourceAddresses) > 0 {
+		permissions.CriticalOptions["source-address"] = strings.Join(p.SourceAddresses, ",")
+	}
+	for _, extension := range p.extensions() {
+		permissions.Extensions[extension] = ""
+	}
+	return permissions
+}
+
+// PermissionsFromCert reads the restrictions back from a signed cert
+func PermissionsFromCert(cert *ssh.Certificate) CertPermissions {
+	p := CertPermissions{
+		ForceCommand: cert.CriticalOptions["force-command"],
+		Extensions:   []string{},
+	}
+	if addresses := cert.CriticalOptions["source-address"]; addresses != "" {
+		p.SourceAddresses = strings.Split(addresses, ",")
+	}
+	for extension := range cert.Extensions {
+		p.Extensions = append(p.Extensions, extension)
+	}
+	sort.Strings(p.Extensions)
+	return p
+}
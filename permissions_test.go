@@ -0,0 +1,139 @@
+package accord
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCertPermissions_Narrow(t *testing.T) {
+	automation := CertPermissions{
+		ForceCommand:    "/usr/local/bin/backup",
+		SourceAddresses: []string{"10.0.0.0/8"},
+		Extensions:      []string{},
+	}
+	tests := []struct {
+		name      string
+		allowed   CertPermissions
+		requested CertPermissions
+		want      CertPermissions
+		wantErr   bool
+	}{
+		{
+			name:      "nothing requested gets what's allowed",
+			allowed:   automation,
+			requested: CertPermissions{},
+			want:      automation,
+		},
+		{
+			name:      "narrower source address",
+			allowed:   automation,
+			requested: CertPermissions{SourceAddresses: []string{"10.1.2.0/24", "10.2.3.4"}},
+			want: CertPermissions{
+				ForceCommand:    "/usr/local/bin/backup",
+				SourceAddresses: []string{"10.1.2.0/24", "10.2.3.4"},
+				Extensions:      []string{},
+			},
+		},
+		{
+			name:      "wider source address",
+			allowed:   automation,
+			requested: CertPermissions{SourceAddresses: []string{"0.0.0.0/0"}},
+			wantErr:   true,
+		},
+		{
+			name:      "a different command",
+			allowed:   automation,
+			requested: CertPermissions{ForceCommand: "/bin/sh"},
+			wantErr:   true,
+		},
+		{
+			name:      "force command when anything is allowed",
+			allowed:   DefaultPermissions(),
+			requested: CertPermissions{ForceCommand: "uptime"},
+			want:      CertPermissions{ForceCommand: "uptime"},
+		},
+		{
+			name:      "max validity is kept",
+			allowed:   CertPermissions{MaxValidity: 8 * time.Hour},
+			requested: CertPermissions{ForceCommand: "uptime"},
+			want:      CertPermissions{ForceCommand: "uptime", MaxValidity: 8 * time.Hour},
+		},
+		{
+			name:      "fewer extensions",
+			allowed:   DefaultPermissions(),
+			requested: CertPermissions{Extensions: []string{"permit-pty"}},
+			want:      CertPermissions{Extensions: []string{"permit-pty"}},
+		},
+		{
+			name:      "extensions that aren't allowed",
+			allowed:   DefaultPermissions(),
+			requested: CertPermissions{Extensions: []string{"permit-port-forwarding"}},
+			wantErr:   true,
+		},
+		{
+			name:      "unknown extensions",
+			allowed:   DefaultPermissions(),
+			requested: CertPermissions{Extensions: []string{"permit-everything"}},
+			wantErr:   true,
+		},
+		{
+			name:      "invalid source address",
+			allowed:   DefaultPermissions(),
+			requested: CertPermissions{SourceAddresses: []string{"10.0.0.300"}},
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.allowed.Narrow(tt.requested)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CertPermissions.Narrow() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CertPermissions.Narrow() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCertPermissions_SSHPermissions(t *testing.T) {
+	tests := []struct {
+		name        string
+		permissions CertPermissions
+		wantOptions map[string]string
+		wantExts    int
+	}{
+		{
+			name:        "defaults match ssh-keygen",
+			permissions: DefaultPermissions(),
+			wantOptions: map[string]string{},
+			wantExts:    4,
+		},
+		{
+			name: "critical options",
+			permissions: CertPermissions{
+				ForceCommand:    "uptime",
+				SourceAddresses: []string{"10.0.0.0/8", "192.168.1.1"},
+				Extensions:      []string{},
+			},
+			wantOptions: map[string]string{
+				"force-command":  "uptime",
+				"source-address": "10.0.0.0/8,192.168.1.1",
+			},
+			wantExts: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.permissions.SSHPermissions()
+			if !reflect.DeepEqual(got.CriticalOptions, tt.wantOptions) {
+				t.Errorf("CertPermissions.SSHPermissions() options = %v, want %v", got.CriticalOptions, tt.wantOptions)
+			}
+			if len(got.Extensions) != tt.wantExts {
+				t.Errorf("CertPermissions.SSHPermissions() has %d extensions, want %d", len(got.Extensions), tt.wantExts)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package accord
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// decodePolicy reads a policy file in YAML or JSON. Unknown keys are errors
+// with the line they're on, a typo in a policy shouldn't quietly grant or
+// restrict less than intended
+func decodePolicy(content []byte, out interface{}, what string) error {
+	if trimmed := bytes.TrimSpace(content); len(trimmed) > 0 && trimmed[0] == '{' {
+		// YAML takes almost any JSON, including what JSON itself doesn't
+		// like trailing commas, so JSON files are checked as JSON first
+		var v interface{}
+		if err := json.Unmarshal(content, &v); err != nil {
+			if syntaxErr, ok := err.(*json.SyntaxError); ok {
+				line := bytes.Count(content[:syntaxErr.Offset], []byte("\n")) + 1
+				return fmt.Errorf("Failed to parse json for %s, line %d: %s", what, line, err)
+			}
+			return errors.Wrapf(err, "Failed to parse json for %s", what)
+		}
+	}
+	if err := yaml.UnmarshalStrict(content, out); err != nil {
+		return errors.Wrapf(err, "Failed to parse %s", what)
+	}
+	return nil
+}
+
+// principals in the policies can be patterns like deploy-*, matched the same
+// way as path.Match. The principals in a cert never are
+const patternChars = `*?[\`
+
+func validPattern(pattern string) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("Invalid pattern %s", pattern)
+	}
+	return nil
+}
+
+func validPatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if err := validPattern(pattern); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func matches(name string, pattern string) bool {
+	matched, _ := path.Match(pattern, name)
+	return matched
+}
+
+func matchesAny(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matches(name, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+func isPattern(name string) bool {
+	return strings.ContainsAny(name, patternChars)
+}
@@ -13,6 +13,7 @@ It has these top-level messages:
 	PingResponse
 	HostAuthRequest
 	Error
+	HostnamePolicy
 	HostAuth
 	ReplyMetadata
 	HostAuthResponse
@@ -23,10 +24,45 @@ It has these top-level messages:
 	UserCertRequest
 	OauthToken
 	UserCertResponse
+	DeniedPrincipal
 	HostCA
 	UserCA
 	PublicTrustedCARequest
 	PublicTrustedCAResponse
+	RevokedKeysRequest
+	RevokedKeysResponse
+	Elevation
+	ElevationRequest
+	ElevationDecision
+	ElevationResponse
+	ElevationListRequest
+	ElevationListResponse
+	IssuedCert
+	CertListRequest
+	CertListResponse
+	AdminRevokeRequest
+	AdminRevokeResponse
+	AdminUser
+	AdminListUsersRequest
+	AdminListUsersResponse
+	AdminDisableUserRequest
+	AdminEnableUserRequest
+	AdminUserResponse
+	AdminPSKVersion
+	AdminDeployment
+	AdminListDeploymentsRequest
+	AdminListDeploymentsResponse
+	AdminRegisterDeploymentRequest
+	AdminRotatePSKRequest
+	AdminRetirePSKRequest
+	AdminMigrateKeyIDRequest
+	AdminRetireLegacyKeyIDRequest
+	AdminDeploymentResponse
+	AdminReloadRequest
+	AdminReloadResponse
+	AdminCA
+	AdminListCAsRequest
+	AdminListCAsResponse
 */
 package protocol
 
@@ -154,16 +190,52 @@ func (m *Error) GetMsg() string {
 	return ""
 }
 
+// which principals the server signs host certs for in the deployment
+type HostnamePolicy struct {
+	// reject or strip the principals that aren't allowed, empty when they aren't checked
+	Principals string `protobuf:"bytes,1,opt,name=principals" json:"principals,omitempty"`
+	// shell patterns the principals can match, on top of the host's own names from its identity
+	HostnamePatterns []string `protobuf:"bytes,2,rep,name=hostnamePatterns" json:"hostnamePatterns,omitempty"`
+}
+
+func (m *HostnamePolicy) Reset()                    { *m = HostnamePolicy{} }
+func (m *HostnamePolicy) String() string            { return proto.CompactTextString(m) }
+func (*HostnamePolicy) ProtoMessage()               {}
+func (*HostnamePolicy) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+
+func (m *HostnamePolicy) GetPrincipals() string {
+	if m != nil {
+		return m.Principals
+	}
+	return ""
+}
+
+func (m *HostnamePolicy) GetHostnamePatterns() []string {
+	if m != nil {
+		return m.HostnamePatterns
+	}
+	return nil
+}
+
 // this is the protobuf message to decrypt the HostAuthResponse.authResponse bytes to
 type HostAuth struct {
+	// empty when there are errors
 	Id     []byte   `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
 	Errors []*Error `protobuf:"bytes,2,rep,name=errors" json:"errors,omitempty"`
+	// the id can't be used for host certs after this
+	ExpiresAt *google_protobuf.Timestamp `protobuf:"bytes,3,opt,name=expiresAt" json:"expiresAt,omitempty"`
+	// how many host certs can be requested with the id
+	UsesLeft int32 `protobuf:"varint,4,opt,name=usesLeft" json:"usesLeft,omitempty"`
+	// not set when the server doesn't have a policy for the deployment
+	HostnamePolicy *HostnamePolicy `protobuf:"bytes,5,opt,name=hostnamePolicy" json:"hostnamePolicy,omitempty"`
+	// the longest the server signs host certs for right now
+	MaxCertValiditySeconds int64 `protobuf:"varint,6,opt,name=maxCertValiditySeconds" json:"maxCertValiditySeconds,omitempty"`
 }
 
 func (m *HostAuth) Reset()                    { *m = HostAuth{} }
 func (m *HostAuth) String() string            { return proto.CompactTextString(m) }
 func (*HostAuth) ProtoMessage()               {}
-func (*HostAuth) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+func (*HostAuth) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
 
 func (m *HostAuth) GetId() []byte {
 	if m != nil {
@@ -179,17 +251,47 @@ func (m *HostAuth) GetErrors() []*Error {
 	return nil
 }
 
+func (m *HostAuth) GetExpiresAt() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.ExpiresAt
+	}
+	return nil
+}
+
+func (m *HostAuth) GetUsesLeft() int32 {
+	if m != nil {
+		return m.UsesLeft
+	}
+	return 0
+}
+
+func (m *HostAuth) GetHostnamePolicy() *HostnamePolicy {
+	if m != nil {
+		return m.HostnamePolicy
+	}
+	return nil
+}
+
+func (m *HostAuth) GetMaxCertValiditySeconds() int64 {
+	if m != nil {
+		return m.MaxCertValiditySeconds
+	}
+	return 0
+}
+
 type ReplyMetadata struct {
 	// copies the request time from the client
 	RequestTime *google_protobuf.Timestamp `protobuf:"bytes,1,opt,name=requestTime" json:"requestTime,omitempty"`
 	// when the server initiated the response
 	ResponseTime *google_protobuf.Timestamp `protobuf:"bytes,2,opt,name=responseTime" json:"responseTime,omitempty"`
+	// the client's x-request-id if it sent one, the server's otherwise
+	RequestId string `protobuf:"bytes,3,opt,name=requestId" json:"requestId,omitempty"`
 }
 
 func (m *ReplyMetadata) Reset()                    { *m = ReplyMetadata{} }
 func (m *ReplyMetadata) String() string            { return proto.CompactTextString(m) }
 func (*ReplyMetadata) ProtoMessage()               {}
-func (*ReplyMetadata) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+func (*ReplyMetadata) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
 
 func (m *ReplyMetadata) GetRequestTime() *google_protobuf.Timestamp {
 	if m != nil {
@@ -205,6 +307,13 @@ func (m *ReplyMetadata) GetResponseTime() *google_protobuf.Timestamp {
 	return nil
 }
 
+func (m *ReplyMetadata) GetRequestId() string {
+	if m != nil {
+		return m.RequestId
+	}
+	return ""
+}
+
 type HostAuthResponse struct {
 	Metadata *ReplyMetadata `protobuf:"bytes,1,opt,name=metadata" json:"metadata,omitempty"`
 	// this should be the encrypted response based on the key sent
@@ -216,7 +325,7 @@ type HostAuthResponse struct {
 func (m *HostAuthResponse) Reset()                    { *m = HostAuthResponse{} }
 func (m *HostAuthResponse) String() string            { return proto.CompactTextString(m) }
 func (*HostAuthResponse) ProtoMessage()               {}
-func (*HostAuthResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
+func (*HostAuthResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
 
 func (m *HostAuthResponse) GetMetadata() *ReplyMetadata {
 	if m != nil {
@@ -248,12 +357,15 @@ type HostCertRequest struct {
 	PublicKey []byte   `protobuf:"bytes,6,opt,name=publicKey,proto3" json:"publicKey,omitempty"`
 	// Send the HostMetadata after the Authentication step
 	HostMetadata []byte `protobuf:"bytes,7,opt,name=hostMetadata,proto3" json:"hostMetadata,omitempty"`
+	// the id of the PSK the host authenticated with, the id above
+	// is only accepted from the same deployment it was issued to
+	KeyId uint32 `protobuf:"varint,8,opt,name=keyId" json:"keyId,omitempty"`
 }
 
 func (m *HostCertRequest) Reset()                    { *m = HostCertRequest{} }
 func (m *HostCertRequest) String() string            { return proto.CompactTextString(m) }
 func (*HostCertRequest) ProtoMessage()               {}
-func (*HostCertRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+func (*HostCertRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
 
 func (m *HostCertRequest) GetRequestTime() *google_protobuf.Timestamp {
 	if m != nil {
@@ -304,6 +416,13 @@ func (m *HostCertRequest) GetHostMetadata() []byte {
 	return nil
 }
 
+func (m *HostCertRequest) GetKeyId() uint32 {
+	if m != nil {
+		return m.KeyId
+	}
+	return 0
+}
+
 type HostCertResponse struct {
 	Metadata *ReplyMetadata `protobuf:"bytes,1,opt,name=metadata" json:"metadata,omitempty"`
 	// this is the cert for the host
@@ -311,12 +430,14 @@ type HostCertResponse struct {
 	// this is the cert the host should "trust" for users
 	// logging into the machines
 	TrustedUsersCACert []byte `protobuf:"bytes,4,opt,name=trustedUsersCACert,proto3" json:"trustedUsersCACert,omitempty"`
+	// the hostnames that were left out of the cert by the host policy
+	DroppedHostnames []string `protobuf:"bytes,5,rep,name=droppedHostnames" json:"droppedHostnames,omitempty"`
 }
 
 func (m *HostCertResponse) Reset()                    { *m = HostCertResponse{} }
 func (m *HostCertResponse) String() string            { return proto.CompactTextString(m) }
 func (*HostCertResponse) ProtoMessage()               {}
-func (*HostCertResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
+func (*HostCertResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
 
 func (m *HostCertResponse) GetMetadata() *ReplyMetadata {
 	if m != nil {
@@ -339,6 +460,13 @@ func (m *HostCertResponse) GetTrustedUsersCACert() []byte {
 	return nil
 }
 
+func (m *HostCertResponse) GetDroppedHostnames() []string {
+	if m != nil {
+		return m.DroppedHostnames
+	}
+	return nil
+}
+
 type UserAuthRequest struct {
 	RequestTime *google_protobuf.Timestamp `protobuf:"bytes,1,opt,name=requestTime" json:"requestTime,omitempty"`
 	Username    string                     `protobuf:"bytes,2,opt,name=username" json:"username,omitempty"`
@@ -349,7 +477,7 @@ type UserAuthRequest struct {
 func (m *UserAuthRequest) Reset()                    { *m = UserAuthRequest{} }
 func (m *UserAuthRequest) String() string            { return proto.CompactTextString(m) }
 func (*UserAuthRequest) ProtoMessage()               {}
-func (*UserAuthRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
+func (*UserAuthRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{10} }
 
 func (m *UserAuthRequest) GetRequestTime() *google_protobuf.Timestamp {
 	if m != nil {
@@ -377,14 +505,16 @@ type UserAuthResponse struct {
 	Username string         `protobuf:"bytes,2,opt,name=username" json:"username,omitempty"`
 	UserId   string         `protobuf:"bytes,3,opt,name=userId" json:"userId,omitempty"`
 	Valid    bool           `protobuf:"varint,4,opt,name=valid" json:"valid,omitempty"`
-	// something cryptographic -- haven't implemented yet
-	AuthResponse []byte `protobuf:"bytes,5,opt,name=authResponse,proto3" json:"authResponse,omitempty"`
+	// the session signed by the server, it has to be sent with
+	// the UserCertRequest, the server only trusts the userId in it
+	AuthResponse  []byte                     `protobuf:"bytes,5,opt,name=authResponse,proto3" json:"authResponse,omitempty"`
+	SessionExpiry *google_protobuf.Timestamp `protobuf:"bytes,6,opt,name=sessionExpiry" json:"sessionExpiry,omitempty"`
 }
 
 func (m *UserAuthResponse) Reset()                    { *m = UserAuthResponse{} }
 func (m *UserAuthResponse) String() string            { return proto.CompactTextString(m) }
 func (*UserAuthResponse) ProtoMessage()               {}
-func (*UserAuthResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{10} }
+func (*UserAuthResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11} }
 
 func (m *UserAuthResponse) GetMetadata() *ReplyMetadata {
 	if m != nil {
@@ -421,6 +551,13 @@ func (m *UserAuthResponse) GetAuthResponse() []byte {
 	return nil
 }
 
+func (m *UserAuthResponse) GetSessionExpiry() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.SessionExpiry
+	}
+	return nil
+}
+
 type UserCertRequest struct {
 	RequestTime *google_protobuf.Timestamp `protobuf:"bytes,1,opt,name=requestTime" json:"requestTime,omitempty"`
 	UserId      string                     `protobuf:"bytes,2,opt,name=userId" json:"userId,omitempty"`
@@ -441,13 +578,22 @@ type UserCertRequest struct {
 	ValidUntil           *google_protobuf.Timestamp `protobuf:"bytes,8,opt,name=validUntil" json:"validUntil,omitempty"`
 	AuthorizedPrincipals []string                   `protobuf:"bytes,9,rep,name=authorizedPrincipals" json:"authorizedPrincipals,omitempty"`
 	// this should be used for scripts to limit access
+	// sshd only honors one, so more than one is rejected
 	ForceCommands []string `protobuf:"bytes,10,rep,name=forceCommands" json:"forceCommands,omitempty"`
+	// addresses or CIDRs the cert can be used from
+	SourceAddresses []string `protobuf:"bytes,11,rep,name=sourceAddresses" json:"sourceAddresses,omitempty"`
+	// the permit-* extensions, only looked at when restrictExtensions
+	// is set so that it's possible to ask for none
+	Extensions         []string `protobuf:"bytes,12,rep,name=extensions" json:"extensions,omitempty"`
+	RestrictExtensions bool     `protobuf:"varint,13,opt,name=restrictExtensions" json:"restrictExtensions,omitempty"`
+	// the authResponse from UserAuth
+	Session []byte `protobuf:"bytes,14,opt,name=session,proto3" json:"session,omitempty"`
 }
 
 func (m *UserCertRequest) Reset()                    { *m = UserCertRequest{} }
 func (m *UserCertRequest) String() string            { return proto.CompactTextString(m) }
 func (*UserCertRequest) ProtoMessage()               {}
-func (*UserCertRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11} }
+func (*UserCertRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{12} }
 
 func (m *UserCertRequest) GetRequestTime() *google_protobuf.Timestamp {
 	if m != nil {
@@ -519,6 +665,34 @@ func (m *UserCertRequest) GetForceCommands() []string {
 	return nil
 }
 
+func (m *UserCertRequest) GetSourceAddresses() []string {
+	if m != nil {
+		return m.SourceAddresses
+	}
+	return nil
+}
+
+func (m *UserCertRequest) GetExtensions() []string {
+	if m != nil {
+		return m.Extensions
+	}
+	return nil
+}
+
+func (m *UserCertRequest) GetRestrictExtensions() bool {
+	if m != nil {
+		return m.RestrictExtensions
+	}
+	return false
+}
+
+func (m *UserCertRequest) GetSession() []byte {
+	if m != nil {
+		return m.Session
+	}
+	return nil
+}
+
 type OauthToken struct {
 	AccessToken  string                     `protobuf:"bytes,1,opt,name=accessToken" json:"accessToken,omitempty"`
 	TokenType    string                     `protobuf:"bytes,2,opt,name=tokenType" json:"tokenType,omitempty"`
@@ -529,7 +703,7 @@ type OauthToken struct {
 func (m *OauthToken) Reset()                    { *m = OauthToken{} }
 func (m *OauthToken) String() string            { return proto.CompactTextString(m) }
 func (*OauthToken) ProtoMessage()               {}
-func (*OauthToken) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{12} }
+func (*OauthToken) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{13} }
 
 func (m *OauthToken) GetAccessToken() string {
 	if m != nil {
@@ -564,12 +738,15 @@ type UserCertResponse struct {
 	Username       string         `protobuf:"bytes,2,opt,name=username" json:"username,omitempty"`
 	UserCert       []byte         `protobuf:"bytes,3,opt,name=userCert,proto3" json:"userCert,omitempty"`
 	TrustedHostCAs []*HostCA      `protobuf:"bytes,4,rep,name=trustedHostCAs" json:"trustedHostCAs,omitempty"`
+	// the principals in the cert, and the requested ones that aren't
+	GrantedPrincipals []string           `protobuf:"bytes,5,rep,name=grantedPrincipals" json:"grantedPrincipals,omitempty"`
+	DeniedPrincipals  []*DeniedPrincipal `protobuf:"bytes,6,rep,name=deniedPrincipals" json:"deniedPrincipals,omitempty"`
 }
 
 func (m *UserCertResponse) Reset()                    { *m = UserCertResponse{} }
 func (m *UserCertResponse) String() string            { return proto.CompactTextString(m) }
 func (*UserCertResponse) ProtoMessage()               {}
-func (*UserCertResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{13} }
+func (*UserCertResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{14} }
 
 func (m *UserCertResponse) GetMetadata() *ReplyMetadata {
 	if m != nil {
@@ -599,6 +776,44 @@ func (m *UserCertResponse) GetTrustedHostCAs() []*HostCA {
 	return nil
 }
 
+func (m *UserCertResponse) GetGrantedPrincipals() []string {
+	if m != nil {
+		return m.GrantedPrincipals
+	}
+	return nil
+}
+
+func (m *UserCertResponse) GetDeniedPrincipals() []*DeniedPrincipal {
+	if m != nil {
+		return m.DeniedPrincipals
+	}
+	return nil
+}
+
+type DeniedPrincipal struct {
+	Principal string `protobuf:"bytes,1,opt,name=principal" json:"principal,omitempty"`
+	Reason    string `protobuf:"bytes,2,opt,name=reason" json:"reason,omitempty"`
+}
+
+func (m *DeniedPrincipal) Reset()                    { *m = DeniedPrincipal{} }
+func (m *DeniedPrincipal) String() string            { return proto.CompactTextString(m) }
+func (*DeniedPrincipal) ProtoMessage()               {}
+func (*DeniedPrincipal) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{15} }
+
+func (m *DeniedPrincipal) GetPrincipal() string {
+	if m != nil {
+		return m.Principal
+	}
+	return ""
+}
+
+func (m *DeniedPrincipal) GetReason() string {
+	if m != nil {
+		return m.Reason
+	}
+	return ""
+}
+
 // Public Host Certificate Authority's Public Key
 // and additional information
 // at any given time more than one public CA might
@@ -614,7 +829,7 @@ type HostCA struct {
 func (m *HostCA) Reset()                    { *m = HostCA{} }
 func (m *HostCA) String() string            { return proto.CompactTextString(m) }
 func (*HostCA) ProtoMessage()               {}
-func (*HostCA) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{14} }
+func (*HostCA) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{16} }
 
 func (m *HostCA) GetValidFrom() *google_protobuf.Timestamp {
 	if m != nil {
@@ -654,7 +869,7 @@ type UserCA struct {
 func (m *UserCA) Reset()                    { *m = UserCA{} }
 func (m *UserCA) String() string            { return proto.CompactTextString(m) }
 func (*UserCA) ProtoMessage()               {}
-func (*UserCA) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{15} }
+func (*UserCA) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{17} }
 
 func (m *UserCA) GetValidFrom() *google_protobuf.Timestamp {
 	if m != nil {
@@ -692,7 +907,7 @@ type PublicTrustedCARequest struct {
 func (m *PublicTrustedCARequest) Reset()                    { *m = PublicTrustedCARequest{} }
 func (m *PublicTrustedCARequest) String() string            { return proto.CompactTextString(m) }
 func (*PublicTrustedCARequest) ProtoMessage()               {}
-func (*PublicTrustedCARequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{16} }
+func (*PublicTrustedCARequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{18} }
 
 func (m *PublicTrustedCARequest) GetRequestTime() *google_protobuf.Timestamp {
 	if m != nil {
@@ -714,7 +929,7 @@ type PublicTrustedCAResponse struct {
 func (m *PublicTrustedCAResponse) Reset()                    { *m = PublicTrustedCAResponse{} }
 func (m *PublicTrustedCAResponse) String() string            { return proto.CompactTextString(m) }
 func (*PublicTrustedCAResponse) ProtoMessage()               {}
-func (*PublicTrustedCAResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{17} }
+func (*PublicTrustedCAResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{19} }
 
 func (m *PublicTrustedCAResponse) GetMetadata() *ReplyMetadata {
 	if m != nil {
@@ -751,268 +966,2125 @@ func (m *PublicTrustedCAResponse) GetRevokedUserCAs() []*UserCA {
 	return nil
 }
 
-func init() {
-	proto.RegisterType((*PingRequest)(nil), "protocol.PingRequest")
-	proto.RegisterType((*PingResponse)(nil), "protocol.PingResponse")
-	proto.RegisterType((*HostAuthRequest)(nil), "protocol.HostAuthRequest")
-	proto.RegisterType((*Error)(nil), "protocol.Error")
-	proto.RegisterType((*HostAuth)(nil), "protocol.HostAuth")
-	proto.RegisterType((*ReplyMetadata)(nil), "protocol.ReplyMetadata")
-	proto.RegisterType((*HostAuthResponse)(nil), "protocol.HostAuthResponse")
-	proto.RegisterType((*HostCertRequest)(nil), "protocol.HostCertRequest")
-	proto.RegisterType((*HostCertResponse)(nil), "protocol.HostCertResponse")
-	proto.RegisterType((*UserAuthRequest)(nil), "protocol.UserAuthRequest")
-	proto.RegisterType((*UserAuthResponse)(nil), "protocol.UserAuthResponse")
-	proto.RegisterType((*UserCertRequest)(nil), "protocol.UserCertRequest")
-	proto.RegisterType((*OauthToken)(nil), "protocol.OauthToken")
-	proto.RegisterType((*UserCertResponse)(nil), "protocol.UserCertResponse")
-	proto.RegisterType((*HostCA)(nil), "protocol.HostCA")
-	proto.RegisterType((*UserCA)(nil), "protocol.UserCA")
-	proto.RegisterType((*PublicTrustedCARequest)(nil), "protocol.PublicTrustedCARequest")
-	proto.RegisterType((*PublicTrustedCAResponse)(nil), "protocol.PublicTrustedCAResponse")
+type RevokedKeysRequest struct {
+	RequestTime *google_protobuf.Timestamp `protobuf:"bytes,1,opt,name=requestTime" json:"requestTime,omitempty"`
 }
 
-// Reference imports to suppress errors if they are not otherwise used.
-var _ context.Context
-var _ grpc.ClientConn
-
-// This is a compile-time assertion to ensure that this generated file
-// is compatible with the grpc package it is being compiled against.
-const _ = grpc.SupportPackageIsVersion4
-
-// Client API for Cert service
+func (m *RevokedKeysRequest) Reset()                    { *m = RevokedKeysRequest{} }
+func (m *RevokedKeysRequest) String() string            { return proto.CompactTextString(m) }
+func (*RevokedKeysRequest) ProtoMessage()               {}
+func (*RevokedKeysRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{20} }
 
-type CertClient interface {
-	HostAuth(ctx context.Context, in *HostAuthRequest, opts ...grpc.CallOption) (*HostAuthResponse, error)
-	HostCert(ctx context.Context, in *HostCertRequest, opts ...grpc.CallOption) (*HostCertResponse, error)
-	UserAuth(ctx context.Context, in *UserAuthRequest, opts ...grpc.CallOption) (*UserAuthResponse, error)
-	UserCert(ctx context.Context, in *UserCertRequest, opts ...grpc.CallOption) (*UserCertResponse, error)
-	// This responds back with both host CAs that the users should trust
-	// and the user CA the servers should trust
-	PublicTrustedCA(ctx context.Context, in *PublicTrustedCARequest, opts ...grpc.CallOption) (*PublicTrustedCAResponse, error)
-	// this is just for test/sanity
-	// We may report he metric to get a sense of how the latency between
-	// environments is faring
-	Ping(ctx context.Context, in *PingRequest, opts ...grpc.CallOption) (*PingResponse, error)
+func (m *RevokedKeysRequest) GetRequestTime() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.RequestTime
+	}
+	return nil
 }
 
-type certClient struct {
-	cc *grpc.ClientConn
+type RevokedKeysResponse struct {
+	Metadata *ReplyMetadata `protobuf:"bytes,1,opt,name=metadata" json:"metadata,omitempty"`
+	// increases every time something new is revoked
+	Version uint64 `protobuf:"varint,2,opt,name=version" json:"version,omitempty"`
+	// the binary KRL, as read by sshd and ssh-keygen -Q
+	Krl []byte `protobuf:"bytes,3,opt,name=krl,proto3" json:"krl,omitempty"`
 }
 
-func NewCertClient(cc *grpc.ClientConn) CertClient {
-	return &certClient{cc}
-}
+func (m *RevokedKeysResponse) Reset()                    { *m = RevokedKeysResponse{} }
+func (m *RevokedKeysResponse) String() string            { return proto.CompactTextString(m) }
+func (*RevokedKeysResponse) ProtoMessage()               {}
+func (*RevokedKeysResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{21} }
 
-func (c *certClient) HostAuth(ctx context.Context, in *HostAuthRequest, opts ...grpc.CallOption) (*HostAuthResponse, error) {
-	out := new(HostAuthResponse)
-	err := grpc.Invoke(ctx, "/protocol.Cert/HostAuth", in, out, c.cc, opts...)
-	if err != nil {
-		return nil, err
+func (m *RevokedKeysResponse) GetMetadata() *ReplyMetadata {
+	if m != nil {
+		return m.Metadata
 	}
-	return out, nil
+	return nil
 }
 
-func (c *certClient) HostCert(ctx context.Context, in *HostCertRequest, opts ...grpc.CallOption) (*HostCertResponse, error) {
-	out := new(HostCertResponse)
-	err := grpc.Invoke(ctx, "/protocol.Cert/HostCert", in, out, c.cc, opts...)
-	if err != nil {
-		return nil, err
+func (m *RevokedKeysResponse) GetVersion() uint64 {
+	if m != nil {
+		return m.Version
 	}
-	return out, nil
+	return 0
 }
 
-func (c *certClient) UserAuth(ctx context.Context, in *UserAuthRequest, opts ...grpc.CallOption) (*UserAuthResponse, error) {
-	out := new(UserAuthResponse)
-	err := grpc.Invoke(ctx, "/protocol.Cert/UserAuth", in, out, c.cc, opts...)
-	if err != nil {
-		return nil, err
+func (m *RevokedKeysResponse) GetKrl() []byte {
+	if m != nil {
+		return m.Krl
 	}
-	return out, nil
+	return nil
 }
 
-func (c *certClient) UserCert(ctx context.Context, in *UserCertRequest, opts ...grpc.CallOption) (*UserCertResponse, error) {
-	out := new(UserCertResponse)
-	err := grpc.Invoke(ctx, "/protocol.Cert/UserCert", in, out, c.cc, opts...)
-	if err != nil {
-		return nil, err
-	}
-	return out, nil
+type Elevation struct {
+	Id            string   `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+	UserId        string   `protobuf:"bytes,2,opt,name=userId" json:"userId,omitempty"`
+	Principals    []string `protobuf:"bytes,3,rep,name=principals" json:"principals,omitempty"`
+	Justification string   `protobuf:"bytes,4,opt,name=justification" json:"justification,omitempty"`
+	// how long it lasts once approved
+	DurationSeconds int64 `protobuf:"varint,5,opt,name=durationSeconds" json:"durationSeconds,omitempty"`
+	// pending, approved, denied or expired
+	State       string                     `protobuf:"bytes,6,opt,name=state" json:"state,omitempty"`
+	RequestedAt *google_protobuf.Timestamp `protobuf:"bytes,7,opt,name=requestedAt" json:"requestedAt,omitempty"`
+	DecidedBy   string                     `protobuf:"bytes,8,opt,name=decidedBy" json:"decidedBy,omitempty"`
+	Reason      string                     `protobuf:"bytes,9,opt,name=reason" json:"reason,omitempty"`
+	ExpiresAt   *google_protobuf.Timestamp `protobuf:"bytes,10,opt,name=expiresAt" json:"expiresAt,omitempty"`
 }
 
-func (c *certClient) PublicTrustedCA(ctx context.Context, in *PublicTrustedCARequest, opts ...grpc.CallOption) (*PublicTrustedCAResponse, error) {
-	out := new(PublicTrustedCAResponse)
-	err := grpc.Invoke(ctx, "/protocol.Cert/PublicTrustedCA", in, out, c.cc, opts...)
-	if err != nil {
-		return nil, err
+func (m *Elevation) Reset()                    { *m = Elevation{} }
+func (m *Elevation) String() string            { return proto.CompactTextString(m) }
+func (*Elevation) ProtoMessage()               {}
+func (*Elevation) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{22} }
+
+func (m *Elevation) GetId() string {
+	if m != nil {
+		return m.Id
 	}
-	return out, nil
+	return ""
 }
 
-func (c *certClient) Ping(ctx context.Context, in *PingRequest, opts ...grpc.CallOption) (*PingResponse, error) {
-	out := new(PingResponse)
-	err := grpc.Invoke(ctx, "/protocol.Cert/Ping", in, out, c.cc, opts...)
-	if err != nil {
-		return nil, err
+func (m *Elevation) GetUserId() string {
+	if m != nil {
+		return m.UserId
 	}
-	return out, nil
+	return ""
 }
 
-// Server API for Cert service
+func (m *Elevation) GetPrincipals() []string {
+	if m != nil {
+		return m.Principals
+	}
+	return nil
+}
 
-type CertServer interface {
-	HostAuth(context.Context, *HostAuthRequest) (*HostAuthResponse, error)
-	HostCert(context.Context, *HostCertRequest) (*HostCertResponse, error)
-	UserAuth(context.Context, *UserAuthRequest) (*UserAuthResponse, error)
-	UserCert(context.Context, *UserCertRequest) (*UserCertResponse, error)
-	// This responds back with both host CAs that the users should trust
-	// and the user CA the servers should trust
-	PublicTrustedCA(context.Context, *PublicTrustedCARequest) (*PublicTrustedCAResponse, error)
-	// this is just for test/sanity
-	// We may report he metric to get a sense of how the latency between
-	// environments is faring
-	Ping(context.Context, *PingRequest) (*PingResponse, error)
+func (m *Elevation) GetJustification() string {
+	if m != nil {
+		return m.Justification
+	}
+	return ""
 }
 
-func RegisterCertServer(s *grpc.Server, srv CertServer) {
-	s.RegisterService(&_Cert_serviceDesc, srv)
+func (m *Elevation) GetDurationSeconds() int64 {
+	if m != nil {
+		return m.DurationSeconds
+	}
+	return 0
 }
 
-func _Cert_HostAuth_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(HostAuthRequest)
-	if err := dec(in); err != nil {
-		return nil, err
+func (m *Elevation) GetState() string {
+	if m != nil {
+		return m.State
 	}
-	if interceptor == nil {
-		return srv.(CertServer).HostAuth(ctx, in)
+	return ""
+}
+
+func (m *Elevation) GetRequestedAt() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.RequestedAt
 	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: "/protocol.Cert/HostAuth",
+	return nil
+}
+
+func (m *Elevation) GetDecidedBy() string {
+	if m != nil {
+		return m.DecidedBy
 	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(CertServer).HostAuth(ctx, req.(*HostAuthRequest))
+	return ""
+}
+
+func (m *Elevation) GetReason() string {
+	if m != nil {
+		return m.Reason
 	}
-	return interceptor(ctx, in, info, handler)
+	return ""
 }
 
-func _Cert_HostCert_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(HostCertRequest)
-	if err := dec(in); err != nil {
-		return nil, err
+func (m *Elevation) GetExpiresAt() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.ExpiresAt
 	}
-	if interceptor == nil {
-		return srv.(CertServer).HostCert(ctx, in)
+	return nil
+}
+
+type ElevationRequest struct {
+	RequestTime *google_protobuf.Timestamp `protobuf:"bytes,1,opt,name=requestTime" json:"requestTime,omitempty"`
+	// the authResponse from UserAuth
+	Session       []byte   `protobuf:"bytes,2,opt,name=session,proto3" json:"session,omitempty"`
+	Principals    []string `protobuf:"bytes,3,rep,name=principals" json:"principals,omitempty"`
+	Justification string   `protobuf:"bytes,4,opt,name=justification" json:"justification,omitempty"`
+	// 0 for the longest the policy allows
+	DurationSeconds int64 `protobuf:"varint,5,opt,name=durationSeconds" json:"durationSeconds,omitempty"`
+}
+
+func (m *ElevationRequest) Reset()                    { *m = ElevationRequest{} }
+func (m *ElevationRequest) String() string            { return proto.CompactTextString(m) }
+func (*ElevationRequest) ProtoMessage()               {}
+func (*ElevationRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{23} }
+
+func (m *ElevationRequest) GetRequestTime() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.RequestTime
+	}
+	return nil
+}
+
+func (m *ElevationRequest) GetSession() []byte {
+	if m != nil {
+		return m.Session
+	}
+	return nil
+}
+
+func (m *ElevationRequest) GetPrincipals() []string {
+	if m != nil {
+		return m.Principals
+	}
+	return nil
+}
+
+func (m *ElevationRequest) GetJustification() string {
+	if m != nil {
+		return m.Justification
+	}
+	return ""
+}
+
+func (m *ElevationRequest) GetDurationSeconds() int64 {
+	if m != nil {
+		return m.DurationSeconds
+	}
+	return 0
+}
+
+type ElevationDecision struct {
+	RequestTime *google_protobuf.Timestamp `protobuf:"bytes,1,opt,name=requestTime" json:"requestTime,omitempty"`
+	Session     []byte                     `protobuf:"bytes,2,opt,name=session,proto3" json:"session,omitempty"`
+	Id          string                     `protobuf:"bytes,3,opt,name=id" json:"id,omitempty"`
+	Approve     bool                       `protobuf:"varint,4,opt,name=approve" json:"approve,omitempty"`
+	Reason      string                     `protobuf:"bytes,5,opt,name=reason" json:"reason,omitempty"`
+}
+
+func (m *ElevationDecision) Reset()                    { *m = ElevationDecision{} }
+func (m *ElevationDecision) String() string            { return proto.CompactTextString(m) }
+func (*ElevationDecision) ProtoMessage()               {}
+func (*ElevationDecision) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{24} }
+
+func (m *ElevationDecision) GetRequestTime() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.RequestTime
+	}
+	return nil
+}
+
+func (m *ElevationDecision) GetSession() []byte {
+	if m != nil {
+		return m.Session
+	}
+	return nil
+}
+
+func (m *ElevationDecision) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *ElevationDecision) GetApprove() bool {
+	if m != nil {
+		return m.Approve
+	}
+	return false
+}
+
+func (m *ElevationDecision) GetReason() string {
+	if m != nil {
+		return m.Reason
+	}
+	return ""
+}
+
+type ElevationResponse struct {
+	Metadata  *ReplyMetadata `protobuf:"bytes,1,opt,name=metadata" json:"metadata,omitempty"`
+	Elevation *Elevation     `protobuf:"bytes,2,opt,name=elevation" json:"elevation,omitempty"`
+}
+
+func (m *ElevationResponse) Reset()                    { *m = ElevationResponse{} }
+func (m *ElevationResponse) String() string            { return proto.CompactTextString(m) }
+func (*ElevationResponse) ProtoMessage()               {}
+func (*ElevationResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{25} }
+
+func (m *ElevationResponse) GetMetadata() *ReplyMetadata {
+	if m != nil {
+		return m.Metadata
+	}
+	return nil
+}
+
+func (m *ElevationResponse) GetElevation() *Elevation {
+	if m != nil {
+		return m.Elevation
+	}
+	return nil
+}
+
+type ElevationListRequest struct {
+	RequestTime *google_protobuf.Timestamp `protobuf:"bytes,1,opt,name=requestTime" json:"requestTime,omitempty"`
+	Session     []byte                     `protobuf:"bytes,2,opt,name=session,proto3" json:"session,omitempty"`
+}
+
+func (m *ElevationListRequest) Reset()                    { *m = ElevationListRequest{} }
+func (m *ElevationListRequest) String() string            { return proto.CompactTextString(m) }
+func (*ElevationListRequest) ProtoMessage()               {}
+func (*ElevationListRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{26} }
+
+func (m *ElevationListRequest) GetRequestTime() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.RequestTime
+	}
+	return nil
+}
+
+func (m *ElevationListRequest) GetSession() []byte {
+	if m != nil {
+		return m.Session
+	}
+	return nil
+}
+
+type ElevationListResponse struct {
+	Metadata   *ReplyMetadata `protobuf:"bytes,1,opt,name=metadata" json:"metadata,omitempty"`
+	Elevations []*Elevation   `protobuf:"bytes,2,rep,name=elevations" json:"elevations,omitempty"`
+}
+
+func (m *ElevationListResponse) Reset()                    { *m = ElevationListResponse{} }
+func (m *ElevationListResponse) String() string            { return proto.CompactTextString(m) }
+func (*ElevationListResponse) ProtoMessage()               {}
+func (*ElevationListResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{27} }
+
+func (m *ElevationListResponse) GetMetadata() *ReplyMetadata {
+	if m != nil {
+		return m.Metadata
+	}
+	return nil
+}
+
+func (m *ElevationListResponse) GetElevations() []*Elevation {
+	if m != nil {
+		return m.Elevations
+	}
+	return nil
+}
+
+type IssuedCert struct {
+	Serial uint64 `protobuf:"varint,1,opt,name=serial" json:"serial,omitempty"`
+	// user or host
+	CertType    string                     `protobuf:"bytes,2,opt,name=certType" json:"certType,omitempty"`
+	KeyId       string                     `protobuf:"bytes,3,opt,name=keyId" json:"keyId,omitempty"`
+	Fingerprint string                     `protobuf:"bytes,4,opt,name=fingerprint" json:"fingerprint,omitempty"`
+	Principals  []string                   `protobuf:"bytes,5,rep,name=principals" json:"principals,omitempty"`
+	ValidFrom   *google_protobuf.Timestamp `protobuf:"bytes,6,opt,name=validFrom" json:"validFrom,omitempty"`
+	ValidUntil  *google_protobuf.Timestamp `protobuf:"bytes,7,opt,name=validUntil" json:"validUntil,omitempty"`
+	IssuedAt    *google_protobuf.Timestamp `protobuf:"bytes,8,opt,name=issuedAt" json:"issuedAt,omitempty"`
+	Requester   string                     `protobuf:"bytes,9,opt,name=requester" json:"requester,omitempty"`
+	// only for the host certs
+	Deployment string `protobuf:"bytes,10,opt,name=deployment" json:"deployment,omitempty"`
+	// only for the user certs
+	Email string `protobuf:"bytes,11,opt,name=email" json:"email,omitempty"`
+}
+
+func (m *IssuedCert) Reset()                    { *m = IssuedCert{} }
+func (m *IssuedCert) String() string            { return proto.CompactTextString(m) }
+func (*IssuedCert) ProtoMessage()               {}
+func (*IssuedCert) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{28} }
+
+func (m *IssuedCert) GetSerial() uint64 {
+	if m != nil {
+		return m.Serial
+	}
+	return 0
+}
+
+func (m *IssuedCert) GetCertType() string {
+	if m != nil {
+		return m.CertType
+	}
+	return ""
+}
+
+func (m *IssuedCert) GetKeyId() string {
+	if m != nil {
+		return m.KeyId
+	}
+	return ""
+}
+
+func (m *IssuedCert) GetFingerprint() string {
+	if m != nil {
+		return m.Fingerprint
+	}
+	return ""
+}
+
+func (m *IssuedCert) GetPrincipals() []string {
+	if m != nil {
+		return m.Principals
+	}
+	return nil
+}
+
+func (m *IssuedCert) GetValidFrom() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.ValidFrom
+	}
+	return nil
+}
+
+func (m *IssuedCert) GetValidUntil() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.ValidUntil
+	}
+	return nil
+}
+
+func (m *IssuedCert) GetIssuedAt() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.IssuedAt
+	}
+	return nil
+}
+
+func (m *IssuedCert) GetRequester() string {
+	if m != nil {
+		return m.Requester
+	}
+	return ""
+}
+
+func (m *IssuedCert) GetDeployment() string {
+	if m != nil {
+		return m.Deployment
+	}
+	return ""
+}
+
+func (m *IssuedCert) GetEmail() string {
+	if m != nil {
+		return m.Email
+	}
+	return ""
+}
+
+type CertListRequest struct {
+	RequestTime *google_protobuf.Timestamp `protobuf:"bytes,1,opt,name=requestTime" json:"requestTime,omitempty"`
+	Session     []byte                     `protobuf:"bytes,2,opt,name=session,proto3" json:"session,omitempty"`
+	// the empty fields match everything
+	CertType string `protobuf:"bytes,3,opt,name=certType" json:"certType,omitempty"`
+	Email    string `protobuf:"bytes,4,opt,name=email" json:"email,omitempty"`
+	// hostname and principal can be patterns like web-*
+	Hostname      string                     `protobuf:"bytes,5,opt,name=hostname" json:"hostname,omitempty"`
+	Deployment    string                     `protobuf:"bytes,6,opt,name=deployment" json:"deployment,omitempty"`
+	Principal     string                     `protobuf:"bytes,7,opt,name=principal" json:"principal,omitempty"`
+	Serial        uint64                     `protobuf:"varint,8,opt,name=serial" json:"serial,omitempty"`
+	ExpiresAfter  *google_protobuf.Timestamp `protobuf:"bytes,9,opt,name=expiresAfter" json:"expiresAfter,omitempty"`
+	ExpiresBefore *google_protobuf.Timestamp `protobuf:"bytes,10,opt,name=expiresBefore" json:"expiresBefore,omitempty"`
+	// leave out the certs that were renewed
+	Latest bool `protobuf:"varint,11,opt,name=latest" json:"latest,omitempty"`
+	// 0 for the default of 100, at most 1000
+	PageSize int32 `protobuf:"varint,12,opt,name=pageSize" json:"pageSize,omitempty"`
+	// the nextPageToken of the previous page
+	PageToken string `protobuf:"bytes,13,opt,name=pageToken" json:"pageToken,omitempty"`
+}
+
+func (m *CertListRequest) Reset()                    { *m = CertListRequest{} }
+func (m *CertListRequest) String() string            { return proto.CompactTextString(m) }
+func (*CertListRequest) ProtoMessage()               {}
+func (*CertListRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{29} }
+
+func (m *CertListRequest) GetRequestTime() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.RequestTime
+	}
+	return nil
+}
+
+func (m *CertListRequest) GetSession() []byte {
+	if m != nil {
+		return m.Session
+	}
+	return nil
+}
+
+func (m *CertListRequest) GetCertType() string {
+	if m != nil {
+		return m.CertType
+	}
+	return ""
+}
+
+func (m *CertListRequest) GetEmail() string {
+	if m != nil {
+		return m.Email
+	}
+	return ""
+}
+
+func (m *CertListRequest) GetHostname() string {
+	if m != nil {
+		return m.Hostname
+	}
+	return ""
+}
+
+func (m *CertListRequest) GetDeployment() string {
+	if m != nil {
+		return m.Deployment
+	}
+	return ""
+}
+
+func (m *CertListRequest) GetPrincipal() string {
+	if m != nil {
+		return m.Principal
+	}
+	return ""
+}
+
+func (m *CertListRequest) GetSerial() uint64 {
+	if m != nil {
+		return m.Serial
+	}
+	return 0
+}
+
+func (m *CertListRequest) GetExpiresAfter() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.ExpiresAfter
+	}
+	return nil
+}
+
+func (m *CertListRequest) GetExpiresBefore() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.ExpiresBefore
+	}
+	return nil
+}
+
+func (m *CertListRequest) GetLatest() bool {
+	if m != nil {
+		return m.Latest
+	}
+	return false
+}
+
+func (m *CertListRequest) GetPageSize() int32 {
+	if m != nil {
+		return m.PageSize
+	}
+	return 0
+}
+
+func (m *CertListRequest) GetPageToken() string {
+	if m != nil {
+		return m.PageToken
+	}
+	return ""
+}
+
+type CertListResponse struct {
+	Metadata *ReplyMetadata `protobuf:"bytes,1,opt,name=metadata" json:"metadata,omitempty"`
+	Certs    []*IssuedCert  `protobuf:"bytes,2,rep,name=certs" json:"certs,omitempty"`
+	// empty on the last page
+	NextPageToken string `protobuf:"bytes,3,opt,name=nextPageToken" json:"nextPageToken,omitempty"`
+}
+
+func (m *CertListResponse) Reset()                    { *m = CertListResponse{} }
+func (m *CertListResponse) String() string            { return proto.CompactTextString(m) }
+func (*CertListResponse) ProtoMessage()               {}
+func (*CertListResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{30} }
+
+func (m *CertListResponse) GetMetadata() *ReplyMetadata {
+	if m != nil {
+		return m.Metadata
+	}
+	return nil
+}
+
+func (m *CertListResponse) GetCerts() []*IssuedCert {
+	if m != nil {
+		return m.Certs
+	}
+	return nil
+}
+
+func (m *CertListResponse) GetNextPageToken() string {
+	if m != nil {
+		return m.NextPageToken
+	}
+	return ""
+}
+
+type AdminRevokeRequest struct {
+	// serial, key_id, fingerprint or ca
+	Type  string `protobuf:"bytes,1,opt,name=type" json:"type,omitempty"`
+	Value string `protobuf:"bytes,2,opt,name=value" json:"value,omitempty"`
+	// only for ca, user or host
+	CaType string `protobuf:"bytes,3,opt,name=caType" json:"caType,omitempty"`
+	Reason string `protobuf:"bytes,4,opt,name=reason" json:"reason,omitempty"`
+}
+
+func (m *AdminRevokeRequest) Reset()                    { *m = AdminRevokeRequest{} }
+func (m *AdminRevokeRequest) String() string            { return proto.CompactTextString(m) }
+func (*AdminRevokeRequest) ProtoMessage()               {}
+func (*AdminRevokeRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{31} }
+
+func (m *AdminRevokeRequest) GetType() string {
+	if m != nil {
+		return m.Type
+	}
+	return ""
+}
+
+func (m *AdminRevokeRequest) GetValue() string {
+	if m != nil {
+		return m.Value
+	}
+	return ""
+}
+
+func (m *AdminRevokeRequest) GetCaType() string {
+	if m != nil {
+		return m.CaType
+	}
+	return ""
+}
+
+func (m *AdminRevokeRequest) GetReason() string {
+	if m != nil {
+		return m.Reason
+	}
+	return ""
+}
+
+type AdminRevokeResponse struct {
+	// of the revocation list, it increases with everything new revoked
+	Version uint64 `protobuf:"varint,1,opt,name=version" json:"version,omitempty"`
+}
+
+func (m *AdminRevokeResponse) Reset()                    { *m = AdminRevokeResponse{} }
+func (m *AdminRevokeResponse) String() string            { return proto.CompactTextString(m) }
+func (*AdminRevokeResponse) ProtoMessage()               {}
+func (*AdminRevokeResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{32} }
+
+func (m *AdminRevokeResponse) GetVersion() uint64 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+type AdminUser struct {
+	Email string `protobuf:"bytes,1,opt,name=email" json:"email,omitempty"`
+	// how many user certs they got
+	Certs          uint32                     `protobuf:"varint,2,opt,name=certs" json:"certs,omitempty"`
+	LastIssuedAt   *google_protobuf.Timestamp `protobuf:"bytes,3,opt,name=lastIssuedAt" json:"lastIssuedAt,omitempty"`
+	LastValidUntil *google_protobuf.Timestamp `protobuf:"bytes,4,opt,name=lastValidUntil" json:"lastValidUntil,omitempty"`
+	Disabled       bool                       `protobuf:"varint,5,opt,name=disabled" json:"disabled,omitempty"`
+	DisabledReason string                     `protobuf:"bytes,6,opt,name=disabledReason" json:"disabledReason,omitempty"`
+	DisabledBy     string                     `protobuf:"bytes,7,opt,name=disabledBy" json:"disabledBy,omitempty"`
+	DisabledAt     *google_protobuf.Timestamp `protobuf:"bytes,8,opt,name=disabledAt" json:"disabledAt,omitempty"`
+}
+
+func (m *AdminUser) Reset()                    { *m = AdminUser{} }
+func (m *AdminUser) String() string            { return proto.CompactTextString(m) }
+func (*AdminUser) ProtoMessage()               {}
+func (*AdminUser) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{33} }
+
+func (m *AdminUser) GetEmail() string {
+	if m != nil {
+		return m.Email
+	}
+	return ""
+}
+
+func (m *AdminUser) GetCerts() uint32 {
+	if m != nil {
+		return m.Certs
+	}
+	return 0
+}
+
+func (m *AdminUser) GetLastIssuedAt() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.LastIssuedAt
+	}
+	return nil
+}
+
+func (m *AdminUser) GetLastValidUntil() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.LastValidUntil
+	}
+	return nil
+}
+
+func (m *AdminUser) GetDisabled() bool {
+	if m != nil {
+		return m.Disabled
+	}
+	return false
+}
+
+func (m *AdminUser) GetDisabledReason() string {
+	if m != nil {
+		return m.DisabledReason
+	}
+	return ""
+}
+
+func (m *AdminUser) GetDisabledBy() string {
+	if m != nil {
+		return m.DisabledBy
+	}
+	return ""
+}
+
+func (m *AdminUser) GetDisabledAt() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.DisabledAt
+	}
+	return nil
+}
+
+type AdminListUsersRequest struct {
+}
+
+func (m *AdminListUsersRequest) Reset()                    { *m = AdminListUsersRequest{} }
+func (m *AdminListUsersRequest) String() string            { return proto.CompactTextString(m) }
+func (*AdminListUsersRequest) ProtoMessage()               {}
+func (*AdminListUsersRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{34} }
+
+type AdminListUsersResponse struct {
+	Users []*AdminUser `protobuf:"bytes,1,rep,name=users" json:"users,omitempty"`
+}
+
+func (m *AdminListUsersResponse) Reset()                    { *m = AdminListUsersResponse{} }
+func (m *AdminListUsersResponse) String() string            { return proto.CompactTextString(m) }
+func (*AdminListUsersResponse) ProtoMessage()               {}
+func (*AdminListUsersResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{35} }
+
+func (m *AdminListUsersResponse) GetUsers() []*AdminUser {
+	if m != nil {
+		return m.Users
+	}
+	return nil
+}
+
+type AdminDisableUserRequest struct {
+	Email  string `protobuf:"bytes,1,opt,name=email" json:"email,omitempty"`
+	Reason string `protobuf:"bytes,2,opt,name=reason" json:"reason,omitempty"`
+	// also revoke the user certs they have that are still valid
+	Revoke bool `protobuf:"varint,3,opt,name=revoke" json:"revoke,omitempty"`
+}
+
+func (m *AdminDisableUserRequest) Reset()                    { *m = AdminDisableUserRequest{} }
+func (m *AdminDisableUserRequest) String() string            { return proto.CompactTextString(m) }
+func (*AdminDisableUserRequest) ProtoMessage()               {}
+func (*AdminDisableUserRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{36} }
+
+func (m *AdminDisableUserRequest) GetEmail() string {
+	if m != nil {
+		return m.Email
+	}
+	return ""
+}
+
+func (m *AdminDisableUserRequest) GetReason() string {
+	if m != nil {
+		return m.Reason
+	}
+	return ""
+}
+
+func (m *AdminDisableUserRequest) GetRevoke() bool {
+	if m != nil {
+		return m.Revoke
+	}
+	return false
+}
+
+type AdminEnableUserRequest struct {
+	Email string `protobuf:"bytes,1,opt,name=email" json:"email,omitempty"`
+}
+
+func (m *AdminEnableUserRequest) Reset()                    { *m = AdminEnableUserRequest{} }
+func (m *AdminEnableUserRequest) String() string            { return proto.CompactTextString(m) }
+func (*AdminEnableUserRequest) ProtoMessage()               {}
+func (*AdminEnableUserRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{37} }
+
+func (m *AdminEnableUserRequest) GetEmail() string {
+	if m != nil {
+		return m.Email
+	}
+	return ""
+}
+
+type AdminUserResponse struct {
+	User *AdminUser `protobuf:"bytes,1,opt,name=user" json:"user,omitempty"`
+	// the serials revoked with the user
+	Revoked []uint64 `protobuf:"varint,2,rep,packed,name=revoked" json:"revoked,omitempty"`
+}
+
+func (m *AdminUserResponse) Reset()                    { *m = AdminUserResponse{} }
+func (m *AdminUserResponse) String() string            { return proto.CompactTextString(m) }
+func (*AdminUserResponse) ProtoMessage()               {}
+func (*AdminUserResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{38} }
+
+func (m *AdminUserResponse) GetUser() *AdminUser {
+	if m != nil {
+		return m.User
+	}
+	return nil
+}
+
+func (m *AdminUserResponse) GetRevoked() []uint64 {
+	if m != nil {
+		return m.Revoked
+	}
+	return nil
+}
+
+type AdminPSKVersion struct {
+	Version uint32 `protobuf:"varint,1,opt,name=version" json:"version,omitempty"`
+	// active, accept-only or retired
+	State     string                     `protobuf:"bytes,2,opt,name=state" json:"state,omitempty"`
+	CreatedAt *google_protobuf.Timestamp `protobuf:"bytes,3,opt,name=createdAt" json:"createdAt,omitempty"`
+}
+
+func (m *AdminPSKVersion) Reset()                    { *m = AdminPSKVersion{} }
+func (m *AdminPSKVersion) String() string            { return proto.CompactTextString(m) }
+func (*AdminPSKVersion) ProtoMessage()               {}
+func (*AdminPSKVersion) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{39} }
+
+func (m *AdminPSKVersion) GetVersion() uint32 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+func (m *AdminPSKVersion) GetState() string {
+	if m != nil {
+		return m.State
+	}
+	return ""
+}
+
+func (m *AdminPSKVersion) GetCreatedAt() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.CreatedAt
+	}
+	return nil
+}
+
+type AdminDeployment struct {
+	Name         string             `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	KeyId        uint32             `protobuf:"varint,2,opt,name=keyId" json:"keyId,omitempty"`
+	KeyIdVersion int32              `protobuf:"varint,3,opt,name=keyIdVersion" json:"keyIdVersion,omitempty"`
+	LegacyKeyId  uint32             `protobuf:"varint,4,opt,name=legacyKeyId" json:"legacyKeyId,omitempty"`
+	Psks         []*AdminPSKVersion `protobuf:"bytes,5,rep,name=psks" json:"psks,omitempty"`
+}
+
+func (m *AdminDeployment) Reset()                    { *m = AdminDeployment{} }
+func (m *AdminDeployment) String() string            { return proto.CompactTextString(m) }
+func (*AdminDeployment) ProtoMessage()               {}
+func (*AdminDeployment) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{40} }
+
+func (m *AdminDeployment) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *AdminDeployment) GetKeyId() uint32 {
+	if m != nil {
+		return m.KeyId
+	}
+	return 0
+}
+
+func (m *AdminDeployment) GetKeyIdVersion() int32 {
+	if m != nil {
+		return m.KeyIdVersion
+	}
+	return 0
+}
+
+func (m *AdminDeployment) GetLegacyKeyId() uint32 {
+	if m != nil {
+		return m.LegacyKeyId
+	}
+	return 0
+}
+
+func (m *AdminDeployment) GetPsks() []*AdminPSKVersion {
+	if m != nil {
+		return m.Psks
+	}
+	return nil
+}
+
+type AdminListDeploymentsRequest struct {
+}
+
+func (m *AdminListDeploymentsRequest) Reset()                    { *m = AdminListDeploymentsRequest{} }
+func (m *AdminListDeploymentsRequest) String() string            { return proto.CompactTextString(m) }
+func (*AdminListDeploymentsRequest) ProtoMessage()               {}
+func (*AdminListDeploymentsRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{41} }
+
+type AdminListDeploymentsResponse struct {
+	Deployments []*AdminDeployment `protobuf:"bytes,1,rep,name=deployments" json:"deployments,omitempty"`
+}
+
+func (m *AdminListDeploymentsResponse) Reset()                    { *m = AdminListDeploymentsResponse{} }
+func (m *AdminListDeploymentsResponse) String() string            { return proto.CompactTextString(m) }
+func (*AdminListDeploymentsResponse) ProtoMessage()               {}
+func (*AdminListDeploymentsResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{42} }
+
+func (m *AdminListDeploymentsResponse) GetDeployments() []*AdminDeployment {
+	if m != nil {
+		return m.Deployments
+	}
+	return nil
+}
+
+type AdminRegisterDeploymentRequest struct {
+	Name string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	// the HMAC key id, the secret for it stays with the operators
+	KeyId uint32 `protobuf:"varint,2,opt,name=keyId" json:"keyId,omitempty"`
+}
+
+func (m *AdminRegisterDeploymentRequest) Reset()         { *m = AdminRegisterDeploymentRequest{} }
+func (m *AdminRegisterDeploymentRequest) String() string { return proto.CompactTextString(m) }
+func (*AdminRegisterDeploymentRequest) ProtoMessage()    {}
+func (*AdminRegisterDeploymentRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor0, []int{43}
+}
+
+func (m *AdminRegisterDeploymentRequest) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *AdminRegisterDeploymentRequest) GetKeyId() uint32 {
+	if m != nil {
+		return m.KeyId
+	}
+	return 0
+}
+
+type AdminRotatePSKRequest struct {
+	Name string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	// the MD5 key id, to find the deployments from before the names
+	LegacyKeyId uint32 `protobuf:"varint,2,opt,name=legacyKeyId" json:"legacyKeyId,omitempty"`
+}
+
+func (m *AdminRotatePSKRequest) Reset()                    { *m = AdminRotatePSKRequest{} }
+func (m *AdminRotatePSKRequest) String() string            { return proto.CompactTextString(m) }
+func (*AdminRotatePSKRequest) ProtoMessage()               {}
+func (*AdminRotatePSKRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{44} }
+
+func (m *AdminRotatePSKRequest) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *AdminRotatePSKRequest) GetLegacyKeyId() uint32 {
+	if m != nil {
+		return m.LegacyKeyId
+	}
+	return 0
+}
+
+type AdminRetirePSKRequest struct {
+	Name        string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	LegacyKeyId uint32 `protobuf:"varint,2,opt,name=legacyKeyId" json:"legacyKeyId,omitempty"`
+	Version     uint32 `protobuf:"varint,3,opt,name=version" json:"version,omitempty"`
+}
+
+func (m *AdminRetirePSKRequest) Reset()                    { *m = AdminRetirePSKRequest{} }
+func (m *AdminRetirePSKRequest) String() string            { return proto.CompactTextString(m) }
+func (*AdminRetirePSKRequest) ProtoMessage()               {}
+func (*AdminRetirePSKRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{45} }
+
+func (m *AdminRetirePSKRequest) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *AdminRetirePSKRequest) GetLegacyKeyId() uint32 {
+	if m != nil {
+		return m.LegacyKeyId
+	}
+	return 0
+}
+
+func (m *AdminRetirePSKRequest) GetVersion() uint32 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+type AdminMigrateKeyIDRequest struct {
+	Name        string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	LegacyKeyId uint32 `protobuf:"varint,2,opt,name=legacyKeyId" json:"legacyKeyId,omitempty"`
+	// the HMAC key id, only the client has the secret for it
+	KeyId uint32 `protobuf:"varint,3,opt,name=keyId" json:"keyId,omitempty"`
+}
+
+func (m *AdminMigrateKeyIDRequest) Reset()                    { *m = AdminMigrateKeyIDRequest{} }
+func (m *AdminMigrateKeyIDRequest) String() string            { return proto.CompactTextString(m) }
+func (*AdminMigrateKeyIDRequest) ProtoMessage()               {}
+func (*AdminMigrateKeyIDRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{46} }
+
+func (m *AdminMigrateKeyIDRequest) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *AdminMigrateKeyIDRequest) GetLegacyKeyId() uint32 {
+	if m != nil {
+		return m.LegacyKeyId
+	}
+	return 0
+}
+
+func (m *AdminMigrateKeyIDRequest) GetKeyId() uint32 {
+	if m != nil {
+		return m.KeyId
+	}
+	return 0
+}
+
+type AdminRetireLegacyKeyIDRequest struct {
+	Name string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+}
+
+func (m *AdminRetireLegacyKeyIDRequest) Reset()                    { *m = AdminRetireLegacyKeyIDRequest{} }
+func (m *AdminRetireLegacyKeyIDRequest) String() string            { return proto.CompactTextString(m) }
+func (*AdminRetireLegacyKeyIDRequest) ProtoMessage()               {}
+func (*AdminRetireLegacyKeyIDRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{47} }
+
+func (m *AdminRetireLegacyKeyIDRequest) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+type AdminDeploymentResponse struct {
+	Deployment *AdminDeployment `protobuf:"bytes,1,opt,name=deployment" json:"deployment,omitempty"`
+	// the new PSK, only from register and rotate
+	Key     []byte `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
+	Version uint32 `protobuf:"varint,3,opt,name=version" json:"version,omitempty"`
+}
+
+func (m *AdminDeploymentResponse) Reset()                    { *m = AdminDeploymentResponse{} }
+func (m *AdminDeploymentResponse) String() string            { return proto.CompactTextString(m) }
+func (*AdminDeploymentResponse) ProtoMessage()               {}
+func (*AdminDeploymentResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{48} }
+
+func (m *AdminDeploymentResponse) GetDeployment() *AdminDeployment {
+	if m != nil {
+		return m.Deployment
+	}
+	return nil
+}
+
+func (m *AdminDeploymentResponse) GetKey() []byte {
+	if m != nil {
+		return m.Key
+	}
+	return nil
+}
+
+func (m *AdminDeploymentResponse) GetVersion() uint32 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+type AdminReloadRequest struct {
+}
+
+func (m *AdminReloadRequest) Reset()                    { *m = AdminReloadRequest{} }
+func (m *AdminReloadRequest) String() string            { return proto.CompactTextString(m) }
+func (*AdminReloadRequest) ProtoMessage()               {}
+func (*AdminReloadRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{49} }
+
+type AdminReloadResponse struct {
+}
+
+func (m *AdminReloadResponse) Reset()                    { *m = AdminReloadResponse{} }
+func (m *AdminReloadResponse) String() string            { return proto.CompactTextString(m) }
+func (*AdminReloadResponse) ProtoMessage()               {}
+func (*AdminReloadResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{50} }
+
+type AdminCA struct {
+	// user or host
+	Type        string                     `protobuf:"bytes,1,opt,name=type" json:"type,omitempty"`
+	Id          uint64                     `protobuf:"varint,2,opt,name=id" json:"id,omitempty"`
+	ValidFrom   *google_protobuf.Timestamp `protobuf:"bytes,3,opt,name=validFrom" json:"validFrom,omitempty"`
+	ValidUntil  *google_protobuf.Timestamp `protobuf:"bytes,4,opt,name=validUntil" json:"validUntil,omitempty"`
+	PublicKey   []byte                     `protobuf:"bytes,5,opt,name=publicKey,proto3" json:"publicKey,omitempty"`
+	Fingerprint string                     `protobuf:"bytes,6,opt,name=fingerprint" json:"fingerprint,omitempty"`
+	// the one signing the certs of its type right now
+	Signing bool `protobuf:"varint,7,opt,name=signing" json:"signing,omitempty"`
+	Expired bool `protobuf:"varint,8,opt,name=expired" json:"expired,omitempty"`
+}
+
+func (m *AdminCA) Reset()                    { *m = AdminCA{} }
+func (m *AdminCA) String() string            { return proto.CompactTextString(m) }
+func (*AdminCA) ProtoMessage()               {}
+func (*AdminCA) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{51} }
+
+func (m *AdminCA) GetType() string {
+	if m != nil {
+		return m.Type
+	}
+	return ""
+}
+
+func (m *AdminCA) GetId() uint64 {
+	if m != nil {
+		return m.Id
+	}
+	return 0
+}
+
+func (m *AdminCA) GetValidFrom() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.ValidFrom
+	}
+	return nil
+}
+
+func (m *AdminCA) GetValidUntil() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.ValidUntil
+	}
+	return nil
+}
+
+func (m *AdminCA) GetPublicKey() []byte {
+	if m != nil {
+		return m.PublicKey
+	}
+	return nil
+}
+
+func (m *AdminCA) GetFingerprint() string {
+	if m != nil {
+		return m.Fingerprint
+	}
+	return ""
+}
+
+func (m *AdminCA) GetSigning() bool {
+	if m != nil {
+		return m.Signing
+	}
+	return false
+}
+
+func (m *AdminCA) GetExpired() bool {
+	if m != nil {
+		return m.Expired
+	}
+	return false
+}
+
+type AdminListCAsRequest struct {
+}
+
+func (m *AdminListCAsRequest) Reset()                    { *m = AdminListCAsRequest{} }
+func (m *AdminListCAsRequest) String() string            { return proto.CompactTextString(m) }
+func (*AdminListCAsRequest) ProtoMessage()               {}
+func (*AdminListCAsRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{52} }
+
+type AdminListCAsResponse struct {
+	Cas []*AdminCA `protobuf:"bytes,1,rep,name=cas" json:"cas,omitempty"`
+}
+
+func (m *AdminListCAsResponse) Reset()                    { *m = AdminListCAsResponse{} }
+func (m *AdminListCAsResponse) String() string            { return proto.CompactTextString(m) }
+func (*AdminListCAsResponse) ProtoMessage()               {}
+func (*AdminListCAsResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{53} }
+
+func (m *AdminListCAsResponse) GetCas() []*AdminCA {
+	if m != nil {
+		return m.Cas
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*PingRequest)(nil), "protocol.PingRequest")
+	proto.RegisterType((*PingResponse)(nil), "protocol.PingResponse")
+	proto.RegisterType((*HostAuthRequest)(nil), "protocol.HostAuthRequest")
+	proto.RegisterType((*Error)(nil), "protocol.Error")
+	proto.RegisterType((*HostnamePolicy)(nil), "protocol.HostnamePolicy")
+	proto.RegisterType((*HostAuth)(nil), "protocol.HostAuth")
+	proto.RegisterType((*ReplyMetadata)(nil), "protocol.ReplyMetadata")
+	proto.RegisterType((*HostAuthResponse)(nil), "protocol.HostAuthResponse")
+	proto.RegisterType((*HostCertRequest)(nil), "protocol.HostCertRequest")
+	proto.RegisterType((*HostCertResponse)(nil), "protocol.HostCertResponse")
+	proto.RegisterType((*UserAuthRequest)(nil), "protocol.UserAuthRequest")
+	proto.RegisterType((*UserAuthResponse)(nil), "protocol.UserAuthResponse")
+	proto.RegisterType((*UserCertRequest)(nil), "protocol.UserCertRequest")
+	proto.RegisterType((*OauthToken)(nil), "protocol.OauthToken")
+	proto.RegisterType((*UserCertResponse)(nil), "protocol.UserCertResponse")
+	proto.RegisterType((*DeniedPrincipal)(nil), "protocol.DeniedPrincipal")
+	proto.RegisterType((*HostCA)(nil), "protocol.HostCA")
+	proto.RegisterType((*UserCA)(nil), "protocol.UserCA")
+	proto.RegisterType((*PublicTrustedCARequest)(nil), "protocol.PublicTrustedCARequest")
+	proto.RegisterType((*PublicTrustedCAResponse)(nil), "protocol.PublicTrustedCAResponse")
+	proto.RegisterType((*RevokedKeysRequest)(nil), "protocol.RevokedKeysRequest")
+	proto.RegisterType((*RevokedKeysResponse)(nil), "protocol.RevokedKeysResponse")
+	proto.RegisterType((*Elevation)(nil), "protocol.Elevation")
+	proto.RegisterType((*ElevationRequest)(nil), "protocol.ElevationRequest")
+	proto.RegisterType((*ElevationDecision)(nil), "protocol.ElevationDecision")
+	proto.RegisterType((*ElevationResponse)(nil), "protocol.ElevationResponse")
+	proto.RegisterType((*ElevationListRequest)(nil), "protocol.ElevationListRequest")
+	proto.RegisterType((*ElevationListResponse)(nil), "protocol.ElevationListResponse")
+	proto.RegisterType((*IssuedCert)(nil), "protocol.IssuedCert")
+	proto.RegisterType((*CertListRequest)(nil), "protocol.CertListRequest")
+	proto.RegisterType((*CertListResponse)(nil), "protocol.CertListResponse")
+	proto.RegisterType((*AdminRevokeRequest)(nil), "protocol.AdminRevokeRequest")
+	proto.RegisterType((*AdminRevokeResponse)(nil), "protocol.AdminRevokeResponse")
+	proto.RegisterType((*AdminUser)(nil), "protocol.AdminUser")
+	proto.RegisterType((*AdminListUsersRequest)(nil), "protocol.AdminListUsersRequest")
+	proto.RegisterType((*AdminListUsersResponse)(nil), "protocol.AdminListUsersResponse")
+	proto.RegisterType((*AdminDisableUserRequest)(nil), "protocol.AdminDisableUserRequest")
+	proto.RegisterType((*AdminEnableUserRequest)(nil), "protocol.AdminEnableUserRequest")
+	proto.RegisterType((*AdminUserResponse)(nil), "protocol.AdminUserResponse")
+	proto.RegisterType((*AdminPSKVersion)(nil), "protocol.AdminPSKVersion")
+	proto.RegisterType((*AdminDeployment)(nil), "protocol.AdminDeployment")
+	proto.RegisterType((*AdminListDeploymentsRequest)(nil), "protocol.AdminListDeploymentsRequest")
+	proto.RegisterType((*AdminListDeploymentsResponse)(nil), "protocol.AdminListDeploymentsResponse")
+	proto.RegisterType((*AdminRegisterDeploymentRequest)(nil), "protocol.AdminRegisterDeploymentRequest")
+	proto.RegisterType((*AdminRotatePSKRequest)(nil), "protocol.AdminRotatePSKRequest")
+	proto.RegisterType((*AdminRetirePSKRequest)(nil), "protocol.AdminRetirePSKRequest")
+	proto.RegisterType((*AdminMigrateKeyIDRequest)(nil), "protocol.AdminMigrateKeyIDRequest")
+	proto.RegisterType((*AdminRetireLegacyKeyIDRequest)(nil), "protocol.AdminRetireLegacyKeyIDRequest")
+	proto.RegisterType((*AdminDeploymentResponse)(nil), "protocol.AdminDeploymentResponse")
+	proto.RegisterType((*AdminReloadRequest)(nil), "protocol.AdminReloadRequest")
+	proto.RegisterType((*AdminReloadResponse)(nil), "protocol.AdminReloadResponse")
+	proto.RegisterType((*AdminCA)(nil), "protocol.AdminCA")
+	proto.RegisterType((*AdminListCAsRequest)(nil), "protocol.AdminListCAsRequest")
+	proto.RegisterType((*AdminListCAsResponse)(nil), "protocol.AdminListCAsResponse")
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// Client API for Cert service
+
+type CertClient interface {
+	HostAuth(ctx context.Context, in *HostAuthRequest, opts ...grpc.CallOption) (*HostAuthResponse, error)
+	HostCert(ctx context.Context, in *HostCertRequest, opts ...grpc.CallOption) (*HostCertResponse, error)
+	UserAuth(ctx context.Context, in *UserAuthRequest, opts ...grpc.CallOption) (*UserAuthResponse, error)
+	UserCert(ctx context.Context, in *UserCertRequest, opts ...grpc.CallOption) (*UserCertResponse, error)
+	// This responds back with both host CAs that the users should trust
+	// and the user CA the servers should trust
+	PublicTrustedCA(ctx context.Context, in *PublicTrustedCARequest, opts ...grpc.CallOption) (*PublicTrustedCAResponse, error)
+	// Everything the server has revoked compiled into an OpenSSH KRL
+	// the hosts install it as the RevokedKeys file for sshd
+	RevokedKeys(ctx context.Context, in *RevokedKeysRequest, opts ...grpc.CallOption) (*RevokedKeysResponse, error)
+	// just in time access to the principals that need an approval, the
+	// user asks for them and someone else approves or denies it
+	RequestElevation(ctx context.Context, in *ElevationRequest, opts ...grpc.CallOption) (*ElevationResponse, error)
+	DecideElevation(ctx context.Context, in *ElevationDecision, opts ...grpc.CallOption) (*ElevationResponse, error)
+	// the elevations of the user and the pending ones they can decide on
+	ListElevations(ctx context.Context, in *ElevationListRequest, opts ...grpc.CallOption) (*ElevationListResponse, error)
+	// this is just for test/sanity
+	// We may report he metric to get a sense of how the latency between
+	// environments is faring
+	Ping(ctx context.Context, in *PingRequest, opts ...grpc.CallOption) (*PingResponse, error)
+}
+
+type certClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewCertClient(cc *grpc.ClientConn) CertClient {
+	return &certClient{cc}
+}
+
+func (c *certClient) HostAuth(ctx context.Context, in *HostAuthRequest, opts ...grpc.CallOption) (*HostAuthResponse, error) {
+	out := new(HostAuthResponse)
+	err := grpc.Invoke(ctx, "/protocol.Cert/HostAuth", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *certClient) HostCert(ctx context.Context, in *HostCertRequest, opts ...grpc.CallOption) (*HostCertResponse, error) {
+	out := new(HostCertResponse)
+	err := grpc.Invoke(ctx, "/protocol.Cert/HostCert", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *certClient) UserAuth(ctx context.Context, in *UserAuthRequest, opts ...grpc.CallOption) (*UserAuthResponse, error) {
+	out := new(UserAuthResponse)
+	err := grpc.Invoke(ctx, "/protocol.Cert/UserAuth", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *certClient) UserCert(ctx context.Context, in *UserCertRequest, opts ...grpc.CallOption) (*UserCertResponse, error) {
+	out := new(UserCertResponse)
+	err := grpc.Invoke(ctx, "/protocol.Cert/UserCert", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *certClient) PublicTrustedCA(ctx context.Context, in *PublicTrustedCARequest, opts ...grpc.CallOption) (*PublicTrustedCAResponse, error) {
+	out := new(PublicTrustedCAResponse)
+	err := grpc.Invoke(ctx, "/protocol.Cert/PublicTrustedCA", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *certClient) RevokedKeys(ctx context.Context, in *RevokedKeysRequest, opts ...grpc.CallOption) (*RevokedKeysResponse, error) {
+	out := new(RevokedKeysResponse)
+	err := grpc.Invoke(ctx, "/protocol.Cert/RevokedKeys", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *certClient) RequestElevation(ctx context.Context, in *ElevationRequest, opts ...grpc.CallOption) (*ElevationResponse, error) {
+	out := new(ElevationResponse)
+	err := grpc.Invoke(ctx, "/protocol.Cert/RequestElevation", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *certClient) DecideElevation(ctx context.Context, in *ElevationDecision, opts ...grpc.CallOption) (*ElevationResponse, error) {
+	out := new(ElevationResponse)
+	err := grpc.Invoke(ctx, "/protocol.Cert/DecideElevation", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *certClient) ListElevations(ctx context.Context, in *ElevationListRequest, opts ...grpc.CallOption) (*ElevationListResponse, error) {
+	out := new(ElevationListResponse)
+	err := grpc.Invoke(ctx, "/protocol.Cert/ListElevations", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *certClient) Ping(ctx context.Context, in *PingRequest, opts ...grpc.CallOption) (*PingResponse, error) {
+	out := new(PingResponse)
+	err := grpc.Invoke(ctx, "/protocol.Cert/Ping", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Server API for Cert service
+
+type CertServer interface {
+	HostAuth(context.Context, *HostAuthRequest) (*HostAuthResponse, error)
+	HostCert(context.Context, *HostCertRequest) (*HostCertResponse, error)
+	UserAuth(context.Context, *UserAuthRequest) (*UserAuthResponse, error)
+	UserCert(context.Context, *UserCertRequest) (*UserCertResponse, error)
+	// This responds back with both host CAs that the users should trust
+	// and the user CA the servers should trust
+	PublicTrustedCA(context.Context, *PublicTrustedCARequest) (*PublicTrustedCAResponse, error)
+	// Everything the server has revoked compiled into an OpenSSH KRL
+	// the hosts install it as the RevokedKeys file for sshd
+	RevokedKeys(context.Context, *RevokedKeysRequest) (*RevokedKeysResponse, error)
+	// just in time access to the principals that need an approval, the
+	// user asks for them and someone else approves or denies it
+	RequestElevation(context.Context, *ElevationRequest) (*ElevationResponse, error)
+	DecideElevation(context.Context, *ElevationDecision) (*ElevationResponse, error)
+	// the elevations of the user and the pending ones they can decide on
+	ListElevations(context.Context, *ElevationListRequest) (*ElevationListResponse, error)
+	// this is just for test/sanity
+	// We may report he metric to get a sense of how the latency between
+	// environments is faring
+	Ping(context.Context, *PingRequest) (*PingResponse, error)
+}
+
+func RegisterCertServer(s *grpc.Server, srv CertServer) {
+	s.RegisterService(&_Cert_serviceDesc, srv)
+}
+
+func _Cert_HostAuth_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HostAuthRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CertServer).HostAuth(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protocol.Cert/HostAuth",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CertServer).HostAuth(ctx, req.(*HostAuthRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Cert_HostCert_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HostCertRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CertServer).HostCert(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protocol.Cert/HostCert",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CertServer).HostCert(ctx, req.(*HostCertRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Cert_UserAuth_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UserAuthRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CertServer).UserAuth(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protocol.Cert/UserAuth",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CertServer).UserAuth(ctx, req.(*UserAuthRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Cert_UserCert_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UserCertRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CertServer).UserCert(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protocol.Cert/UserCert",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CertServer).UserCert(ctx, req.(*UserCertRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Cert_PublicTrustedCA_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PublicTrustedCARequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CertServer).PublicTrustedCA(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protocol.Cert/PublicTrustedCA",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CertServer).PublicTrustedCA(ctx, req.(*PublicTrustedCARequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Cert_RevokedKeys_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevokedKeysRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CertServer).RevokedKeys(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protocol.Cert/RevokedKeys",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CertServer).RevokedKeys(ctx, req.(*RevokedKeysRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Cert_RequestElevation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ElevationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CertServer).RequestElevation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protocol.Cert/RequestElevation",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CertServer).RequestElevation(ctx, req.(*ElevationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Cert_DecideElevation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ElevationDecision)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CertServer).DecideElevation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protocol.Cert/DecideElevation",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CertServer).DecideElevation(ctx, req.(*ElevationDecision))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Cert_ListElevations_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ElevationListRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CertServer).ListElevations(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protocol.Cert/ListElevations",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CertServer).ListElevations(ctx, req.(*ElevationListRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Cert_Ping_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PingRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CertServer).Ping(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protocol.Cert/Ping",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CertServer).Ping(ctx, req.(*PingRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _Cert_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "protocol.Cert",
+	HandlerType: (*CertServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "HostAuth",
+			Handler:    _Cert_HostAuth_Handler,
+		},
+		{
+			MethodName: "HostCert",
+			Handler:    _Cert_HostCert_Handler,
+		},
+		{
+			MethodName: "UserAuth",
+			Handler:    _Cert_UserAuth_Handler,
+		},
+		{
+			MethodName: "UserCert",
+			Handler:    _Cert_UserCert_Handler,
+		},
+		{
+			MethodName: "PublicTrustedCA",
+			Handler:    _Cert_PublicTrustedCA_Handler,
+		},
+		{
+			MethodName: "RevokedKeys",
+			Handler:    _Cert_RevokedKeys_Handler,
+		},
+		{
+			MethodName: "RequestElevation",
+			Handler:    _Cert_RequestElevation_Handler,
+		},
+		{
+			MethodName: "DecideElevation",
+			Handler:    _Cert_DecideElevation_Handler,
+		},
+		{
+			MethodName: "ListElevations",
+			Handler:    _Cert_ListElevations_Handler,
+		},
+		{
+			MethodName: "Ping",
+			Handler:    _Cert_Ping_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "protocol.proto",
+}
+
+// Client API for Admin service
+
+type AdminClient interface {
+	Revoke(ctx context.Context, in *AdminRevokeRequest, opts ...grpc.CallOption) (*AdminRevokeResponse, error)
+	// everyone who got a user cert or was disabled
+	ListUsers(ctx context.Context, in *AdminListUsersRequest, opts ...grpc.CallOption) (*AdminListUsersResponse, error)
+	// disabled users can't authenticate or get certs whatever the authz says
+	DisableUser(ctx context.Context, in *AdminDisableUserRequest, opts ...grpc.CallOption) (*AdminUserResponse, error)
+	EnableUser(ctx context.Context, in *AdminEnableUserRequest, opts ...grpc.CallOption) (*AdminUserResponse, error)
+	// these edit the PSK file and reload it
+	ListDeployments(ctx context.Context, in *AdminListDeploymentsRequest, opts ...grpc.CallOption) (*AdminListDeploymentsResponse, error)
+	RegisterDeployment(ctx context.Context, in *AdminRegisterDeploymentRequest, opts ...grpc.CallOption) (*AdminDeploymentResponse, error)
+	RotatePSK(ctx context.Context, in *AdminRotatePSKRequest, opts ...grpc.CallOption) (*AdminDeploymentResponse, error)
+	RetirePSK(ctx context.Context, in *AdminRetirePSKRequest, opts ...grpc.CallOption) (*AdminDeploymentResponse, error)
+	// the MD5 key id keeps working until it's retired
+	MigrateKeyID(ctx context.Context, in *AdminMigrateKeyIDRequest, opts ...grpc.CallOption) (*AdminDeploymentResponse, error)
+	RetireLegacyKeyID(ctx context.Context, in *AdminRetireLegacyKeyIDRequest, opts ...grpc.CallOption) (*AdminDeploymentResponse, error)
+	// reloads the PSKs, the CAs and the authz like SIGHUP
+	Reload(ctx context.Context, in *AdminReloadRequest, opts ...grpc.CallOption) (*AdminReloadResponse, error)
+	ListCAs(ctx context.Context, in *AdminListCAsRequest, opts ...grpc.CallOption) (*AdminListCAsResponse, error)
+}
+
+type adminClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewAdminClient(cc *grpc.ClientConn) AdminClient {
+	return &adminClient{cc}
+}
+
+func (c *adminClient) Revoke(ctx context.Context, in *AdminRevokeRequest, opts ...grpc.CallOption) (*AdminRevokeResponse, error) {
+	out := new(AdminRevokeResponse)
+	err := grpc.Invoke(ctx, "/protocol.Admin/Revoke", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) ListUsers(ctx context.Context, in *AdminListUsersRequest, opts ...grpc.CallOption) (*AdminListUsersResponse, error) {
+	out := new(AdminListUsersResponse)
+	err := grpc.Invoke(ctx, "/protocol.Admin/ListUsers", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) DisableUser(ctx context.Context, in *AdminDisableUserRequest, opts ...grpc.CallOption) (*AdminUserResponse, error) {
+	out := new(AdminUserResponse)
+	err := grpc.Invoke(ctx, "/protocol.Admin/DisableUser", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) EnableUser(ctx context.Context, in *AdminEnableUserRequest, opts ...grpc.CallOption) (*AdminUserResponse, error) {
+	out := new(AdminUserResponse)
+	err := grpc.Invoke(ctx, "/protocol.Admin/EnableUser", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) ListDeployments(ctx context.Context, in *AdminListDeploymentsRequest, opts ...grpc.CallOption) (*AdminListDeploymentsResponse, error) {
+	out := new(AdminListDeploymentsResponse)
+	err := grpc.Invoke(ctx, "/protocol.Admin/ListDeployments", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) RegisterDeployment(ctx context.Context, in *AdminRegisterDeploymentRequest, opts ...grpc.CallOption) (*AdminDeploymentResponse, error) {
+	out := new(AdminDeploymentResponse)
+	err := grpc.Invoke(ctx, "/protocol.Admin/RegisterDeployment", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) RotatePSK(ctx context.Context, in *AdminRotatePSKRequest, opts ...grpc.CallOption) (*AdminDeploymentResponse, error) {
+	out := new(AdminDeploymentResponse)
+	err := grpc.Invoke(ctx, "/protocol.Admin/RotatePSK", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) RetirePSK(ctx context.Context, in *AdminRetirePSKRequest, opts ...grpc.CallOption) (*AdminDeploymentResponse, error) {
+	out := new(AdminDeploymentResponse)
+	err := grpc.Invoke(ctx, "/protocol.Admin/RetirePSK", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) MigrateKeyID(ctx context.Context, in *AdminMigrateKeyIDRequest, opts ...grpc.CallOption) (*AdminDeploymentResponse, error) {
+	out := new(AdminDeploymentResponse)
+	err := grpc.Invoke(ctx, "/protocol.Admin/MigrateKeyID", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) RetireLegacyKeyID(ctx context.Context, in *AdminRetireLegacyKeyIDRequest, opts ...grpc.CallOption) (*AdminDeploymentResponse, error) {
+	out := new(AdminDeploymentResponse)
+	err := grpc.Invoke(ctx, "/protocol.Admin/RetireLegacyKeyID", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) Reload(ctx context.Context, in *AdminReloadRequest, opts ...grpc.CallOption) (*AdminReloadResponse, error) {
+	out := new(AdminReloadResponse)
+	err := grpc.Invoke(ctx, "/protocol.Admin/Reload", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) ListCAs(ctx context.Context, in *AdminListCAsRequest, opts ...grpc.CallOption) (*AdminListCAsResponse, error) {
+	out := new(AdminListCAsResponse)
+	err := grpc.Invoke(ctx, "/protocol.Admin/ListCAs", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Server API for Admin service
+
+type AdminServer interface {
+	Revoke(context.Context, *AdminRevokeRequest) (*AdminRevokeResponse, error)
+	// everyone who got a user cert or was disabled
+	ListUsers(context.Context, *AdminListUsersRequest) (*AdminListUsersResponse, error)
+	// disabled users can't authenticate or get certs whatever the authz says
+	DisableUser(context.Context, *AdminDisableUserRequest) (*AdminUserResponse, error)
+	EnableUser(context.Context, *AdminEnableUserRequest) (*AdminUserResponse, error)
+	// these edit the PSK file and reload it
+	ListDeployments(context.Context, *AdminListDeploymentsRequest) (*AdminListDeploymentsResponse, error)
+	RegisterDeployment(context.Context, *AdminRegisterDeploymentRequest) (*AdminDeploymentResponse, error)
+	RotatePSK(context.Context, *AdminRotatePSKRequest) (*AdminDeploymentResponse, error)
+	RetirePSK(context.Context, *AdminRetirePSKRequest) (*AdminDeploymentResponse, error)
+	// the MD5 key id keeps working until it's retired
+	MigrateKeyID(context.Context, *AdminMigrateKeyIDRequest) (*AdminDeploymentResponse, error)
+	RetireLegacyKeyID(context.Context, *AdminRetireLegacyKeyIDRequest) (*AdminDeploymentResponse, error)
+	// reloads the PSKs, the CAs and the authz like SIGHUP
+	Reload(context.Context, *AdminReloadRequest) (*AdminReloadResponse, error)
+	ListCAs(context.Context, *AdminListCAsRequest) (*AdminListCAsResponse, error)
+}
+
+func RegisterAdminServer(s *grpc.Server, srv AdminServer) {
+	s.RegisterService(&_Admin_serviceDesc, srv)
+}
+
+func _Admin_Revoke_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AdminRevokeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).Revoke(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/protocol.Cert/HostCert",
+		FullMethod: "/protocol.Admin/Revoke",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(CertServer).HostCert(ctx, req.(*HostCertRequest))
+		return srv.(AdminServer).Revoke(ctx, req.(*AdminRevokeRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _Cert_UserAuth_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(UserAuthRequest)
+func _Admin_ListUsers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AdminListUsersRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(CertServer).UserAuth(ctx, in)
+		return srv.(AdminServer).ListUsers(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/protocol.Cert/UserAuth",
+		FullMethod: "/protocol.Admin/ListUsers",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(CertServer).UserAuth(ctx, req.(*UserAuthRequest))
+		return srv.(AdminServer).ListUsers(ctx, req.(*AdminListUsersRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _Cert_UserCert_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(UserCertRequest)
+func _Admin_DisableUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AdminDisableUserRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(CertServer).UserCert(ctx, in)
+		return srv.(AdminServer).DisableUser(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/protocol.Cert/UserCert",
+		FullMethod: "/protocol.Admin/DisableUser",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(CertServer).UserCert(ctx, req.(*UserCertRequest))
+		return srv.(AdminServer).DisableUser(ctx, req.(*AdminDisableUserRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _Cert_PublicTrustedCA_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(PublicTrustedCARequest)
+func _Admin_EnableUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AdminEnableUserRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(CertServer).PublicTrustedCA(ctx, in)
+		return srv.(AdminServer).EnableUser(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/protocol.Cert/PublicTrustedCA",
+		FullMethod: "/protocol.Admin/EnableUser",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(CertServer).PublicTrustedCA(ctx, req.(*PublicTrustedCARequest))
+		return srv.(AdminServer).EnableUser(ctx, req.(*AdminEnableUserRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _Cert_Ping_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(PingRequest)
+func _Admin_ListDeployments_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AdminListDeploymentsRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(CertServer).Ping(ctx, in)
+		return srv.(AdminServer).ListDeployments(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/protocol.Cert/Ping",
+		FullMethod: "/protocol.Admin/ListDeployments",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(CertServer).Ping(ctx, req.(*PingRequest))
+		return srv.(AdminServer).ListDeployments(ctx, req.(*AdminListDeploymentsRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-var _Cert_serviceDesc = grpc.ServiceDesc{
-	ServiceName: "protocol.Cert",
-	HandlerType: (*CertServer)(nil),
+func _Admin_RegisterDeployment_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AdminRegisterDeploymentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).RegisterDeployment(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protocol.Admin/RegisterDeployment",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).RegisterDeployment(ctx, req.(*AdminRegisterDeploymentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_RotatePSK_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AdminRotatePSKRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).RotatePSK(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protocol.Admin/RotatePSK",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).RotatePSK(ctx, req.(*AdminRotatePSKRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_RetirePSK_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AdminRetirePSKRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).RetirePSK(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protocol.Admin/RetirePSK",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).RetirePSK(ctx, req.(*AdminRetirePSKRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_MigrateKeyID_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AdminMigrateKeyIDRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).MigrateKeyID(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protocol.Admin/MigrateKeyID",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).MigrateKeyID(ctx, req.(*AdminMigrateKeyIDRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_RetireLegacyKeyID_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AdminRetireLegacyKeyIDRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).RetireLegacyKeyID(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protocol.Admin/RetireLegacyKeyID",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).RetireLegacyKeyID(ctx, req.(*AdminRetireLegacyKeyIDRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_Reload_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AdminReloadRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).Reload(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protocol.Admin/Reload",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).Reload(ctx, req.(*AdminReloadRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_ListCAs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AdminListCAsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).ListCAs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protocol.Admin/ListCAs",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).ListCAs(ctx, req.(*AdminListCAsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _Admin_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "protocol.Admin",
+	HandlerType: (*AdminServer)(nil),
 	Methods: []grpc.MethodDesc{
 		{
-			MethodName: "HostAuth",
-			Handler:    _Cert_HostAuth_Handler,
+			MethodName: "Revoke",
+			Handler:    _Admin_Revoke_Handler,
 		},
 		{
-			MethodName: "HostCert",
-			Handler:    _Cert_HostCert_Handler,
+			MethodName: "ListUsers",
+			Handler:    _Admin_ListUsers_Handler,
 		},
 		{
-			MethodName: "UserAuth",
-			Handler:    _Cert_UserAuth_Handler,
+			MethodName: "DisableUser",
+			Handler:    _Admin_DisableUser_Handler,
 		},
 		{
-			MethodName: "UserCert",
-			Handler:    _Cert_UserCert_Handler,
+			MethodName: "EnableUser",
+			Handler:    _Admin_EnableUser_Handler,
 		},
 		{
-			MethodName: "PublicTrustedCA",
-			Handler:    _Cert_PublicTrustedCA_Handler,
+			MethodName: "ListDeployments",
+			Handler:    _Admin_ListDeployments_Handler,
 		},
 		{
-			MethodName: "Ping",
-			Handler:    _Cert_Ping_Handler,
+			MethodName: "RegisterDeployment",
+			Handler:    _Admin_RegisterDeployment_Handler,
+		},
+		{
+			MethodName: "RotatePSK",
+			Handler:    _Admin_RotatePSK_Handler,
+		},
+		{
+			MethodName: "RetirePSK",
+			Handler:    _Admin_RetirePSK_Handler,
+		},
+		{
+			MethodName: "MigrateKeyID",
+			Handler:    _Admin_MigrateKeyID_Handler,
+		},
+		{
+			MethodName: "RetireLegacyKeyID",
+			Handler:    _Admin_RetireLegacyKeyID_Handler,
+		},
+		{
+			MethodName: "Reload",
+			Handler:    _Admin_Reload_Handler,
+		},
+		{
+			MethodName: "ListCAs",
+			Handler:    _Admin_ListCAs_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "protocol.proto",
+}
+
+// Client API for CertQuery service
+
+type CertQueryClient interface {
+	// needs the session from UserAuth, the users who aren't admins only see
+	// the host certs and their own user certs
+	ListCerts(ctx context.Context, in *CertListRequest, opts ...grpc.CallOption) (*CertListResponse, error)
+}
+
+type certQueryClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewCertQueryClient(cc *grpc.ClientConn) CertQueryClient {
+	return &certQueryClient{cc}
+}
+
+func (c *certQueryClient) ListCerts(ctx context.Context, in *CertListRequest, opts ...grpc.CallOption) (*CertListResponse, error) {
+	out := new(CertListResponse)
+	err := grpc.Invoke(ctx, "/protocol.CertQuery/ListCerts", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Server API for CertQuery service
+
+type CertQueryServer interface {
+	// needs the session from UserAuth, the users who aren't admins only see
+	// the host certs and their own user certs
+	ListCerts(context.Context, *CertListRequest) (*CertListResponse, error)
+}
+
+func RegisterCertQueryServer(s *grpc.Server, srv CertQueryServer) {
+	s.RegisterService(&_CertQuery_serviceDesc, srv)
+}
+
+func _CertQuery_ListCerts_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CertListRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CertQueryServer).ListCerts(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protocol.CertQuery/ListCerts",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CertQueryServer).ListCerts(ctx, req.(*CertListRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _CertQuery_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "protocol.CertQuery",
+	HandlerType: (*CertQueryServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListCerts",
+			Handler:    _CertQuery_ListCerts_Handler,
 		},
 	},
 	Streams:  []grpc.StreamDesc{},
@@ -1022,65 +3094,169 @@ var _Cert_serviceDesc = grpc.ServiceDesc{
 func init() { proto.RegisterFile("protocol.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 958 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xd4, 0x57, 0x4b, 0x8f, 0xe3, 0x44,
-	0x10, 0xc6, 0x76, 0x9e, 0x95, 0xec, 0x64, 0xd4, 0x1a, 0x66, 0x4d, 0x84, 0x44, 0xb0, 0x78, 0x44,
-	0x2b, 0x91, 0x95, 0xb2, 0x07, 0x10, 0x42, 0x48, 0xd9, 0x08, 0xc4, 0x0a, 0x21, 0x46, 0x56, 0x06,
-	0x71, 0x41, 0xc8, 0x63, 0xf7, 0x24, 0xd6, 0xc4, 0xee, 0xd0, 0xdd, 0x5e, 0x31, 0xfc, 0x07, 0x4e,
-	0x1c, 0x90, 0x38, 0x73, 0x84, 0xe3, 0x1e, 0x38, 0xf3, 0xc7, 0x50, 0x3f, 0x1c, 0xb7, 0x7b, 0x93,
-	0x61, 0x77, 0x93, 0xcb, 0xde, 0xdc, 0x55, 0x5f, 0x7f, 0x5d, 0x5d, 0xf5, 0x55, 0x57, 0x02, 0x27,
-	0x1b, 0x4a, 0x38, 0x89, 0xc9, 0x7a, 0x22, 0x3f, 0x50, 0xa7, 0x5c, 0x0f, 0xdf, 0x59, 0x12, 0xb2,
-	0x5c, 0xe3, 0x87, 0xd2, 0x70, 0x55, 0x5c, 0x3f, 0xe4, 0x69, 0x86, 0x19, 0x8f, 0xb2, 0x8d, 0x82,
-	0x06, 0x3f, 0x42, 0xef, 0x22, 0xcd, 0x97, 0x21, 0xfe, 0xa9, 0xc0, 0x8c, 0xa3, 0xcf, 0xa0, 0x47,
-	0xd5, 0xe7, 0x22, 0xcd, 0xb0, 0xef, 0x8c, 0x9c, 0x71, 0x6f, 0x3a, 0x9c, 0x28, 0x96, 0x49, 0xc9,
-	0x32, 0x59, 0x94, 0x2c, 0xa1, 0x09, 0x47, 0x08, 0x1a, 0x79, 0x94, 0x61, 0xdf, 0x1d, 0x39, 0xe3,
-	0x6e, 0x28, 0xbf, 0x83, 0x1f, 0xa0, 0xaf, 0x0e, 0x60, 0x1b, 0x92, 0x33, 0x8c, 0x1e, 0x41, 0x27,
-	0xc3, 0x3c, 0x4a, 0x22, 0x1e, 0x69, 0xfa, 0xfb, 0x93, 0x6d, 0xf8, 0x21, 0xde, 0xac, 0x6f, 0xbf,
-	0xd1, 0xee, 0x70, 0x0b, 0x44, 0x3e, 0xb4, 0x33, 0xcc, 0x58, 0xb4, 0x2c, 0xb9, 0xcb, 0x65, 0x70,
-	0x03, 0x83, 0xaf, 0x08, 0xe3, 0xb3, 0x82, 0xaf, 0x8e, 0x73, 0x87, 0x21, 0x74, 0xa2, 0x82, 0xaf,
-	0x9e, 0xe4, 0xd7, 0x44, 0x9e, 0xd5, 0x0f, 0xb7, 0xeb, 0xe0, 0x23, 0x68, 0x7e, 0x41, 0x29, 0xa1,
-	0xe2, 0xa2, 0xfc, 0x76, 0xa3, 0xb8, 0xbb, 0xa1, 0xfc, 0x46, 0xa7, 0xe0, 0x65, 0x6c, 0xa9, 0xe3,
-	0x13, 0x9f, 0xc1, 0x1c, 0x3a, 0x65, 0x6c, 0xe8, 0x04, 0xdc, 0x34, 0x91, 0xf8, 0x7e, 0xe8, 0xa6,
-	0x09, 0xfa, 0x10, 0x5a, 0x58, 0x50, 0x31, 0xdf, 0x1d, 0x79, 0xe3, 0xde, 0x74, 0x50, 0x25, 0x41,
-	0x1e, 0x11, 0x6a, 0x77, 0xf0, 0xab, 0x03, 0xf7, 0x6a, 0x69, 0x39, 0xf0, 0x7e, 0x9f, 0x43, 0x9f,
-	0xea, 0x5a, 0xc8, 0xed, 0xee, 0xff, 0x6e, 0xaf, 0xe1, 0x83, 0x1b, 0x38, 0xad, 0x12, 0x7e, 0x48,
-	0x4d, 0x03, 0xe8, 0x47, 0x06, 0x89, 0xef, 0xc9, 0xdc, 0xd4, 0x6c, 0xc1, 0x33, 0x57, 0x95, 0x77,
-	0x8e, 0x29, 0x3f, 0x4e, 0x79, 0x3f, 0x81, 0xee, 0xd3, 0x68, 0x9d, 0x26, 0x5f, 0x52, 0x92, 0xbd,
-	0xc0, 0xdd, 0x2b, 0x30, 0xfa, 0x14, 0x40, 0x2e, 0x2e, 0x73, 0x9e, 0xae, 0x65, 0xb4, 0x77, 0x6f,
-	0x35, 0xd0, 0xba, 0xfa, 0x8d, 0x6d, 0xf5, 0xdf, 0x86, 0xee, 0x8a, 0x30, 0x2e, 0x1a, 0x84, 0xf9,
-	0xcd, 0x91, 0x37, 0xee, 0x86, 0x95, 0x41, 0x78, 0x37, 0xc5, 0xd5, 0x3a, 0x8d, 0xbf, 0xc6, 0xb7,
-	0x7e, 0x4b, 0x6e, 0xaa, 0x0c, 0x22, 0x6f, 0x02, 0x5a, 0x66, 0xd4, 0x6f, 0xab, 0xbc, 0x99, 0xb6,
-	0xe0, 0x37, 0x47, 0x55, 0x49, 0xe5, 0xed, 0x90, 0x2a, 0x0d, 0xa1, 0xb3, 0xd2, 0x44, 0xba, 0x42,
-	0xdb, 0x35, 0x9a, 0x00, 0xe2, 0xb4, 0x60, 0x1c, 0x27, 0x97, 0x0c, 0x53, 0x36, 0x9f, 0x49, 0x94,
-	0xba, 0xe5, 0x0e, 0x4f, 0xf0, 0xbb, 0x03, 0x03, 0xb1, 0x3e, 0x6a, 0xb3, 0x16, 0x0c, 0x53, 0xe3,
-	0xd1, 0xd9, 0xae, 0xd1, 0x03, 0x68, 0x72, 0x72, 0x83, 0x73, 0x19, 0x50, 0x6f, 0x7a, 0x56, 0xdd,
-	0xf5, 0x5b, 0xa1, 0xb1, 0x85, 0xf0, 0x85, 0x0a, 0x12, 0x3c, 0x73, 0xe0, 0xb4, 0x8a, 0xec, 0xc0,
-	0x7c, 0xed, 0x8d, 0xe8, 0x1c, 0x5a, 0xe2, 0xfb, 0x49, 0x22, 0x33, 0xd9, 0x0d, 0xf5, 0x0a, 0x9d,
-	0x41, 0x53, 0x6a, 0x45, 0x46, 0xda, 0x09, 0xd5, 0xe2, 0xb9, 0xfe, 0x68, 0xee, 0xe8, 0x8f, 0x7f,
-	0x3d, 0x95, 0xd1, 0xe3, 0xf5, 0x47, 0x15, 0xa3, 0x5b, 0x8b, 0xd1, 0xbc, 0x97, 0x67, 0xdd, 0xeb,
-	0x03, 0x38, 0xa1, 0x38, 0x23, 0x1c, 0x5f, 0x96, 0x88, 0x86, 0x44, 0x58, 0xd6, 0xba, 0xae, 0x9b,
-	0xb6, 0xae, 0xc7, 0x30, 0x88, 0x0b, 0x4a, 0x71, 0xce, 0xcb, 0x1b, 0x69, 0xed, 0xdb, 0xe6, 0x7a,
-	0x0f, 0xb7, 0x5f, 0xbd, 0x87, 0x3b, 0x2f, 0xd5, 0xc3, 0x53, 0x38, 0x13, 0xb9, 0x27, 0x34, 0xfd,
-	0x05, 0x27, 0x17, 0x34, 0xcd, 0xe3, 0x74, 0x13, 0xad, 0x99, 0xdf, 0x95, 0xed, 0xbb, 0xd3, 0x87,
-	0xde, 0x83, 0x7b, 0xd7, 0x84, 0xc6, 0x78, 0x4e, 0xb2, 0x2c, 0xca, 0x13, 0xe6, 0x83, 0x04, 0xd7,
-	0x8d, 0xc1, 0x9f, 0x0e, 0x40, 0xa5, 0x49, 0x34, 0x82, 0x5e, 0x14, 0xc7, 0x98, 0x31, 0xb9, 0xd4,
-	0x33, 0xc6, 0x34, 0x89, 0x44, 0x4a, 0xdd, 0x2e, 0xc4, 0x0c, 0x52, 0x75, 0xaa, 0x0c, 0x42, 0x38,
-	0x14, 0x5f, 0x53, 0xcc, 0x14, 0x9f, 0x2e, 0x57, 0xcd, 0x86, 0xa6, 0xd0, 0xc2, 0x3f, 0x6f, 0x52,
-	0x7a, 0xab, 0xbb, 0xe3, 0xae, 0x24, 0x68, 0x64, 0xf0, 0x8f, 0x6e, 0x92, 0xa3, 0x3c, 0x2a, 0x7b,
-	0x9b, 0x44, 0xfb, 0xcc, 0x07, 0xa7, 0xa8, 0x0a, 0x7f, 0xa2, 0x9f, 0x15, 0xf9, 0xb8, 0xcd, 0x98,
-	0xdf, 0x90, 0xc3, 0xf3, 0xb4, 0x3a, 0x52, 0x39, 0x42, 0x0b, 0x17, 0xfc, 0xe5, 0x40, 0x4b, 0x7d,
-	0xd7, 0xd5, 0xe3, 0xbc, 0xba, 0x7a, 0xdc, 0x97, 0x52, 0x4f, 0x4d, 0xfb, 0x9e, 0xad, 0xfd, 0x6a,
-	0x3e, 0x34, 0xc4, 0x7c, 0x90, 0xe1, 0xca, 0x54, 0xbf, 0x1e, 0xe1, 0x7e, 0x07, 0xe7, 0x17, 0xd2,
-	0xb9, 0x50, 0x59, 0x9f, 0xcf, 0x8e, 0xf2, 0x18, 0x05, 0x7f, 0xb8, 0x70, 0xff, 0x39, 0xe2, 0x43,
-	0x84, 0xf7, 0x00, 0xda, 0x2b, 0xad, 0x1c, 0x77, 0x8f, 0x72, 0x4a, 0x80, 0xc0, 0xaa, 0x12, 0x30,
-	0xdf, 0xb3, 0xb1, 0xca, 0x11, 0x96, 0x00, 0x21, 0x4c, 0x8a, 0x9f, 0x92, 0x9b, 0x17, 0x10, 0x66,
-	0x1d, 0x67, 0xec, 0x2c, 0x0f, 0x6b, 0xee, 0x39, 0xcc, 0xc2, 0x4d, 0xff, 0xf6, 0xa0, 0x21, 0xbb,
-	0xc2, 0xfc, 0x99, 0xf9, 0x56, 0xfd, 0x40, 0x63, 0xd2, 0x0e, 0x87, 0xbb, 0x5c, 0x7a, 0x8e, 0xbc,
-	0x51, 0x92, 0x48, 0x42, 0x8b, 0xc4, 0x18, 0x2e, 0x36, 0x89, 0xf9, 0x14, 0x28, 0x92, 0x72, 0x8a,
-	0x9a, 0x24, 0xd6, 0xcc, 0x37, 0x49, 0xec, 0xa1, 0x5b, 0x91, 0xd8, 0x91, 0x58, 0x63, 0xce, 0x26,
-	0xb1, 0x22, 0xf9, 0x1e, 0x06, 0x96, 0x70, 0xd0, 0xa8, 0xda, 0xb0, 0x5b, 0xac, 0xc3, 0x77, 0xef,
-	0x40, 0x6c, 0x99, 0x3f, 0x86, 0x86, 0xf8, 0x3f, 0x83, 0xde, 0x34, 0xc0, 0xd5, 0x1f, 0xa8, 0xe1,
-	0xb9, 0x6d, 0x2e, 0x37, 0x3e, 0x7e, 0x1f, 0xfc, 0x98, 0x64, 0x93, 0x2c, 0x65, 0x7c, 0x12, 0xc5,
-	0x31, 0xa1, 0xc9, 0x16, 0xfa, 0xb8, 0x3d, 0x8b, 0xa5, 0xe5, 0xc2, 0xb9, 0x6a, 0x49, 0xe3, 0xa3,
-	0xff, 0x02, 0x00, 0x00, 0xff, 0xff, 0x54, 0x30, 0x8a, 0xd0, 0xd4, 0x0d, 0x00, 0x00,
+	// 2622 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xd4, 0x5a, 0x5b, 0x73, 0x1c, 0x47,
+	0x15, 0x66, 0xf6, 0xa6, 0xdd, 0xa3, 0xab, 0xdb, 0xb2, 0xbc, 0x99, 0x58, 0x8e, 0x3c, 0x90, 0x58,
+	0xb8, 0x88, 0x5c, 0xc8, 0x55, 0xc1, 0x10, 0x2a, 0x95, 0xd5, 0x85, 0xc4, 0xb1, 0x5d, 0x91, 0xc7,
+	0x17, 0xa8, 0x02, 0x2a, 0x35, 0x9e, 0x69, 0xad, 0x06, 0xed, 0xce, 0x2c, 0xd3, 0xbd, 0x2a, 0x6f,
+	0xf2, 0x02, 0xfc, 0x05, 0x1e, 0x52, 0xe4, 0x99, 0x37, 0x2e, 0xc5, 0x3f, 0xe0, 0x07, 0xf0, 0x03,
+	0xa8, 0x82, 0x27, 0x9e, 0x78, 0xe3, 0x95, 0x57, 0xaa, 0x6f, 0xd3, 0x3d, 0x3d, 0xb3, 0xd2, 0xda,
+	0x92, 0x53, 0xc5, 0xdb, 0x9e, 0xd3, 0xa7, 0x4f, 0x77, 0x9f, 0xeb, 0xd7, 0x3d, 0x0b, 0x4b, 0xa3,
+	0x2c, 0xa5, 0x69, 0x98, 0x0e, 0xb6, 0xf8, 0x0f, 0xd4, 0x56, 0xb4, 0xfb, 0x56, 0x3f, 0x4d, 0xfb,
+	0x03, 0x7c, 0x9b, 0x33, 0x9e, 0x8f, 0x0f, 0x6f, 0xd3, 0x78, 0x88, 0x09, 0x0d, 0x86, 0x23, 0x21,
+	0xea, 0x7d, 0x06, 0xf3, 0x07, 0x71, 0xd2, 0xf7, 0xf1, 0x2f, 0xc7, 0x98, 0x50, 0xf4, 0x43, 0x98,
+	0xcf, 0xc4, 0xcf, 0x27, 0xf1, 0x10, 0x77, 0x9d, 0x0d, 0x67, 0x73, 0x7e, 0xdb, 0xdd, 0x12, 0x5a,
+	0xb6, 0x94, 0x96, 0xad, 0x27, 0x4a, 0x8b, 0x6f, 0x8a, 0x23, 0x04, 0x8d, 0x24, 0x18, 0xe2, 0x6e,
+	0x6d, 0xc3, 0xd9, 0xec, 0xf8, 0xfc, 0xb7, 0xf7, 0x73, 0x58, 0x10, 0x0b, 0x90, 0x51, 0x9a, 0x10,
+	0x8c, 0xee, 0x40, 0x7b, 0x88, 0x69, 0x10, 0x05, 0x34, 0x90, 0xea, 0xaf, 0x6e, 0xe5, 0xdb, 0xf7,
+	0xf1, 0x68, 0x30, 0x79, 0x28, 0x87, 0xfd, 0x5c, 0x10, 0x75, 0x61, 0x6e, 0x88, 0x09, 0x09, 0xfa,
+	0x4a, 0xb7, 0x22, 0xbd, 0x63, 0x58, 0xfe, 0x38, 0x25, 0xb4, 0x37, 0xa6, 0x47, 0x17, 0x73, 0x06,
+	0x17, 0xda, 0xc1, 0x98, 0x1e, 0xdd, 0x4b, 0x0e, 0x53, 0xbe, 0xd6, 0x82, 0x9f, 0xd3, 0xde, 0xbb,
+	0xd0, 0xdc, 0xcf, 0xb2, 0x34, 0x63, 0x07, 0xa5, 0x93, 0x91, 0xd0, 0xdd, 0xf1, 0xf9, 0x6f, 0xb4,
+	0x02, 0xf5, 0x21, 0xe9, 0xcb, 0xfd, 0xb1, 0x9f, 0xde, 0xcf, 0x60, 0x89, 0xed, 0x8d, 0x99, 0xe1,
+	0x20, 0x1d, 0xc4, 0xe1, 0x04, 0x5d, 0x07, 0x18, 0x65, 0x71, 0x12, 0xc6, 0xa3, 0x60, 0x40, 0xe4,
+	0x6c, 0x83, 0x83, 0x6e, 0xc1, 0xca, 0x91, 0x9a, 0x11, 0x50, 0x8a, 0xb3, 0x84, 0x74, 0x6b, 0x1b,
+	0xf5, 0xcd, 0x8e, 0x5f, 0xe2, 0x7b, 0xbf, 0xad, 0x41, 0x5b, 0x1d, 0x1d, 0x2d, 0x41, 0x2d, 0x8e,
+	0xb8, 0xc2, 0x05, 0xbf, 0x16, 0x47, 0xe8, 0x26, 0xb4, 0x30, 0xdb, 0xa9, 0x98, 0x3e, 0xbf, 0xbd,
+	0xac, 0x6d, 0xcc, 0x4f, 0xe0, 0xcb, 0x61, 0x74, 0x17, 0x3a, 0xf8, 0xc5, 0x28, 0xce, 0x30, 0xe9,
+	0xd1, 0x6e, 0xfd, 0x4c, 0x53, 0x69, 0x61, 0x66, 0xa8, 0x31, 0xc1, 0xe4, 0x01, 0x3e, 0xa4, 0xdd,
+	0xc6, 0x86, 0xb3, 0xd9, 0xf4, 0x73, 0x1a, 0x7d, 0x08, 0x4b, 0x47, 0x85, 0x93, 0x77, 0x9b, 0x5c,
+	0x75, 0x57, 0x6f, 0xa3, 0x68, 0x19, 0xdf, 0x92, 0x47, 0xef, 0xc1, 0xda, 0x30, 0x78, 0xb1, 0x8b,
+	0x33, 0xfa, 0x2c, 0x18, 0xc4, 0x51, 0x4c, 0x27, 0x8f, 0x71, 0x98, 0x26, 0x11, 0xe9, 0xb6, 0x36,
+	0x9c, 0xcd, 0xba, 0x3f, 0x65, 0xd4, 0xfb, 0x83, 0x03, 0x8b, 0x85, 0x28, 0x3a, 0x67, 0x38, 0x7c,
+	0x00, 0x0b, 0x99, 0x0c, 0x5d, 0x3e, 0xbd, 0x76, 0xe6, 0xf4, 0x82, 0x3c, 0xba, 0x06, 0x1d, 0xa9,
+	0xee, 0x5e, 0xc4, 0xed, 0xdb, 0xf1, 0x35, 0xc3, 0x3b, 0x86, 0x15, 0x1d, 0xbd, 0xe7, 0x49, 0x10,
+	0x0f, 0x16, 0x02, 0x43, 0x09, 0x5f, 0x69, 0xc1, 0x2f, 0xf0, 0xbc, 0xbf, 0xd5, 0x44, 0xae, 0x30,
+	0xb3, 0x5d, 0x4c, 0xae, 0xdc, 0x85, 0xce, 0x09, 0xb3, 0xff, 0x8f, 0xb2, 0x74, 0x38, 0x83, 0x65,
+	0xb4, 0x30, 0xfa, 0x01, 0x00, 0x27, 0x9e, 0x26, 0x34, 0x1e, 0xcc, 0x10, 0x77, 0x86, 0xb4, 0x8c,
+	0xf5, 0x46, 0x1e, 0xeb, 0xd7, 0xa0, 0xa3, 0x82, 0x87, 0x74, 0x9b, 0x3c, 0x5b, 0x34, 0x83, 0x8d,
+	0x8e, 0xc6, 0xcf, 0x07, 0x71, 0x78, 0x1f, 0x4f, 0x78, 0xec, 0x2c, 0xf8, 0x9a, 0xc1, 0xec, 0xc6,
+	0x44, 0x95, 0x45, 0xbb, 0x73, 0xc2, 0x6e, 0x26, 0x0f, 0xad, 0x42, 0xf3, 0x18, 0x4f, 0xee, 0x45,
+	0xdd, 0xf6, 0x86, 0xb3, 0xb9, 0xe8, 0x0b, 0xc2, 0xfb, 0xab, 0x03, 0x2b, 0xda, 0x9a, 0xe7, 0xf1,
+	0x9d, 0x0b, 0xed, 0x23, 0xa9, 0x48, 0xfa, 0x2d, 0xa7, 0xd1, 0x16, 0x20, 0x9a, 0x8d, 0x09, 0xc5,
+	0xd1, 0x53, 0x82, 0x33, 0xb2, 0xdb, 0xe3, 0x52, 0xe2, 0xec, 0x15, 0x23, 0xac, 0x80, 0x44, 0x59,
+	0x3a, 0x1a, 0xe1, 0xe8, 0x63, 0xcb, 0x24, 0x25, 0xbe, 0xf7, 0xa5, 0x03, 0xcb, 0x6c, 0xee, 0x85,
+	0xd6, 0xce, 0x31, 0xc1, 0x99, 0xd1, 0x03, 0x72, 0x1a, 0xdd, 0x82, 0x26, 0x4d, 0x8f, 0x71, 0xc2,
+	0x37, 0x3f, 0xbf, 0xbd, 0xaa, 0xed, 0xf2, 0x29, 0x8b, 0xd2, 0x27, 0x6c, 0xcc, 0x17, 0x22, 0xde,
+	0x7f, 0x1d, 0x58, 0xd1, 0x3b, 0x3b, 0xa7, 0x6d, 0xa7, 0xee, 0x68, 0x0d, 0x5a, 0xec, 0x77, 0x9e,
+	0x97, 0x92, 0x62, 0xfe, 0xe6, 0xd1, 0xc6, 0x77, 0xda, 0xf6, 0x05, 0x51, 0xca, 0xb0, 0x66, 0x39,
+	0xc3, 0xd0, 0x87, 0xb0, 0x48, 0x30, 0x21, 0x71, 0x9a, 0xec, 0xb3, 0x32, 0x29, 0xe2, 0xed, 0x74,
+	0xfb, 0x15, 0x27, 0x78, 0x7f, 0x6f, 0x08, 0x9f, 0x5c, 0x5c, 0x8e, 0xea, 0x53, 0xd6, 0x0a, 0xa7,
+	0x34, 0x2d, 0x53, 0xb7, 0x2c, 0xf3, 0x0e, 0x2c, 0x65, 0x78, 0x98, 0x52, 0xfc, 0x54, 0x49, 0x34,
+	0xb8, 0x84, 0xc5, 0x2d, 0xe6, 0x56, 0xd3, 0xce, 0xad, 0x4d, 0x58, 0x0e, 0xc7, 0x59, 0x86, 0x13,
+	0xaa, 0x4e, 0x24, 0xf3, 0xcf, 0x66, 0x17, 0xeb, 0xc8, 0xdc, 0xab, 0xd7, 0x91, 0xf6, 0x4b, 0xd5,
+	0x91, 0x6d, 0x58, 0x65, 0xde, 0x4b, 0xb3, 0xf8, 0x73, 0x1c, 0x1d, 0xe8, 0xb6, 0xdc, 0xe1, 0xf9,
+	0x52, 0x39, 0x86, 0xbe, 0x05, 0x8b, 0x87, 0x69, 0x16, 0xe2, 0xdd, 0x74, 0x38, 0x0c, 0x58, 0x37,
+	0x02, 0x2e, 0x5c, 0x64, 0xb2, 0x93, 0x93, 0x74, 0x9c, 0x85, 0xb8, 0x17, 0x45, 0x19, 0x26, 0x04,
+	0x93, 0xee, 0x3c, 0x97, 0xb3, 0xd9, 0x0c, 0x10, 0xe0, 0x17, 0x14, 0x27, 0x2c, 0x04, 0x48, 0x77,
+	0x81, 0x0b, 0x19, 0x1c, 0x96, 0xff, 0x19, 0x26, 0x34, 0x8b, 0x43, 0xba, 0xaf, 0xe5, 0x16, 0x79,
+	0x60, 0x56, 0x8c, 0x30, 0xa0, 0x24, 0x03, 0xaa, 0xbb, 0xc4, 0x6d, 0xad, 0x48, 0xef, 0xf7, 0x0e,
+	0x80, 0xce, 0x34, 0xb4, 0x01, 0xf3, 0x41, 0x18, 0x62, 0x42, 0x38, 0x29, 0xa1, 0x88, 0xc9, 0x62,
+	0xce, 0xe5, 0xd9, 0xf8, 0x84, 0x01, 0x1d, 0x11, 0x3b, 0x9a, 0xc1, 0xd2, 0x21, 0xc3, 0x87, 0x19,
+	0x26, 0x42, 0x9f, 0x0c, 0xa1, 0x02, 0x0f, 0x6d, 0x43, 0x0b, 0x8b, 0x3c, 0x68, 0x9c, 0xe9, 0x18,
+	0x29, 0xe9, 0xfd, 0xb9, 0x06, 0x2b, 0x2a, 0x2e, 0x5e, 0x5f, 0xea, 0xcb, 0x31, 0xb3, 0xe4, 0x8e,
+	0x75, 0x30, 0x2e, 0xc9, 0xc2, 0xca, 0xcb, 0x7b, 0x8f, 0x74, 0x1b, 0x1c, 0x42, 0xad, 0x14, 0xb1,
+	0xcb, 0x6e, 0xcf, 0xb7, 0xe4, 0xd0, 0x77, 0xe0, 0x52, 0x3f, 0x0b, 0x12, 0x5a, 0x88, 0x26, 0x51,
+	0x7d, 0xcb, 0x03, 0x68, 0x1f, 0x56, 0x22, 0x9c, 0xc4, 0x05, 0xe1, 0x16, 0x5f, 0xe9, 0x0d, 0xbd,
+	0xd2, 0x5e, 0x51, 0xc2, 0x2f, 0x4d, 0xf1, 0x3e, 0x82, 0x65, 0x4b, 0x88, 0xa7, 0xa5, 0x22, 0xa4,
+	0x67, 0x35, 0x83, 0x15, 0x84, 0x0c, 0x07, 0x24, 0x4d, 0x54, 0x41, 0x10, 0x94, 0xf7, 0x47, 0x07,
+	0x5a, 0xe2, 0x24, 0xc5, 0x7c, 0x74, 0x5e, 0x3d, 0x1f, 0x6b, 0x2f, 0x95, 0x8f, 0x85, 0x6a, 0x52,
+	0xb7, 0xab, 0x89, 0xee, 0xfa, 0x0d, 0xd6, 0xf5, 0xf9, 0x76, 0x79, 0xa0, 0xfc, 0x7f, 0x6c, 0xf7,
+	0x19, 0xac, 0x1d, 0xf0, 0xc1, 0x27, 0x22, 0x66, 0x76, 0x7b, 0x17, 0x52, 0xde, 0xbd, 0xaf, 0x6a,
+	0x70, 0xb5, 0xa4, 0xf8, 0x3c, 0x69, 0x73, 0x0b, 0xe6, 0x8e, 0x64, 0xdc, 0xd7, 0xa6, 0xc4, 0xbd,
+	0x12, 0x60, 0xb2, 0xc2, 0x05, 0xa4, 0x5b, 0xb7, 0x65, 0xc5, 0x80, 0xaf, 0x04, 0x58, 0x5a, 0x65,
+	0xf8, 0x24, 0x3d, 0x9e, 0x21, 0xad, 0x8a, 0x72, 0xc6, 0x4c, 0xb5, 0x58, 0x73, 0xca, 0x62, 0x96,
+	0x9c, 0xe7, 0x03, 0xf2, 0x05, 0xe7, 0x3e, 0x9e, 0x90, 0x8b, 0x31, 0xf8, 0x09, 0x5c, 0x2e, 0xe8,
+	0x3c, 0xe7, 0xb5, 0xf6, 0x04, 0x67, 0xbc, 0x5a, 0xd7, 0x78, 0xa4, 0x28, 0x92, 0x5d, 0x26, 0x8f,
+	0xb3, 0x81, 0x0c, 0x2b, 0xf6, 0xd3, 0xfb, 0x77, 0x0d, 0x3a, 0xfb, 0x03, 0x7c, 0x12, 0x50, 0x36,
+	0xae, 0xef, 0x7b, 0x1d, 0x8e, 0x81, 0xa7, 0x75, 0xf9, 0xe2, 0x85, 0xb3, 0x2e, 0xfa, 0xcb, 0xa8,
+	0xd0, 0xcf, 0x7e, 0x31, 0x26, 0x34, 0x3e, 0x8c, 0x43, 0xae, 0x58, 0x36, 0xfa, 0x22, 0x93, 0xf5,
+	0xb3, 0x68, 0x9c, 0xf1, 0xdf, 0xea, 0x16, 0xd6, 0xe4, 0xb7, 0x30, 0x9b, 0xcd, 0xb0, 0x13, 0xa1,
+	0x01, 0xc5, 0xbc, 0xd3, 0x77, 0x7c, 0x41, 0x18, 0x16, 0xc7, 0x51, 0x8f, 0xce, 0xd0, 0xe1, 0x4d,
+	0x71, 0x96, 0x68, 0x11, 0x0e, 0xe3, 0x08, 0x47, 0x3b, 0x13, 0xde, 0xe2, 0x3b, 0xbe, 0x66, 0x18,
+	0xe5, 0xac, 0x63, 0x96, 0xb3, 0xe2, 0xc5, 0x16, 0x5e, 0xe2, 0x62, 0xeb, 0xfd, 0xc3, 0x81, 0x95,
+	0xdc, 0xd2, 0x17, 0x03, 0xc2, 0x8c, 0xb6, 0x5c, 0x2b, 0xb4, 0xe5, 0xaf, 0xdb, 0x41, 0xde, 0x9f,
+	0x1c, 0xb8, 0x94, 0x1f, 0x6e, 0x0f, 0x87, 0x31, 0xdf, 0xc5, 0xeb, 0x3a, 0x9d, 0x08, 0xd3, 0x7a,
+	0x1e, 0xa6, 0x5d, 0x98, 0x0b, 0x46, 0xa3, 0x2c, 0x3d, 0xc1, 0x12, 0x5c, 0x2b, 0xd2, 0x70, 0x63,
+	0xb3, 0xd0, 0x95, 0xbe, 0x30, 0xb6, 0x7b, 0xbe, 0x64, 0xfb, 0x2e, 0x74, 0xb0, 0xd2, 0x24, 0x4b,
+	0xfd, 0x65, 0xe3, 0x55, 0x24, 0x5f, 0x44, 0x4b, 0x79, 0x09, 0xac, 0xe6, 0xfc, 0x07, 0x31, 0xa1,
+	0xaf, 0x39, 0x18, 0xbc, 0x5f, 0x3b, 0x70, 0xc5, 0x5a, 0xf0, 0x3c, 0x27, 0xbe, 0x03, 0x90, 0x9f,
+	0x45, 0x55, 0xf3, 0xca, 0x23, 0x1b, 0x62, 0xde, 0x57, 0x75, 0x80, 0x7b, 0x84, 0x8c, 0x71, 0xc4,
+	0xd1, 0xd0, 0x1a, 0xb4, 0x08, 0xce, 0x62, 0x09, 0x24, 0x1a, 0xbe, 0xa4, 0x18, 0x82, 0x0a, 0x71,
+	0x46, 0x0d, 0x70, 0x98, 0xd3, 0xfa, 0xc2, 0x2c, 0x1c, 0x2f, 0x08, 0x86, 0x38, 0x0f, 0xe3, 0xa4,
+	0x8f, 0x33, 0x16, 0xdd, 0x54, 0xc6, 0xb1, 0xc9, 0xb2, 0x72, 0xa1, 0x59, 0xca, 0x85, 0x42, 0x9f,
+	0x6f, 0xbd, 0x7a, 0x9f, 0x9f, 0x7b, 0xa9, 0x3e, 0xff, 0x1e, 0xb4, 0x63, 0x6e, 0x8f, 0x1e, 0x9d,
+	0xe1, 0x82, 0x91, 0xcb, 0x1a, 0x2f, 0x3f, 0x38, 0x93, 0xb5, 0x49, 0x33, 0xd8, 0x59, 0x23, 0x3c,
+	0x1a, 0xa4, 0x93, 0x21, 0x4e, 0x44, 0x7d, 0xea, 0xf8, 0x06, 0x87, 0xd9, 0x10, 0x0f, 0x83, 0x78,
+	0xd0, 0x9d, 0x17, 0x36, 0xe4, 0x84, 0xf7, 0xaf, 0x3a, 0x2c, 0x33, 0xb7, 0x7c, 0x0d, 0xc1, 0x58,
+	0xf0, 0x70, 0xbd, 0xec, 0x61, 0xb1, 0xbb, 0x86, 0xb1, 0x3b, 0xf5, 0x90, 0xc1, 0x11, 0xb7, 0xc8,
+	0xe2, 0x9c, 0xb6, 0xce, 0xdb, 0x2a, 0x9d, 0xb7, 0x80, 0x59, 0xe7, 0x2a, 0x30, 0xab, 0x8c, 0xc2,
+	0x76, 0x21, 0x0a, 0x3f, 0x80, 0x05, 0x55, 0xb7, 0x0f, 0x95, 0x99, 0xcf, 0x78, 0x9d, 0x33, 0xe5,
+	0xd9, 0x85, 0x5d, 0xd2, 0x3b, 0xf8, 0x30, 0xcd, 0xf0, 0x0c, 0x8d, 0xa2, 0x38, 0x81, 0xed, 0x6c,
+	0x10, 0x50, 0x4c, 0x28, 0x77, 0x54, 0xdb, 0x97, 0x14, 0xb3, 0xc5, 0x28, 0xe8, 0xe3, 0xc7, 0xf1,
+	0xe7, 0xb8, 0xbb, 0x20, 0x5e, 0x47, 0x15, 0xcd, 0xcf, 0x1a, 0xf4, 0xb1, 0xb8, 0x38, 0x2d, 0xca,
+	0xb3, 0x2a, 0x86, 0xf7, 0x3b, 0x07, 0x56, 0xb4, 0x8f, 0xcf, 0x07, 0xe5, 0x9a, 0xcc, 0x63, 0x2a,
+	0xf5, 0x8d, 0x27, 0x17, 0x9d, 0xe0, 0xbe, 0x10, 0x61, 0x7d, 0x26, 0xc1, 0x2f, 0xe8, 0x41, 0xbe,
+	0x2f, 0xe1, 0xf2, 0x22, 0xd3, 0x4b, 0x00, 0xf5, 0xa2, 0x61, 0x9c, 0x08, 0x04, 0xa4, 0x22, 0xb0,
+	0xea, 0x35, 0x5c, 0x3c, 0xa2, 0x8c, 0x55, 0x71, 0x10, 0x04, 0xb3, 0x56, 0x18, 0x18, 0x11, 0x25,
+	0x29, 0xa3, 0xfa, 0x37, 0x0a, 0xd5, 0xff, 0x36, 0x5c, 0x2e, 0xac, 0x27, 0xad, 0x61, 0xe0, 0x26,
+	0xa7, 0x80, 0x9b, 0xbc, 0x7f, 0xd6, 0xa0, 0xc3, 0x67, 0x30, 0x08, 0xa8, 0xc3, 0xd4, 0x31, 0xc3,
+	0x74, 0x55, 0x9b, 0x85, 0xbf, 0xe7, 0x71, 0x82, 0x85, 0xd2, 0x20, 0x20, 0xf4, 0x9e, 0x4a, 0xf5,
+	0xb3, 0xdf, 0x24, 0x0b, 0xf2, 0x68, 0x07, 0x96, 0x18, 0xfd, 0x4c, 0x97, 0x99, 0xb3, 0x2f, 0xbd,
+	0xd6, 0x0c, 0x16, 0x34, 0x51, 0x4c, 0x82, 0xe7, 0x03, 0x1c, 0xf1, 0x04, 0x6a, 0xfb, 0x39, 0xcd,
+	0xde, 0x64, 0xd4, 0x6f, 0x5f, 0x98, 0x4a, 0x24, 0x91, 0xc5, 0xe5, 0x89, 0x26, 0x39, 0x3b, 0x13,
+	0x99, 0x49, 0x06, 0x87, 0x95, 0x42, 0x45, 0xcd, 0x54, 0xd0, 0x0c, 0x69, 0xef, 0x2a, 0x5c, 0xe1,
+	0xc6, 0x65, 0xa1, 0xc9, 0x0c, 0xac, 0x20, 0xb5, 0xb7, 0x0b, 0x6b, 0xf6, 0x80, 0x74, 0xd5, 0xb7,
+	0xa1, 0xc9, 0xa0, 0x28, 0xfb, 0xd8, 0x61, 0xb5, 0x9f, 0xdc, 0x4d, 0xbe, 0x90, 0xf0, 0x3e, 0x83,
+	0xab, 0x9c, 0xb7, 0x27, 0x16, 0xe4, 0x43, 0x32, 0xc2, 0xaa, 0x1d, 0x39, 0xe5, 0x26, 0x2b, 0xf8,
+	0x2c, 0x60, 0xb8, 0x13, 0xdb, 0xbe, 0xa4, 0xbc, 0x2d, 0xb9, 0xcb, 0xfd, 0x64, 0x26, 0xfd, 0xde,
+	0x33, 0xb8, 0xa4, 0x37, 0xa9, 0x0e, 0x74, 0x13, 0x1a, 0x6c, 0xbb, 0x32, 0x0b, 0x2b, 0xcf, 0xc3,
+	0x05, 0x58, 0x90, 0x8a, 0x75, 0x23, 0x9e, 0x7f, 0x0d, 0x5f, 0x91, 0xde, 0x17, 0xb0, 0xcc, 0x85,
+	0x0f, 0x1e, 0xdf, 0x7f, 0x26, 0xf1, 0xbe, 0x15, 0xd1, 0x8b, 0xfa, 0x26, 0x90, 0x23, 0xea, 0x9a,
+	0x89, 0xa8, 0xef, 0x42, 0x27, 0xcc, 0x70, 0x40, 0x67, 0x0c, 0x55, 0x2d, 0xec, 0xfd, 0xc5, 0x91,
+	0xab, 0xef, 0xe9, 0xe2, 0xab, 0xbe, 0xdb, 0x39, 0xfa, 0xbb, 0x9d, 0x6e, 0xe2, 0x35, 0xe3, 0xd5,
+	0x9b, 0x3d, 0xfb, 0xf0, 0x1f, 0x72, 0xdf, 0x7c, 0xe9, 0xa6, 0x5f, 0xe0, 0xb1, 0x46, 0x3f, 0xc0,
+	0xfd, 0x20, 0x9c, 0xdc, 0xe7, 0xf3, 0x1b, 0x7c, 0xbe, 0xc9, 0x42, 0xef, 0x42, 0x63, 0x44, 0x8e,
+	0xd5, 0x3d, 0xee, 0x0d, 0xcb, 0x86, 0xda, 0x2c, 0x3e, 0x17, 0xf3, 0xd6, 0xe1, 0xcd, 0x3c, 0xba,
+	0xf4, 0xae, 0xf3, 0xe0, 0xfb, 0x29, 0x5c, 0xab, 0x1e, 0x96, 0x1e, 0x7b, 0x1f, 0xe6, 0x75, 0xa3,
+	0x51, 0x81, 0x68, 0x2f, 0xaa, 0x27, 0xfa, 0xa6, 0xb4, 0xf7, 0x09, 0x5c, 0x97, 0x15, 0xa8, 0x1f,
+	0x13, 0x8a, 0x33, 0x43, 0x4e, 0x57, 0xbf, 0xd9, 0x8c, 0xe7, 0x3d, 0x94, 0xe9, 0xe3, 0xa7, 0xcc,
+	0x87, 0x07, 0x8f, 0xef, 0x9f, 0xa6, 0xc2, 0xb2, 0x62, 0xad, 0x64, 0x45, 0xaf, 0xaf, 0xd4, 0x61,
+	0x1a, 0x67, 0xe7, 0x56, 0x67, 0x86, 0x60, 0xbd, 0x10, 0x82, 0xde, 0x21, 0x74, 0xf9, 0x42, 0x0f,
+	0xe3, 0x7e, 0x16, 0x50, 0xcc, 0xc4, 0xf7, 0xce, 0xb7, 0x56, 0x01, 0x21, 0xe6, 0xf6, 0xb9, 0x03,
+	0xeb, 0xc6, 0x81, 0x1e, 0xe4, 0xf2, 0xa7, 0x2d, 0xe6, 0xfd, 0xca, 0x51, 0x65, 0xc3, 0xf0, 0x8c,
+	0xf4, 0xfc, 0xf7, 0x0b, 0xa0, 0x43, 0x64, 0xec, 0x29, 0x8e, 0x37, 0x84, 0xf9, 0x05, 0x1c, 0x4f,
+	0x24, 0x26, 0x62, 0x3f, 0x4f, 0xb1, 0xcf, 0x6a, 0xde, 0x15, 0x07, 0x69, 0x10, 0xa9, 0xb0, 0xbc,
+	0x02, 0x97, 0x0b, 0x5c, 0xf9, 0x15, 0xee, 0xcb, 0x1a, 0xcc, 0x71, 0xfe, 0x6e, 0xaf, 0xb2, 0x71,
+	0x8a, 0x2b, 0x53, 0x4d, 0x3d, 0x1c, 0x15, 0x41, 0x6f, 0xfd, 0xd5, 0x41, 0x6f, 0xe3, 0xd5, 0x1f,
+	0xb7, 0x4a, 0x2f, 0xfb, 0x16, 0x94, 0x6f, 0x95, 0xa1, 0x3c, 0x83, 0x95, 0x71, 0x3f, 0x89, 0x93,
+	0x3e, 0x6f, 0x41, 0x6d, 0x5f, 0x91, 0x6c, 0x44, 0x20, 0x28, 0xf1, 0x3d, 0xad, 0xed, 0x2b, 0x32,
+	0x37, 0x18, 0xcb, 0xe3, 0xdd, 0x5e, 0x9e, 0xde, 0xef, 0xc3, 0x6a, 0x91, 0x2d, 0x9d, 0xfb, 0x4d,
+	0xa8, 0x87, 0x81, 0x4a, 0xe7, 0x4b, 0x96, 0x57, 0x77, 0x7b, 0x3e, 0x1b, 0xdd, 0xfe, 0x4f, 0x13,
+	0x1a, 0xfc, 0x1e, 0xb3, 0x6b, 0x7c, 0x2c, 0x7f, 0xa3, 0xf8, 0xe4, 0x64, 0x7c, 0xff, 0x72, 0xdd,
+	0xaa, 0x21, 0xe9, 0xb9, 0x6f, 0x28, 0x25, 0x5c, 0xa1, 0xa5, 0xc4, 0xf8, 0x60, 0xe3, 0xba, 0x55,
+	0x43, 0xa6, 0x12, 0xf5, 0x6d, 0xcb, 0x54, 0x62, 0x7d, 0x89, 0x73, 0xdd, 0xaa, 0x21, 0x5b, 0x89,
+	0xbd, 0x13, 0xeb, 0xd3, 0x91, 0xeb, 0x56, 0x0d, 0xe5, 0x4a, 0x7e, 0x02, 0xcb, 0xd6, 0xd3, 0x21,
+	0xda, 0xd0, 0x13, 0xaa, 0x9f, 0x2b, 0xdd, 0x1b, 0xa7, 0x48, 0xe4, 0x9a, 0x1f, 0xc0, 0xbc, 0xf1,
+	0x48, 0x86, 0xae, 0x99, 0x58, 0xd5, 0x7e, 0x8f, 0x73, 0xd7, 0xa7, 0x8c, 0xe6, 0xda, 0x1e, 0xc2,
+	0x8a, 0x94, 0xd5, 0x0f, 0x60, 0x6e, 0xd5, 0x3d, 0x56, 0x2a, 0x7c, 0xb3, 0x72, 0xcc, 0x50, 0xb7,
+	0xbc, 0xc7, 0x9f, 0x8f, 0xb4, 0xb6, 0xaa, 0x19, 0xea, 0x71, 0xe4, 0x2c, 0x75, 0x8f, 0x61, 0x89,
+	0x85, 0x66, 0x3e, 0x44, 0xd0, 0xf5, 0x8a, 0x09, 0xc6, 0x8d, 0xcd, 0x7d, 0x6b, 0xea, 0x78, 0xae,
+	0xf4, 0x7b, 0xd0, 0x60, 0xff, 0x9a, 0x41, 0x57, 0x0c, 0x6b, 0xeb, 0xbf, 0xe9, 0xb8, 0x6b, 0x36,
+	0x5b, 0x4d, 0xdc, 0xfe, 0x4d, 0x1b, 0x9a, 0x3c, 0x03, 0xd0, 0x47, 0xd0, 0x12, 0xe6, 0x34, 0xcd,
+	0x5f, 0x46, 0xef, 0xee, 0xfa, 0x94, 0xd1, 0x7c, 0x2f, 0x07, 0xd0, 0xc9, 0x71, 0x1d, 0x7a, 0xcb,
+	0x92, 0xb6, 0xa1, 0xa0, 0xbb, 0x31, 0x5d, 0x20, 0xd7, 0xf8, 0x29, 0xcc, 0x1b, 0x20, 0x0f, 0xdd,
+	0xb0, 0x4b, 0x72, 0x09, 0x00, 0xba, 0x6f, 0x5a, 0x22, 0x26, 0x24, 0xe3, 0x2e, 0x05, 0x0d, 0xea,
+	0x90, 0xbd, 0x85, 0xfd, 0xe4, 0x25, 0xd5, 0x3d, 0x87, 0x65, 0x0b, 0x4c, 0xa0, 0xb7, 0x2b, 0x8e,
+	0x55, 0xc6, 0x22, 0xee, 0x3b, 0x67, 0x89, 0xe5, 0x6b, 0x84, 0x80, 0xca, 0x98, 0x02, 0x6d, 0x96,
+	0x9c, 0x31, 0x05, 0x76, 0xb8, 0x37, 0xa6, 0xf7, 0x31, 0xbd, 0xc8, 0x23, 0xe8, 0xe4, 0x60, 0xa3,
+	0xe4, 0x3a, 0x1b, 0x86, 0xcc, 0xae, 0x52, 0x01, 0x8e, 0xb2, 0x4a, 0x0b, 0x8a, 0xcc, 0xa6, 0xf2,
+	0xc7, 0xb0, 0x60, 0x42, 0x0b, 0xe4, 0x59, 0x93, 0x2a, 0x70, 0xc7, 0x6c, 0x8a, 0x03, 0xb8, 0x54,
+	0xc2, 0x12, 0xe8, 0x66, 0xe5, 0x9e, 0xcb, 0x68, 0x63, 0xb6, 0x25, 0x78, 0x96, 0xb1, 0x06, 0x5f,
+	0x91, 0x65, 0x06, 0x1a, 0x70, 0xd7, 0xa7, 0x8c, 0xe6, 0x8a, 0x3e, 0x81, 0x39, 0xd9, 0xe1, 0xd0,
+	0x7a, 0x45, 0x10, 0xe9, 0x86, 0xe8, 0x5e, 0x9f, 0x36, 0x9c, 0x17, 0x81, 0x47, 0xd0, 0x61, 0xa5,
+	0xfe, 0xd1, 0x18, 0x67, 0x13, 0xb4, 0x27, 0xd2, 0x77, 0x97, 0xdf, 0x72, 0x8d, 0x5e, 0x61, 0xbd,
+	0x23, 0xb9, 0x6e, 0xd5, 0x90, 0x52, 0xb9, 0xf3, 0x36, 0x74, 0xc3, 0x74, 0xb8, 0x35, 0x8c, 0x09,
+	0xdd, 0x0a, 0xc2, 0x30, 0xcd, 0xa2, 0x5c, 0x7c, 0x67, 0xae, 0x17, 0x72, 0xce, 0x81, 0xf3, 0xbc,
+	0xc5, 0x99, 0x77, 0xfe, 0x37, 0x00, 0x3c, 0x46, 0x43, 0xf9, 0x92, 0x28, 0x00, 0x00,
 }
@@ -7,3 +7,20 @@ package accord
 type PSKStore interface {
 	GetPSK([]byte) ([]byte, error)
 }
+
+// VersionedPSKStore keeps several versions of the PSK for each key id so
+// they can be rotated without every host having to change at once. GetPSK
+// takes the 4 byte key id for the active version, or the key id followed by
+// the 4 byte version for that version, as long as it's still accepted
+type VersionedPSKStore interface {
+	PSKStore
+	// AcceptedVersions are the versions that can still be decrypted with, the active one first
+	AcceptedVersions(keyId uint32) []uint32
+}
+
+// LegacyKeyIDStore is implemented by the stores that know which deployments
+// still use the MD5 key ids
+type LegacyKeyIDStore interface {
+	// LegacyKeyID returns the deployment and its current key id if keyId is an MD5 one
+	LegacyKeyID(keyId uint32) (string, uint32, bool)
+}
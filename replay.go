@@ -0,0 +1,71 @@
+package accord
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrClockSkew       = errors.New("The request time is too far from the server time")
+	ErrReplay          = errors.New("The request has been seen before")
+	ErrReplayCacheFull = errors.New("Too many requests for the key, try again later")
+)
+
+const (
+	DefaultMaxClockSkew = 2 * time.Minute
+	// per key, a deployment would need this many hosts authenticating
+	// within the skew window to run into it
+	DefaultReplayCacheSize = 10000
+)
+
+// ReplayCache remembers the nonces of the requests for each key for as long as
+// the requests would be accepted because of their timestamp. Anything older
+// is rejected for the timestamp, so that's all that needs to be remembered
+type ReplayCache struct {
+	mu        sync.Mutex
+	maxSkew   time.Duration
+	maxPerKey int
+	// key id -> nonce -> request time
+	seen map[uint32]map[string]time.Time
+}
+
+func NewReplayCache(maxSkew time.Duration, maxPerKey int) *ReplayCache {
+	return &ReplayCache{
+		maxSkew:   maxSkew,
+		maxPerKey: maxPerKey,
+		seen:      make(map[uint32]map[string]time.Time),
+	}
+}
+
+// Check accepts a request only once, and only if it was made within the skew
+// window around now. When the cache for the key is full the request is
+// rejected instead of forgetting a nonce that could then be replayed
+func (r *ReplayCache) Check(keyId uint32, nonce []byte, timestamp time.Time, now time.Time) error {
+	if timestamp.Before(now.Add(-r.maxSkew)) || timestamp.After(now.Add(r.maxSkew)) {
+		return ErrClockSkew
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	nonces, ok := r.seen[keyId]
+	if !ok {
+		nonces = make(map[string]time.Time)
+		r.seen[keyId] = nonces
+	}
+	if _, ok := nonces[string(nonce)]; ok {
+		return ErrReplay
+	}
+	if len(nonces) >= r.maxPerKey {
+		for n, t := range nonces {
+			if t.Before(now.Add(-r.maxSkew)) {
+				delete(nonces, n)
+			}
+		}
+		if len(nonces) >= r.maxPerKey {
+			return ErrReplayCacheFull
+		}
+	}
+	nonces[string(nonce)] = timestamp
+	return nil
+}
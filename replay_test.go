@@ -0,0 +1,89 @@
+package accord
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReplayCache_Check(t *testing.T) {
+	now := time.Now()
+	cache := NewReplayCache(time.Minute, 2)
+
+	tests := []struct {
+		name      string
+		keyId     uint32
+		nonce     string
+		timestamp time.Time
+		now       time.Time
+		wantErr   error
+	}{
+		{
+			name:      "first request",
+			keyId:     1,
+			nonce:     "nonce-1",
+			timestamp: now,
+			now:       now,
+		},
+		{
+			name:      "replayed request",
+			keyId:     1,
+			nonce:     "nonce-1",
+			timestamp: now,
+			now:       now.Add(time.Second),
+			wantErr:   ErrReplay,
+		},
+		{
+			name:      "same nonce for another key",
+			keyId:     2,
+			nonce:     "nonce-1",
+			timestamp: now,
+			now:       now,
+		},
+		{
+			name:      "too old",
+			keyId:     1,
+			nonce:     "nonce-2",
+			timestamp: now.Add(-2 * time.Minute),
+			now:       now,
+			wantErr:   ErrClockSkew,
+		},
+		{
+			name:      "too far in the future",
+			keyId:     1,
+			nonce:     "nonce-2",
+			timestamp: now.Add(2 * time.Minute),
+			now:       now,
+			wantErr:   ErrClockSkew,
+		},
+		{
+			name:      "second request fills the cache for the key",
+			keyId:     1,
+			nonce:     "nonce-2",
+			timestamp: now,
+			now:       now,
+		},
+		{
+			name:      "full cache rejects instead of forgetting",
+			keyId:     1,
+			nonce:     "nonce-3",
+			timestamp: now,
+			now:       now,
+			wantErr:   ErrReplayCacheFull,
+		},
+		{
+			name:      "old nonces are forgotten once they'd fail the skew check",
+			keyId:     1,
+			nonce:     "nonce-3",
+			timestamp: now.Add(90 * time.Second),
+			now:       now.Add(90 * time.Second),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := cache.Check(tt.keyId, []byte(tt.nonce), tt.timestamp, tt.now)
+			if err != tt.wantErr {
+				t.Errorf("ReplayCache.Check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package accord
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrNoSession      = errors.New("No session, authenticate with UserAuth first")
+	ErrInvalidSession = errors.New("The session isn't valid")
+	ErrSessionExpired = errors.New("The session has expired, authenticate again")
+)
+
+// DefaultSessionTTL is long enough to request the certs right after authenticating
+const DefaultSessionTTL = 10 * time.Minute
+
+// UserSession is handed to the user once the server has validated their
+// OAuth token, the cert requests use it to prove who the user is
+type UserSession struct {
+	Email     string    `json:"email"`
+	Username  string    `json:"username"`
+	IssuedAt  time.Time `json:"issued_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// SessionSigner issues and verifies the sessions. They're the JSON of the
+// UserSession and an HMAC-SHA256 over it, both base64 encoded and joined with
+// a '.'. Nothing is stored on the server, so the sessions are only valid on
+// servers with the same key
+type SessionSigner struct {
+	key []byte
+	ttl time.Duration
+	// only replaced in tests
+	now func() time.Time
+}
+
+// NewSessionSigner uses key for the HMAC, if it's empty a random key is
+// generated, which means the sessions don't survive a restart
+func NewSessionSigner(key []byte, ttl time.Duration) (*SessionSigner, error) {
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, errors.Wrapf(err, "Failed to generate the session key")
+		}
+	}
+	if ttl <= 0 {
+		ttl = DefaultSessionTTL
+	}
+	return &SessionSigner{key: key, ttl: ttl}, nil
+}
+
+func (s *SessionSigner) currentTime() time.Time {
+	if s.now == nil {
+		return time.Now()
+	}
+	return s.now()
+}
+
+func (s *SessionSigner) mac(payload []byte) []byte {
+	h := hmac.New(sha256.New, s.key)
+	h.Write(payload)
+	return h.Sum(nil)
+}
+
+// Issue returns the signed session for the user
+func (s *SessionSigner) Issue(email, username string) ([]byte, *UserSession, error) {
+	if email == "" {
+		return nil, nil, errors.New("Cannot issue a session without an email")
+	}
+	now := s.currentTime()
+	session := &UserSession{
+		Email:     email,
+		Username:  username,
+		IssuedAt:  now,
+		ExpiresAt: now.Add(s.ttl),
+	}
+	payload, err := json.Marshal(session)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "Failed to marshal the session")
+	}
+	b := &bytes.Buffer{}
+	b.WriteString(base64.RawURLEncoding.EncodeToString(payload))
+	b.WriteByte('.')
+	b.WriteString(base64.RawURLEncoding.EncodeToString(s.mac(payload)))
+	return b.Bytes(), session, nil
+}
+
+// Verify checks the signature and expiry and returns the session
+func (s *SessionSigner) Verify(token []byte) (*UserSession, error) {
+	if len(token) == 0 {
+		return nil, ErrNoSession
+	}
+	parts := bytes.Split(token, []byte{'.'})
+	if len(parts) != 2 {
+		return nil, ErrInvalidSession
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(string(parts[0]))
+	if err != nil {
+		return nil, ErrInvalidSession
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(string(parts[1]))
+	if err != nil {
+		return nil, ErrInvalidSession
+	}
+	if !hmac.Equal(mac, s.mac(payload)) {
+		return nil, ErrInvalidSession
+	}
+	session := &UserSession{}
+	if err := json.Unmarshal(payload, session); err != nil {
+		return nil, ErrInvalidSession
+	}
+	if !s.currentTime().Before(session.ExpiresAt) {
+		return nil, ErrSessionExpired
+	}
+	return session, nil
+}
@@ -0,0 +1,89 @@
+package accord
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestSessionSigner_Verify(t *testing.T) {
+	signer, err := NewSessionSigner([]byte("test session key"), time.Minute)
+	if err != nil {
+		t.Fatalf("NewSessionSigner() error = %v", err)
+	}
+	other, err := NewSessionSigner(nil, time.Minute)
+	if err != nil {
+		t.Fatalf("NewSessionSigner() error = %v", err)
+	}
+	token, _, err := signer.Issue("user1@ex.ample.com", "user1")
+	if err != nil {
+		t.Fatalf("SessionSigner.Issue() error = %v", err)
+	}
+	otherToken, _, err := other.Issue("user1@ex.ample.com", "user1")
+	if err != nil {
+		t.Fatalf("SessionSigner.Issue() error = %v", err)
+	}
+	// swap the payload for another user's but keep the original signature
+	forged, _, err := signer.Issue("user2@ex.ample.com", "user2")
+	if err != nil {
+		t.Fatalf("SessionSigner.Issue() error = %v", err)
+	}
+	forged = append(forged[:bytes.IndexByte(forged, '.')], token[bytes.IndexByte(token, '.'):]...)
+
+	tests := []struct {
+		name    string
+		token   []byte
+		now     time.Time
+		want    string
+		wantErr error
+	}{
+		{
+			name:  "valid session",
+			token: token,
+			now:   time.Now(),
+			want:  "user1@ex.ample.com",
+		},
+		{
+			name:    "no session",
+			now:     time.Now(),
+			wantErr: ErrNoSession,
+		},
+		{
+			name:    "expired session",
+			token:   token,
+			now:     time.Now().Add(2 * time.Minute),
+			wantErr: ErrSessionExpired,
+		},
+		{
+			name:    "signed with another key",
+			token:   otherToken,
+			now:     time.Now(),
+			wantErr: ErrInvalidSession,
+		},
+		{
+			name:    "payload swapped",
+			token:   forged,
+			now:     time.Now(),
+			wantErr: ErrInvalidSession,
+		},
+		{
+			name:    "garbage",
+			token:   []byte("user1@ex.ample.com"),
+			now:     time.Now(),
+			wantErr: ErrInvalidSession,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer.now = func() time.Time { return tt.now }
+			got, err := signer.Verify(tt.token)
+			if err != tt.wantErr {
+				t.Errorf("SessionSigner.Verify() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil && got.Email != tt.want {
+				t.Errorf("SessionSigner.Verify() email = %s, want %s", got.Email, tt.want)
+			}
+		})
+	}
+}
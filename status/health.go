@@ -0,0 +1,62 @@
+package status
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Check is the last result of one of the readiness checks
+type Check struct {
+	Ready bool   `json:"ready"`
+	Error string `json:"error,omitempty"`
+	// what doesn't make the server unready yet, but will
+	Warning string    `json:"warning,omitempty"`
+	Checked time.Time `json:"checked"`
+}
+
+var checksMu sync.Mutex
+var checks = map[string]Check{}
+
+// SetCheck records how a readiness check went, the server is ready once
+// there are checks and all of them are
+func SetCheck(name string, check Check) {
+	checksMu.Lock()
+	defer checksMu.Unlock()
+	checks[name] = check
+}
+
+// Ready is whether all the checks are, and the checks
+func Ready() (bool, map[string]Check) {
+	checksMu.Lock()
+	defer checksMu.Unlock()
+	ready := len(checks) > 0
+	copied := map[string]Check{}
+	for name, check := range checks {
+		ready = ready && check.Ready
+		copied[name] = check
+	}
+	return ready, copied
+}
+
+// HandleHealthz is the liveness check, answering is all it takes
+func HandleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	fmt.Fprintln(w, "ok")
+}
+
+// HandleReadyz is 503 until the server can take requests, with the checks
+// in the body either way
+func HandleReadyz(w http.ResponseWriter, r *http.Request) {
+	ready, checks := Ready()
+	w.Header().Set("Content-Type", "application/json")
+	if !ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(struct {
+		Ready  bool             `json:"ready"`
+		Checks map[string]Check `json:"checks"`
+	}{ready, checks})
+}
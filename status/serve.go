@@ -9,6 +9,7 @@ import (
 
 	"github.com/mistsys/mist_go_utils/cloud"
 	"github.com/mistsys/mist_go_utils/pprofutil"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 var defaultHttpPort = 9110
@@ -56,6 +57,9 @@ func ServePort(port int) Mux {
 	addr := ":" + strconv.Itoa(port)
 	mux := http.NewServeMux()
 	mux.HandleFunc("/about", HandleAbout)
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/healthz", HandleHealthz)
+	mux.HandleFunc("/readyz", HandleReadyz)
 
 	pprofutil.InitMux(mux)
 
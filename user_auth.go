@@ -14,6 +14,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"strconv"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -89,6 +90,12 @@ func tokenFromFile(file string) (*oauth2.Token, error) {
 // Classic paper:
 func (u *GoogleAuth) ValidateToken(ctx context.Context) (bool, string, error) {
 	log.Println("validating token")
+	// error is when Google couldn't be asked
+	outcome := "error"
+	start := time.Now()
+	defer func() {
+		tokenValidationDuration.WithLabelValues(outcome).Observe(time.Since(start).Seconds())
+	}()
 	var httpClient = &http.Client{}
 	oauth2Service, err := google_oauth.New(httpClient)
 	if err != nil {
@@ -96,17 +103,19 @@ func (u *GoogleAuth) ValidateToken(ctx context.Context) (bool, string, error) {
 	}
 	tokenInfoCall := oauth2Service.Tokeninfo()
 	tokenInfoCall.AccessToken(u.Token.AccessToken)
-	tokenInfo, err := tokenInfoCall.Do()
+	tokenInfo, err := tokenInfoCall.Context(ctx).Do()
 	if err != nil {
 		return false, "", err
 	}
 
+	outcome = "invalid"
 	if tokenInfo.IssuedTo != Unquote(u.ClientId) {
 		return false, "", fmt.Errorf("Token was generated by invalid clientID")
 	}
 	if !tokenInfo.VerifiedEmail {
 		return false, "", fmt.Errorf("Token was obtained with an invalid email")
 	}
+	outcome = "valid"
 	return true, tokenInfo.Email, nil
 }
 